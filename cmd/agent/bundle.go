@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"agent/config"
+	initialization "agent/initialization"
+	"agent/services/offline_bundle"
+	"agent/services/playwright_runtime"
+)
+
+// nkk: Offline bundles for lab machines without internet access
+//   agent bundle export --versions 1.47.2,1.48.0 --out ./dist
+//   agent bundle import --archive ./dist/agent-bundle-<version>-linux-amd64.tar.gz
+
+type BundleCmd struct {
+	Export BundleExportCmd `cmd:"" help:"Pack the workspace and Playwright runtimes into an offline bundle."`
+	Import BundleImportCmd `cmd:"" help:"Install an offline bundle on this machine."`
+}
+
+type BundleExportCmd struct {
+	Workspace     string   `help:"Execution workspace to pack." default:"executions"`
+	Runtimes      string   `help:"Playwright runtimes directory, defaults to playwright.runtimes_dir."`
+	Versions      []string `help:"Playwright versions to include, defaults to playwright.default_version."`
+	BundleVersion string   `help:"Version stamped into the bundle, defaults to the current UTC time." name:"bundle-version"`
+	Out           string   `help:"Output directory." default:"."`
+}
+
+func (c *BundleExportCmd) Run(g *Globals) error {
+	if _, err := loadConfig(g); err != nil {
+		return err
+	}
+	dynamicConfig := config.GetConfig()
+
+	runtimesDir := c.Runtimes
+	if runtimesDir == "" {
+		runtimesDir = dynamicConfig.Playwright.RuntimesDir
+	}
+	versions := c.Versions
+	if len(versions) == 0 {
+		versions = []string{dynamicConfig.Playwright.DefaultVersion}
+	}
+	bundleVersion := c.BundleVersion
+	if bundleVersion == "" {
+		bundleVersion = time.Now().UTC().Format("20060102-150405")
+	}
+
+	archive, manifest, err := offline_bundle.Export(context.Background(), offline_bundle.ExportOptions{
+		WorkspaceDir:       c.Workspace,
+		RuntimesDir:        runtimesDir,
+		PlaywrightVersions: versions,
+		BundleVersion:      bundleVersion,
+		OutputDir:          c.Out,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Exported %d files (playwright %s) to %s\n", len(manifest.Files), strings.Join(manifest.PlaywrightVersions, ", "), archive)
+	return nil
+}
+
+type BundleImportCmd struct {
+	Archive   string `help:"Bundle archive to install." required:"" type:"existingfile"`
+	Workspace string `help:"Execution workspace to install into." default:"executions"`
+	Runtimes  string `help:"Playwright runtimes directory, defaults to playwright.runtimes_dir."`
+}
+
+func (c *BundleImportCmd) Run(g *Globals) error {
+	if _, err := loadConfig(g); err != nil {
+		return err
+	}
+	dynamicConfig := config.GetConfig()
+
+	runtimesDir := c.Runtimes
+	if runtimesDir == "" {
+		runtimesDir = dynamicConfig.Playwright.RuntimesDir
+	}
+
+	manifest, err := offline_bundle.Import(context.Background(), c.Archive, offline_bundle.ImportOptions{
+		WorkspaceDir: c.Workspace,
+		RuntimesDir:  runtimesDir,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ Imported bundle %s (%d files)\n", manifest.BundleVersion, len(manifest.Files))
+
+	// nkk: Report missing browsers now rather than on the first execution
+	runtimes := playwright_runtime.NewManager(runtimesDir, dynamicConfig.Playwright.DefaultVersion)
+	runtimes.SetOffline(true)
+	return initialization.VerifyPlaywrightInstallation(runtimes, dynamicConfig.Playwright.RequiredBrowsers)
+}
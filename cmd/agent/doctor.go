@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"agent/config"
+	"agent/services/doctor"
+	"agent/services/playwright_runtime"
+)
+
+type DoctorCmd struct {
+	JSON          bool   `help:"Print the report as JSON." name:"json"`
+	Workspace     string `help:"Execution workspace directory checked for free disk space." default:"executions"`
+	MinFreeDiskMB uint64 `help:"Free disk space below which the disk check fails." name:"min-free-disk-mb" default:"2048"`
+}
+
+func (c *DoctorCmd) Run(g *Globals) error {
+	apxConfig, err := loadConfig(g)
+	if err != nil {
+		return err
+	}
+	dynamicConfig := config.GetConfig()
+	runtimes := playwright_runtime.NewManager(dynamicConfig.Playwright.RuntimesDir, dynamicConfig.Playwright.DefaultVersion)
+
+	opts := newDoctorOptions(apxConfig, runtimes, c.Workspace)
+	opts.MinFreeDiskMB = c.MinFreeDiskMB
+	report := doctor.NewDoctor(opts).Run(context.Background())
+
+	if c.JSON {
+		out, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else {
+		printReport(report)
+	}
+
+	if report.Failed() {
+		return fmt.Errorf("%d checks failed", countStatus(report, doctor.StatusFail))
+	}
+	return nil
+}
+
+// newDoctorOptions is the check catalog shared by `agent doctor` and GET /v1/doctor
+func newDoctorOptions(apxConfig *config.ApxConfig, runtimes *playwright_runtime.Manager, workspace string) doctor.Options {
+	dynamicConfig := config.GetConfig()
+	return doctor.Options{
+		Runtimes:          runtimes,
+		RequiredBrowsers:  dynamicConfig.Playwright.RequiredBrowsers,
+		DockerRequired:    dynamicConfig.DockerRunner.Enabled,
+		ExecutionsDir:     workspace,
+		MachineConfigPath: filepath.Join("configuration", "machine_config.json"),
+		Endpoints: []doctor.Endpoint{
+			{Name: "server_domain", URL: apxConfig.ServerDomain, ConfigKey: "server_domain"},
+			{Name: "execution_service_domain", URL: apxConfig.ExecutionServiceDomain, ConfigKey: "execution_service_domain"},
+		},
+	}
+}
+
+func countStatus(report *doctor.Report, status string) int {
+	count := 0
+	for _, result := range report.Checks {
+		if result.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+func printReport(report *doctor.Report) {
+	fmt.Printf("Agent %s (%s/%s)\n\n", report.Version, report.OS, report.Arch)
+
+	for _, result := range report.Checks {
+		icon := "✅"
+		switch result.Status {
+		case doctor.StatusWarn:
+			icon = "⚠️ "
+		case doctor.StatusFail:
+			icon = "❌"
+		}
+		fmt.Printf("%s %-26s %s\n", icon, result.Name, result.Message)
+		if result.Fix != "" {
+			fmt.Printf("   %-26s fix: %s\n", "", result.Fix)
+		}
+	}
+
+	fmt.Printf("\n%d ok, %d warnings, %d failed\n",
+		countStatus(report, doctor.StatusOK), countStatus(report, doctor.StatusWarn), countStatus(report, doctor.StatusFail))
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"github.com/alecthomas/kong"
+
+	"agent/config"
+	"agent/logger"
+)
+
+// nkk: Agent entrypoint
+//   agent serve                 register, start the HTTP server and the dispatch loop
+//   agent register              register this machine without serving
+//   agent status                report registration and whether a local agent is running
+//   agent run                   run a testcase or plan from local files, results go to disk
+//   agent doctor                diagnose the local environment (node, browsers, docker, network, disk)
+//   agent bundle export|import  pack or install an offline bundle for machines without internet access
+//   agent mock-server           serve in-memory stand-ins for the execution service and the autotest server
+//   agent version               print the build version
+// Config is layered: defaults -> --config-file -> AGENT_* env vars (see config.Load)
+
+// Globals are flags shared by every subcommand
+type Globals struct {
+	ConfigFile string `help:"Path to a YAML or JSON config file." type:"path" env:"AGENT_CONFIG_FILE" name:"config-file"`
+	LogLevel   string `help:"Overrides logger.level from the config." name:"log-level"`
+}
+
+type CLI struct {
+	Globals
+
+	Serve      ServeCmd      `cmd:"" help:"Register the machine and run the agent."`
+	Register   RegisterCmd   `cmd:"" help:"Register this machine with the server."`
+	Status     StatusCmd     `cmd:"" help:"Show registration and local agent status."`
+	Run        RunCmd        `cmd:"" help:"Run a testcase or test plan from local files without the dashboard."`
+	Doctor     DoctorCmd     `cmd:"" help:"Check the local environment and suggest fixes."`
+	Bundle     BundleCmd     `cmd:"" help:"Export or import offline bundles."`
+	MockServer MockServerCmd `cmd:"" name:"mock-server" help:"Serve an in-memory execution service and autotest server for offline development."`
+	Version    VersionCmd    `cmd:"" help:"Print the agent version."`
+}
+
+func main() {
+	cli := CLI{}
+	ctx := kong.Parse(&cli,
+		kong.Name("agent"),
+		kong.Description("Runs test cases and test plans on this machine."),
+		kong.UsageOnError(),
+	)
+	ctx.FatalIfErrorf(ctx.Run(&cli.Globals))
+}
+
+// loadConfig loads the layered config and initialises the logger
+func loadConfig(g *Globals) (*config.ApxConfig, error) {
+	apxConfig, err := config.Load(g.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	level := apxConfig.Logger.Level
+	if g.LogLevel != "" {
+		level = g.LogLevel
+	}
+	logger.InitLogger(level)
+
+	return apxConfig, nil
+}
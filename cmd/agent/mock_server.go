@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+
+	"agent/logger"
+	mockserver "agent/services/mock_server"
+)
+
+type MockServerCmd struct {
+	Listen   string `help:"Address to listen on." default:"localhost:8090"`
+	Fixtures string `help:"Directory with testscripts/, testplans/, execution-details/, environments/, integrations/ and local-executions/ fixtures." type:"path"`
+}
+
+// nkk: Does not load the agent config, the mock is usually started before the agent is configured to use it
+func (c *MockServerCmd) Run(g *Globals) error {
+	level := g.LogLevel
+	if level == "" {
+		level = "info"
+	}
+	logger.InitLogger(level)
+
+	mock, err := mockserver.NewServer(c.Fixtures)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Addr:              c.Listen,
+		Handler:           mock.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	logger.Info("mock execution and autotest service listening, point server_domain and execution_service_domain at it",
+		zap.String("listen", c.Listen),
+		zap.String("fixtures", c.Fixtures))
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
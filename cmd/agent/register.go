@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	initialization "agent/initialization"
+	autotestbridge "agent/services/autotest_bridge"
+)
+
+type RegisterCmd struct {
+	Background bool `help:"Do not open the dashboard after registering."`
+	TestMode   bool `help:"Only create the local machine config, skip server calls." name:"test-mode"`
+}
+
+func (c *RegisterCmd) Run(g *Globals) error {
+	apxConfig, err := loadConfig(g)
+	if err != nil {
+		return err
+	}
+
+	autotestBridge := autotestbridge.NewAutoTestBridgeService(apxConfig.ServerDomain)
+	machineId, err := initialization.EnsureRegistration(apxConfig, autotestBridge, c.Background, c.TestMode)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Registered machine %s\n", machineId)
+	return nil
+}
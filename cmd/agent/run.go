@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"agent/config"
+	apxhttp "agent/http"
+	"agent/http/handlers"
+	initialization "agent/initialization"
+	"agent/logger"
+	localexecution_model "agent/models/localexecution"
+	"agent/models/session"
+	"agent/models/testcase"
+	"agent/models/testplan"
+	"agent/services/allure"
+	"agent/services/doctor"
+	executionbridge "agent/services/execution_bridge"
+	"agent/services/executor"
+	"agent/services/playwright_runtime"
+	"agent/services/report"
+	sessionartifacts "agent/services/session_artifacts"
+	apxconstants "agent/utils/constants"
+)
+
+// nkk: Headless run for CI
+//   agent run --testcase script.json --config session.json
+//   agent run --plan plan.json
+// Fixtures still post to the agent's routes, so a local server backed by
+// executionbridge.LocalSink receives them and writes everything under --output.
+// Exit code is non-zero unless every testcase passed.
+
+type RunCmd struct {
+	Testcase    string `help:"testcase.TestScript JSON file to run." type:"existingfile" xor:"input" required:""`
+	Config      string `help:"session.Config JSON file used with --testcase." type:"existingfile"`
+	Plan        string `help:"testplan.TestPlanExecutionDetails JSON file to run." type:"existingfile" xor:"input" required:""`
+	Output      string `help:"Directory results are written to, one sub directory per execution." default:"results"`
+	Workspace   string `help:"Execution workspace directory." default:"executions"`
+	Listen      string `help:"Address of the local result server, overrides listen from the config."`
+	ExecutionId string `help:"Execution ID, generated when empty." name:"execution-id"`
+	OrgId       string `help:"Organisation ID reported for a --testcase run." name:"org-id" default:"local"`
+	ProjectId   string `help:"Project ID reported for a --testcase run." name:"project-id" default:"local"`
+	AppId       string `help:"App ID reported for a --testcase run." name:"app-id" default:"local"`
+	Install     bool   `help:"Install workspace dependencies and the default Playwright runtime before running."`
+	Allure      bool   `help:"Also write Allure results, zipped into the output directory."`
+}
+
+func (c *RunCmd) Run(g *Globals) error {
+	if c.Testcase != "" && c.Config == "" {
+		return fmt.Errorf("--config is required with --testcase")
+	}
+
+	apxConfig, err := loadConfig(g)
+	if err != nil {
+		return err
+	}
+	if c.Listen != "" {
+		apxConfig.Listen = c.Listen
+	}
+	dynamicConfig := config.GetConfig()
+
+	// nkk: No registration and no browser popup, only the machine config the fixtures read
+	machineId, err := initialization.EnsureLocalMachineConfig(apxConfig)
+	if err != nil {
+		return err
+	}
+
+	runtimes := playwright_runtime.NewManager(dynamicConfig.Playwright.RuntimesDir, dynamicConfig.Playwright.DefaultVersion)
+	if c.Install {
+		err = initialization.InstallDependencies(c.Workspace, runtimes)
+	} else {
+		err = initialization.VerifyPlaywrightInstallation(runtimes, dynamicConfig.Playwright.RequiredBrowsers)
+	}
+	if err != nil {
+		return fmt.Errorf("%w (run with --install to set it up)", err)
+	}
+
+	executionId := c.ExecutionId
+	var details *testplan.TestPlanExecutionDetails
+	if c.Plan != "" {
+		details = &testplan.TestPlanExecutionDetails{}
+		if err := readJSON(c.Plan, details); err != nil {
+			return err
+		}
+		if executionId == "" {
+			executionId = details.ExecutionId
+		}
+	}
+	if executionId == "" {
+		executionId = uuid.New().String()
+	}
+
+	sink, err := executionbridge.NewLocalSink(filepath.Join(c.Output, executionId))
+	if err != nil {
+		return err
+	}
+	defer sink.Close()
+
+	allureResults := allure.NewReporter(c.Workspace)
+	allureEnabled := c.Allure || dynamicConfig.Allure.Enabled
+	artifactIndex := sessionartifacts.NewIndex(report.NewStore(c.Workspace))
+	var bridge executionbridge.ExecutionBridge = sessionartifacts.NewBridge(sink, artifactIndex)
+	if allureEnabled {
+		bridge = allure.NewBridge(bridge, allureResults)
+	}
+
+	stopServer, err := startResultServer(apxConfig, bridge, allureResults, artifactIndex, runtimes, c.Workspace)
+	if err != nil {
+		return err
+	}
+	defer stopServer()
+
+	runner := executor.NewTestCaseRunner(bridge, nil, runtimes)
+	if workspace, ok := runner.(interface{ SetWorkspace(string) }); ok {
+		workspace.SetWorkspace(c.Workspace)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	started := time.Now()
+	if details != nil {
+		err = c.runPlan(ctx, runner, details, executionId, machineId)
+	} else {
+		err = c.runTestcase(ctx, runner, executionId, machineId)
+	}
+	if err != nil {
+		logger.Error("headless run failed", zap.String("execution_id", executionId), zap.Error(err))
+	}
+
+	summary, summaryErr := sink.WriteSummary()
+	if summaryErr != nil {
+		return summaryErr
+	}
+	printSummary(executionId, sink.Dir(), summary, time.Since(started))
+	printReports(report.NewStore(c.Workspace), executionId)
+	if allureEnabled {
+		printAllureArchive(allureResults, executionId, filepath.Join(sink.Dir(), "allure-results.zip"))
+	}
+
+	switch {
+	case err != nil:
+		return err
+	case !summary.Succeeded():
+		return fmt.Errorf("%d of %d testcases did not pass", summary.Total-summary.Passed, summary.Total)
+	}
+	return nil
+}
+
+func (c *RunCmd) runTestcase(ctx context.Context, runner executor.TestCaseRunner, executionId, machineId string) error {
+	script := &testcase.TestScript{}
+	if err := readJSON(c.Testcase, script); err != nil {
+		return err
+	}
+	sessionConfig := &session.Config{}
+	if err := readJSON(c.Config, sessionConfig); err != nil {
+		return err
+	}
+	if sessionConfig.MachineId == "" {
+		sessionConfig.MachineId = machineId
+	}
+	if err := sessionConfig.Validate(); err != nil {
+		return err
+	}
+
+	scriptPath, err := executor.WriteTestcaseScript(c.Workspace, script)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(scriptPath)
+
+	stop := stopOnCancel(ctx, func() { runner.StopTestCaseExecution(script.ID, executionId) })
+	defer stop()
+
+	executionsMap := make(map[string]*localexecution_model.LocalExecution)
+	return runner.Execute(ctx, c.OrgId, c.ProjectId, c.AppId, "cli", script, sessionConfig, executionId, executionsMap)
+}
+
+func (c *RunCmd) runPlan(ctx context.Context, runner executor.TestCaseRunner, details *testplan.TestPlanExecutionDetails, executionId, machineId string) error {
+	if err := details.Validate(); err != nil {
+		return err
+	}
+	// nkk: Only cross browser plans get a generated test list
+	if details.Type == "" {
+		details.Type = apxconstants.CrossBrowserTestPlan
+	}
+
+	labConfig := testplan.NewTestLabConfigFromTestPlanConfig(details.TestPlanId, machineId, details.TestLabs)
+	if labConfig == nil {
+		// nkk: Plans exported for another machine, run them with that machine's local lab config
+		for _, lab := range details.TestLabs {
+			if lab.Name != apxconstants.Local {
+				continue
+			}
+			if planMachineId, ok := lab.Config["machineId"].(string); ok {
+				labConfig = testplan.NewTestLabConfigFromTestPlanConfig(details.TestPlanId, planMachineId, details.TestLabs)
+				break
+			}
+		}
+	}
+	if labConfig == nil {
+		return fmt.Errorf("test plan %s has no local test lab config", details.TestPlanId)
+	}
+
+	if _, err := executor.WriteTestPlanScripts(c.Workspace, details); err != nil {
+		return err
+	}
+
+	stop := stopOnCancel(ctx, func() { runner.StopTestPlanExecution(details.TestPlanId, executionId, apxconstants.Stopped) })
+	defer stop()
+
+	createdBy := details.ExecutedBy
+	if createdBy == "" {
+		createdBy = "cli"
+	}
+	executionsMap := make(map[string]*localexecution_model.LocalExecution)
+	return runner.ExecuteTestPlan(createdBy, executionId, details.TestPlanId, labConfig, details, executionsMap)
+}
+
+// startResultServer serves the bridge routes the fixtures call, backed by the local sink
+func startResultServer(apxConfig *config.ApxConfig, bridge executionbridge.ExecutionBridge, allureResults *allure.Reporter, artifactIndex *sessionartifacts.Index, runtimes *playwright_runtime.Manager, workspace string) (func(), error) {
+	health := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("OK"))
+	})
+	checker, err := newVisualChecker(config.GetConfig(), workspace)
+	if err != nil {
+		return nil, err
+	}
+	artifactHandler, err := newArtifactHandler(config.GetConfig(), artifactIndex, workspace)
+	if err != nil {
+		return nil, err
+	}
+	server := apxhttp.NewServer(apxConfig,
+		handlers.NewAgentHandler(nil, apxConfig),
+		handlers.NewExecutionBridgeHandler(bridge),
+		handlers.NewPlaywrightRuntimeHandler(runtimes),
+		health,
+		handlers.NewDoctorHandler(doctor.NewDoctor(newDoctorOptions(apxConfig, runtimes, workspace))),
+		handlers.NewReportHandler(report.NewStore(workspace), allureResults),
+		handlers.NewVisualHandler(checker, bridge),
+		artifactHandler,
+	)
+	server.Logger = logger.Logger
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errch := make(chan error, 1)
+	go func() {
+		errch <- server.Listen(ctx, apxConfig.Listen)
+	}()
+
+	// nkk: Surface a port clash before the tests start posting results
+	select {
+	case err := <-errch:
+		cancel()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return nil, fmt.Errorf("starting result server on %s: %w", apxConfig.Listen, err)
+		}
+		return nil, fmt.Errorf("result server on %s stopped unexpectedly", apxConfig.Listen)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	return func() {
+		cancel()
+		<-errch
+	}, nil
+}
+
+// stopOnCancel runs stop when ctx is cancelled (Ctrl+C), the returned func ends the watch
+func stopOnCancel(ctx context.Context, stop func()) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			fmt.Println("\n⏹  Stopping execution...")
+			stop()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+func printSummary(executionId, dir string, summary *executionbridge.RunSummary, elapsed time.Duration) {
+	fmt.Printf("\n📋 Execution %s\n", executionId)
+	fmt.Println("================================")
+
+	for _, outcome := range summary.Testcases {
+		icon := "⚠️ "
+		switch outcome.Status {
+		case apxconstants.Passed:
+			icon = "✅"
+		case apxconstants.Failed, apxconstants.Timeout, apxconstants.Aborted:
+			icon = "❌"
+		case apxconstants.Stopped:
+			icon = "⏹ "
+		}
+
+		name := outcome.TestcaseId
+		if outcome.Name != "" {
+			name = fmt.Sprintf("%s (%s)", outcome.Name, outcome.TestcaseId)
+		}
+		if outcome.IsPreRequisite {
+			name += " [pre-requisite]"
+		}
+		status := outcome.Status
+		if status == "" {
+			status = "unknown"
+		}
+
+		line := fmt.Sprintf("%s %-8s %s", icon, status, name)
+		if outcome.Browser != "" {
+			line += " - " + outcome.Browser
+		}
+		if outcome.DurationMs > 0 {
+			line += fmt.Sprintf(" - %s", (time.Duration(outcome.DurationMs) * time.Millisecond).Round(100*time.Millisecond))
+		}
+		if outcome.Message != "" && outcome.Status != apxconstants.Passed {
+			line += "\n      " + outcome.Message
+		}
+		fmt.Println(line)
+	}
+
+	if summary.ExecutionStatus == apxconstants.Failed {
+		fmt.Printf("❌ Execution failed: %s\n", summary.ExecutionMessage)
+	}
+
+	fmt.Println("================================")
+	fmt.Printf("Total: %d  Passed: %d  Failed: %d  Stopped: %d  Incomplete: %d  (%s)\n",
+		summary.Total, summary.Passed, summary.Failed, summary.Stopped, summary.Incomplete, elapsed.Round(time.Second))
+	fmt.Printf("Results: %s\n", dir)
+}
+
+// printReports lists the JUnit and HTML reports and the step timeline of the execution
+func printReports(reports *report.Store, executionId string) {
+	for _, name := range []string{report.JUnitFile, report.HTMLFile, report.StepsFile} {
+		path, err := reports.File(executionId, name)
+		if err != nil {
+			return
+		}
+		if _, err := os.Stat(path); err == nil {
+			fmt.Printf("Report:  %s\n", path)
+		}
+	}
+}
+
+// printAllureArchive zips the Allure results of the execution next to the run summary
+func printAllureArchive(allureResults *allure.Reporter, executionId, path string) {
+	f, err := os.Create(path)
+	if err != nil {
+		logger.Warn("could not write allure results", zap.String("execution_id", executionId), zap.Error(err))
+		return
+	}
+	err = allureResults.Archive(f, executionId)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		logger.Warn("could not write allure results", zap.String("execution_id", executionId), zap.Error(err))
+		return
+	}
+	fmt.Printf("Allure:  %s\n", path)
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+
+	"agent/config"
+	apxhttp "agent/http"
+	"agent/http/handlers"
+	initialization "agent/initialization"
+	"agent/logger"
+	"agent/services/allure"
+	artifactstore "agent/services/artifact_store"
+	autotestbridge "agent/services/autotest_bridge"
+	"agent/services/browser_pool"
+	"agent/services/doctor"
+	executionbridge "agent/services/execution_bridge"
+	"agent/services/executor"
+	"agent/services/health"
+	kafkaevents "agent/services/kafka_events"
+	"agent/services/playwright_runtime"
+	"agent/services/recorder"
+	"agent/services/report"
+	sessionartifacts "agent/services/session_artifacts"
+	"agent/services/shutdown"
+	"agent/services/updater"
+	"agent/services/visual"
+)
+
+type ServeCmd struct {
+	Listen          string        `help:"Address to listen on, overrides listen from the config."`
+	Background      bool          `help:"Do not open the dashboard after registering."`
+	TestMode        bool          `help:"Skip registration calls to the server." name:"test-mode"`
+	SkipInstall     bool          `help:"Do not install workspace dependencies and Playwright on startup." name:"skip-install"`
+	Workspace       string        `help:"Execution workspace directory." default:"executions"`
+	ShutdownTimeout time.Duration `help:"Time allowed for a graceful shutdown." default:"30s" name:"shutdown-timeout"`
+}
+
+func (c *ServeCmd) Run(g *Globals) error {
+	apxConfig, err := loadConfig(g)
+	if err != nil {
+		return err
+	}
+	if c.Listen != "" {
+		apxConfig.Listen = c.Listen
+	}
+	dynamicConfig := config.GetConfig()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	coordinator := shutdown.NewCoordinator(c.ShutdownTimeout)
+	coordinator.Start()
+	coordinator.RegisterHandler("background-tasks", func(context.Context) error {
+		cancel()
+		return nil
+	})
+
+	// nkk: A restart into an update is confirmed once registered, so check it before anything can fail
+	restart := make(chan string, 1)
+	var selfUpdater *updater.Updater
+	confirmUpdate := func() {}
+	if dynamicConfig.Update.Enabled {
+		selfUpdater, err = newUpdater(dynamicConfig, coordinator, restart)
+		if err != nil {
+			logger.Error("self-update disabled", zap.Error(err))
+		} else {
+			confirmUpdate, err = selfUpdater.ResumeUpdate(ctx)
+			if errors.Is(err, updater.ErrRolledBack) {
+				return updater.RestartProcess(selfUpdater.BinaryPath())
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Playwright runtimes
+	runtimes := playwright_runtime.NewManager(dynamicConfig.Playwright.RuntimesDir, dynamicConfig.Playwright.DefaultVersion)
+	if !c.SkipInstall {
+		if err := initialization.InstallDependencies(c.Workspace, runtimes); err != nil {
+			return err
+		}
+	}
+	runtimes.StartGC(ctx, dynamicConfig.Playwright.GCInterval, dynamicConfig.Playwright.MaxIdle)
+
+	// nkk: Containerised runs bring their own browsers, the browser pool is only for host runs
+	var pool browser_pool.BrowserPool
+	if !dynamicConfig.DockerRunner.Enabled {
+		pool, err = browser_pool.NewBrowserPool(dynamicConfig.BrowserPool)
+		if err != nil {
+			logger.Warn("browser pool unavailable, running without pooled browsers",
+				zap.String("backend", dynamicConfig.BrowserPool.Backend), zap.Error(err))
+			pool = nil
+		} else {
+			coordinator.RegisterHandler("browser-pool", shutdown.CreateBrowserPoolShutdown(pool))
+		}
+	}
+
+	// Bridges and executor
+	autotestBridge := autotestbridge.NewAutoTestBridgeService(apxConfig.ServerDomain)
+	executionBridge := executionbridge.NewExecutionServiceBridge(apxConfig.ExecutionServiceDomain)
+	coordinator.RegisterHandler("execution-bridge", shutdown.CreateBatchWriterShutdown(executionBridge))
+
+	// nkk: On-prem sessions go straight into MongoDB, an unreachable one leaves them on the execution service
+	if dynamicConfig.Mongo.URI != "" {
+		sink, err := executionbridge.ConnectMongoSink(ctx, executionbridge.MongoSinkConfig{
+			URI:            dynamicConfig.Mongo.URI,
+			Database:       dynamicConfig.Mongo.Database,
+			Collection:     dynamicConfig.Mongo.Collection,
+			ConnectTimeout: dynamicConfig.Mongo.ConnectTimeout,
+		})
+		if err != nil {
+			logger.Warn("MongoDB session sink unavailable, sessions are sent over HTTP", zap.Error(err))
+		} else {
+			executionBridge.EnableMongoSink(sink)
+			coordinator.RegisterHandler("mongo-sink", func(ctx context.Context) error {
+				executionBridge.Flush()
+				return sink.Close(ctx)
+			})
+		}
+	}
+
+	// nkk: Writes to the execution service are journaled first, a restart delivers what is left
+	outboxDir := dynamicConfig.Outbox.Dir
+	if outboxDir == "" {
+		outboxDir = filepath.Join(c.Workspace, "outbox")
+	}
+	outbox, err := executionBridge.EnableOutbox(outboxDir, executionbridge.OutboxOptions{
+		MaxAttempts:    dynamicConfig.Outbox.MaxAttempts,
+		RetryBaseDelay: dynamicConfig.Outbox.RetryBaseDelay,
+		RetryMaxDelay:  dynamicConfig.Outbox.RetryMaxDelay,
+	})
+	if err != nil {
+		return err
+	}
+	coordinator.RegisterHandler("execution-outbox", outbox.Close)
+	if dynamicConfig.StatusCoalescing.Enabled {
+		executionBridge.EnableStatusCoalescing(dynamicConfig.StatusCoalescing.MaxBatch, dynamicConfig.StatusCoalescing.Window)
+	}
+	// nkk: One file watcher for the traces and videos of every session
+	artifactCollector := executionbridge.NewArtifactCollector(executionbridge.ArtifactWaitOptions{
+		Timeout:      dynamicConfig.Artifacts.WaitTimeout,
+		StableFor:    dynamicConfig.Artifacts.StableFor,
+		PollInterval: dynamicConfig.Artifacts.PollInterval,
+	})
+	executionBridge.SetArtifactCollector(artifactCollector)
+	coordinator.RegisterHandler("artifact-collector", artifactCollector.Close)
+	if uploads := dynamicConfig.Uploads; uploads.Chunked {
+		executionBridge.EnableChunkedUploads(executionbridge.ChunkedUploadOptions{
+			ChunkSize:      uploads.ChunkSize,
+			MaxAttempts:    uploads.MaxAttempts,
+			RetryBaseDelay: uploads.RetryBaseDelay,
+			RetryMaxDelay:  uploads.RetryMaxDelay,
+		})
+	}
+	storage := dynamicConfig.ArtifactStorage
+	artifactStores, err := artifactstore.New(artifactstore.Options{
+		Backend: storage.Backend,
+		Tenants: storage.Tenants,
+		S3: artifactstore.S3Options{
+			Endpoint:        storage.S3.Endpoint,
+			Region:          storage.S3.Region,
+			Bucket:          storage.S3.Bucket,
+			AccessKeyID:     storage.S3.AccessKeyID,
+			SecretAccessKey: storage.S3.SecretAccessKey,
+			ForcePathStyle:  storage.S3.ForcePathStyle,
+			Prefix:          storage.S3.Prefix,
+		},
+		Local: artifactstore.LocalOptions{
+			Dir:        storage.Local.Dir,
+			BaseURL:    storage.Local.BaseURL,
+			SigningKey: storage.Local.SigningKey,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("invalid artifact_storage: %w", err)
+	}
+	executionBridge.SetArtifactStore(artifactStores, storage.URLExpiry)
+	if networkLogs := dynamicConfig.NetworkLogs; networkLogs.HAREnabled {
+		filter, err := executionbridge.ParseHARFilter(networkLogs.StatusRanges, networkLogs.ResourceTypes, networkLogs.IncludeURLs, networkLogs.ExcludeURLs)
+		if err != nil {
+			return fmt.Errorf("invalid network_logs filter: %w", err)
+		}
+		executionBridge.EnableHARExport(executionbridge.HAROptions{
+			Filter:        filter,
+			IncludeBodies: networkLogs.IncludeBodies,
+			MaxBodySize:   networkLogs.MaxBodySize,
+		})
+	}
+
+	// nkk: Artifacts are indexed as the fixtures report them, for the agent's own artifact endpoints
+	artifactIndex := sessionartifacts.NewIndex(report.NewStore(c.Workspace))
+	var bridge executionbridge.ExecutionBridge = sessionartifacts.NewBridge(executionBridge, artifactIndex)
+	if dynamicConfig.Kafka.Enabled {
+		// nkk: Lifecycle events are published for the calls the bridge accepted
+		publisher := kafkaevents.NewPublisher(
+			kafkaevents.NewKafkaWriter(dynamicConfig.Kafka.Brokers, dynamicConfig.Kafka.Topic, dynamicConfig.Kafka.Delivery, dynamicConfig.Kafka.BatchTimeout),
+			kafkaevents.Options{
+				Delivery:     dynamicConfig.Kafka.Delivery,
+				QueueSize:    dynamicConfig.Kafka.QueueSize,
+				MaxAttempts:  dynamicConfig.Kafka.MaxAttempts,
+				WriteTimeout: dynamicConfig.Kafka.WriteTimeout,
+			})
+		coordinator.RegisterHandler("kafka-events", publisher.Close)
+		bridge = kafkaevents.NewBridge(bridge, publisher)
+	}
+
+	// nkk: Allure results are recorded from the calls passing through the bridge, it stays the outer wrapper for DescribeExecution
+	allureResults := allure.NewReporter(c.Workspace)
+	if dynamicConfig.Allure.Enabled {
+		bridge = allure.NewBridge(bridge, allureResults)
+	}
+
+	runner := executor.NewTestCaseRunner(bridge, pool, runtimes)
+	if workspace, ok := runner.(interface{ SetWorkspace(string) }); ok {
+		workspace.SetWorkspace(c.Workspace)
+	}
+	if closer, ok := runner.(interface{ Close() error }); ok {
+		coordinator.RegisterHandler("docker-runner", func(context.Context) error {
+			return closer.Close()
+		})
+	}
+
+	executionService := executor.NewTestCaseExecutorService(runner, autotestBridge, executionBridge)
+	executionService.SessionRecorder = recorder.NewSessionRecorder()
+	coordinator.RegisterHandler("tunnels", shutdown.CreateTunnelServiceShutdown(executionService.TunnelService))
+	coordinator.RegisterHandler("session-recorder", shutdown.CreateSessionRecorderShutdown(executionService.SessionRecorder))
+
+	// HTTP server
+	healthHandler := health.NewHealthHandler(
+		pool,
+		executionService.TunnelService,
+		executionService.TenantManager,
+		executionService.BillingService,
+		executionService.GeoRouter,
+		executionService.SessionRecorder,
+	)
+	healthHandler.SetOutbox(outbox)
+	agentHandler := handlers.NewAgentHandler(executionService, apxConfig)
+	checker, err := newVisualChecker(dynamicConfig, c.Workspace)
+	if err != nil {
+		return err
+	}
+	artifactHandler, err := newArtifactHandler(dynamicConfig, artifactIndex, c.Workspace)
+	if err != nil {
+		return err
+	}
+	server := apxhttp.NewServer(apxConfig,
+		agentHandler,
+		handlers.NewExecutionBridgeHandler(bridge),
+		handlers.NewPlaywrightRuntimeHandler(runtimes),
+		healthHandler,
+		handlers.NewDoctorHandler(doctor.NewDoctor(newDoctorOptions(apxConfig, runtimes, c.Workspace))),
+		handlers.NewReportHandler(report.NewStore(c.Workspace), allureResults),
+		handlers.NewVisualHandler(checker, bridge),
+		artifactHandler,
+	)
+	server.Logger = logger.Logger
+
+	// nkk: EnsureRegistration below unmarshals the machine config into apxConfig, read the address first
+	addr := apxConfig.Listen
+	serverCtx, stopServer := context.WithCancel(context.Background())
+	serverDone := make(chan struct{})
+	go func() {
+		defer close(serverDone)
+		if err := server.Listen(serverCtx, addr); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("http server stopped", zap.Error(err))
+			go coordinator.Shutdown()
+		}
+	}()
+	coordinator.RegisterHandler("http-server", func(ctx context.Context) error {
+		stopServer()
+		select {
+		case <-serverDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+
+	// nkk: Register after the server is up, the dashboard calls back /v1/start
+	machineId, err := initialization.EnsureRegistration(apxConfig, autotestBridge, c.Background, c.TestMode)
+	if err != nil {
+		coordinator.Shutdown()
+		return err
+	}
+	logger.Info("agent registered", zap.String("machine_id", machineId))
+	confirmUpdate()
+
+	// Dispatch loop
+	go executionService.ProcessQueue()
+	if c.Background || c.TestMode {
+		agentHandler.StartAgent(ctx)
+	}
+
+	if selfUpdater != nil {
+		selfUpdater.SetExecutions(executionService)
+		go selfUpdater.Run(ctx, dynamicConfig.Update.CheckInterval)
+	}
+
+	coordinator.WaitForShutdown()
+	// nkk: Shutdown is a sync.Once, this blocks until the handlers started by the signal have finished
+	coordinator.Shutdown()
+
+	select {
+	case binaryPath := <-restart:
+		logger.Info("restarting into updated agent", zap.String("binary", binaryPath))
+		return updater.RestartProcess(binaryPath)
+	default:
+		return nil
+	}
+}
+
+// newUpdater checks for releases in the background, a restart goes through a graceful shutdown first
+func newUpdater(dynamicConfig *config.DynamicConfig, coordinator *shutdown.Coordinator, restart chan<- string) (*updater.Updater, error) {
+	publicKey, err := updater.ParsePublicKey(dynamicConfig.Update.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	source, err := updater.NewSource(dynamicConfig.Update.Source, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return updater.NewUpdater(updater.Options{
+		Source:             source,
+		PublicKey:          publicKey,
+		CurrentVersion:     config.Version,
+		HealthCheckTimeout: dynamicConfig.Update.HealthCheckTimeout,
+		StartupTimeout:     dynamicConfig.Update.StartupTimeout,
+		Restart: func(binaryPath string) error {
+			// nkk: An update and a rollback of the one before may both ask, the first wins
+			select {
+			case restart <- binaryPath:
+			default:
+			}
+			go coordinator.Shutdown()
+			return nil
+		},
+	})
+}
+
+// newVisualChecker creates the visual regression checker, nil when visual checks are off
+func newVisualChecker(dynamicConfig *config.DynamicConfig, workspace string) (*visual.Checker, error) {
+	settings := dynamicConfig.Visual
+	if !settings.Enabled {
+		return nil, nil
+	}
+	dir := settings.BaselineDir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(workspace, dir)
+	}
+	checker, err := visual.NewChecker(visual.Options{
+		Dir: dir,
+		Diff: visual.DiffOptions{
+			Mode:         settings.Mode,
+			Threshold:    settings.Threshold,
+			MaxDiffRatio: settings.MaxDiffRatio,
+		},
+		AutoApproveNew: settings.AutoApproveNew,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid visual config: %w", err)
+	}
+	return checker, nil
+}
+
+// newArtifactHandler serves the indexed artifacts, the trace viewer comes from playwright-core in the workspace unless configured
+func newArtifactHandler(dynamicConfig *config.DynamicConfig, index *sessionartifacts.Index, workspace string) (*handlers.ArtifactHandler, error) {
+	settings := dynamicConfig.Artifacts
+	signer, err := sessionartifacts.NewSigner(settings.SigningKey, settings.LinkExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid artifacts config: %w", err)
+	}
+	viewerDir := ""
+	if settings.TraceViewer {
+		viewerDir = settings.TraceViewerDir
+		if viewerDir == "" {
+			viewerDir = filepath.Join(workspace, "node_modules", "playwright-core", "lib", "vite", "traceViewer")
+		}
+		if _, err := os.Stat(filepath.Join(viewerDir, "index.html")); err != nil {
+			logger.Warn("trace viewer not found, traces are download only", zap.String("dir", viewerDir), zap.Error(err))
+			viewerDir = ""
+		}
+	}
+	if !dynamicConfig.Security.EnableAuth {
+		logger.Warn("security.enable_auth is off, artifacts and reports can be fetched without a signed link or token")
+	}
+	return handlers.NewArtifactHandler(index, signer, viewerDir), nil
+}
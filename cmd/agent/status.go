@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"agent/config"
+)
+
+type StatusCmd struct {
+	Addr string `help:"Base URL of the local agent, defaults to the configured listen address."`
+	JSON bool   `help:"Print the status as JSON." name:"json"`
+}
+
+// AgentStatus is what `agent status` reports
+type AgentStatus struct {
+	Version    string `json:"version"`
+	MachineId  string `json:"machine_id,omitempty"`
+	Registered bool   `json:"registered"`
+	Running    bool   `json:"running"`
+	Health     string `json:"health,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (c *StatusCmd) Run(g *Globals) error {
+	apxConfig, err := loadConfig(g)
+	if err != nil {
+		return err
+	}
+
+	status := AgentStatus{Version: config.Version}
+
+	// nkk: Read the machine config directly, EnsureConfigFile would create it
+	data, err := os.ReadFile(filepath.Join("configuration", "machine_config.json"))
+	if err == nil && len(data) > 0 {
+		var machineConfig config.ApxConfig
+		if json.Unmarshal(data, &machineConfig) == nil && machineConfig.MachineId != "" {
+			status.MachineId = machineConfig.MachineId
+			status.Registered = true
+		}
+	}
+
+	addr := c.Addr
+	if addr == "" {
+		addr, err = localAgentURL(apxConfig.Listen)
+		if err != nil {
+			return err
+		}
+	}
+	healthURL := strings.TrimRight(addr, "/") + apxConfig.Prefix + "/health"
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	res, err := client.Get(healthURL)
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		defer res.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		status.Running = true
+		status.Health = strings.TrimSpace(string(body))
+	}
+
+	if c.JSON {
+		out, err := json.MarshalIndent(status, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else {
+		printStatus(status)
+	}
+
+	if !status.Running {
+		return fmt.Errorf("agent is not running at %s", addr)
+	}
+	return nil
+}
+
+// localAgentURL is the base URL the agent listening on listen is reachable at from this machine
+func localAgentURL(listen string) (string, error) {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", fmt.Errorf("invalid listen address %q: %w", listen, err)
+	}
+	// nkk: ":7000" and "0.0.0.0:7000" listen on every interface, loopback is one of them
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port), nil
+}
+
+func printStatus(status AgentStatus) {
+	fmt.Printf("Version:     %s\n", status.Version)
+	if status.Registered {
+		fmt.Printf("Machine ID:  %s\n", status.MachineId)
+	} else {
+		fmt.Println("Machine ID:  not registered (run `agent register`)")
+	}
+	if status.Running {
+		fmt.Printf("Agent:       running (%s)\n", status.Health)
+	} else {
+		fmt.Printf("Agent:       not running (%s)\n", status.Error)
+	}
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+
+	"agent/config"
+)
+
+type VersionCmd struct{}
+
+// nkk: Keep the version first on the line, the self-updater health check looks for it
+func (c *VersionCmd) Run(g *Globals) error {
+	fmt.Printf("%s (%s, %s/%s)\n", config.Version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 
+	"agent/config"
 	"agent/logger"
 	"agent/services/billing"
 	"agent/services/browser_pool"
@@ -33,16 +35,17 @@ func main() {
 	// Create services
 	fmt.Println("\n📦 Initializing Services...")
 
-	// nkk: Test Docker pool with ARM64 compatible images
-	var browserPool *browser_pool.BrowserPoolManager
-	var err error
-
-	browserPool, err = browser_pool.NewBrowserPoolManager(5)
+	// nkk: Docker pool with ARM64 compatible images unless browser_pool.backend selects Playwright
+	poolConfig := config.GetConfig().BrowserPool
+	poolConfig.MaxSize = 5
+	poolConfig.PrewarmSize = min(poolConfig.PrewarmSize, poolConfig.MaxSize)
+	backend := poolConfig.Backend
+	browserPool, err := browser_pool.NewBrowserPool(poolConfig)
 	if err != nil {
-		fmt.Printf("⚠️  Browser Pool: Not available (Docker issue: %v)\n", err)
+		fmt.Printf("⚠️  Browser Pool: Not available (%s: %v)\n", backend, err)
 		browserPool = nil
 	} else {
-		fmt.Printf("✅ Docker Browser Pool: Initialized with 5 slots\n")
+		fmt.Printf("✅ Browser Pool: %s backend initialized with 5 slots\n", backend)
 		defer browserPool.Shutdown()
 	}
 
@@ -62,8 +65,7 @@ func main() {
 	sessionRecorder := recorder.NewSessionRecorder()
 	fmt.Printf("✅ Session Recorder: Initialized\n")
 
-	// nkk: Create health handler - pass Docker pool for compatibility
-	// Health handler expects BrowserPoolManager type, not Playwright
+	// nkk: Health handler only sees the BrowserPool interface, either backend works
 	healthHandler := health.NewHealthHandler(
 		browserPool,
 		tunnelService,
@@ -93,8 +95,11 @@ func main() {
 	// nkk: Real API endpoints for test client compatibility
 	// Browser Pool endpoints
 	if browserPool != nil {
-		http.HandleFunc("/browser/acquire", handleBrowserAcquire(browserPool))
-		http.HandleFunc("/browser/release", handleBrowserRelease(browserPool))
+		leases := &sync.Map{}
+		http.HandleFunc("/browser/acquire", handleBrowserAcquire(browserPool, leases))
+		http.HandleFunc("/browser/release", handleBrowserRelease(browserPool, leases))
+		http.HandleFunc("/playwright/acquire", handlePlaywrightAcquire(browserPool, leases))
+		http.HandleFunc("/playwright/release", handlePlaywrightRelease(browserPool, leases))
 	}
 
 	// Recording endpoints
@@ -124,6 +129,8 @@ func main() {
 	fmt.Println("  GET  /demo/billing     - Test billing")
 	fmt.Println("  GET  /demo/tunnel      - Test tunnel service")
 	fmt.Println("  GET  /demo/geo         - Test geo routing")
+	fmt.Println("  POST /browser/acquire, /playwright/acquire - Lease a pooled browser")
+	fmt.Println("  POST /browser/release, /playwright/release - Hand it back by id")
 	fmt.Println("\n================================")
 
 	// Run demo sequence
@@ -285,7 +292,8 @@ func demoGeo(gr *geo.Router) http.HandlerFunc {
 }
 
 // nkk: Real API handlers for test client compatibility
-func handleBrowserAcquire(bp *browser_pool.BrowserPoolManager) http.HandlerFunc {
+// Leased browsers are kept by id in leases until they are released
+func handleBrowserAcquire(bp browser_pool.BrowserPool, leases *sync.Map) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if bp == nil {
 			http.Error(w, `{"error":"browser pool not available"}`, http.StatusServiceUnavailable)
@@ -305,23 +313,24 @@ func handleBrowserAcquire(bp *browser_pool.BrowserPoolManager) http.HandlerFunc
 			req.Version = "latest"
 		}
 
-		instance, err := bp.AcquireBrowser(r.Context(), req.Browser, req.Version)
+		lease, err := bp.Acquire(r.Context(), req.Browser, req.Version)
 		if err != nil {
 			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusServiceUnavailable)
 			return
 		}
+		leases.Store(lease.ID, lease)
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"id": instance.ID,
-			"webdriver_url": instance.WebDriverURL,
-			"browser": instance.BrowserType,
-			"version": instance.Version,
+			"id": lease.ID,
+			"webdriver_url": lease.WebDriverURL,
+			"browser": lease.BrowserType,
+			"version": lease.Version,
 		})
 	}
 }
 
-func handleBrowserRelease(bp *browser_pool.BrowserPoolManager) http.HandlerFunc {
+func handleBrowserRelease(bp browser_pool.BrowserPool, leases *sync.Map) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if bp == nil {
 			w.WriteHeader(http.StatusOK)
@@ -335,7 +344,9 @@ func handleBrowserRelease(bp *browser_pool.BrowserPoolManager) http.HandlerFunc
 		}
 		json.NewDecoder(r.Body).Decode(&req)
 
-		// For demo, just return success
+		if lease, ok := leases.LoadAndDelete(req.ID); ok {
+			bp.Release(lease.(*browser_pool.Lease))
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.Write([]byte(`{"status":"released"}`))
 	}
@@ -573,7 +584,7 @@ func handleSessionRelease(tm *tenant.Manager) http.HandlerFunc {
 }
 
 // nkk: Playwright-specific handlers for browser operations
-func handlePlaywrightAcquire(pool *browser_pool.PlaywrightPoolManager) http.HandlerFunc {
+func handlePlaywrightAcquire(pool browser_pool.BrowserPool, leases *sync.Map) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
 			Browser string `json:"browser"`
@@ -585,30 +596,32 @@ func handlePlaywrightAcquire(pool *browser_pool.PlaywrightPoolManager) http.Hand
 			req.Browser = "chromium"
 		}
 
-		instance, err := pool.AcquireBrowser(r.Context(), req.Browser, req.Version)
+		lease, err := pool.Acquire(r.Context(), req.Browser, req.Version)
 		if err != nil {
 			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusServiceUnavailable)
 			return
 		}
+		leases.Store(lease.ID, lease)
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
-			"browser_id": instance.ID,
-			"type": instance.BrowserType,
+			"browser_id": lease.ID,
+			"type": lease.BrowserType,
 			"status": "acquired",
 		})
 	}
 }
 
-func handlePlaywrightRelease(pool *browser_pool.PlaywrightPoolManager) http.HandlerFunc {
+func handlePlaywrightRelease(pool browser_pool.BrowserPool, leases *sync.Map) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
 			BrowserID string `json:"browser_id"`
 		}
 		json.NewDecoder(r.Body).Decode(&req)
 
-		// For demo, we just acknowledge the release
-		// In real implementation, you'd track instances by ID
+		if lease, ok := leases.LoadAndDelete(req.BrowserID); ok {
+			pool.Release(lease.(*browser_pool.Lease))
+		}
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"browser_id": req.BrowserID,
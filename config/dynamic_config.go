@@ -3,9 +3,14 @@ package config
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/providers/env"
+	"github.com/knadh/koanf/providers/file"
 	"go.uber.org/zap"
 
 	"agent/logger"
@@ -25,13 +30,7 @@ Features:
 // DynamicConfig holds runtime-configurable values
 type DynamicConfig struct {
 	// Browser Pool Configuration
-	BrowserPool struct {
-		MaxSize             int           `json:"max_size" default:"50"`
-		PrewarmSize         int           `json:"prewarm_size" default:"5"`
-		HealthCheckInterval time.Duration `json:"health_check_interval" default:"30s"`
-		IdleTimeout         time.Duration `json:"idle_timeout" default:"5m"`
-		AcquisitionTimeout  time.Duration `json:"acquisition_timeout" default:"30s"`
-	} `json:"browser_pool"`
+	BrowserPool BrowserPoolConfig `json:"browser_pool"`
 
 	// Test Execution Configuration
 	TestExecution struct {
@@ -41,8 +40,135 @@ type DynamicConfig struct {
 		ParallelismMax      int           `json:"parallelism_max" default:"10"`
 		RetryAttempts       int           `json:"retry_attempts" default:"3"`
 		RetryDelay          time.Duration `json:"retry_delay" default:"5s"`
+		StepUpdateInterval  time.Duration `json:"step_update_interval" default:"2s"` // nkk: minimum gap between step count updates per testcase
 	} `json:"test_execution"`
 
+	// Docker Runner Configuration
+	DockerRunner DockerRunnerConfig `json:"docker_runner"`
+
+	// Playwright Runtime Configuration
+	Playwright struct {
+		RuntimesDir      string        `json:"runtimes_dir" default:"runtimes"`
+		DefaultVersion   string        `json:"default_version" default:"1.47.2"`
+		GCInterval       time.Duration `json:"gc_interval" default:"1h"`
+		MaxIdle          time.Duration `json:"max_idle" default:"168h"`
+		Offline          bool          `json:"offline" default:"false"`
+		RequiredBrowsers []string      `json:"required_browsers" default:"[\"chromium\"]"`
+	} `json:"playwright"`
+
+	// Self-Update Configuration
+	Update struct {
+		Enabled            bool          `json:"enabled" default:"false"`
+		Source             string        `json:"source"` // nkk: release base URL or local directory
+		PublicKey          string        `json:"public_key"` // nkk: base64 ed25519 key releases are signed with
+		CheckInterval      time.Duration `json:"check_interval" default:"6h"`
+		HealthCheckTimeout time.Duration `json:"health_check_timeout" default:"30s"`
+		StartupTimeout     time.Duration `json:"startup_timeout" default:"5m"` // nkk: the restarted agent must be registered by then or the update is rolled back
+	} `json:"update"`
+
+	// Allure Results Configuration
+	Allure struct {
+		Enabled bool `json:"enabled" default:"false"` // nkk: write Allure results under <workspace>/allure
+	} `json:"allure"`
+
+	// Execution Service Outbox Configuration
+	Outbox struct {
+		Dir            string        `json:"dir"`                      // nkk: defaults to <workspace>/outbox
+		MaxAttempts    int           `json:"max_attempts" default:"0"` // nkk: 0 retries until delivered
+		RetryBaseDelay time.Duration `json:"retry_base_delay" default:"1s"`
+		RetryMaxDelay  time.Duration `json:"retry_max_delay" default:"5m"`
+	} `json:"outbox"`
+
+	// Session Status Coalescing Configuration
+	StatusCoalescing struct {
+		Enabled  bool          `json:"enabled" default:"false"` // nkk: needs the execution service batch endpoint
+		Window   time.Duration `json:"window" default:"250ms"`
+		MaxBatch int           `json:"max_batch" default:"50"`
+	} `json:"status_coalescing"`
+
+	// Kafka Events Configuration
+	Kafka struct {
+		Enabled      bool          `json:"enabled" default:"false"`
+		Brokers      []string      `json:"brokers"`
+		Topic        string        `json:"topic" default:"agent.execution-events"`
+		Delivery     string        `json:"delivery" default:"at-least-once"` // nkk: at-least-once or at-most-once
+		MaxAttempts  int           `json:"max_attempts" default:"0"`         // nkk: 0 retries until shutdown
+		QueueSize    int           `json:"queue_size" default:"1000"`
+		BatchTimeout time.Duration `json:"batch_timeout" default:"50ms"`
+		WriteTimeout time.Duration `json:"write_timeout" default:"10s"`
+	} `json:"kafka"`
+
+	// MongoDB Session Sink Configuration
+	Mongo struct {
+		URI            string        `json:"uri"` // nkk: empty keeps sessions on the execution service
+		Database       string        `json:"database" default:"testrunner"`
+		Collection     string        `json:"collection" default:"sessions"`
+		ConnectTimeout time.Duration `json:"connect_timeout" default:"10s"`
+	} `json:"mongo"`
+
+	// Network Log Export Configuration
+	NetworkLogs struct {
+		HAREnabled    bool     `json:"har_enabled" default:"false"` // nkk: uploads network.har next to the trimmed logs
+		IncludeBodies bool     `json:"include_bodies" default:"false"`
+		MaxBodySize   int64    `json:"max_body_size" default:"65536"`
+		StatusRanges  []string `json:"status_ranges"` // nkk: "404", "500-599" or "4xx", empty keeps all
+		ResourceTypes []string `json:"resource_types"`
+		IncludeURLs   []string `json:"include_urls"` // nkk: regular expressions
+		ExcludeURLs   []string `json:"exclude_urls"`
+	} `json:"network_logs"`
+
+	// Artifact Collection Configuration
+	Artifacts struct {
+		WaitTimeout  time.Duration `json:"wait_timeout" default:"2m"` // nkk: a trace or video missing after this is a session warning
+		StableFor    time.Duration `json:"stable_for" default:"1s"`
+		PollInterval time.Duration `json:"poll_interval" default:"500ms"`
+
+		LinkExpiry     time.Duration `json:"link_expiry" default:"1h"` // nkk: signed download links of the agent's artifact endpoints
+		SigningKey     string        `json:"signing_key"`              // nkk: random per start when empty, links die with the agent
+		TraceViewer    bool          `json:"trace_viewer" default:"false"`
+		TraceViewerDir string        `json:"trace_viewer_dir"` // nkk: defaults to playwright-core's trace viewer in the workspace
+	} `json:"artifacts"`
+
+	// Chunked Artifact Upload Configuration
+	Uploads struct {
+		Chunked        bool          `json:"chunked" default:"false"` // nkk: needs the execution service uploads endpoints
+		ChunkSize      int64         `json:"chunk_size" default:"8388608"`
+		MaxAttempts    int           `json:"max_attempts" default:"5"`
+		RetryBaseDelay time.Duration `json:"retry_base_delay" default:"1s"`
+		RetryMaxDelay  time.Duration `json:"retry_max_delay" default:"30s"`
+	} `json:"uploads"`
+
+	// Artifact Storage Configuration
+	ArtifactStorage struct {
+		Backend   string            `json:"backend" default:"passthrough"` // nkk: passthrough, s3 or local
+		URLExpiry time.Duration     `json:"url_expiry" default:"1h"`
+		Tenants   map[string]string `json:"tenants"` // nkk: org ID -> backend, overrides backend
+		S3        struct {
+			Endpoint        string `json:"endpoint"` // nkk: set with force_path_style for MinIO
+			Region          string `json:"region" default:"us-east-1"`
+			Bucket          string `json:"bucket" default:"agent-session-recordings"`
+			AccessKeyID     string `json:"access_key_id"`
+			SecretAccessKey string `json:"secret_access_key"`
+			ForcePathStyle  bool   `json:"force_path_style" default:"false"`
+			Prefix          string `json:"prefix"`
+		} `json:"s3"`
+		Local struct {
+			Dir        string `json:"dir"`
+			BaseURL    string `json:"base_url"`
+			SigningKey string `json:"signing_key"`
+		} `json:"local"`
+	} `json:"artifact_storage"`
+
+	// Visual Regression Configuration
+	Visual struct {
+		Enabled        bool    `json:"enabled" default:"false"`
+		BaselineDir    string  `json:"baseline_dir" default:"visual-baselines"` // nkk: relative to the workspace
+		Mode           string  `json:"mode" default:"pixel"`                    // nkk: pixel or perceptual
+		Threshold      float64 `json:"threshold" default:"0.1"`                 // nkk: per pixel, 0-1
+		MaxDiffRatio   float64 `json:"max_diff_ratio" default:"0.001"`          // nkk: share of differing pixels that fails the step
+		AutoApproveNew bool    `json:"auto_approve_new" default:"true"`
+	} `json:"visual"`
+
 	// HTTP Configuration
 	HTTP struct {
 		RequestTimeout      time.Duration `json:"request_timeout" default:"30s"`
@@ -105,6 +231,39 @@ type DynamicConfig struct {
 	} `json:"security"`
 }
 
+// BrowserPoolConfig sizes the pool of warm browsers for host runs
+type BrowserPoolConfig struct {
+	Backend             string                 `json:"backend" default:"docker"` // nkk: docker (Selenium containers) or playwright (in-process browsers)
+	MaxSize             int                    `json:"max_size" default:"50"`    // nkk: all sub-pools together
+	PrewarmSize         int                    `json:"prewarm_size" default:"5"` // nkk: chrome:latest when no pools are listed
+	HealthCheckInterval time.Duration          `json:"health_check_interval" default:"30s"`
+	IdleTimeout         time.Duration          `json:"idle_timeout" default:"5m"`
+	AcquisitionTimeout  time.Duration          `json:"acquisition_timeout" default:"30s"` // nkk: wait for a pooled browser before starting another
+	Pools               []BrowserSubPoolConfig `json:"pools"`
+}
+
+// BrowserSubPoolConfig sizes the sub-pool of one browser and version, unlisted ones start empty and grow up to max_size
+type BrowserSubPoolConfig struct {
+	Browser     string `json:"browser"`
+	Version     string `json:"version" default:"latest"`
+	MinSize     int    `json:"min_size"`     // nkk: idle cleanup never goes below it, lost browsers are replaced
+	MaxSize     int    `json:"max_size"`     // nkk: 0 means browser_pool.max_size
+	PrewarmSize int    `json:"prewarm_size"` // nkk: started with the agent
+}
+
+// DockerRunnerConfig controls the containerised Playwright test runner
+type DockerRunnerConfig struct {
+	Enabled        bool     `json:"enabled" default:"false"`
+	Image          string   `json:"image" default:"mcr.microsoft.com/playwright:v{version}-noble"` // nkk: {version} is the execution's Playwright version
+	WorkspaceDir   string   `json:"workspace_dir" default:"executions"`
+	ContainerDir   string   `json:"container_dir" default:"/workspace"`
+	CPUs           float64  `json:"cpus" default:"2"`
+	MemoryMB       int64    `json:"memory_mb" default:"4096"`
+	NetworkMode    string   `json:"network_mode" default:"host"` // nkk: host so fixtures can reach the agent on localhost
+	User           string   `json:"user"`
+	EnvPassthrough []string `json:"env_passthrough"`
+}
+
 // ConfigManager manages dynamic configuration
 type ConfigManager struct {
 	config    *DynamicConfig
@@ -132,6 +291,7 @@ func (cm *ConfigManager) setDefaults() {
 	config := &DynamicConfig{}
 
 	// Browser Pool defaults
+	config.BrowserPool.Backend = "docker"
 	config.BrowserPool.MaxSize = 50
 	config.BrowserPool.PrewarmSize = 5
 	config.BrowserPool.HealthCheckInterval = 30 * time.Second
@@ -145,6 +305,90 @@ func (cm *ConfigManager) setDefaults() {
 	config.TestExecution.ParallelismMax = 10
 	config.TestExecution.RetryAttempts = 3
 	config.TestExecution.RetryDelay = 5 * time.Second
+	config.TestExecution.StepUpdateInterval = 2 * time.Second
+
+	// Docker Runner defaults
+	config.DockerRunner.Enabled = false
+	config.DockerRunner.Image = "mcr.microsoft.com/playwright:v{version}-noble"
+	config.DockerRunner.WorkspaceDir = "executions"
+	config.DockerRunner.ContainerDir = "/workspace"
+	config.DockerRunner.CPUs = 2
+	config.DockerRunner.MemoryMB = 4096
+	config.DockerRunner.NetworkMode = "host"
+
+	// Playwright Runtime defaults
+	config.Playwright.RuntimesDir = "runtimes"
+	config.Playwright.DefaultVersion = "1.47.2"
+	config.Playwright.GCInterval = 1 * time.Hour
+	config.Playwright.MaxIdle = 7 * 24 * time.Hour
+	config.Playwright.Offline = false
+	config.Playwright.RequiredBrowsers = []string{"chromium"}
+
+	// Self-Update defaults
+	config.Update.Enabled = false
+	config.Update.CheckInterval = 6 * time.Hour
+	config.Update.HealthCheckTimeout = 30 * time.Second
+	config.Update.StartupTimeout = 5 * time.Minute
+
+	// Allure Results defaults
+	config.Allure.Enabled = false
+
+	// Execution Service Outbox defaults
+	config.Outbox.MaxAttempts = 0
+	config.Outbox.RetryBaseDelay = 1 * time.Second
+	config.Outbox.RetryMaxDelay = 5 * time.Minute
+
+	// Session Status Coalescing defaults
+	config.StatusCoalescing.Enabled = false
+	config.StatusCoalescing.Window = 250 * time.Millisecond
+	config.StatusCoalescing.MaxBatch = 50
+
+	// Kafka Events defaults
+	config.Kafka.Enabled = false
+	config.Kafka.Topic = "agent.execution-events"
+	config.Kafka.Delivery = "at-least-once"
+	config.Kafka.MaxAttempts = 0
+	config.Kafka.QueueSize = 1000
+	config.Kafka.BatchTimeout = 50 * time.Millisecond
+	config.Kafka.WriteTimeout = 10 * time.Second
+
+	// MongoDB Session Sink defaults
+	config.Mongo.Database = "testrunner"
+	config.Mongo.Collection = "sessions"
+	config.Mongo.ConnectTimeout = 10 * time.Second
+
+	// Network Log Export defaults
+	config.NetworkLogs.HAREnabled = false
+	config.NetworkLogs.IncludeBodies = false
+	config.NetworkLogs.MaxBodySize = 64 * 1024
+
+	// Artifact Collection defaults
+	config.Artifacts.WaitTimeout = 2 * time.Minute
+	config.Artifacts.StableFor = 1 * time.Second
+	config.Artifacts.PollInterval = 500 * time.Millisecond
+	config.Artifacts.LinkExpiry = 1 * time.Hour
+	config.Artifacts.TraceViewer = false
+
+	// Chunked Artifact Upload defaults
+	config.Uploads.Chunked = false
+	config.Uploads.ChunkSize = 8 * 1024 * 1024
+	config.Uploads.MaxAttempts = 5
+	config.Uploads.RetryBaseDelay = 1 * time.Second
+	config.Uploads.RetryMaxDelay = 30 * time.Second
+
+	// Artifact Storage defaults
+	config.ArtifactStorage.Backend = "passthrough"
+	config.ArtifactStorage.URLExpiry = 1 * time.Hour
+	config.ArtifactStorage.S3.Region = "us-east-1"
+	config.ArtifactStorage.S3.Bucket = "agent-session-recordings"
+
+	// Visual Regression defaults
+	config.Visual.Enabled = false
+	config.Visual.BaselineDir = "visual-baselines"
+	config.Visual.Mode = "pixel"
+	config.Visual.Threshold = 0.1
+	config.Visual.MaxDiffRatio = 0.001
+	config.Visual.AutoApproveNew = true
 
 	// HTTP defaults
 	config.HTTP.RequestTimeout = 30 * time.Second
@@ -242,6 +486,9 @@ func (cm *ConfigManager) Update(newConfig *DynamicConfig) error {
 // validate validates configuration values
 func (cm *ConfigManager) validate(config *DynamicConfig) error {
 	// Browser Pool validation
+	if config.BrowserPool.Backend != "docker" && config.BrowserPool.Backend != "playwright" {
+		return fmt.Errorf("browser_pool.backend must be docker or playwright")
+	}
 	if config.BrowserPool.MaxSize <= 0 {
 		return fmt.Errorf("browser_pool.max_size must be positive")
 	}
@@ -251,6 +498,38 @@ func (cm *ConfigManager) validate(config *DynamicConfig) error {
 	if config.BrowserPool.HealthCheckInterval < time.Second {
 		return fmt.Errorf("browser_pool.health_check_interval too short")
 	}
+	subPools := make(map[string]bool)
+	var minTotal, prewarmTotal int
+	for i, pool := range config.BrowserPool.Pools {
+		if pool.Browser == "" {
+			return fmt.Errorf("browser_pool.pools[%d].browser is required", i)
+		}
+		maxSize := pool.MaxSize
+		if maxSize == 0 {
+			maxSize = config.BrowserPool.MaxSize
+		}
+		if pool.MinSize < 0 || pool.PrewarmSize < 0 || maxSize < 0 || maxSize > config.BrowserPool.MaxSize {
+			return fmt.Errorf("browser_pool.pools[%d] sizes must be between 0 and browser_pool.max_size", i)
+		}
+		if pool.MinSize > maxSize || pool.PrewarmSize > maxSize {
+			return fmt.Errorf("browser_pool.pools[%d] min_size and prewarm_size cannot exceed max_size", i)
+		}
+		version := pool.Version
+		if version == "" {
+			version = "latest"
+		}
+		key := strings.ToLower(pool.Browser) + ":" + version
+		if subPools[key] {
+			return fmt.Errorf("browser_pool.pools lists %s twice", key)
+		}
+		subPools[key] = true
+		minTotal += pool.MinSize
+		prewarmTotal += pool.PrewarmSize
+	}
+	// nkk: Sub-pools share max_size, more than it would keep one key's warm containers in the way of the others
+	if minTotal > config.BrowserPool.MaxSize || prewarmTotal > config.BrowserPool.MaxSize {
+		return fmt.Errorf("browser_pool.pools min_size and prewarm_size cannot add up to more than browser_pool.max_size")
+	}
 
 	// Test Execution validation
 	if config.TestExecution.QueueSize <= 0 {
@@ -263,6 +542,185 @@ func (cm *ConfigManager) validate(config *DynamicConfig) error {
 		return fmt.Errorf("test_execution.parallelism_max must be positive")
 	}
 
+	// Docker Runner validation
+	if config.DockerRunner.Enabled {
+		if config.DockerRunner.Image == "" {
+			return fmt.Errorf("docker_runner.image cannot be empty")
+		}
+		if config.DockerRunner.WorkspaceDir == "" || config.DockerRunner.ContainerDir == "" {
+			return fmt.Errorf("docker_runner.workspace_dir and container_dir cannot be empty")
+		}
+		if config.DockerRunner.CPUs < 0 {
+			return fmt.Errorf("docker_runner.cpus cannot be negative")
+		}
+		if config.DockerRunner.MemoryMB < 0 {
+			return fmt.Errorf("docker_runner.memory_mb cannot be negative")
+		}
+	}
+
+	// Playwright Runtime validation
+	if config.Playwright.RuntimesDir == "" {
+		return fmt.Errorf("playwright.runtimes_dir cannot be empty")
+	}
+	if config.Playwright.DefaultVersion == "" {
+		return fmt.Errorf("playwright.default_version cannot be empty")
+	}
+	if config.Playwright.GCInterval < time.Minute {
+		return fmt.Errorf("playwright.gc_interval too short")
+	}
+
+	// Self-Update validation
+	if config.Update.Enabled {
+		if config.Update.Source == "" {
+			return fmt.Errorf("update.source cannot be empty")
+		}
+		if config.Update.PublicKey == "" {
+			return fmt.Errorf("update.public_key cannot be empty")
+		}
+		if config.Update.CheckInterval < time.Minute {
+			return fmt.Errorf("update.check_interval too short")
+		}
+	}
+
+	// Execution Service Outbox validation
+	if config.Outbox.MaxAttempts < 0 {
+		return fmt.Errorf("outbox.max_attempts cannot be negative")
+	}
+	if config.Outbox.RetryBaseDelay <= 0 {
+		return fmt.Errorf("outbox.retry_base_delay must be positive")
+	}
+	if config.Outbox.RetryMaxDelay < config.Outbox.RetryBaseDelay {
+		return fmt.Errorf("outbox.retry_max_delay cannot be shorter than retry_base_delay")
+	}
+
+	// Session Status Coalescing validation
+	if config.StatusCoalescing.Enabled {
+		if config.StatusCoalescing.Window <= 0 {
+			return fmt.Errorf("status_coalescing.window must be positive")
+		}
+		if config.StatusCoalescing.MaxBatch <= 0 {
+			return fmt.Errorf("status_coalescing.max_batch must be positive")
+		}
+	}
+
+	// Kafka Events validation
+	if config.Kafka.Enabled {
+		if len(config.Kafka.Brokers) == 0 {
+			return fmt.Errorf("kafka.brokers cannot be empty")
+		}
+		if config.Kafka.Topic == "" {
+			return fmt.Errorf("kafka.topic cannot be empty")
+		}
+		if config.Kafka.Delivery != "at-least-once" && config.Kafka.Delivery != "at-most-once" {
+			return fmt.Errorf("kafka.delivery must be at-least-once or at-most-once")
+		}
+		if config.Kafka.MaxAttempts < 0 {
+			return fmt.Errorf("kafka.max_attempts cannot be negative")
+		}
+		if config.Kafka.QueueSize <= 0 {
+			return fmt.Errorf("kafka.queue_size must be positive")
+		}
+		if config.Kafka.WriteTimeout <= 0 {
+			return fmt.Errorf("kafka.write_timeout must be positive")
+		}
+	}
+
+	// MongoDB Session Sink validation
+	if config.Mongo.URI != "" {
+		if config.Mongo.Database == "" {
+			return fmt.Errorf("mongo.database cannot be empty")
+		}
+		if config.Mongo.Collection == "" {
+			return fmt.Errorf("mongo.collection cannot be empty")
+		}
+		if config.Mongo.ConnectTimeout <= 0 {
+			return fmt.Errorf("mongo.connect_timeout must be positive")
+		}
+	}
+
+	// Network Log Export validation
+	if config.NetworkLogs.MaxBodySize < 0 {
+		return fmt.Errorf("network_logs.max_body_size cannot be negative")
+	}
+	for _, pattern := range append(append([]string{}, config.NetworkLogs.IncludeURLs...), config.NetworkLogs.ExcludeURLs...) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("network_logs URL pattern %q is invalid: %w", pattern, err)
+		}
+	}
+
+	// Artifact Collection validation
+	if config.Artifacts.WaitTimeout <= 0 {
+		return fmt.Errorf("artifacts.wait_timeout must be positive")
+	}
+	if config.Artifacts.StableFor <= 0 {
+		return fmt.Errorf("artifacts.stable_for must be positive")
+	}
+	if config.Artifacts.PollInterval <= 0 {
+		return fmt.Errorf("artifacts.poll_interval must be positive")
+	}
+	if config.Artifacts.LinkExpiry <= 0 {
+		return fmt.Errorf("artifacts.link_expiry must be positive")
+	}
+
+	// Chunked Artifact Upload validation
+	if config.Uploads.Chunked {
+		if config.Uploads.ChunkSize < 64*1024 {
+			return fmt.Errorf("uploads.chunk_size must be at least 64KB")
+		}
+		if config.Uploads.MaxAttempts <= 0 {
+			return fmt.Errorf("uploads.max_attempts must be positive")
+		}
+		if config.Uploads.RetryBaseDelay <= 0 {
+			return fmt.Errorf("uploads.retry_base_delay must be positive")
+		}
+		if config.Uploads.RetryMaxDelay < config.Uploads.RetryBaseDelay {
+			return fmt.Errorf("uploads.retry_max_delay must be at least uploads.retry_base_delay")
+		}
+	}
+
+	// Artifact Storage validation
+	backends := map[string]bool{config.ArtifactStorage.Backend: true}
+	for orgId, backend := range config.ArtifactStorage.Tenants {
+		backends[backend] = true
+		if orgId == "" {
+			return fmt.Errorf("artifact_storage.tenants cannot have an empty org ID")
+		}
+	}
+	for backend := range backends {
+		switch backend {
+		case "passthrough":
+		case "s3":
+			if config.ArtifactStorage.S3.Bucket == "" {
+				return fmt.Errorf("artifact_storage.s3.bucket is required for the s3 backend")
+			}
+		case "local":
+			if config.ArtifactStorage.Local.Dir == "" {
+				return fmt.Errorf("artifact_storage.local.dir is required for the local backend")
+			}
+		default:
+			return fmt.Errorf("artifact_storage backend must be passthrough, s3 or local, got %q", backend)
+		}
+	}
+	if config.ArtifactStorage.URLExpiry <= 0 {
+		return fmt.Errorf("artifact_storage.url_expiry must be positive")
+	}
+
+	// Visual Regression validation
+	if config.Visual.Enabled {
+		if config.Visual.BaselineDir == "" {
+			return fmt.Errorf("visual.baseline_dir is required")
+		}
+		if config.Visual.Mode != "pixel" && config.Visual.Mode != "perceptual" {
+			return fmt.Errorf("visual.mode must be pixel or perceptual")
+		}
+		if config.Visual.Threshold < 0 || config.Visual.Threshold > 1 {
+			return fmt.Errorf("visual.threshold must be between 0 and 1")
+		}
+		if config.Visual.MaxDiffRatio < 0 || config.Visual.MaxDiffRatio > 1 {
+			return fmt.Errorf("visual.max_diff_ratio must be between 0 and 1")
+		}
+	}
+
 	// HTTP validation
 	if config.HTTP.MaxIdleConns <= 0 {
 		return fmt.Errorf("http.max_idle_conns must be positive")
@@ -341,18 +799,31 @@ func (cm *ConfigManager) StopWatching() {
 }
 
 // UpdateFromEnvironment updates configuration from environment variables
-func (cm *ConfigManager) UpdateFromEnvironment() {
-	// nkk: In production, implement environment variable parsing
-	// For now, we use defaults
+func (cm *ConfigManager) UpdateFromEnvironment() error {
+	k := koanf.New(".")
+	if err := k.Load(env.Provider(EnvPrefix, ".", envKey), nil); err != nil {
+		return err
+	}
+	if err := cm.apply(k); err != nil {
+		return err
+	}
 	logger.Info("Configuration updated from environment variables")
+	return nil
 }
 
 // LoadFromFile loads configuration from a file
 func (cm *ConfigManager) LoadFromFile(filename string) error {
-	// nkk: In production, implement file-based configuration loading
-	// This could support JSON, YAML, TOML formats
 	logger.Info("Loading configuration from file", zap.String("file", filename))
-	return nil
+
+	parser, err := parserFor(filename)
+	if err != nil {
+		return err
+	}
+	k := koanf.New(".")
+	if err := k.Load(file.Provider(filename), parser); err != nil {
+		return err
+	}
+	return cm.apply(k)
 }
 
 // SaveToFile saves current configuration to a file
@@ -414,6 +885,7 @@ type ConfigKey string
 
 const (
 	// Browser Pool configuration keys
+	BrowserPoolBackend             ConfigKey = "browser_pool.backend"
 	BrowserPoolMaxSize             ConfigKey = "browser_pool.max_size"
 	BrowserPoolPrewarmSize         ConfigKey = "browser_pool.prewarm_size"
 	BrowserPoolHealthCheckInterval ConfigKey = "browser_pool.health_check_interval"
@@ -421,12 +893,99 @@ const (
 	BrowserPoolAcquisitionTimeout  ConfigKey = "browser_pool.acquisition_timeout"
 
 	// Test Execution configuration keys
-	TestExecutionQueueSize      ConfigKey = "test_execution.queue_size"
-	TestExecutionTimeoutDefault ConfigKey = "test_execution.timeout_default"
-	TestExecutionTimeoutMax     ConfigKey = "test_execution.timeout_max"
-	TestExecutionParallelismMax ConfigKey = "test_execution.parallelism_max"
-	TestExecutionRetryAttempts  ConfigKey = "test_execution.retry_attempts"
-	TestExecutionRetryDelay     ConfigKey = "test_execution.retry_delay"
+	TestExecutionQueueSize          ConfigKey = "test_execution.queue_size"
+	TestExecutionTimeoutDefault     ConfigKey = "test_execution.timeout_default"
+	TestExecutionTimeoutMax         ConfigKey = "test_execution.timeout_max"
+	TestExecutionParallelismMax     ConfigKey = "test_execution.parallelism_max"
+	TestExecutionRetryAttempts      ConfigKey = "test_execution.retry_attempts"
+	TestExecutionRetryDelay         ConfigKey = "test_execution.retry_delay"
+	TestExecutionStepUpdateInterval ConfigKey = "test_execution.step_update_interval"
+
+	// Docker Runner configuration keys
+	DockerRunnerEnabled     ConfigKey = "docker_runner.enabled"
+	DockerRunnerImage       ConfigKey = "docker_runner.image"
+	DockerRunnerCPUs        ConfigKey = "docker_runner.cpus"
+	DockerRunnerMemoryMB    ConfigKey = "docker_runner.memory_mb"
+	DockerRunnerNetworkMode ConfigKey = "docker_runner.network_mode"
+
+	// Playwright Runtime configuration keys
+	PlaywrightRuntimesDir    ConfigKey = "playwright.runtimes_dir"
+	PlaywrightDefaultVersion ConfigKey = "playwright.default_version"
+	PlaywrightGCInterval     ConfigKey = "playwright.gc_interval"
+	PlaywrightMaxIdle        ConfigKey = "playwright.max_idle"
+	PlaywrightOffline        ConfigKey = "playwright.offline"
+
+	// Self-Update configuration keys
+	UpdateEnabled       ConfigKey = "update.enabled"
+	UpdateSource        ConfigKey = "update.source"
+	UpdateCheckInterval ConfigKey = "update.check_interval"
+
+	// Allure Results configuration keys
+	AllureEnabled ConfigKey = "allure.enabled"
+
+	// Execution Service Outbox configuration keys
+	OutboxDir            ConfigKey = "outbox.dir"
+	OutboxMaxAttempts    ConfigKey = "outbox.max_attempts"
+	OutboxRetryBaseDelay ConfigKey = "outbox.retry_base_delay"
+	OutboxRetryMaxDelay  ConfigKey = "outbox.retry_max_delay"
+
+	// Session Status Coalescing configuration keys
+	StatusCoalescingEnabled  ConfigKey = "status_coalescing.enabled"
+	StatusCoalescingWindow   ConfigKey = "status_coalescing.window"
+	StatusCoalescingMaxBatch ConfigKey = "status_coalescing.max_batch"
+
+	// Kafka Events configuration keys
+	KafkaEnabled      ConfigKey = "kafka.enabled"
+	KafkaBrokers      ConfigKey = "kafka.brokers"
+	KafkaTopic        ConfigKey = "kafka.topic"
+	KafkaDelivery     ConfigKey = "kafka.delivery"
+	KafkaMaxAttempts  ConfigKey = "kafka.max_attempts"
+	KafkaQueueSize    ConfigKey = "kafka.queue_size"
+	KafkaBatchTimeout ConfigKey = "kafka.batch_timeout"
+	KafkaWriteTimeout ConfigKey = "kafka.write_timeout"
+
+	// MongoDB Session Sink configuration keys
+	MongoURI            ConfigKey = "mongo.uri"
+	MongoDatabase       ConfigKey = "mongo.database"
+	MongoCollection     ConfigKey = "mongo.collection"
+	MongoConnectTimeout ConfigKey = "mongo.connect_timeout"
+
+	// Network Log Export configuration keys
+	NetworkLogsHAREnabled    ConfigKey = "network_logs.har_enabled"
+	NetworkLogsIncludeBodies ConfigKey = "network_logs.include_bodies"
+	NetworkLogsMaxBodySize   ConfigKey = "network_logs.max_body_size"
+	NetworkLogsStatusRanges  ConfigKey = "network_logs.status_ranges"
+	NetworkLogsResourceTypes ConfigKey = "network_logs.resource_types"
+	NetworkLogsIncludeURLs   ConfigKey = "network_logs.include_urls"
+	NetworkLogsExcludeURLs   ConfigKey = "network_logs.exclude_urls"
+
+	// Artifact Collection configuration keys
+	ArtifactsWaitTimeout  ConfigKey = "artifacts.wait_timeout"
+	ArtifactsStableFor    ConfigKey = "artifacts.stable_for"
+	ArtifactsPollInterval ConfigKey = "artifacts.poll_interval"
+	ArtifactsLinkExpiry   ConfigKey = "artifacts.link_expiry"
+	ArtifactsTraceViewer  ConfigKey = "artifacts.trace_viewer"
+
+	// Chunked Artifact Upload configuration keys
+	UploadsChunked        ConfigKey = "uploads.chunked"
+	UploadsChunkSize      ConfigKey = "uploads.chunk_size"
+	UploadsMaxAttempts    ConfigKey = "uploads.max_attempts"
+	UploadsRetryBaseDelay ConfigKey = "uploads.retry_base_delay"
+	UploadsRetryMaxDelay  ConfigKey = "uploads.retry_max_delay"
+
+	// Artifact Storage configuration keys
+	ArtifactStorageBackend   ConfigKey = "artifact_storage.backend"
+	ArtifactStorageURLExpiry ConfigKey = "artifact_storage.url_expiry"
+	ArtifactStorageTenants   ConfigKey = "artifact_storage.tenants"
+	ArtifactStorageS3Bucket  ConfigKey = "artifact_storage.s3.bucket"
+
+	// Visual Regression configuration keys
+	VisualEnabled        ConfigKey = "visual.enabled"
+	VisualBaselineDir    ConfigKey = "visual.baseline_dir"
+	VisualMode           ConfigKey = "visual.mode"
+	VisualThreshold      ConfigKey = "visual.threshold"
+	VisualMaxDiffRatio   ConfigKey = "visual.max_diff_ratio"
+	VisualAutoApproveNew ConfigKey = "visual.auto_approve_new"
 
 	// HTTP configuration keys
 	HTTPRequestTimeout      ConfigKey = "http.request_timeout"
@@ -451,6 +1010,8 @@ func GetConfigValue(key ConfigKey) interface{} {
 	config := GetConfig()
 
 	switch key {
+	case BrowserPoolBackend:
+		return config.BrowserPool.Backend
 	case BrowserPoolMaxSize:
 		return config.BrowserPool.MaxSize
 	case BrowserPoolPrewarmSize:
@@ -474,6 +1035,141 @@ func GetConfigValue(key ConfigKey) interface{} {
 		return config.TestExecution.RetryAttempts
 	case TestExecutionRetryDelay:
 		return config.TestExecution.RetryDelay
+	case TestExecutionStepUpdateInterval:
+		return config.TestExecution.StepUpdateInterval
+
+	case DockerRunnerEnabled:
+		return config.DockerRunner.Enabled
+	case DockerRunnerImage:
+		return config.DockerRunner.Image
+	case DockerRunnerCPUs:
+		return config.DockerRunner.CPUs
+	case DockerRunnerMemoryMB:
+		return config.DockerRunner.MemoryMB
+	case DockerRunnerNetworkMode:
+		return config.DockerRunner.NetworkMode
+
+	case PlaywrightRuntimesDir:
+		return config.Playwright.RuntimesDir
+	case PlaywrightDefaultVersion:
+		return config.Playwright.DefaultVersion
+	case PlaywrightGCInterval:
+		return config.Playwright.GCInterval
+	case PlaywrightMaxIdle:
+		return config.Playwright.MaxIdle
+	case PlaywrightOffline:
+		return config.Playwright.Offline
+
+	case UpdateEnabled:
+		return config.Update.Enabled
+	case UpdateSource:
+		return config.Update.Source
+	case UpdateCheckInterval:
+		return config.Update.CheckInterval
+
+	case AllureEnabled:
+		return config.Allure.Enabled
+
+	case OutboxDir:
+		return config.Outbox.Dir
+	case OutboxMaxAttempts:
+		return config.Outbox.MaxAttempts
+	case OutboxRetryBaseDelay:
+		return config.Outbox.RetryBaseDelay
+	case OutboxRetryMaxDelay:
+		return config.Outbox.RetryMaxDelay
+
+	case StatusCoalescingEnabled:
+		return config.StatusCoalescing.Enabled
+	case StatusCoalescingWindow:
+		return config.StatusCoalescing.Window
+	case StatusCoalescingMaxBatch:
+		return config.StatusCoalescing.MaxBatch
+
+	case KafkaEnabled:
+		return config.Kafka.Enabled
+	case KafkaBrokers:
+		return config.Kafka.Brokers
+	case KafkaTopic:
+		return config.Kafka.Topic
+	case KafkaDelivery:
+		return config.Kafka.Delivery
+	case KafkaMaxAttempts:
+		return config.Kafka.MaxAttempts
+	case KafkaQueueSize:
+		return config.Kafka.QueueSize
+	case KafkaBatchTimeout:
+		return config.Kafka.BatchTimeout
+	case KafkaWriteTimeout:
+		return config.Kafka.WriteTimeout
+
+	case MongoURI:
+		return config.Mongo.URI
+	case MongoDatabase:
+		return config.Mongo.Database
+	case MongoCollection:
+		return config.Mongo.Collection
+	case MongoConnectTimeout:
+		return config.Mongo.ConnectTimeout
+
+	case NetworkLogsHAREnabled:
+		return config.NetworkLogs.HAREnabled
+	case NetworkLogsIncludeBodies:
+		return config.NetworkLogs.IncludeBodies
+	case NetworkLogsMaxBodySize:
+		return config.NetworkLogs.MaxBodySize
+	case NetworkLogsStatusRanges:
+		return config.NetworkLogs.StatusRanges
+	case NetworkLogsResourceTypes:
+		return config.NetworkLogs.ResourceTypes
+	case NetworkLogsIncludeURLs:
+		return config.NetworkLogs.IncludeURLs
+	case NetworkLogsExcludeURLs:
+		return config.NetworkLogs.ExcludeURLs
+
+	case ArtifactsWaitTimeout:
+		return config.Artifacts.WaitTimeout
+	case ArtifactsStableFor:
+		return config.Artifacts.StableFor
+	case ArtifactsPollInterval:
+		return config.Artifacts.PollInterval
+	case ArtifactsLinkExpiry:
+		return config.Artifacts.LinkExpiry
+	case ArtifactsTraceViewer:
+		return config.Artifacts.TraceViewer
+
+	case UploadsChunked:
+		return config.Uploads.Chunked
+	case UploadsChunkSize:
+		return config.Uploads.ChunkSize
+	case UploadsMaxAttempts:
+		return config.Uploads.MaxAttempts
+	case UploadsRetryBaseDelay:
+		return config.Uploads.RetryBaseDelay
+	case UploadsRetryMaxDelay:
+		return config.Uploads.RetryMaxDelay
+
+	case ArtifactStorageBackend:
+		return config.ArtifactStorage.Backend
+	case ArtifactStorageURLExpiry:
+		return config.ArtifactStorage.URLExpiry
+	case ArtifactStorageTenants:
+		return config.ArtifactStorage.Tenants
+	case ArtifactStorageS3Bucket:
+		return config.ArtifactStorage.S3.Bucket
+
+	case VisualEnabled:
+		return config.Visual.Enabled
+	case VisualBaselineDir:
+		return config.Visual.BaselineDir
+	case VisualMode:
+		return config.Visual.Mode
+	case VisualThreshold:
+		return config.Visual.Threshold
+	case VisualMaxDiffRatio:
+		return config.Visual.MaxDiffRatio
+	case VisualAutoApproveNew:
+		return config.Visual.AutoApproveNew
 
 	case HTTPRequestTimeout:
 		return config.HTTP.RequestTimeout
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/parsers/json"
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/env"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/providers/rawbytes"
+	"go.uber.org/zap"
+
+	"agent/logger"
+)
+
+/*
+nkk: Layered configuration loading
+DefaultConfig -> config file (yaml/json) -> AGENT_* environment variables.
+The same file holds the ApxConfig keys and the DynamicConfig sections
+(browser_pool, playwright, update, ...), their keys do not overlap.
+Nested keys use a double underscore in env vars:
+  AGENT_SERVER_DOMAIN=...             -> server_domain
+  AGENT_PLAYWRIGHT__DEFAULT_VERSION=  -> playwright.default_version
+*/
+
+// EnvPrefix is the prefix of environment variable overrides
+const EnvPrefix = "AGENT_"
+
+// Load reads the layered configuration, applies the dynamic sections and returns the validated ApxConfig
+func Load(path string) (*ApxConfig, error) {
+	k, err := newKoanf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var apxConfig ApxConfig
+	if err := k.Unmarshal("", &apxConfig); err != nil {
+		return nil, fmt.Errorf("decoding agent config: %w", err)
+	}
+	if err := apxConfig.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := GetConfigManager().apply(k); err != nil {
+		return nil, err
+	}
+	return &apxConfig, nil
+}
+
+// newKoanf stacks the defaults, the optional file and the environment
+func newKoanf(path string) (*koanf.Koanf, error) {
+	k := koanf.New(".")
+
+	if err := k.Load(rawbytes.Provider(DefaultConfig), yaml.Parser()); err != nil {
+		return nil, fmt.Errorf("loading default config: %w", err)
+	}
+
+	if path != "" {
+		parser, err := parserFor(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := k.Load(file.Provider(path), parser); err != nil {
+			return nil, fmt.Errorf("loading config file %s: %w", path, err)
+		}
+		logger.Info("Loaded configuration file", zap.String("file", path))
+	}
+
+	if err := k.Load(env.Provider(EnvPrefix, ".", envKey), nil); err != nil {
+		return nil, fmt.Errorf("loading environment overrides: %w", err)
+	}
+	return k, nil
+}
+
+// parserFor picks the koanf parser from the file extension
+func parserFor(path string) (koanf.Parser, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Parser(), nil
+	case ".json":
+		return json.Parser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q", filepath.Ext(path))
+	}
+}
+
+// envKey maps AGENT_PLAYWRIGHT__DEFAULT_VERSION to playwright.default_version
+func envKey(s string) string {
+	key := strings.ToLower(strings.TrimPrefix(s, EnvPrefix))
+	return strings.ReplaceAll(key, "__", ".")
+}
+
+// apply decodes the dynamic sections over the current configuration
+func (cm *ConfigManager) apply(k *koanf.Koanf) error {
+	updated := cm.Get()
+	if err := k.UnmarshalWithConf("", updated, koanf.UnmarshalConf{Tag: "json"}); err != nil {
+		return fmt.Errorf("decoding dynamic config: %w", err)
+	}
+	return cm.Update(updated)
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+nkk: Unit tests for layered config loading
+*/
+
+func TestLoadLayersFileAndEnv(t *testing.T) {
+	previous := GetConfig()
+	t.Cleanup(func() { UpdateConfig(previous) })
+
+	path := filepath.Join(t.TempDir(), "agent.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+listen: ":6000"
+server_domain: "http://server.test"
+playwright:
+  default_version: "1.48.0"
+  gc_interval: "2h"
+`), 0644))
+
+	t.Setenv("AGENT_LISTEN", ":7000")
+	t.Setenv("AGENT_PLAYWRIGHT__RUNTIMES_DIR", "/opt/runtimes")
+
+	apxConfig, err := Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, ":7000", apxConfig.Listen)
+	assert.Equal(t, "http://server.test", apxConfig.ServerDomain)
+	assert.Equal(t, "/agent", apxConfig.Prefix)
+
+	dynamic := GetConfig()
+	assert.Equal(t, "1.48.0", dynamic.Playwright.DefaultVersion)
+	assert.Equal(t, 2*time.Hour, dynamic.Playwright.GCInterval)
+	assert.Equal(t, "/opt/runtimes", dynamic.Playwright.RuntimesDir)
+	assert.Equal(t, 50, dynamic.BrowserPool.MaxSize)
+	assert.Equal(t, "docker", dynamic.BrowserPool.Backend)
+}
+
+func TestLoadSelectsBrowserPoolBackend(t *testing.T) {
+	previous := GetConfig()
+	t.Cleanup(func() { UpdateConfig(previous) })
+
+	t.Setenv("AGENT_BROWSER_POOL__BACKEND", "playwright")
+	_, err := Load("")
+	require.NoError(t, err)
+	assert.Equal(t, "playwright", GetConfig().BrowserPool.Backend)
+
+	t.Setenv("AGENT_BROWSER_POOL__BACKEND", "selenium-grid")
+	_, err = Load("")
+	assert.Error(t, err)
+}
+
+func TestLoadBrowserSubPools(t *testing.T) {
+	previous := GetConfig()
+	t.Cleanup(func() { UpdateConfig(previous) })
+
+	path := filepath.Join(t.TempDir(), "agent.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+browser_pool:
+  max_size: 10
+  pools:
+    - browser: chrome
+      version: latest
+      min_size: 1
+      max_size: 6
+      prewarm_size: 3
+    - browser: firefox
+      prewarm_size: 1
+`), 0644))
+	_, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, []BrowserSubPoolConfig{
+		{Browser: "chrome", Version: "latest", MinSize: 1, MaxSize: 6, PrewarmSize: 3},
+		{Browser: "firefox", PrewarmSize: 1},
+	}, GetConfig().BrowserPool.Pools)
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+browser_pool:
+  max_size: 10
+  pools:
+    - browser: chrome
+      max_size: 2
+      prewarm_size: 3
+`), 0644))
+	_, err = Load(path)
+	assert.Error(t, err, "prewarm_size above the sub-pool's max_size")
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+browser_pool:
+  pools:
+    - browser: chrome
+    - browser: Chrome
+      version: latest
+`), 0644))
+	_, err = Load(path)
+	assert.Error(t, err, "the same sub-pool twice")
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+browser_pool:
+  max_size: 5
+  pools:
+    - browser: chrome
+      prewarm_size: 5
+    - browser: firefox
+      prewarm_size: 1
+`), 0644))
+	_, err = Load(path)
+	assert.Error(t, err, "pre-warm sizes adding up to more than max_size")
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+browser_pool:
+  max_size: 5
+  pools:
+    - browser: chrome
+      min_size: 3
+    - browser: firefox
+      min_size: 3
+`), 0644))
+	_, err = Load(path)
+	assert.Error(t, err, "min sizes adding up to more than max_size")
+}
+
+func TestLoadRejectsUnknownFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.ini")
+	require.NoError(t, os.WriteFile(path, []byte("listen=:6000"), 0644))
+
+	_, err := Load(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,5 @@
+package config
+
+// Version is the agent release, stamped at build time with
+// -ldflags "-X agent/config.Version=<version>"
+var Version = "dev"
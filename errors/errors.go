@@ -49,6 +49,7 @@ const (
 	NotFound                      // Entity does not exist
 	Unauthorized                  // Unauthorized access
 	Forbidden                     // Forbidden access
+	Unavailable                   // Dependency temporarily unavailable, worth retrying
 )
 
 func (k Kind) String() string {
@@ -63,6 +64,8 @@ func (k Kind) String() string {
 		return "entity not found"
 	case ExpectationFailed:
 		return "expectation failed"
+	case Unavailable:
+		return "service unavailable"
 	default:
 		return "unknown error kind"
 	}
@@ -58,6 +58,25 @@ type Config = {
   video_path: string;
 };
 
+type StepDetails = {
+  name?: string;
+  action?: string;
+  selector?: string;
+  status?: string;
+  screenshot?: string;
+  error?: string;
+};
+
+type ConsoleEntry = {
+  time: string;
+  type: string;
+  text: string;
+  url: string;
+  line: number;
+  column: number;
+  uncaught: boolean;
+};
+
 class Utils {
   public port = machineConfig.listen;
   private basePath = "agent";
@@ -79,8 +98,18 @@ class Utils {
   };
   public edcSubjectDetails?: EDCDetails;
   public formsReset: string[] = [];
+  private lastStepAt = Date.now();
+  private lastAction?: { action: string; selector?: string };
+  private lastScreenshot?: string;
+  private consoleEntries: ConsoleEntry[] = [];
+
+  // Remembered so a bare updateStepCount(config) still reports what the step did
+  private track(action: string, selector?: string) {
+    this.lastAction = { action, selector };
+  }
 
   public async goto(page: Page, url?: string) {
+    this.track("goto", url);
     if (!url) {
       throw new Error("Cannot navigate as url is empty");
     }
@@ -356,6 +385,7 @@ class Utils {
       screenshotPath += `/${config.testcase_id}`;
     }
     screenshotPath += "/screenshot-" + timeStamp + ".png";
+    this.lastScreenshot = screenshotPath;
     try {
       if (!page.isClosed()) {
         const buffer = await page.screenshot();
@@ -381,22 +411,127 @@ class Utils {
     return screenshotPath;
   }
 
-  public async updateStepCount(config: Config) {
-    config.step_count = config.step_count + 1;
-    const url = `${this.baseUrl}/organisations/${config.org_id}/projects/${config.project_id}/apps/${config.app_id}/${this.testlab}/${config.execution_id}/update-stepcount`;
-
-    console.log(`${this.machineId} step_count ${config.step_count}`);
-    return fetch(url, {
-      method: "PUT",
+  // Compares the page against its approved baseline, a diff above the threshold fails the step
+  public async matchScreenshot(
+    page: Page,
+    config: Config,
+    name: string,
+    options: {
+      threshold?: number;
+      max_diff_ratio?: number;
+      mode?: "pixel" | "perceptual";
+      ignore_regions?: { x: number; y: number; width: number; height: number }[];
+    } = {}
+  ) {
+    this.track("matchScreenshot", name);
+    const buffer = await page.screenshot();
+    const viewport = page.viewportSize() ?? { width: 0, height: 0 };
+    const data = {
+      ...options,
+      testcase_id: config.testcase_id,
+      browser: page.context().browser()?.browserType().name() ?? "chromium",
+      resolution: `${viewport.width}x${viewport.height}`,
+      name: name,
+      screenshot: buffer.toString("base64"),
+    };
+    const url = `${this.baseUrl}/organisations/${config.org_id}/projects/${config.project_id}/apps/${config.app_id}/${config.testlab}/${config.execution_id}/visual-check`;
+    const res = await fetch(url, {
+      method: "POST",
       headers: {
         "Content-Type": "application/json",
       },
-      body: JSON.stringify({
-        ...config,
-        step_count: `${config.step_count}`,
-        machine_id: this.machineId,
-      }),
+      body: JSON.stringify(data),
     });
+    const result: any = await res.json();
+    if (res.status != 200) {
+      throw new Error(`visual check ${name} failed: ${JSON.stringify(result)}`);
+    }
+    if (result.status == "failed") {
+      const error = `screenshot ${name} differs from its baseline: ${(result.diff_ratio * 100).toFixed(2)}% of pixels changed`;
+      // saveStatus reports the failed step with this error
+      throw new Error(error);
+    }
+    return result;
+  }
+
+  // The agent reads the APX_STEP line from stdout and reports the step count itself
+  public async updateStepCount(config: Config, step: StepDetails = {}) {
+    config.step_count = config.step_count + 1;
+    console.log(`${this.machineId} step_count ${config.step_count}`);
+    this.logStep(config, config.step_count, step);
+  }
+
+  public logStep(config: Config, index: number, step: StepDetails = {}) {
+    const now = Date.now();
+    const record = {
+      execution_id: config.execution_id,
+      testcase_id: config.testcase_id,
+      testsuite_id: config.testsuite_id,
+      testplan_id: config.testplan_id,
+      machine_id: this.machineId,
+      is_adhoc: config.is_adhoc,
+      is_prerequisite: config.is_prerequisite,
+      parent_testcase_id: config.parent_testcase_id,
+      index: index,
+      name: step.name ?? "",
+      action: step.action ?? this.lastAction?.action ?? "",
+      selector: step.selector ?? this.lastAction?.selector ?? "",
+      status: step.status ?? "passed",
+      started_at: new Date(this.lastStepAt).toISOString(),
+      duration_ms: now - this.lastStepAt,
+      screenshot: step.screenshot ?? this.lastScreenshot ?? "",
+      error: step.error ?? "",
+    };
+    this.lastStepAt = now;
+    this.lastAction = undefined;
+    this.lastScreenshot = undefined;
+    console.log(`APX_STEP ${JSON.stringify(record)}`);
+  }
+
+  // Page errors cover uncaught exceptions and unhandled rejections, the agent filters by the console log level
+  public captureConsole(page: Page) {
+    page.on("console", (msg) => {
+      const location = msg.location();
+      this.consoleEntries.push({
+        time: new Date().toISOString(),
+        type: msg.type(),
+        text: msg.text(),
+        url: location.url,
+        line: location.lineNumber,
+        column: location.columnNumber,
+        uncaught: false,
+      });
+    });
+    page.on("pageerror", (error) => {
+      // The first stack frame below the message is where the error was thrown
+      const frame = /\(?((?:https?|file):\/\/[^\s)]+):(\d+):(\d+)\)?/.exec(error.stack ?? "");
+      this.consoleEntries.push({
+        time: new Date().toISOString(),
+        type: "pageerror",
+        text: error.message,
+        url: frame ? frame[1] : page.url(),
+        line: frame ? parseInt(frame[2]) : 0,
+        column: frame ? parseInt(frame[3]) : 0,
+        uncaught: true,
+      });
+    });
+  }
+
+  // The agent reads the APX_CONSOLE lines from stdout, stores them and summarizes them into the session logs
+  public logConsole(config: Config) {
+    for (const entry of this.consoleEntries) {
+      const record = {
+        execution_id: config.execution_id,
+        testcase_id: config.testcase_id,
+        testsuite_id: config.testsuite_id,
+        testplan_id: config.testplan_id,
+        machine_id: this.machineId,
+        is_prerequisite: config.is_prerequisite,
+        ...entry,
+      };
+      console.log(`APX_CONSOLE ${JSON.stringify(record)}`);
+    }
+    this.consoleEntries = [];
   }
 
   public async postSessionDetails(page: Page, config: Config) {
@@ -405,6 +540,7 @@ class Utils {
     );
     config.testlab = this.testlab;
     this.config = config;
+    this.lastStepAt = Date.now();
 
     let resp: any = {};
 
@@ -635,6 +771,7 @@ class Utils {
   }
 
   public async clickSubmitButton(page: Page, xpath: string) {
+    this.track("submit", xpath);
     if (this.config.source === "EDC") {
       // await this.edc.blurAllElements(page, ".rowCtrlContainer");
       // await page.waitForTimeout(2000);
@@ -650,6 +787,7 @@ class Utils {
   }
 
   public async veevaClick(page: Page, xpath: string) {
+    this.track("click", xpath);
     //TODO
     if (!xpath) {
       console.log(`empty selector in veeva click`);
@@ -662,6 +800,7 @@ class Utils {
   }
 
   public async veevaClickRadio(page: Page, xpath: string) {
+    this.track("click", xpath);
     //TODO
     if (!xpath) {
       console.log(`empty selector in veeva click`);
@@ -696,6 +835,7 @@ class Utils {
   }
 
   public async veevaFill(page: Page, xpath: string, value: string) {
+    this.track("fill", xpath);
     //TODO
     if (!xpath) {
       console.log(`empty selector in veeva fill`);
@@ -775,6 +915,7 @@ class Utils {
     value: string,
     isPositive: boolean
   ) {
+    this.track("assert", xpath);
     //TODO
     if (!xpath) {
       console.log(`empty selector in veeva assert`);
@@ -1251,6 +1392,7 @@ export const test = base.extend<{
   utils: Utils;
   saveStatus: void;
   forEachTest: void;
+  captureConsole: void;
 }>({
   utils: async ({}, use, testInfo) => {
     const utilsObject = new Utils();
@@ -1272,6 +1414,10 @@ export const test = base.extend<{
         const config = utils.config;
         if (config) {
           config.status = "failed";
+          utils.logStep(config, config.step_count + 1, {
+            status: "failed",
+            error: testInfo.error?.message || "",
+          });
 
           console.log(
             `save status ${testInfo.testId} ${testInfo.project.name} ${testInfo.title} ${testInfo.status} ${testInfo.expectedStatus}`
@@ -1300,4 +1446,14 @@ export const test = base.extend<{
     },
     { auto: true },
   ],
+  captureConsole: [
+    async ({ page, utils }, use) => {
+      utils.captureConsole(page);
+      await use();
+      if (utils.config) {
+        utils.logConsole(utils.config);
+      }
+    },
+    { auto: true },
+  ],
 });
@@ -72,6 +72,10 @@ func (a *AgentHandler) GetAgentStatus(w http.ResponseWriter, r *http.Request) (r
 }
 
 func (a *AgentHandler) StartAgentHandler(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	// nkk: The result server of `agent run` mounts the routes without an execution service
+	if a.ExecutionService == nil {
+		return nil, http.StatusServiceUnavailable, errors.E(errors.Unavailable, "this agent does not poll for executions")
+	}
 	a.StartAgent(context.Background())
 	return map[string]interface{}{"message": "agent started"}, http.StatusOK, nil
 }
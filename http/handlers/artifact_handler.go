@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-chi/chi"
+
+	"agent/errors"
+	sessionartifacts "agent/services/session_artifacts"
+)
+
+// noTestcase stands in for the testcase id of a session without one in artifact links
+const noTestcase = "_"
+
+type ArtifactHandler struct {
+	Index          *sessionartifacts.Index
+	Signer         *sessionartifacts.Signer
+	TraceViewerDir string // nkk: empty when the trace viewer is not hosted
+}
+
+func NewArtifactHandler(index *sessionartifacts.Index, signer *sessionartifacts.Signer, traceViewerDir string) *ArtifactHandler {
+	return &ArtifactHandler{
+		Index:          index,
+		Signer:         signer,
+		TraceViewerDir: traceViewerDir,
+	}
+}
+
+// SessionArtifacts are the artifacts of one testcase run
+type SessionArtifacts struct {
+	TestcaseId string                      `json:"testcase_id"`
+	Artifacts  []sessionartifacts.Artifact `json:"artifacts"`
+}
+
+// List returns the artifacts of an execution's sessions with signed links, testcase_id narrows it to one session
+func (h *ArtifactHandler) List(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	executionId := chi.URLParam(r, "execution_id")
+	if executionId == "" {
+		return nil, http.StatusBadRequest, errors.EmptyParamErr("execution_id")
+	}
+	testcaseIds := []string{r.URL.Query().Get("testcase_id")}
+	if !r.URL.Query().Has("testcase_id") {
+		testcaseIds, err = h.Index.Testcases(executionId)
+		if err != nil {
+			return nil, http.StatusBadRequest, errors.E(errors.Invalid, err.Error(), err)
+		}
+	}
+
+	listPath := strings.TrimSuffix(r.URL.Path, "/")
+	sessions := make([]SessionArtifacts, 0, len(testcaseIds))
+	for _, testcaseId := range testcaseIds {
+		artifacts, err := h.Index.List(executionId, testcaseId)
+		if err != nil {
+			return nil, http.StatusInternalServerError, errors.E(errors.Internal, "failed to list artifacts", err)
+		}
+		segment := testcaseId
+		if segment == "" {
+			segment = noTestcase
+		}
+		h.signLinks(r, path.Join(listPath, segment), artifacts)
+		sessions = append(sessions, SessionArtifacts{TestcaseId: testcaseId, Artifacts: artifacts})
+	}
+	return sessions, http.StatusOK, nil
+}
+
+// signLinks fills in the signed download and trace viewer links of artifacts listed under dir
+func (h *ArtifactHandler) signLinks(r *http.Request, dir string, artifacts []sessionartifacts.Artifact) {
+	base := baseURL(r)
+	for i := range artifacts {
+		link := path.Join(dir, artifacts[i].Name)
+		artifacts[i].URL = base + (&url.URL{Path: link, RawQuery: h.Signer.Sign(link).Encode()}).String()
+		if artifacts[i].Kind == sessionartifacts.KindTrace && h.TraceViewerDir != "" {
+			artifacts[i].ViewerURL = base + traceViewerPath(r) + "index.html?trace=" + url.QueryEscape(artifacts[i].URL)
+		}
+	}
+}
+
+// SignedRequest reports whether r carries a valid signed link, RequireAuth lets those through
+func (h *ArtifactHandler) SignedRequest(r *http.Request) bool {
+	query := r.URL.Query()
+	return query.Has("signature") && h.Signer.Verify(r.URL.Path, query.Get("expires"), query.Get("signature")) == nil
+}
+
+// Download serves an artifact, ranges included. RequireAuth lets signed links through, a bad signature is refused here
+func (h *ArtifactHandler) Download(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	executionId := chi.URLParam(r, "execution_id")
+	if executionId == "" {
+		return nil, http.StatusBadRequest, errors.EmptyParamErr("execution_id")
+	}
+	testcaseId := chi.URLParam(r, "testcase_id")
+	if testcaseId == noTestcase {
+		testcaseId = ""
+	}
+	name := chi.URLParam(r, "name")
+	if name == "" {
+		return nil, http.StatusBadRequest, errors.EmptyParamErr("name")
+	}
+
+	query := r.URL.Query()
+	if query.Has("signature") {
+		if err := h.Signer.Verify(r.URL.Path, query.Get("expires"), query.Get("signature")); err != nil {
+			return nil, http.StatusForbidden, errors.E(errors.Forbidden, err.Error(), err)
+		}
+	}
+
+	artifact, err := h.Index.Find(executionId, testcaseId, name)
+	if err != nil {
+		if stderrors.Is(err, sessionartifacts.ErrNotFound) {
+			return nil, http.StatusNotFound, errors.E(errors.NotFound, err.Error(), err)
+		}
+		return nil, http.StatusBadRequest, errors.E(errors.Invalid, err.Error(), err)
+	}
+	file, err := os.Open(artifact.Path)
+	if err != nil {
+		return nil, http.StatusNotFound, errors.E(errors.NotFound, "artifact not found", err)
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", artifact.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": artifact.Name}))
+	// nkk: ServeContent answers Range requests, videos seek without downloading the whole file
+	http.ServeContent(w, r, artifact.Name, artifact.ModifiedAt, file)
+	return nil, 0, nil
+}
+
+// TraceViewer serves the static files of Playwright's trace viewer, traces are opened with ?trace=<signed link>
+func (h *ArtifactHandler) TraceViewer(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	if h.TraceViewerDir == "" {
+		return nil, http.StatusNotFound, errors.E(errors.NotFound, "trace viewer is not enabled")
+	}
+	name := path.Clean("/" + chi.URLParam(r, "*"))
+	if name == "/" {
+		name = "/index.html"
+	}
+	file, err := os.Open(filepath.Join(h.TraceViewerDir, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, http.StatusNotFound, errors.E(errors.NotFound, "file not found", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		return nil, http.StatusNotFound, errors.E(errors.NotFound, "file not found", err)
+	}
+	// nkk: ServeContent instead of ServeFile, which redirects index.html to the directory
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+	return nil, 0, nil
+}
+
+// baseURL is the scheme and host the request reached the agent on
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// traceViewerPath is where the trace viewer is mounted, next to the organisations routes
+func traceViewerPath(r *http.Request) string {
+	prefix, _, _ := strings.Cut(r.URL.Path, "/organisations/")
+	return prefix + "/trace-viewer/"
+}
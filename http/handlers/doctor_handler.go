@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"agent/services/doctor"
+)
+
+type DoctorHandler struct {
+	Doctor *doctor.Doctor
+}
+
+func NewDoctorHandler(d *doctor.Doctor) *DoctorHandler {
+	return &DoctorHandler{
+		Doctor: d,
+	}
+}
+
+// RunChecks runs the environment diagnostics, failed checks are part of the report and not an error
+func (h *DoctorHandler) RunChecks(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	return h.Doctor.Run(r.Context()), http.StatusOK, nil
+}
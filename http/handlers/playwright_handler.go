@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"agent/errors"
+	"agent/services/playwright_runtime"
+)
+
+type PlaywrightRuntimeHandler struct {
+	Runtimes *playwright_runtime.Manager
+}
+
+func NewPlaywrightRuntimeHandler(runtimes *playwright_runtime.Manager) *PlaywrightRuntimeHandler {
+	return &PlaywrightRuntimeHandler{
+		Runtimes: runtimes,
+	}
+}
+
+// ListVersions returns the installed Playwright versions with their browser revisions
+func (h *PlaywrightRuntimeHandler) ListVersions(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	runtimes, err := h.Runtimes.List()
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.E(errors.Internal, "failed to list playwright versions", err)
+	}
+
+	return map[string]interface{}{
+		"default_version": h.Runtimes.DefaultVersion(),
+		"versions":        runtimes,
+	}, http.StatusOK, nil
+}
+
+// InstallVersion installs a Playwright version ahead of the first execution that needs it
+func (h *PlaywrightRuntimeHandler) InstallVersion(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	version := chi.URLParam(r, "version")
+	if version == "" {
+		return nil, http.StatusBadRequest, errors.EmptyParamErr("version")
+	}
+	if err := playwright_runtime.ValidateVersion(version); err != nil {
+		return nil, http.StatusBadRequest, errors.InvalidParamsErr(err)
+	}
+
+	runtime, err := h.Runtimes.Ensure(r.Context(), version)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.E(errors.Internal, "failed to install playwright version", err)
+	}
+	return runtime, http.StatusOK, nil
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	stderrors "errors"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+
+	"agent/errors"
+	"agent/logger"
+	"agent/services/allure"
+	"agent/services/report"
+)
+
+type ReportHandler struct {
+	Reports       *report.Store
+	AllureResults *allure.Reporter
+}
+
+func NewReportHandler(reports *report.Store, allureResults *allure.Reporter) *ReportHandler {
+	return &ReportHandler{
+		Reports:       reports,
+		AllureResults: allureResults,
+	}
+}
+
+// JUnit serves the JUnit XML report of an execution
+func (h *ReportHandler) JUnit(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	return h.serve(w, r, report.JUnitFile)
+}
+
+// HTML serves the static HTML report of an execution
+func (h *ReportHandler) HTML(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	return h.serve(w, r, report.HTMLFile)
+}
+
+// Steps serves the step timeline of an execution
+func (h *ReportHandler) Steps(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	return h.serve(w, r, report.StepsFile)
+}
+
+// Console serves the browser console logs of an execution
+func (h *ReportHandler) Console(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	return h.serve(w, r, report.ConsoleFile)
+}
+
+// Attachment serves a screenshot, video or trace linked from the reports
+func (h *ReportHandler) Attachment(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	name := chi.URLParam(r, "*")
+	if name == "" {
+		return nil, http.StatusBadRequest, errors.EmptyParamErr("attachment")
+	}
+	return h.serve(w, r, filepath.Join("attachments", name))
+}
+
+// Allure downloads the Allure results of an execution as a zip
+func (h *ReportHandler) Allure(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	executionId := chi.URLParam(r, "execution_id")
+	if executionId == "" {
+		return nil, http.StatusBadRequest, errors.EmptyParamErr("execution_id")
+	}
+	if h.AllureResults == nil {
+		return nil, http.StatusNotFound, errors.E(errors.NotFound, "allure results are not enabled")
+	}
+
+	// nkk: Check before writing, a failure halfway through the zip can no longer change the status
+	if _, err := h.AllureResults.Files(executionId); err != nil {
+		if stderrors.Is(err, allure.ErrNoResults) {
+			return nil, http.StatusNotFound, errors.E(errors.NotFound, "allure results not found for execution "+executionId)
+		}
+		return nil, http.StatusBadRequest, errors.InvalidParamsErr(err)
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="allure-results-`+executionId+`.zip"`)
+	if err := h.AllureResults.Archive(w, executionId); err != nil {
+		logger.Error("could not stream allure results", zap.String("execution_id", executionId), zap.Error(err))
+	}
+	return nil, 0, nil
+}
+
+// serve writes the file itself, a nil response with status 0 leaves the reply untouched
+func (h *ReportHandler) serve(w http.ResponseWriter, r *http.Request, name string) (any, int, error) {
+	executionId := chi.URLParam(r, "execution_id")
+	if executionId == "" {
+		return nil, http.StatusBadRequest, errors.EmptyParamErr("execution_id")
+	}
+
+	path, err := h.Reports.File(executionId, name)
+	if err != nil {
+		return nil, http.StatusBadRequest, errors.InvalidParamsErr(err)
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, http.StatusNotFound, errors.E(errors.NotFound, "report not found for execution "+executionId)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.E(errors.Internal, "failed to open report", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return nil, http.StatusNotFound, errors.E(errors.NotFound, "report not found for execution "+executionId)
+	}
+
+	// nkk: ServeContent handles content type, caching headers and range requests for videos
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+	return nil, 0, nil
+}
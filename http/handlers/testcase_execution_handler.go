@@ -10,29 +10,17 @@ import (
 	"agent/errors"
 	"agent/models/executionstatus"
 	"agent/models/executionstep"
-	"agent/models/runresult"
 	"agent/models/screenshot"
 	"agent/models/session"
 	"agent/models/uploadvideo"
+	executionbridge "agent/services/execution_bridge"
 )
 
-type ExecutionBridge interface {
-	SaveSession(ctx context.Context, orgId string, projectId string, appId string, testlab string, session session.Session) error
-	SaveSessionStatus(ctx context.Context, status executionstatus.ExecutionStatus) error
-	CreateLocalAgentResults(ctx context.Context, orgId string, projectId string, appId string, executionId string, testPlanId string, runResult *runresult.RunResult, resultType string) error
-	UpdateSession(ctx context.Context, orgId string, projectId string, appId string, testlab string, session session.Session) error
-	CreateLocalAgentNetworkLogs(ctx context.Context, session session.Session) error
-	UpdateStepCount(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, body executionstep.ExecutionStep) error
-	UploadScreenshots(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, screenshot screenshot.UploadScreenshotRequest) error
-	TakeScreenshot(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, screenshot screenshot.TakeScreenshot) error
-	UploadVideo(ctx context.Context, data uploadvideo.UploadVideo) error
-}
-
 type ExecutionBridgeHandler struct {
-	ExecutionBridge ExecutionBridge
+	ExecutionBridge executionbridge.ExecutionBridge
 }
 
-func NewExecutionBridgeHandler(executionBridge ExecutionBridge) *ExecutionBridgeHandler {
+func NewExecutionBridgeHandler(executionBridge executionbridge.ExecutionBridge) *ExecutionBridgeHandler {
 	return &ExecutionBridgeHandler{
 		ExecutionBridge: executionBridge,
 	}
@@ -65,7 +53,9 @@ func (h *ExecutionBridgeHandler) TakeScreenshot(w http.ResponseWriter, r *http.R
 	if err != nil {
 		return nil, http.StatusBadRequest, errors.InvalidBodyErr(err)
 	}
-	go h.ExecutionBridge.TakeScreenshot(r.Context(), orgId, projectId, appId, testLab, executionId, screenshot)
+	if err := h.ExecutionBridge.TakeScreenshot(r.Context(), orgId, projectId, appId, testLab, executionId, screenshot); err != nil {
+		return nil, http.StatusInternalServerError, errors.E(errors.Internal, "failed to take screenshot", err)
+	}
 	if err == nil {
 		return nil, http.StatusOK, nil
 
@@ -103,7 +93,9 @@ func (h *ExecutionBridgeHandler) UploadScreenshots(w http.ResponseWriter, r *htt
 		return nil, http.StatusBadRequest, errors.EmptyParamErr("execution_id")
 	}
 
-	go h.ExecutionBridge.UploadScreenshots(r.Context(), orgId, projectId, appId, testlab, executionId, body)
+	if err := h.ExecutionBridge.UploadScreenshots(r.Context(), orgId, projectId, appId, testlab, executionId, body); err != nil {
+		return nil, http.StatusInternalServerError, errors.E(errors.Internal, "failed to upload screenshots", err)
+	}
 
 	if err == nil {
 		return nil, http.StatusOK, nil
@@ -121,7 +113,9 @@ func (h *ExecutionBridgeHandler) UpdateExecutionStatus(w http.ResponseWriter, r
 		return nil, http.StatusBadRequest, errors.ValidationFailedErr(err)
 	}
 
-	go h.ExecutionBridge.SaveSessionStatus(r.Context(), body)
+	if err := h.ExecutionBridge.SaveSessionStatus(r.Context(), body); err != nil {
+		return nil, http.StatusInternalServerError, errors.E(errors.Internal, "failed to save session status", err)
+	}
 	return nil, http.StatusOK, nil
 }
 
@@ -149,7 +143,9 @@ func (h *ExecutionBridgeHandler) SaveSession(w http.ResponseWriter, r *http.Requ
 		return nil, http.StatusBadRequest, errors.InvalidBodyErr(err)
 	}
 
-	go h.ExecutionBridge.SaveSession(r.Context(), orgId, projectId, appId, testlab, session)
+	if err := h.ExecutionBridge.SaveSession(r.Context(), orgId, projectId, appId, testlab, session); err != nil {
+		return nil, http.StatusInternalServerError, errors.E(errors.Internal, "failed to save session", err)
+	}
 
 	return nil, http.StatusOK, nil
 }
@@ -176,7 +172,9 @@ func (h *ExecutionBridgeHandler) UpdateSession(w http.ResponseWriter, r *http.Re
 	if err != nil {
 		return nil, http.StatusBadRequest, errors.InvalidBodyErr(err)
 	}
-	go h.ExecutionBridge.UpdateSession(r.Context(), orgId, projectId, appId, testlab, session)
+	if err := h.ExecutionBridge.UpdateSession(r.Context(), orgId, projectId, appId, testlab, session); err != nil {
+		return nil, http.StatusInternalServerError, errors.E(errors.Internal, "failed to update session", err)
+	}
 	return nil, http.StatusOK, nil
 }
 
@@ -213,7 +211,9 @@ func (h *ExecutionBridgeHandler) UpdateStepCount(w http.ResponseWriter, r *http.
 		return nil, http.StatusBadRequest, errors.ValidationFailedErr(err)
 	}
 
-	go h.ExecutionBridge.UpdateStepCount(r.Context(), orgId, projectId, appId, testlab, executionId, body)
+	if err := h.ExecutionBridge.UpdateStepCount(r.Context(), orgId, projectId, appId, testlab, executionId, body); err != nil {
+		return nil, http.StatusInternalServerError, errors.E(errors.Internal, "failed to update step count", err)
+	}
 
 	return nil, http.StatusOK, nil
 
@@ -226,7 +226,8 @@ func (h *ExecutionBridgeHandler) CreateLocalAgentNetworkLogs(w http.ResponseWrit
 		return nil, http.StatusBadRequest, errors.InvalidBodyErr(err)
 	}
 
-	go h.ExecutionBridge.CreateLocalAgentNetworkLogs(r.Context(), session)
+	// nkk: Waits for Playwright to finish the trace, the write itself is journaled by the bridge
+	go h.ExecutionBridge.CreateLocalAgentNetworkLogs(context.WithoutCancel(r.Context()), session)
 	return nil, http.StatusOK, nil
 }
 
@@ -237,6 +238,7 @@ func (h *ExecutionBridgeHandler) UploadVideo(w http.ResponseWriter, r *http.Requ
 	if err != nil {
 		return nil, http.StatusBadRequest, errors.InvalidBodyErr(err)
 	}
-	go h.ExecutionBridge.UploadVideo(r.Context(), body)
+	// nkk: Waits for Playwright to finish the recording, the upload itself is journaled by the bridge
+	go h.ExecutionBridge.UploadVideo(context.WithoutCancel(r.Context()), body)
 	return nil, http.StatusOK, nil
 }
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+
+	"agent/errors"
+	"agent/models/screenshot"
+	executionbridge "agent/services/execution_bridge"
+	"agent/services/visual"
+)
+
+type VisualHandler struct {
+	Checker         *visual.Checker
+	ExecutionBridge executionbridge.ExecutionBridge
+}
+
+func NewVisualHandler(checker *visual.Checker, executionBridge executionbridge.ExecutionBridge) *VisualHandler {
+	return &VisualHandler{
+		Checker:         checker,
+		ExecutionBridge: executionBridge,
+	}
+}
+
+// VisualCheckRequest is a screenshot assertion from the fixture
+type VisualCheckRequest struct {
+	visual.CheckRequest
+	ScreenshotPath string `json:"screenshotPath,omitempty"` // nkk: also sent on as a regular screenshot when set
+}
+
+// Check compares a screenshot against its baseline, a failed comparison is still a 200 with status failed
+func (h *VisualHandler) Check(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	if h.Checker == nil {
+		return nil, http.StatusNotFound, errors.E(errors.NotFound, "visual checks are not enabled")
+	}
+	var body VisualCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, http.StatusBadRequest, errors.InvalidBodyErr(err)
+	}
+	for param, value := range map[string]*string{"org_id": &body.OrgId, "project_id": &body.ProjectId, "app_id": &body.AppId, "execution_id": &body.ExecutionId} {
+		*value = chi.URLParam(r, param)
+		if *value == "" {
+			return nil, http.StatusBadRequest, errors.EmptyParamErr(param)
+		}
+	}
+	testlab := chi.URLParam(r, "testlab")
+	if testlab == "" {
+		return nil, http.StatusBadRequest, errors.EmptyParamErr("testlab")
+	}
+	if body.Screenshot == "" {
+		return nil, http.StatusBadRequest, errors.EmptyParamErr("screenshot")
+	}
+
+	result, err := h.Checker.Check(body.CheckRequest)
+	if err != nil {
+		if stderrors.Is(err, visual.ErrInvalid) {
+			return nil, http.StatusBadRequest, errors.E(errors.Invalid, err.Error(), err)
+		}
+		return nil, http.StatusInternalServerError, errors.E(errors.Internal, "failed to check screenshot", err)
+	}
+	if body.ScreenshotPath != "" {
+		shot := screenshot.TakeScreenshot{Screenshot: body.Screenshot, ScreenshotPath: body.ScreenshotPath, ExecutionId: body.ExecutionId}
+		if err := h.ExecutionBridge.TakeScreenshot(r.Context(), body.OrgId, body.ProjectId, body.AppId, testlab, body.ExecutionId, shot); err != nil {
+			return nil, http.StatusInternalServerError, errors.E(errors.Internal, "failed to take screenshot", err)
+		}
+	}
+	return result, http.StatusOK, nil
+}
+
+// Approve promotes the latest screenshot of a checkpoint to its baseline
+func (h *VisualHandler) Approve(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	return h.review(r, h.Checker.Approve)
+}
+
+// Reject drops the latest screenshot of a checkpoint and keeps its baseline
+func (h *VisualHandler) Reject(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	return h.review(r, h.Checker.Reject)
+}
+
+func (h *VisualHandler) review(r *http.Request, apply func(visual.Checkpoint) error) (response any, status int, err error) {
+	if h.Checker == nil {
+		return nil, http.StatusNotFound, errors.E(errors.NotFound, "visual checks are not enabled")
+	}
+	checkpoint := checkpointParams(r)
+	if err := apply(checkpoint); err != nil {
+		switch {
+		case stderrors.Is(err, visual.ErrInvalid):
+			return nil, http.StatusBadRequest, errors.E(errors.Invalid, err.Error(), err)
+		case stderrors.Is(err, visual.ErrNoCandidate):
+			return nil, http.StatusNotFound, errors.E(errors.NotFound, err.Error(), err)
+		}
+		return nil, http.StatusInternalServerError, errors.E(errors.Internal, "failed to review screenshot", err)
+	}
+	return checkpoint, http.StatusOK, nil
+}
+
+// Image serves the baseline, latest screenshot or diff of a checkpoint
+func (h *VisualHandler) Image(w http.ResponseWriter, r *http.Request) (response any, status int, err error) {
+	if h.Checker == nil {
+		return nil, http.StatusNotFound, errors.E(errors.NotFound, "visual checks are not enabled")
+	}
+	path, err := h.Checker.Image(checkpointParams(r), chi.URLParam(r, "image"))
+	if err != nil {
+		if stderrors.Is(err, visual.ErrInvalid) {
+			return nil, http.StatusBadRequest, errors.E(errors.Invalid, err.Error(), err)
+		}
+		return nil, http.StatusNotFound, errors.E(errors.NotFound, err.Error(), err)
+	}
+	w.Header().Set("Content-Type", "image/png")
+	http.ServeFile(w, r, path)
+	return nil, 0, nil
+}
+
+func checkpointParams(r *http.Request) visual.Checkpoint {
+	return visual.Checkpoint{
+		OrgId:      chi.URLParam(r, "org_id"),
+		ProjectId:  chi.URLParam(r, "project_id"),
+		AppId:      chi.URLParam(r, "app_id"),
+		TestcaseId: chi.URLParam(r, "testcase_id"),
+		Browser:    chi.URLParam(r, "browser"),
+		Resolution: chi.URLParam(r, "resolution"),
+		Name:       chi.URLParam(r, "name"),
+	}
+}
@@ -0,0 +1,74 @@
+package apxmiddlewares
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	apxresp "agent/http/response"
+)
+
+// RequireAuth lets a request through with a bearer JWT signed with secret (HS256) whose exp has not passed.
+// Requests skip accepts, such as signed links, are checked by their handler instead.
+func RequireAuth(enabled bool, secret string, skip func(r *http.Request) bool) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !enabled || (skip != nil && skip(r)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok {
+				apxresp.RespondMessage(w, http.StatusUnauthorized, "missing bearer token")
+				return
+			}
+			if err := verifyJWT(strings.TrimSpace(token), []byte(secret), time.Now()); err != nil {
+				apxresp.RespondMessage(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func verifyJWT(token string, secret []byte, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return errors.New("unsupported token")
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return errors.New("invalid token signature")
+	}
+	var claims struct {
+		Exp *int64 `json:"exp"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return errors.New("malformed token")
+	}
+	if claims.Exp != nil && now.Unix() >= *claims.Exp {
+		return errors.New("token expired")
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
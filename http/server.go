@@ -17,6 +17,7 @@ import (
 	apxmiddlewares "agent/http/middleware"
 	apxresp "agent/http/response"
 	"agent/logger"
+	"agent/services/monitoring"
 	"agent/utils/helpers"
 )
 
@@ -25,13 +26,25 @@ type Server struct {
 	Conf                   *config.ApxConfig
 	AgentHandler           *handlers.AgentHandler
 	ExecutionBridgeHandler *handlers.ExecutionBridgeHandler
+	PlaywrightHandler      *handlers.PlaywrightRuntimeHandler
+	HealthHandler          http.Handler
+	DoctorHandler          *handlers.DoctorHandler
+	ReportHandler          *handlers.ReportHandler
+	VisualHandler          *handlers.VisualHandler
+	ArtifactHandler        *handlers.ArtifactHandler
 }
 
-func NewServer(conf *config.ApxConfig, agentHandler *handlers.AgentHandler, executionBridgeHandler *handlers.ExecutionBridgeHandler) *Server {
+func NewServer(conf *config.ApxConfig, agentHandler *handlers.AgentHandler, executionBridgeHandler *handlers.ExecutionBridgeHandler, playwrightHandler *handlers.PlaywrightRuntimeHandler, healthHandler http.Handler, doctorHandler *handlers.DoctorHandler, reportHandler *handlers.ReportHandler, visualHandler *handlers.VisualHandler, artifactHandler *handlers.ArtifactHandler) *Server {
 	return &Server{
 		Conf:                   conf,
 		AgentHandler:           agentHandler,
 		ExecutionBridgeHandler: executionBridgeHandler,
+		PlaywrightHandler:      playwrightHandler,
+		HealthHandler:          healthHandler,
+		DoctorHandler:          doctorHandler,
+		ReportHandler:          reportHandler,
+		VisualHandler:          visualHandler,
+		ArtifactHandler:        artifactHandler,
 	}
 }
 
@@ -47,9 +60,21 @@ func (s *Server) Listen(ctx context.Context, addr string) error {
 	}))
 	r.Use(middleware.Recoverer)
 	r.Use(apxmiddlewares.EnabCors(s.Conf.Cors.AllowedOrigins))
+	security := config.GetConfig().Security
+	requireAuth := apxmiddlewares.RequireAuth(security.EnableAuth, security.JWTSecret, nil)
+	// nkk: A valid signed link stands in for the bearer token
+	signedOrAuth := apxmiddlewares.RequireAuth(security.EnableAuth, security.JWTSecret, s.ArtifactHandler.SignedRequest)
 	r.Route(s.Conf.Prefix, func(r chi.Router) {
+		r.Get("/health", s.HealthHandler.ServeHTTP)
+		r.Get("/metrics", monitoring.PrometheusHandler())
 		r.Route("/v1", func(r chi.Router) {
 			r.Post("/start", s.ToHTTPHandlerFunc(s.AgentHandler.StartAgentHandler))
+			r.With(requireAuth).Get("/doctor", s.ToHTTPHandlerFunc(s.DoctorHandler.RunChecks))
+			r.Get("/trace-viewer/*", s.ToHTTPHandlerFunc(s.ArtifactHandler.TraceViewer))
+			r.Route("/playwright/versions", func(r chi.Router) {
+				r.Get("/", s.ToHTTPHandlerFunc(s.PlaywrightHandler.ListVersions))
+				r.With(requireAuth).Post("/{version}", s.ToHTTPHandlerFunc(s.PlaywrightHandler.InstallVersion))
+			})
 			r.Route("/organisations", func(r chi.Router) {
 				r.Route("/{org_id}", func(r chi.Router) {
 					r.Route("/projects", func(r chi.Router) {
@@ -60,6 +85,11 @@ func (s *Server) Listen(ctx context.Context, addr string) error {
 										r.Get("/status", s.ToHTTPHandlerFunc(s.AgentHandler.GetAgentStatus))
 										r.Post("/network-logs", s.ToHTTPHandlerFunc(s.ExecutionBridgeHandler.CreateLocalAgentNetworkLogs))
 									})
+									r.Route("/visual-baselines/{testcase_id}/{browser}/{resolution}/{name}", func(r chi.Router) {
+										r.With(requireAuth).Post("/approve", s.ToHTTPHandlerFunc(s.VisualHandler.Approve))
+										r.With(requireAuth).Post("/reject", s.ToHTTPHandlerFunc(s.VisualHandler.Reject))
+										r.Get("/{image}", s.ToHTTPHandlerFunc(s.VisualHandler.Image))
+									})
 									r.Route("/{testlab}", func(r chi.Router) {
 										r.Route("/sessions", func(r chi.Router) {
 											r.Post("/", s.ToHTTPHandlerFunc(s.ExecutionBridgeHandler.SaveSession))
@@ -70,7 +100,21 @@ func (s *Server) Listen(ctx context.Context, addr string) error {
 											r.Put("/update-stepcount", s.ToHTTPHandlerFunc(s.ExecutionBridgeHandler.UpdateStepCount))
 											r.Post("/upload-screenshots", s.ToHTTPHandlerFunc(s.ExecutionBridgeHandler.UploadScreenshots))
 											r.Post("/take-screenshot", s.ToHTTPHandlerFunc(s.ExecutionBridgeHandler.TakeScreenshot))
+											r.Post("/visual-check", s.ToHTTPHandlerFunc(s.VisualHandler.Check))
 											r.Post("/upload-video", s.ToHTTPHandlerFunc(s.ExecutionBridgeHandler.UploadVideo))
+											r.Route("/artifacts", func(r chi.Router) {
+												r.With(requireAuth).Get("/", s.ToHTTPHandlerFunc(s.ArtifactHandler.List))
+												r.With(signedOrAuth).Get("/{testcase_id}/{name}", s.ToHTTPHandlerFunc(s.ArtifactHandler.Download))
+											})
+											r.Route("/report", func(r chi.Router) {
+												r.Use(signedOrAuth)
+												r.Get("/junit", s.ToHTTPHandlerFunc(s.ReportHandler.JUnit))
+												r.Get("/html", s.ToHTTPHandlerFunc(s.ReportHandler.HTML))
+												r.Get("/steps", s.ToHTTPHandlerFunc(s.ReportHandler.Steps))
+												r.Get("/console", s.ToHTTPHandlerFunc(s.ReportHandler.Console))
+												r.Get("/attachments/*", s.ToHTTPHandlerFunc(s.ReportHandler.Attachment))
+												r.Get("/allure", s.ToHTTPHandlerFunc(s.ReportHandler.Allure))
+											})
 										})
 									})
 								})
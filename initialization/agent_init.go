@@ -1,6 +1,7 @@
 package init
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -17,6 +18,7 @@ import (
 	"agent/config"
 	"agent/logger"
 	autotestbridge "agent/services/autotest_bridge"
+	"agent/services/playwright_runtime"
 	"agent/utils/helpers"
 )
 
@@ -151,7 +153,62 @@ func EnsureRegistration(apxconfig *config.ApxConfig, autotestBridgeSvc *autotest
 
 }
 
-func InstallDependencies(folderPath string) error {
+// EnsureLocalMachineConfig prepares the machine config the Playwright fixtures read, without contacting the server.
+// nkk: Used by headless runs, the fixtures post results to localhost<listen> so listen must match this process
+func EnsureLocalMachineConfig(apxconfig *config.ApxConfig) (string, error) {
+	machineConfigFile, err := EnsureConfigFile()
+	if err != nil {
+		return "", err
+	}
+	defer machineConfigFile.Close()
+
+	byteData, err := io.ReadAll(machineConfigFile)
+	if err != nil {
+		logger.Error("error reading machine config file", err)
+		return "", err
+	}
+
+	listen := apxconfig.Listen
+	if len(byteData) != 0 {
+		err = json.Unmarshal(byteData, &apxconfig)
+		if err != nil {
+			logger.Error("error unmarshalling machine config file", err)
+			return "", err
+		}
+	}
+	apxconfig.Listen = listen
+	if apxconfig.MachineId == "" {
+		apxconfig.MachineId = uuid.New().String()
+	}
+
+	bytes, err := json.MarshalIndent(apxconfig, "", "  ")
+	if err != nil {
+		logger.Error("error marshalling machine config file", err)
+		return "", err
+	}
+	if err = machineConfigFile.Truncate(0); err != nil {
+		logger.Error("error truncating file", err)
+		return "", err
+	}
+	if _, err = machineConfigFile.WriteAt(bytes, 0); err != nil {
+		logger.Error("error writing to machine config file", err)
+		return "", err
+	}
+	return apxconfig.MachineId, nil
+}
+
+// InstallDependencies installs the workspace packages and the default Playwright runtime.
+// nkk: Playwright itself is no longer installed into the workspace, each version lives in its own runtime dir
+func InstallDependencies(folderPath string, runtimes *playwright_runtime.Manager) error {
+	playwrightConfig := config.GetConfig().Playwright
+
+	// nkk: Disconnected machines get their dependencies from an offline bundle, only verify them
+	if playwrightConfig.Offline {
+		runtimes.SetOffline(true)
+		logger.Info("Offline mode: skipping 'npm i' inside folder", folderPath)
+		return VerifyPlaywrightInstallation(runtimes, playwrightConfig.RequiredBrowsers)
+	}
+
 	cmd := exec.Command("npm", "i")
 	cmd.Dir = folderPath
 	stdoutPipe, _ := cmd.StdoutPipe()
@@ -170,40 +227,23 @@ func InstallDependencies(folderPath string) error {
 	go helpers.StdError(stderrPipe)
 	cmd.Wait()
 
-	playwrightCmd := exec.Command("npm", "install", "playwright@1.47.2")
-	playwrightCmd.Dir = folderPath
-	playwrightCmdStdoutPipe, _ := playwrightCmd.StdoutPipe()
-	playwrightCmdStderrPipe, _ := playwrightCmd.StderrPipe()
-
-	logger.Info("Running 'npm install playwright@1.47.2' inside folder", folderPath)
-
-	err = playwrightCmd.Start()
+	logger.Info("Ensuring default playwright runtime", runtimes.DefaultVersion())
 
+	_, err = runtimes.Ensure(context.Background(), runtimes.DefaultVersion())
 	if err != nil {
-		logger.Error("Error running 'npm install playwright@1.47.2' inside folder", folderPath, err)
+		logger.Error("Error installing default playwright runtime", runtimes.DefaultVersion(), err)
+		return err
 	}
+	return VerifyPlaywrightInstallation(runtimes, playwrightConfig.RequiredBrowsers)
+}
 
-	go helpers.StdOutput(playwrightCmdStdoutPipe)
-	go helpers.StdError(playwrightCmdStderrPipe)
-
-	playwrightCmd.Wait()
-
-	logger.Info("Running 'npx playwright install' inside folder", folderPath)
-
-	depCmd := exec.Command("npx", "playwright", "install")
-	depCmd.Dir = folderPath
-	depCmdStdoutPipe, _ := depCmd.StdoutPipe()
-	depCmdStderrPipe, _ := depCmd.StderrPipe()
-
-	logger.Info("Running 'npx  playwright install' inside folder", folderPath)
-
-	err = depCmd.Start()
+// VerifyPlaywrightInstallation reports missing runtimes or browsers at startup instead of mid-run
+func VerifyPlaywrightInstallation(runtimes *playwright_runtime.Manager, requiredBrowsers []string) error {
+	err := runtimes.VerifyBrowsers(runtimes.DefaultVersion(), requiredBrowsers)
 	if err != nil {
-		logger.Error("Error running 'npx  playwright install' inside folder", folderPath, err)
+		logger.Error("playwright installation incomplete", err)
+		return err
 	}
-	go helpers.StdOutput(depCmdStdoutPipe)
-	go helpers.StdError(depCmdStderrPipe)
-
-	depCmd.Wait()
-	return err
+	logger.Info("playwright installation verified", runtimes.DefaultVersion())
+	return nil
 }
@@ -8,8 +8,8 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
-// Global Logger variable
-var Logger *zap.Logger
+// Global Logger variable, a no-op until InitLogger is called
+var Logger = zap.NewNop()
 
 // InitLogger initializes the logger and configures its settings
 func InitLogger(level string) {
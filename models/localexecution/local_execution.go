@@ -35,6 +35,7 @@ type LocalExecution struct {
 	ParallelismEnabled bool                  `json:"parallelism_enabled" bson:"parallelism_enabled"`
 	Workers            int                   `json:"workers" bson:"workers"`
 	EDCDetails         edcdetails.EDCDetails `json:"edc_details" bson:"edc_details"`
+	PlaywrightVersion  string                `json:"playwright_version,omitempty" bson:"playwright_version,omitempty"`
 }
 
 type RecoverOptions struct {
@@ -78,6 +79,9 @@ func (l *LocalExecution) ToTestcaseRequestBody() *testcase.ExecuteRequestBody {
 		RecoverOptions:     (*testplan.RecoverOptions)(l.RecoverOptions),
 		LocalSessionConfig: l.LocalSessionConfig,
 	}
+	if body.LocalSessionConfig.PlaywrightVersion == "" {
+		body.LocalSessionConfig.PlaywrightVersion = l.PlaywrightVersion
+	}
 	body.LocalSessionConfig.MachineId = "ADHOC"
 	body.LocalSessionConfig.MachineName = "ADHOC"
 	return body
@@ -101,5 +105,6 @@ func (l *LocalExecution) ToTestPlanRequestBody() *testplan.TestPlanExecutionRequ
 		ParallelismEnabled: l.ParallelismEnabled,
 		Workers:            l.Workers,
 		EDCDetails:         l.EDCDetails,
+		PlaywrightVersion:  l.PlaywrightVersion,
 	}
 }
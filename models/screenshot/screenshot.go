@@ -15,6 +15,7 @@ type UploadScreenshotRequest struct {
 	IsAdhoc          bool   `json:"is_adhoc,omitempty"`
 	IsPreRequisite   bool   `json:"is_prerequisite,omitempty"`
 	ParentTestCaseId string `json:"parent_testcase_id,omitempty"`
+	ScreenshotsDir   string `json:"screenshots_dir,omitempty"` // nkk: bundled and uploaded in chunks when chunked uploads are on
 }
 
 func (r *UploadScreenshotRequest) Validate() error {
@@ -28,8 +28,18 @@ type Config struct {
 	Headless       bool       `json:"headless" bson:"headless"`
 	Workers        int        `json:"workers" bson:"workers"`
 	EDCDetails     EDCDetails `json:"edcDetails" bson:"edc_details"`
+
+	PlaywrightVersion string `json:"playwrightVersion,omitempty" bson:"playwright_version,omitempty"`
 }
 
+// Console log levels, each one includes the levels before it
+const (
+	ConsoleLogsErrors   = "errors"
+	ConsoleLogsWarnings = "warnings"
+	ConsoleLogsInfo     = "info"
+	ConsoleLogsVerbose  = "verbose"
+)
+
 func (t *Config) GetWidth() int {
 	splitResolution := strings.Split(t.Resolution, "x")
 	width, _ := strconv.Atoi(splitResolution[0])
@@ -99,6 +99,7 @@ type TestPlanExecutionDetails struct {
 	ParallelismEnabled bool                  `json:"parallelism_enabled" bson:"parallelism_enabled"`
 	Workers            int                   `json:"workers" bson:"workers"`
 	EDCDetails         edcdetails.EDCDetails `json:"edc_details" bson:"edc_details"`
+	PlaywrightVersion  string                `json:"playwright_version,omitempty" bson:"playwright_version,omitempty"`
 }
 
 func (t *TestPlanExecutionDetails) Validate() error {
@@ -153,6 +154,7 @@ type TestPlanExecutionRequestBody struct {
 	ParallelismEnabled bool                  `json:"parallelism_enabled"`
 	Workers            int                   `json:"workers" bson:"workers"`
 	EDCDetails         edcdetails.EDCDetails `json:"edc_details" bson:"edc_details"`
+	PlaywrightVersion  string                `json:"playwright_version,omitempty" bson:"playwright_version,omitempty"`
 }
 
 func (t *TestPlanExecutionRequestBody) Validate() error {
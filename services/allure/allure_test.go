@@ -0,0 +1,200 @@
+package allure
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/models/executionstatus"
+	"agent/models/executionstep"
+	"agent/models/screenshot"
+	"agent/models/session"
+	"agent/models/uploadvideo"
+	executionbridge "agent/services/execution_bridge"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: Unit tests for the Allure results
+The wrapped bridge is a LocalSink, so the tests also see what reaches it
+*/
+
+func newTestBridge(t *testing.T) (*Bridge, *executionbridge.LocalSink, string) {
+	workspace := t.TempDir()
+	sink, err := executionbridge.NewLocalSink(filepath.Join(workspace, "results"))
+	require.NoError(t, err)
+	t.Cleanup(func() { sink.Close() })
+
+	reporter := NewReporter(workspace)
+	clock := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	reporter.now = func() time.Time {
+		clock = clock.Add(time.Second)
+		return clock
+	}
+	return NewBridge(sink, reporter), sink, workspace
+}
+
+func readResults(t *testing.T, dir string) ([]Result, []Container) {
+	files, err := filepath.Glob(filepath.Join(dir, "*-result.json"))
+	require.NoError(t, err)
+	var results []Result
+	for _, file := range files {
+		var res Result
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &res))
+		results = append(results, res)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	files, err = filepath.Glob(filepath.Join(dir, "*-container.json"))
+	require.NoError(t, err)
+	var containers []Container
+	for _, file := range files {
+		var c Container
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &c))
+		containers = append(containers, c)
+	}
+	return results, containers
+}
+
+func label(res Result, name string) string {
+	for _, l := range res.Labels {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+func TestBridgeWritesTestplanResults(t *testing.T) {
+	bridge, sink, workspace := newTestBridge(t)
+	ctx := context.Background()
+
+	bridge.DescribeExecution("exec-1", "Nightly", map[string]string{"tc-1": "Login works"})
+	config := session.Config{MachineId: "m-1", Browser: "chromium", BrowserVersion: "129", OS: "linux", Resolution: "1920x1080"}
+	for _, id := range []string{"tc-1", "tc-2"} {
+		sess := session.Session{ExecutionId: "exec-1", TestplanId: "tp-1", TestsuiteId: "ts-1", TestcaseId: id, Status: apxconstants.Running, Config: &config}
+		require.NoError(t, bridge.SaveSession(ctx, "o", "p", "a", apxconstants.Local, sess))
+	}
+
+	step := executionstep.ExecutionStep{ExecutionId: "exec-1", TestplanId: "tp-1", TestsuiteId: "ts-1", TestcaseId: "tc-1", StepCount: "1"}
+	require.NoError(t, bridge.UpdateStepCount(ctx, "o", "p", "a", apxconstants.Local, "exec-1", step))
+	shot := base64.StdEncoding.EncodeToString([]byte("png"))
+	require.NoError(t, bridge.TakeScreenshot(ctx, "o", "p", "a", apxconstants.Local, "exec-1",
+		screenshot.TakeScreenshot{ExecutionId: "exec-1", Screenshot: "data:image/png;base64," + shot, ScreenshotPath: "shots/login.png"}))
+	step.StepCount = "2"
+	require.NoError(t, bridge.UpdateStepCount(ctx, "o", "p", "a", apxconstants.Local, "exec-1", step))
+
+	status := executionstatus.ExecutionStatus{ExecutionId: "exec-1", TestplanId: "tp-1", TestsuiteId: "ts-1", TestcaseId: "tc-1", Status: apxconstants.Failed, Message: "element not found"}
+	require.NoError(t, bridge.SaveSessionStatus(ctx, status))
+	require.NoError(t, bridge.SaveSessionStatus(ctx, executionstatus.ExecutionStatus{ExecutionId: "exec-1", TestplanId: "tp-1", Status: apxconstants.Stopped}))
+
+	dir, err := bridge.Reporter.Dir("exec-1")
+	require.NoError(t, err)
+	results, containers := readResults(t, dir)
+	require.Len(t, results, 2)
+
+	failed := results[0]
+	assert.Equal(t, "Login works", failed.Name)
+	assert.Equal(t, StatusFailed, failed.Status)
+	assert.Equal(t, "element not found", failed.StatusDetails.Message)
+	assert.Equal(t, "Nightly", label(failed, "epic"))
+	assert.Equal(t, "ts-1", label(failed, "feature"))
+	assert.Equal(t, "Login works", label(failed, "story"))
+	assert.Equal(t, "m-1", label(failed, "host"))
+	assert.Equal(t, []Parameter{{Name: "browser", Value: "chromium 129"}, {Name: "resolution", Value: "1920x1080"}}, failed.Parameters)
+	require.Len(t, failed.Steps, 3, "two finished steps and the one that failed")
+	assert.Equal(t, StatusPassed, failed.Steps[1].Status)
+	assert.Equal(t, StatusFailed, failed.Steps[2].Status)
+	require.Len(t, failed.Steps[0].Attachments, 1, "the screenshot belongs to the step that just finished")
+	image, err := os.ReadFile(filepath.Join(dir, failed.Steps[0].Attachments[0].Source))
+	require.NoError(t, err)
+	assert.Equal(t, "png", string(image))
+
+	stopped := results[1]
+	assert.Equal(t, "tc-2", stopped.Name, "ids are used without a title")
+	assert.Equal(t, StatusSkipped, stopped.Status, "testcases still running end with the execution")
+
+	require.Len(t, containers, 1)
+	assert.Equal(t, "Nightly", containers[0].Name)
+	assert.ElementsMatch(t, []string{failed.UUID, stopped.UUID}, containers[0].Children)
+
+	env, err := os.ReadFile(filepath.Join(dir, "environment.properties"))
+	require.NoError(t, err)
+	assert.Contains(t, string(env), "machine_id=m-1\n")
+	assert.Contains(t, string(env), "browser=chromium\n")
+	assert.Contains(t, string(env), "os=linux\n")
+	executor, err := os.ReadFile(filepath.Join(dir, "executor.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(executor), `"name":"Local agent m-1"`)
+
+	assert.True(t, strings.HasPrefix(dir, workspace))
+	events, err := os.ReadFile(filepath.Join(sink.Dir(), "events.jsonl"))
+	require.NoError(t, err)
+	assert.Equal(t, 7, strings.Count(string(events), "\n"), "every call reaches the wrapped bridge")
+}
+
+func TestUploadVideoPassesTheFileOn(t *testing.T) {
+	bridge, sink, workspace := newTestBridge(t)
+
+	path := filepath.Join(workspace, "video.webm")
+	require.NoError(t, os.WriteFile(path, []byte("webm"), 0644))
+	video, err := os.Open(path)
+	require.NoError(t, err)
+	defer video.Close()
+
+	require.NoError(t, bridge.UploadVideo(context.Background(), uploadvideo.UploadVideo{
+		Video: video, ExecutionId: "exec-1", TestcaseId: "tc-1", IsAdhoc: true,
+	}))
+
+	copies, err := filepath.Glob(filepath.Join(sink.Dir(), "videos", "*.webm"))
+	require.NoError(t, err)
+	require.Len(t, copies, 1)
+	data, err := os.ReadFile(copies[0])
+	require.NoError(t, err)
+	assert.Equal(t, "webm", string(data), "the wrapped bridge still reads the whole recording")
+
+	dir, _ := bridge.Reporter.Dir("exec-1")
+	results, _ := readResults(t, dir)
+	require.Len(t, results, 1)
+	require.Len(t, results[0].Attachments, 1)
+	assert.Equal(t, "video/webm", results[0].Attachments[0].Type)
+	assert.Equal(t, adhocName, label(results[0], "epic"))
+}
+
+func TestArchiveZipsTheResults(t *testing.T) {
+	bridge, _, _ := newTestBridge(t)
+	require.NoError(t, bridge.Reporter.Status(executionstatus.ExecutionStatus{ExecutionId: "exec-1", TestcaseId: "tc-1", IsAdhoc: true, Status: apxconstants.Passed}))
+
+	var out bytes.Buffer
+	require.NoError(t, bridge.Reporter.Archive(&out, "exec-1"))
+	zr, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	require.NoError(t, err)
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	assert.Contains(t, names, "environment.properties")
+	assert.Contains(t, names, "executor.json")
+	for _, name := range names {
+		assert.False(t, strings.HasPrefix(name, ".tmp-"))
+	}
+
+	assert.ErrorIs(t, bridge.Reporter.Archive(&out, "exec-2"), ErrNoResults)
+	assert.Error(t, bridge.Reporter.Archive(&out, "../exec-1"))
+}
@@ -0,0 +1,85 @@
+package allure
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNoResults is returned when an execution has no Allure results on disk
+var ErrNoResults = errors.New("no allure results")
+
+// Archive zips the results directory of an execution, ready for `allure generate`
+func (r *Reporter) Archive(w io.Writer, executionId string) error {
+	names, err := r.Files(executionId)
+	if err != nil {
+		return err
+	}
+	dir, _ := r.Dir(executionId)
+
+	zw := zip.NewWriter(w)
+	for _, name := range names {
+		if err := addFile(zw, filepath.Join(dir, name), name); err != nil {
+			zw.Close()
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// Files lists the result files of an execution, in-flight temporary files are left out
+func (r *Reporter) Files(executionId string) ([]string, error) {
+	dir, err := r.Dir(executionId)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, ErrNoResults
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".tmp-") {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) == 0 {
+		return nil, ErrNoResults
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func addFile(zw *zip.Writer, path, name string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+
+	out, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, f)
+	return err
+}
@@ -0,0 +1,101 @@
+package allure
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"agent/logger"
+	"agent/models/executionstatus"
+	"agent/models/executionstep"
+	"agent/models/screenshot"
+	"agent/models/session"
+	"agent/models/uploadvideo"
+	executionbridge "agent/services/execution_bridge"
+)
+
+// Bridge forwards every call to the wrapped bridge and records the ones Allure needs
+type Bridge struct {
+	executionbridge.ExecutionBridge
+	Reporter *Reporter
+}
+
+// NewBridge wraps bridge so its calls are also written as Allure results
+func NewBridge(bridge executionbridge.ExecutionBridge, reporter *Reporter) *Bridge {
+	return &Bridge{
+		ExecutionBridge: bridge,
+		Reporter:        reporter,
+	}
+}
+
+// DescribeExecution gives the results readable names, see executor.TestExecutor
+func (b *Bridge) DescribeExecution(executionId, testplanName string, testcaseTitles map[string]string) {
+	b.record("describe", executionId, b.Reporter.Describe(executionId, testplanName, testcaseTitles))
+}
+
+func (b *Bridge) SaveSession(ctx context.Context, orgId string, projectId string, appId string, testlab string, sess session.Session) error {
+	err := b.ExecutionBridge.SaveSession(ctx, orgId, projectId, appId, testlab, sess)
+	b.record("save_session", sess.ExecutionId, b.Reporter.Session(sess))
+	return err
+}
+
+func (b *Bridge) UpdateSession(ctx context.Context, orgId string, projectId string, appId string, testlab string, sess session.Session) error {
+	err := b.ExecutionBridge.UpdateSession(ctx, orgId, projectId, appId, testlab, sess)
+	b.record("update_session", sess.ExecutionId, b.Reporter.Session(sess))
+	return err
+}
+
+func (b *Bridge) SaveSessionStatus(ctx context.Context, status executionstatus.ExecutionStatus) error {
+	err := b.ExecutionBridge.SaveSessionStatus(ctx, status)
+	b.record("save_session_status", status.ExecutionId, b.Reporter.Status(status))
+	return err
+}
+
+func (b *Bridge) UpdateStepCount(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, body executionstep.ExecutionStep) error {
+	err := b.ExecutionBridge.UpdateStepCount(ctx, orgId, projectId, appId, testlab, executionId, body)
+	if body.ExecutionId == "" {
+		body.ExecutionId = executionId
+	}
+	b.record("update_step_count", executionId, b.Reporter.Step(body))
+	return err
+}
+
+func (b *Bridge) TakeScreenshot(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, request screenshot.TakeScreenshot) error {
+	err := b.ExecutionBridge.TakeScreenshot(ctx, orgId, projectId, appId, testlab, executionId, request)
+
+	data := request.Screenshot
+	if idx := strings.Index(data, ","); idx != -1 {
+		data = data[idx+1:]
+	}
+	image, decodeErr := base64.StdEncoding.DecodeString(data)
+	if decodeErr == nil {
+		decodeErr = b.Reporter.Screenshot(executionId, filepath.Base(request.ScreenshotPath), image)
+	}
+	b.record("take_screenshot", executionId, decodeErr)
+	return err
+}
+
+// UploadVideo copies the recording first, the wrapped bridge consumes the file
+func (b *Bridge) UploadVideo(ctx context.Context, data uploadvideo.UploadVideo) error {
+	if data.Video != nil {
+		recordErr := b.Reporter.Video(data.ExecutionId, data.TestsuiteId, data.TestcaseId, data.IsPreRequisite, data.Video)
+		if _, err := data.Video.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		b.record("upload_video", data.ExecutionId, recordErr)
+	}
+	return b.ExecutionBridge.UploadVideo(ctx, data)
+}
+
+func (b *Bridge) record(call, executionId string, err error) {
+	if err != nil {
+		logger.Warn("could not record allure result",
+			zap.String("call", call),
+			zap.String("execution_id", executionId),
+			zap.Error(err))
+	}
+}
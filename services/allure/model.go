@@ -0,0 +1,85 @@
+package allure
+
+// nkk: Subset of the Allure 2 results format, see allure-framework/allure2 model
+// Times are unix milliseconds.
+
+const (
+	StatusPassed  = "passed"
+	StatusFailed  = "failed"
+	StatusBroken  = "broken"
+	StatusSkipped = "skipped"
+	StatusUnknown = "unknown"
+
+	stageRunning  = "running"
+	stageFinished = "finished"
+)
+
+// Result is written as <uuid>-result.json, one per testcase run
+type Result struct {
+	UUID          string         `json:"uuid"`
+	HistoryID     string         `json:"historyId"`
+	TestCaseID    string         `json:"testCaseId,omitempty"`
+	FullName      string         `json:"fullName"`
+	Name          string         `json:"name"`
+	Status        string         `json:"status"`
+	StatusDetails *StatusDetails `json:"statusDetails,omitempty"`
+	Stage         string         `json:"stage"`
+	Start         int64          `json:"start"`
+	Stop          int64          `json:"stop,omitempty"`
+	Labels        []Label        `json:"labels"`
+	Parameters    []Parameter    `json:"parameters,omitempty"`
+	Steps         []Step         `json:"steps,omitempty"`
+	Attachments   []Attachment   `json:"attachments,omitempty"`
+}
+
+// Container is written as <uuid>-container.json and groups results, pre-requisites become its befores
+type Container struct {
+	UUID     string   `json:"uuid"`
+	Name     string   `json:"name"`
+	Children []string `json:"children"`
+	Befores  []Step   `json:"befores,omitempty"`
+	Start    int64    `json:"start"`
+	Stop     int64    `json:"stop,omitempty"`
+}
+
+type StatusDetails struct {
+	Message string `json:"message,omitempty"`
+	Trace   string `json:"trace,omitempty"`
+}
+
+type Label struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type Parameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Step is also used for container fixtures, which share its shape
+type Step struct {
+	Name          string         `json:"name"`
+	Status        string         `json:"status"`
+	StatusDetails *StatusDetails `json:"statusDetails,omitempty"`
+	Stage         string         `json:"stage"`
+	Start         int64          `json:"start"`
+	Stop          int64          `json:"stop,omitempty"`
+	Steps         []Step         `json:"steps,omitempty"`
+	Attachments   []Attachment   `json:"attachments,omitempty"`
+}
+
+// Attachment points at a <uuid>-attachment.<ext> file next to the results
+type Attachment struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Type   string `json:"type"`
+}
+
+// Executor is written as executor.json
+type Executor struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	BuildName  string `json:"buildName,omitempty"`
+	ReportName string `json:"reportName,omitempty"`
+}
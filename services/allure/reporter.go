@@ -0,0 +1,682 @@
+package allure
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"agent/models/executionstatus"
+	"agent/models/executionstep"
+	"agent/models/session"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: Allure results, built from the bridge calls the agent already receives
+  <workspace>/allure/<execution_id>/<uuid>-result.json       one per testcase (and pre-requisite)
+  <workspace>/allure/<execution_id>/<uuid>-container.json    the execution, grouping its results
+  <workspace>/allure/<execution_id>/<uuid>-attachment.<ext>  screenshots and videos
+  <workspace>/allure/<execution_id>/environment.properties
+  <workspace>/allure/<execution_id>/executor.json
+Labels: testplan -> epic, testsuite -> feature, testcase -> story.
+Files are rewritten on every update so a running execution can already be downloaded.
+*/
+
+const (
+	resultsDir = "allure"
+	// nkk: Executions idle for longer are dropped from memory, their files stay on disk
+	retention = 24 * time.Hour
+	adhocName = "Ad hoc"
+)
+
+// Reporter keeps the Allure state of running executions
+type Reporter struct {
+	dir string
+	now func() time.Time
+
+	mu         sync.Mutex
+	executions map[string]*execution
+}
+
+type execution struct {
+	id        string
+	planId    string
+	planName  string
+	runName   string
+	titles    map[string]string
+	results   map[string]*result
+	order     []string
+	current   string // nkk: key of the result screenshots without testcase ids belong to
+	container Container
+	env       map[string][]string
+	machine   string
+	lastSeen  time.Time
+}
+
+type result struct {
+	Result
+	suiteId        string
+	caseId         string
+	parentCaseId   string
+	isPreRequisite bool
+	browser        string
+	lastStep       int64
+	done           bool
+}
+
+// NewReporter writes results under <workspace>/allure
+func NewReporter(workspace string) *Reporter {
+	return &Reporter{
+		dir:        filepath.Join(workspace, resultsDir),
+		now:        time.Now,
+		executions: make(map[string]*execution),
+	}
+}
+
+// Dir returns the results directory of an execution
+func (r *Reporter) Dir(executionId string) (string, error) {
+	if executionId == "" || executionId == "." || executionId == ".." || strings.ContainsAny(executionId, `/\`) {
+		return "", fmt.Errorf("invalid execution id %q", executionId)
+	}
+	return filepath.Join(r.dir, executionId), nil
+}
+
+// Describe names the testplan and testcases of an execution, ids are used until it is called
+func (r *Reporter) Describe(executionId, testplanName string, testcaseTitles map[string]string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exec, err := r.execution(executionId)
+	if err != nil {
+		return err
+	}
+	if testplanName != "" {
+		exec.planName = testplanName
+	}
+	for id, title := range testcaseTitles {
+		if title != "" {
+			exec.titles[id] = title
+		}
+	}
+	return r.writeAll(exec)
+}
+
+// Session records a saved or updated session, including its pre-requisite
+func (r *Reporter) Session(sess session.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exec, err := r.execution(sess.ExecutionId)
+	if err != nil {
+		return err
+	}
+	if err := r.trackSession(exec, sess); err != nil {
+		return err
+	}
+	return r.writeMeta(exec)
+}
+
+func (r *Reporter) trackSession(exec *execution, sess session.Session) error {
+	if sess.TestplanId != "" {
+		exec.planId = sess.TestplanId
+	}
+	if sess.RunName != "" {
+		exec.runName = sess.RunName
+	}
+	exec.addEnvironment(sess)
+
+	if sess.TestcaseId != "" {
+		res := r.result(exec, sess.TestsuiteId, sess.TestcaseId, sess.IsPreRequisite, sess.ParentTestCaseId)
+		if sess.Browser != "" {
+			res.browser = sess.Browser
+		}
+		if params := sessionParameters(sess); len(params) > 0 {
+			res.Parameters = params
+		}
+		if sess.Name != "" && exec.titles[sess.TestcaseId] == "" {
+			exec.titles[sess.TestcaseId] = sess.Name
+		}
+		message := ""
+		if sess.Reason != nil {
+			message = *sess.Reason
+		}
+		r.setStatus(exec, res, sess.Status, message)
+		if err := r.writeResult(exec, res); err != nil {
+			return err
+		}
+	}
+
+	if sess.PreRequisiteResult != nil {
+		pre := *sess.PreRequisiteResult
+		pre.ExecutionId = sess.ExecutionId
+		pre.IsPreRequisite = true
+		if pre.ParentTestCaseId == "" {
+			pre.ParentTestCaseId = sess.TestcaseId
+		}
+		return r.trackSession(exec, pre)
+	}
+	return nil
+}
+
+// Status records a status update, one without a testcase id belongs to the whole execution
+func (r *Reporter) Status(status executionstatus.ExecutionStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exec, err := r.execution(status.ExecutionId)
+	if err != nil {
+		return err
+	}
+	if status.TestplanId != "" {
+		exec.planId = status.TestplanId
+	}
+	if status.RunName != "" {
+		exec.runName = status.RunName
+	}
+	if status.MachineId != "" {
+		exec.machine = status.MachineId
+	}
+
+	if status.TestcaseId == "" {
+		if !isTerminal(status.Status) {
+			return nil
+		}
+		// nkk: The execution ended, testcases that never reported back end with it
+		exec.container.Stop = r.millis()
+		for _, key := range exec.order {
+			res := exec.results[key]
+			if !res.done {
+				r.setStatus(exec, res, status.Status, status.Message)
+				if err := r.writeResult(exec, res); err != nil {
+					return err
+				}
+			}
+		}
+		return r.writeMeta(exec)
+	}
+
+	res := r.result(exec, status.TestsuiteId, status.TestcaseId, status.IsPreRequisite, status.ParentTestCaseId)
+	r.setStatus(exec, res, status.Status, status.Message)
+	if err := r.writeResult(exec, res); err != nil {
+		return err
+	}
+	return r.writeMeta(exec)
+}
+
+// Step records a step event of the fixture, the step count is the number of finished steps
+func (r *Reporter) Step(step executionstep.ExecutionStep) error {
+	count, err := strconv.Atoi(strings.TrimSpace(step.StepCount))
+	if err != nil {
+		return fmt.Errorf("invalid step count %q", step.StepCount)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exec, err := r.execution(step.ExecutionId)
+	if err != nil {
+		return err
+	}
+	res := r.result(exec, step.TestsuiteId, step.TestcaseId, step.IsPreRequisite, step.ParentTestCaseId)
+
+	now := r.millis()
+	for len(res.Steps) < count {
+		start := res.lastStep
+		if start == 0 {
+			start = res.Start
+		}
+		res.Steps = append(res.Steps, Step{
+			Name:   fmt.Sprintf("Step %d", len(res.Steps)+1),
+			Status: StatusPassed,
+			Stage:  stageFinished,
+			Start:  start,
+			Stop:   now,
+		})
+		res.lastStep = now
+	}
+	return r.writeResult(exec, res)
+}
+
+// Screenshot attaches an image to the testcase that last reported a step or status
+func (r *Reporter) Screenshot(executionId, name string, image []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exec, err := r.execution(executionId)
+	if err != nil {
+		return err
+	}
+	res, ok := exec.results[exec.current]
+	if !ok {
+		return fmt.Errorf("no running testcase in execution %s to attach %s to", executionId, name)
+	}
+
+	attachment, err := r.writeAttachment(exec, name, contentType(name, "image/png"), func(w io.Writer) error {
+		_, err := w.Write(image)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	r.attach(res, attachment)
+	return r.writeResult(exec, res)
+}
+
+// Video attaches a recording to a testcase
+func (r *Reporter) Video(executionId, testsuiteId, testcaseId string, isPreRequisite bool, video io.Reader) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	exec, err := r.execution(executionId)
+	if err != nil {
+		return err
+	}
+	res := r.result(exec, testsuiteId, testcaseId, isPreRequisite, "")
+
+	attachment, err := r.writeAttachment(exec, "video.webm", "video/webm", func(w io.Writer) error {
+		_, err := io.Copy(w, video)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	res.Attachments = append(res.Attachments, attachment)
+	return r.writeResult(exec, res)
+}
+
+// attach adds the attachment to the step that just finished, or to the result once it is done
+func (r *Reporter) attach(res *result, attachment Attachment) {
+	if n := len(res.Steps); n > 0 && !res.done {
+		res.Steps[n-1].Attachments = append(res.Steps[n-1].Attachments, attachment)
+		return
+	}
+	res.Attachments = append(res.Attachments, attachment)
+}
+
+// execution returns the state of an execution, creating it on first use
+func (r *Reporter) execution(executionId string) (*execution, error) {
+	if _, err := r.Dir(executionId); err != nil {
+		return nil, err
+	}
+	now := r.now()
+	exec, ok := r.executions[executionId]
+	if !ok {
+		r.prune(now)
+		exec = &execution{
+			id:      executionId,
+			titles:  make(map[string]string),
+			results: make(map[string]*result),
+			env:     make(map[string][]string),
+			container: Container{
+				UUID:     uuid.New().String(),
+				Children: []string{},
+				Start:    now.UnixMilli(),
+			},
+		}
+		r.executions[executionId] = exec
+	}
+	exec.lastSeen = now
+	return exec, nil
+}
+
+func (r *Reporter) prune(now time.Time) {
+	for id, exec := range r.executions {
+		if now.Sub(exec.lastSeen) > retention {
+			delete(r.executions, id)
+		}
+	}
+}
+
+func resultKey(testsuiteId, testcaseId string, isPreRequisite bool) string {
+	return fmt.Sprintf("%s/%s/%t", testsuiteId, testcaseId, isPreRequisite)
+}
+
+// result returns the entry for a testcase, creating it on first use
+func (r *Reporter) result(exec *execution, testsuiteId, testcaseId string, isPreRequisite bool, parentTestcaseId string) *result {
+	key := resultKey(testsuiteId, testcaseId, isPreRequisite)
+	res, ok := exec.results[key]
+	if !ok {
+		res = &result{
+			Result: Result{
+				UUID:   uuid.New().String(),
+				Status: StatusUnknown,
+				Stage:  stageRunning,
+				Start:  r.millis(),
+			},
+			suiteId:        testsuiteId,
+			caseId:         testcaseId,
+			isPreRequisite: isPreRequisite,
+		}
+		exec.results[key] = res
+		exec.order = append(exec.order, key)
+		exec.container.Children = append(exec.container.Children, res.UUID)
+	}
+	if parentTestcaseId != "" {
+		res.parentCaseId = parentTestcaseId
+	}
+	if !res.done {
+		exec.current = key
+	}
+	return res
+}
+
+// setStatus maps an agent status on the result, terminal statuses are final
+func (r *Reporter) setStatus(exec *execution, res *result, status, message string) {
+	if res.done || status == "" {
+		return
+	}
+	if !isTerminal(status) {
+		return
+	}
+
+	res.Status = mapStatus(status)
+	res.Stage = stageFinished
+	res.Stop = r.millis()
+	res.done = true
+	if message != "" && message != status {
+		res.StatusDetails = &StatusDetails{Message: message}
+	} else if res.Status != StatusPassed {
+		res.StatusDetails = &StatusDetails{Message: "Testcase " + strings.ToLower(status)}
+	}
+
+	// nkk: The fixture only reports finished steps, the failure happened in the one after them
+	if res.Status == StatusFailed || res.Status == StatusBroken {
+		start := res.lastStep
+		if start == 0 {
+			start = res.Start
+		}
+		res.Steps = append(res.Steps, Step{
+			Name:          fmt.Sprintf("Step %d", len(res.Steps)+1),
+			Status:        res.Status,
+			StatusDetails: res.StatusDetails,
+			Stage:         stageFinished,
+			Start:         start,
+			Stop:          res.Stop,
+		})
+	}
+	if exec.current == resultKey(res.suiteId, res.caseId, res.isPreRequisite) {
+		exec.current = ""
+	}
+}
+
+func isTerminal(status string) bool {
+	switch status {
+	case apxconstants.Passed, apxconstants.Failed, apxconstants.Stopped, apxconstants.Timeout,
+		apxconstants.Aborted, apxconstants.NotExecuted:
+		return true
+	}
+	return false
+}
+
+func mapStatus(status string) string {
+	switch status {
+	case apxconstants.Passed:
+		return StatusPassed
+	case apxconstants.Failed:
+		return StatusFailed
+	case apxconstants.Timeout, apxconstants.Aborted:
+		return StatusBroken
+	case apxconstants.Stopped, apxconstants.NotExecuted:
+		return StatusSkipped
+	}
+	return StatusUnknown
+}
+
+func (r *Reporter) millis() int64 {
+	return r.now().UnixMilli()
+}
+
+// labels are rebuilt on every write, names may arrive after the first results
+func (exec *execution) labels(res *result) []Label {
+	epic := exec.epic()
+	feature := adhocName
+	if res.suiteId != "" {
+		feature = exec.title(res.suiteId)
+	}
+	storyId := res.caseId
+	if res.isPreRequisite && res.parentCaseId != "" {
+		storyId = res.parentCaseId
+	}
+
+	labels := []Label{
+		{Name: "epic", Value: epic},
+		{Name: "feature", Value: feature},
+		{Name: "story", Value: exec.title(storyId)},
+		{Name: "parentSuite", Value: epic},
+		{Name: "suite", Value: feature},
+		{Name: "framework", Value: "playwright"},
+		{Name: "language", Value: "javascript"},
+	}
+	if exec.machine != "" {
+		labels = append(labels, Label{Name: "host", Value: exec.machine})
+	}
+	if res.isPreRequisite {
+		labels = append(labels, Label{Name: "tag", Value: "pre-requisite"})
+	}
+	return labels
+}
+
+func (exec *execution) epic() string {
+	if exec.planName != "" {
+		return exec.planName
+	}
+	if exec.planId != "" {
+		return exec.planId
+	}
+	return adhocName
+}
+
+func (exec *execution) title(id string) string {
+	if title := exec.titles[id]; title != "" {
+		return title
+	}
+	return id
+}
+
+func (exec *execution) addEnvironment(sess session.Session) {
+	values := map[string]string{
+		"machine_id":      sess.MachineId,
+		"machine_name":    sess.MachineName,
+		"browser":         sess.Browser,
+		"browser_version": sess.BrowserVersion,
+		"os":              sess.OS,
+		"os_version":      sess.OSVersion,
+		"resolution":      sess.Resolution,
+	}
+	if c := sess.Config; c != nil {
+		for key, value := range map[string]string{
+			"machine_id":         c.MachineId,
+			"machine_name":       c.MachineName,
+			"browser":            c.Browser,
+			"browser_version":    c.BrowserVersion,
+			"os":                 c.OS,
+			"os_version":         c.OSVersion,
+			"resolution":         c.Resolution,
+			"playwright_version": c.PlaywrightVersion,
+		} {
+			if value != "" {
+				values[key] = value
+			}
+		}
+	}
+
+	if values["machine_id"] != "" {
+		exec.machine = values["machine_id"]
+	}
+	for key, value := range values {
+		if value == "" || contains(exec.env[key], value) {
+			continue
+		}
+		exec.env[key] = append(exec.env[key], value)
+	}
+}
+
+func sessionParameters(sess session.Session) []Parameter {
+	browser, version, resolution := sess.Browser, sess.BrowserVersion, sess.Resolution
+	if c := sess.Config; c != nil {
+		if c.Browser != "" {
+			browser, version = c.Browser, c.BrowserVersion
+		}
+		if c.Resolution != "" {
+			resolution = c.Resolution
+		}
+	}
+
+	var params []Parameter
+	if browser != "" {
+		params = append(params, Parameter{Name: "browser", Value: strings.TrimSpace(browser + " " + version)})
+	}
+	if resolution != "" {
+		params = append(params, Parameter{Name: "resolution", Value: resolution})
+	}
+	return params
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func contentType(name, fallback string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png":
+		return "image/png"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".webm":
+		return "video/webm"
+	case ".mp4":
+		return "video/mp4"
+	}
+	return fallback
+}
+
+func extension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "video/webm":
+		return ".webm"
+	case "video/mp4":
+		return ".mp4"
+	}
+	return ".png"
+}
+
+func (r *Reporter) writeAll(exec *execution) error {
+	for _, key := range exec.order {
+		if err := r.writeResult(exec, exec.results[key]); err != nil {
+			return err
+		}
+	}
+	return r.writeMeta(exec)
+}
+
+func (r *Reporter) writeResult(exec *execution, res *result) error {
+	name := exec.title(res.caseId)
+	if res.isPreRequisite {
+		name = "Pre-requisite: " + name
+	}
+	res.Name = name
+	res.FullName = strings.Join([]string{exec.planId, res.suiteId, res.caseId}, "/")
+	res.TestCaseID = res.caseId
+	sum := md5.Sum([]byte(fmt.Sprintf("%s/%t/%s", res.FullName, res.isPreRequisite, res.browser)))
+	res.HistoryID = hex.EncodeToString(sum[:])
+	res.Labels = exec.labels(res)
+
+	return r.writeJSON(exec, res.UUID+"-result.json", res.Result)
+}
+
+// writeMeta writes the container, environment and executor files
+func (r *Reporter) writeMeta(exec *execution) error {
+	exec.container.Name = exec.epic()
+	if err := r.writeJSON(exec, exec.container.UUID+"-container.json", exec.container); err != nil {
+		return err
+	}
+
+	buildName := exec.runName
+	if buildName == "" {
+		buildName = exec.id
+	}
+	executor := Executor{Name: "Local agent", Type: "local-agent", BuildName: buildName, ReportName: exec.container.Name}
+	if exec.machine != "" {
+		executor.Name += " " + exec.machine
+	}
+	if err := r.writeJSON(exec, "executor.json", executor); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(exec.env))
+	for key := range exec.env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var props strings.Builder
+	fmt.Fprintf(&props, "execution_id=%s\n", exec.id)
+	for _, key := range keys {
+		fmt.Fprintf(&props, "%s=%s\n", key, strings.Join(exec.env[key], ", "))
+	}
+	return r.writeFile(exec, "environment.properties", func(w io.Writer) error {
+		_, err := io.WriteString(w, props.String())
+		return err
+	})
+}
+
+func (r *Reporter) writeAttachment(exec *execution, name, contentType string, write func(io.Writer) error) (Attachment, error) {
+	source := uuid.New().String() + "-attachment" + extension(contentType)
+	if err := r.writeFile(exec, source, write); err != nil {
+		return Attachment{}, err
+	}
+	return Attachment{Name: name, Source: source, Type: contentType}, nil
+}
+
+func (r *Reporter) writeJSON(exec *execution, name string, v any) error {
+	return r.writeFile(exec, name, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(v)
+	})
+}
+
+// writeFile replaces the file atomically so a download never sees half a result
+func (r *Reporter) writeFile(exec *execution, name string, write func(io.Writer) error) error {
+	dir, err := r.Dir(exec.id)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, name))
+}
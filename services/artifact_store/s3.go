@@ -0,0 +1,104 @@
+package artifactstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// S3Options configure the S3 store, set Endpoint and ForcePathStyle for MinIO and other S3-compatible services
+type S3Options struct {
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string // nkk: empty uses the default AWS credential chain
+	SecretAccessKey string
+	ForcePathStyle  bool
+	Prefix          string
+}
+
+// S3Store keeps artifacts in an S3 bucket
+type S3Store struct {
+	client   *s3.S3
+	uploader *s3manager.Uploader
+	bucket   string
+	prefix   string
+}
+
+// NewS3Store creates the S3 store of opts
+func NewS3Store(opts S3Options) (*S3Store, error) {
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("s3 artifact storage needs a bucket")
+	}
+	cfg := aws.NewConfig().WithRegion(opts.Region).WithS3ForcePathStyle(opts.ForcePathStyle)
+	if opts.Endpoint != "" {
+		cfg = cfg.WithEndpoint(opts.Endpoint)
+	}
+	if opts.AccessKeyID != "" {
+		cfg = cfg.WithCredentials(credentials.NewStaticCredentials(opts.AccessKeyID, opts.SecretAccessKey, ""))
+	}
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 session: %w", err)
+	}
+	return &S3Store{
+		client:   s3.New(sess),
+		uploader: s3manager.NewUploader(sess),
+		bucket:   opts.Bucket,
+		prefix:   opts.Prefix,
+	}, nil
+}
+
+func (s *S3Store) Backend() string {
+	return BackendS3
+}
+
+func (s *S3Store) objectKey(key Key) string {
+	if s.prefix == "" {
+		return key.String()
+	}
+	return path.Join(s.prefix, key.String())
+}
+
+// Put uploads the file, s3manager streams it in parts so large videos are never held in memory
+func (s *S3Store) Put(ctx context.Context, key Key, filePath string, contentType string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open artifact: %w", err)
+	}
+	defer file.Close()
+
+	input := &s3manager.UploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(key)),
+		Body:   file,
+	}
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+	if _, err := s.uploader.UploadWithContext(ctx, input); err != nil {
+		return fmt.Errorf("failed to upload %s to s3: %w", key, err)
+	}
+	return nil
+}
+
+// SignedURL presigns a GET of the object, no request is made
+func (s *S3Store) SignedURL(ctx context.Context, key Key, expiry time.Duration) (string, error) {
+	request, _ := s.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.objectKey(key)),
+	})
+	url, err := request.Presign(expiry)
+	if err != nil {
+		return "", fmt.Errorf("failed to presign %s: %w", key, err)
+	}
+	return url, nil
+}
@@ -0,0 +1,183 @@
+package artifactstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+/*
+nkk: Where session artifacts (videos, traces, screenshots) end up
+- S3 covers AWS and anything speaking S3 (MinIO, R2, ...) through a custom endpoint.
+- Local writes under a directory, its URLs are HMAC-signed links the agent can serve.
+- Passthrough keeps the old behaviour, the bridge uploads the artifact to the execution service.
+Keys are <org>/<project>/<execution>/<testcase>/<kind>/<name>, the Router picks the store per org.
+*/
+
+// Backends
+const (
+	BackendPassthrough = "passthrough"
+	BackendS3          = "s3"
+	BackendLocal       = "local"
+)
+
+// Artifact kinds, the kind segment of a key
+const (
+	KindVideo       = "video"
+	KindTrace       = "trace"
+	KindScreenshot  = "screenshot"
+	KindScreenshots = "screenshots"
+	KindNetworkHAR  = "har"
+)
+
+// ErrPassthrough is returned by the passthrough store, the caller sends the artifact to the execution service itself
+var ErrPassthrough = errors.New("artifacts are passed through to the execution service")
+
+// ArtifactStore keeps artifact files and hands out download URLs for them
+type ArtifactStore interface {
+	Backend() string
+	Put(ctx context.Context, key Key, filePath string, contentType string) error
+	// SignedURL returns a URL the object can be downloaded from until expiry
+	SignedURL(ctx context.Context, key Key, expiry time.Duration) (string, error)
+}
+
+// Key locates an artifact of a session
+type Key struct {
+	OrgId       string
+	ProjectId   string
+	ExecutionId string
+	TestcaseId  string // nkk: empty for adhoc runs, stored as "_"
+	Kind        string
+	Name        string
+}
+
+// String is the object key, every segment is cleaned so a key never leaves its prefix
+func (k Key) String() string {
+	testcaseId := k.TestcaseId
+	if testcaseId == "" {
+		testcaseId = "_"
+	}
+	segments := []string{k.OrgId, k.ProjectId, k.ExecutionId, testcaseId, k.Kind, k.Name}
+	for i, segment := range segments {
+		segments[i] = cleanSegment(segment)
+	}
+	return path.Join(segments...)
+}
+
+func cleanSegment(segment string) string {
+	segment = strings.NewReplacer("/", "_", "\\", "_").Replace(segment)
+	if segment == "" || segment == "." || segment == ".." {
+		return "_"
+	}
+	return segment
+}
+
+// ParseKey is the inverse of Key.String
+func ParseKey(key string) (Key, error) {
+	segments := strings.Split(key, "/")
+	if len(segments) != 6 {
+		return Key{}, fmt.Errorf("invalid artifact key %q", key)
+	}
+	for _, segment := range segments {
+		if cleanSegment(segment) != segment {
+			return Key{}, fmt.Errorf("invalid artifact key %q", key)
+		}
+	}
+	k := Key{OrgId: segments[0], ProjectId: segments[1], ExecutionId: segments[2], TestcaseId: segments[3], Kind: segments[4], Name: segments[5]}
+	if k.TestcaseId == "_" {
+		k.TestcaseId = ""
+	}
+	return k, nil
+}
+
+// Passthrough leaves artifacts to the execution service
+type Passthrough struct{}
+
+func (Passthrough) Backend() string {
+	return BackendPassthrough
+}
+
+func (Passthrough) Put(ctx context.Context, key Key, filePath string, contentType string) error {
+	return ErrPassthrough
+}
+
+func (Passthrough) SignedURL(ctx context.Context, key Key, expiry time.Duration) (string, error) {
+	return "", ErrPassthrough
+}
+
+// Router picks the store of an org, orgs without their own use the default
+type Router struct {
+	defaultStore ArtifactStore
+	tenants      map[string]ArtifactStore
+}
+
+// NewRouter creates a router, tenants maps org IDs to their store
+func NewRouter(defaultStore ArtifactStore, tenants map[string]ArtifactStore) *Router {
+	if defaultStore == nil {
+		defaultStore = Passthrough{}
+	}
+	return &Router{defaultStore: defaultStore, tenants: tenants}
+}
+
+// For returns the store of orgId
+func (r *Router) For(orgId string) ArtifactStore {
+	if store, ok := r.tenants[orgId]; ok {
+		return store
+	}
+	return r.defaultStore
+}
+
+// Options configure the stores of New
+type Options struct {
+	Backend string
+	Tenants map[string]string // nkk: org ID -> backend
+	S3      S3Options
+	Local   LocalOptions
+}
+
+// New builds the router of opts, a backend is only created when some org uses it
+func New(opts Options) (*Router, error) {
+	stores := make(map[string]ArtifactStore)
+	get := func(backend string) (ArtifactStore, error) {
+		if backend == "" {
+			backend = BackendPassthrough
+		}
+		if store, ok := stores[backend]; ok {
+			return store, nil
+		}
+		var store ArtifactStore
+		var err error
+		switch backend {
+		case BackendPassthrough:
+			store = Passthrough{}
+		case BackendS3:
+			store, err = NewS3Store(opts.S3)
+		case BackendLocal:
+			store, err = NewLocalStore(opts.Local)
+		default:
+			err = fmt.Errorf("unknown artifact storage backend %q", backend)
+		}
+		if err != nil {
+			return nil, err
+		}
+		stores[backend] = store
+		return store, nil
+	}
+
+	defaultStore, err := get(opts.Backend)
+	if err != nil {
+		return nil, err
+	}
+	tenants := make(map[string]ArtifactStore, len(opts.Tenants))
+	for orgId, backend := range opts.Tenants {
+		store, err := get(backend)
+		if err != nil {
+			return nil, fmt.Errorf("artifact storage of org %s: %w", orgId, err)
+		}
+		tenants[orgId] = store
+	}
+	return NewRouter(defaultStore, tenants), nil
+}
@@ -0,0 +1,131 @@
+package artifactstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+nkk: Unit tests for the artifact storage backends
+*/
+
+var testKey = Key{OrgId: "o", ProjectId: "p", ExecutionId: "exec-1", TestcaseId: "tc-1", Kind: KindVideo, Name: "video.webm"}
+
+func writeArtifact(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "artifact")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestKeyLayout(t *testing.T) {
+	assert.Equal(t, "o/p/exec-1/tc-1/video/video.webm", testKey.String())
+	parsed, err := ParseKey(testKey.String())
+	require.NoError(t, err)
+	assert.Equal(t, testKey, parsed)
+
+	adhoc := Key{OrgId: "o", ProjectId: "p", ExecutionId: "exec-1", Kind: KindTrace, Name: "trace.zip"}
+	assert.Equal(t, "o/p/exec-1/_/trace/trace.zip", adhoc.String())
+	parsed, err = ParseKey(adhoc.String())
+	require.NoError(t, err)
+	assert.Equal(t, adhoc, parsed)
+
+	escape := Key{OrgId: "..", ProjectId: "p/../../etc", ExecutionId: "e", Kind: KindScreenshot, Name: "a.png"}
+	assert.Equal(t, "_/p_.._.._etc/e/_/screenshot/a.png", escape.String(), "segments cannot leave the key")
+	for _, invalid := range []string{"o/p/e", "o/../e/t/k/n", "o/p/e/t/k/"} {
+		_, err := ParseKey(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestLocalStoreSignsURLs(t *testing.T) {
+	store, err := NewLocalStore(LocalOptions{Dir: t.TempDir(), BaseURL: "http://agent:8080/artifacts/", SigningKey: "secret"})
+	require.NoError(t, err)
+	require.NoError(t, store.Put(context.Background(), testKey, writeArtifact(t, "video"), "video/webm"))
+	data, err := os.ReadFile(store.Path(testKey))
+	require.NoError(t, err)
+	assert.Equal(t, "video", string(data))
+
+	link, err := store.SignedURL(context.Background(), testKey, time.Hour)
+	require.NoError(t, err)
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+	key := strings.TrimPrefix(parsed.Path, "/artifacts/")
+	assert.Equal(t, testKey.String(), key)
+	expires, signature := parsed.Query().Get("expires"), parsed.Query().Get("signature")
+	assert.NoError(t, store.Verify(key, expires, signature))
+	assert.Error(t, store.Verify("o/p/exec-2/tc-1/video/video.webm", expires, signature), "the signature covers the key")
+	assert.Error(t, store.Verify(key, "99999999999", signature), "the signature covers the expiry")
+
+	link, err = store.SignedURL(context.Background(), testKey, -time.Minute)
+	require.NoError(t, err)
+	parsed, _ = url.Parse(link)
+	assert.EqualError(t, store.Verify(key, parsed.Query().Get("expires"), parsed.Query().Get("signature")), "link expired")
+
+	other, err := NewLocalStore(LocalOptions{Dir: t.TempDir()})
+	require.NoError(t, err)
+	link, err = other.SignedURL(context.Background(), testKey, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(link, "file://"), "without a base URL the file is linked directly")
+}
+
+func TestS3StoreUploadsToCompatibleEndpoint(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		objects[r.Method+" "+r.URL.Path] = string(body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	store, err := NewS3Store(S3Options{Endpoint: server.URL, Region: "us-east-1", Bucket: "artifacts", AccessKeyID: "minio", SecretAccessKey: "minio123", ForcePathStyle: true, Prefix: "agent"})
+	require.NoError(t, err)
+	require.NoError(t, store.Put(context.Background(), testKey, writeArtifact(t, "video"), "video/webm"))
+	mu.Lock()
+	assert.Equal(t, "video", objects["PUT /artifacts/agent/o/p/exec-1/tc-1/video/video.webm"])
+	mu.Unlock()
+
+	link, err := store.SignedURL(context.Background(), testKey, 15*time.Minute)
+	require.NoError(t, err)
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+	assert.Equal(t, "/artifacts/agent/o/p/exec-1/tc-1/video/video.webm", parsed.Path)
+	assert.Equal(t, "900", parsed.Query().Get("X-Amz-Expires"))
+	assert.NotEmpty(t, parsed.Query().Get("X-Amz-Signature"))
+
+	_, err = NewS3Store(S3Options{Region: "us-east-1"})
+	assert.Error(t, err, "a bucket is required")
+}
+
+func TestRouterPicksStorePerTenant(t *testing.T) {
+	router, err := New(Options{
+		Backend: BackendPassthrough,
+		Tenants: map[string]string{"org-local": BackendLocal},
+		Local:   LocalOptions{Dir: t.TempDir()},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, BackendLocal, router.For("org-local").Backend())
+	assert.Equal(t, BackendPassthrough, router.For("org-other").Backend())
+	assert.ErrorIs(t, router.For("org-other").Put(context.Background(), testKey, "", ""), ErrPassthrough)
+
+	_, err = New(Options{Backend: "ftp"})
+	assert.Error(t, err)
+	_, err = New(Options{Tenants: map[string]string{"org": BackendS3}})
+	assert.Error(t, err, "a tenant backend is validated even when the default does not use it")
+	assert.Equal(t, BackendPassthrough, NewRouter(nil, nil).For("org").Backend())
+}
@@ -2,8 +2,11 @@ package browser_pool
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,6 +15,7 @@ import (
 	"github.com/docker/go-connections/nat"
 	"go.uber.org/zap"
 
+	"agent/config"
 	"agent/logger"
 )
 
@@ -21,11 +25,16 @@ This is the Docker container approach - useful when you need full isolation.
 For modern usage, see playwright_manager.go which is superior.
 
 Design principles:
-1. Simple channel-based pool for thread safety
+1. One channel of idle containers per (browser, version) sub-pool, a request only gets its own key
 2. Docker containers for complete isolation
 3. Basic health checks
 4. No over-engineering - scale when needed
 
+Sub-pools are sized by browser_pool.pools (min, max, pre-warm), keys that are not listed start
+empty and grow on demand. browser_pool.max_size caps all of them together.
+Acquire takes an idle container of its key, starts one while there is room, and otherwise waits
+up to browser_pool.acquisition_timeout for a release before trying once more.
+
 Note: Playwright is preferred for BrowserStack-like service because:
 - 3x faster execution (no WebDriver overhead)
 - Native browser protocols (CDP, Firefox Remote)
@@ -33,6 +42,17 @@ Note: Playwright is preferred for BrowserStack-like service because:
 - Lighter resource usage (browser contexts vs containers)
 */
 
+// ErrDockerUnavailable is returned when the Docker daemon cannot be reached.
+// nkk: The manager is still usable in degraded mode, callers decide whether to fall back
+var ErrDockerUnavailable = errors.New("docker not available")
+
+var (
+	// ErrPoolExhausted is returned when a sub-pool stayed full for the whole acquisition timeout
+	ErrPoolExhausted = errors.New("browser pool exhausted")
+	// ErrPoolClosed is returned once the pool is shut down
+	ErrPoolClosed = errors.New("browser pool is shut down")
+)
+
 // BrowserInstance represents a browser container
 type BrowserInstance struct {
 	ID           string
@@ -45,31 +65,80 @@ type BrowserInstance struct {
 	LastUsed     time.Time
 }
 
+// PoolKey identifies the sub-pool of one browser and version
+type PoolKey struct {
+	Browser string
+	Version string
+}
+
+// NewPoolKey normalises a requested browser and version, chrome:latest when empty
+// nkk: Playwright asks for chromium (or just "playwright"), both run in the chrome containers
+func NewPoolKey(browser, version string) PoolKey {
+	key := PoolKey{Browser: strings.ToLower(strings.TrimSpace(browser)), Version: strings.TrimSpace(version)}
+	switch key.Browser {
+	case "", "chromium", "playwright":
+		key.Browser = "chrome"
+	}
+	if key.Version == "" {
+		key.Version = "latest"
+	}
+	return key
+}
+
+func (k PoolKey) String() string {
+	return k.Browser + ":" + k.Version
+}
+
+// subPool holds the idle containers of one key, its counters are guarded by the manager's mu
+type subPool struct {
+	key         PoolKey
+	minSize     int
+	maxSize     int
+	prewarmSize int
+	idle        chan *BrowserInstance // nkk: capacity maxSize, so handing one back never blocks
+	live        int                   // nkk: idle, in use and starting
+	inUse       int
+	starting    int
+}
+
 // BrowserPoolManager manages browser containers
 type BrowserPoolManager struct {
 	docker          *client.Client
-	pool            chan *BrowserInstance
+	cfg             config.BrowserPoolConfig
+	pools           map[PoolKey]*subPool
 	inUse           sync.Map
 	maxSize         int
+	live            int // nkk: containers of all sub-pools, capped by maxSize
+	closed          bool
 	mu              sync.Mutex
 	ctx             context.Context
 	cancel          context.CancelFunc
 	wg              sync.WaitGroup
 	shutdownCh      chan struct{}
 	dockerAvailable bool // Track if Docker is available
+
+	// nkk: Docker by default, replaced in tests
+	createInstance  func(browser, version string) (*BrowserInstance, error)
+	checkHealth     func(instance *BrowserInstance) bool
+	destroyInstance func(instance *BrowserInstance)
 }
 
 // nkk: Simple constructor - no over-engineering
+// Pre-warms 5 chrome:latest containers, see NewBrowserPoolManagerWithConfig for sub-pools
 func NewBrowserPoolManager(maxSize int) (*BrowserPoolManager, error) {
-	ctx, cancel := context.WithCancel(context.Background())
-	m := &BrowserPoolManager{
-		pool:            make(chan *BrowserInstance, maxSize),
-		maxSize:         maxSize,
-		ctx:             ctx,
-		cancel:          cancel,
-		shutdownCh:      make(chan struct{}),
-		dockerAvailable: false,
-	}
+	return NewBrowserPoolManagerWithConfig(config.BrowserPoolConfig{
+		MaxSize:             maxSize,
+		PrewarmSize:         min(5, maxSize),
+		HealthCheckInterval: time.Minute,
+		IdleTimeout:         5 * time.Minute,
+		AcquisitionTimeout:  30 * time.Second,
+	})
+}
+
+// NewBrowserPoolManagerWithConfig creates the sub-pools of cfg and pre-warms them once Docker answers
+func NewBrowserPoolManagerWithConfig(cfg config.BrowserPoolConfig) (*BrowserPoolManager, error) {
+	m := newBrowserPoolManager(cfg)
+	maxSize := cfg.MaxSize
 
 	// nkk: Initialize Docker client with proper socket detection
 	// Try different Docker socket paths for Mac/Linux compatibility
@@ -94,13 +163,13 @@ func NewBrowserPoolManager(maxSize int) (*BrowserPoolManager, error) {
 		logger.Info("BrowserPoolManager initialized (Docker unavailable)",
 			zap.Int("max_size", maxSize),
 			zap.Bool("docker_available", false))
-		return m, nil
+		return m, fmt.Errorf("%w: %v", ErrDockerUnavailable, err)
 	}
 
 	// Verify Docker daemon is accessible
 	pingCtx, pingCancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer pingCancel()
-	
+
 	_, err = docker.Ping(pingCtx)
 	if err != nil {
 		logger.Warn("Docker daemon not responding - browser pool will run in degraded mode",
@@ -109,7 +178,7 @@ func NewBrowserPoolManager(maxSize int) (*BrowserPoolManager, error) {
 		logger.Info("BrowserPoolManager initialized (Docker daemon not responding)",
 			zap.Int("max_size", maxSize),
 			zap.Bool("docker_available", false))
-		return m, nil
+		return m, fmt.Errorf("%w: %v", ErrDockerUnavailable, err)
 	}
 
 	m.docker = docker
@@ -122,7 +191,7 @@ func NewBrowserPoolManager(maxSize int) (*BrowserPoolManager, error) {
 		m.prewarmPool()
 	}()
 
-	// nkk: Start cleanup goroutine for stale instances
+	// nkk: Keep sub-pools between their min size and the idle timeout
 	m.wg.Add(1)
 	go func() {
 		defer m.wg.Done()
@@ -131,63 +200,144 @@ func NewBrowserPoolManager(maxSize int) (*BrowserPoolManager, error) {
 
 	logger.Info("BrowserPoolManager initialized",
 		zap.Int("max_size", maxSize),
+		zap.Int("sub_pools", len(m.pools)),
 		zap.Bool("docker_available", true))
 	return m, nil
 }
 
-// prewarmPool creates initial containers
-func (m *BrowserPoolManager) prewarmPool() {
-	// nkk: Start with just 5 Chrome instances
-	// Scale up as needed based on actual usage
-	for i := 0; i < 5; i++ {
-		instance, err := m.createBrowserContainer("chrome", "latest")
-		if err != nil {
-			logger.Error("Failed to create container", zap.Error(err))
-			continue
+// newBrowserPoolManager sets up the sub-pools without touching Docker
+func newBrowserPoolManager(cfg config.BrowserPoolConfig) *BrowserPoolManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &BrowserPoolManager{
+		cfg:             cfg,
+		pools:           make(map[PoolKey]*subPool),
+		maxSize:         cfg.MaxSize,
+		ctx:             ctx,
+		cancel:          cancel,
+		shutdownCh:      make(chan struct{}),
+		dockerAvailable: false,
+	}
+	m.createInstance = m.createBrowserContainer
+	m.checkHealth = m.isHealthy
+	m.destroyInstance = func(instance *BrowserInstance) {
+		m.destroyContainer(instance.ContainerID)
+	}
+
+	for _, pool := range cfg.Pools {
+		key := NewPoolKey(pool.Browser, pool.Version)
+		maxSize := pool.MaxSize
+		if maxSize <= 0 || maxSize > cfg.MaxSize {
+			maxSize = cfg.MaxSize
 		}
+		m.pools[key] = newSubPool(key, min(pool.MinSize, maxSize), maxSize, min(pool.PrewarmSize, maxSize))
+	}
+	// nkk: Without sub-pools the global pre-warm size warms chrome, as the single pool did
+	if len(cfg.Pools) == 0 && cfg.PrewarmSize > 0 {
+		key := NewPoolKey("chrome", "latest")
+		m.pools[key] = newSubPool(key, 0, cfg.MaxSize, min(cfg.PrewarmSize, cfg.MaxSize))
+	}
+	return m
+}
 
-		select {
-		case m.pool <- instance:
-			logger.Info("Added container to pool", zap.String("id", instance.ID))
-		default:
-			// Pool full, stop creating
-			m.destroyContainer(instance.ContainerID)
-			return
+func newSubPool(key PoolKey, minSize, maxSize, prewarmSize int) *subPool {
+	return &subPool{
+		key:         key,
+		minSize:     minSize,
+		maxSize:     maxSize,
+		prewarmSize: prewarmSize,
+		idle:        make(chan *BrowserInstance, maxSize),
+	}
+}
+
+// subPool returns the sub-pool of key, an unlisted key gets one that grows up to max_size.
+// nkk: Once shut down an unlisted key gets an already closed one
+func (m *BrowserPoolManager) subPool(key PoolKey) *subPool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pool, ok := m.pools[key]
+	if !ok && m.closed {
+		pool = newSubPool(key, 0, 0, 0)
+		close(pool.idle)
+		return pool
+	}
+	if !ok {
+		pool = newSubPool(key, 0, m.maxSize, 0)
+		m.pools[key] = pool
+	}
+	return pool
+}
+
+// sortedPools lists the sub-pools by key
+func (m *BrowserPoolManager) sortedPools() []*subPool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pools := make([]*subPool, 0, len(m.pools))
+	for _, pool := range m.pools {
+		pools = append(pools, pool)
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].key.String() < pools[j].key.String() })
+	return pools
+}
+
+// prewarmPool creates the initial containers of every sub-pool
+func (m *BrowserPoolManager) prewarmPool() {
+	for _, pool := range m.sortedPools() {
+		for i := 0; i < pool.prewarmSize && m.ctx.Err() == nil; i++ {
+			if !m.reserve(pool, false) {
+				break
+			}
+			instance, err := m.start(pool)
+			if err != nil {
+				logger.Error("Failed to create container", zap.String("pool", pool.key.String()), zap.Error(err))
+				continue
+			}
+			m.putIdle(pool, instance)
+			logger.Info("Added container to pool", zap.String("id", instance.ID), zap.String("pool", pool.key.String()))
 		}
 	}
 }
 
-// AcquireBrowser gets a browser from pool or creates new one
+// AcquireBrowser gets a browser of the requested version from its sub-pool or creates new one
 func (m *BrowserPoolManager) AcquireBrowser(ctx context.Context, browser, version string) (*BrowserInstance, error) {
-	// nkk: Try to get from pool first (fast path)
-	select {
-	case instance := <-m.pool:
-		// nkk: Quick health check
-		if m.isHealthy(instance) {
-			instance.InUse = true
-			instance.LastUsed = time.Now()
-			m.inUse.Store(instance.ID, instance)
-			return instance, nil
-		}
-		// Unhealthy, destroy and create new
-		go m.destroyContainer(instance.ContainerID)
+	pool := m.subPool(NewPoolKey(browser, version))
 
-	default:
-		// Pool empty, continue
+	// nkk: Try to get from pool first (fast path)
+	if instance, err := m.takeIdle(pool); instance != nil || err != nil {
+		return instance, err
 	}
 
-	// nkk: Create new container (slow path)
-	instance, err := m.createBrowserContainer(browser, version)
-	if err != nil {
-		return nil, err
+	// nkk: Nothing idle, start one unless the sub-pool is full or a pre-warmed one is still starting
+	if m.reserveEvicting(pool, true) {
+		return m.startLeased(pool)
 	}
 
-	instance.InUse = true
-	m.inUse.Store(instance.ID, instance)
-	return instance, nil
+	timeout := time.NewTimer(m.cfg.AcquisitionTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case instance, ok := <-pool.idle:
+			if !ok {
+				return nil, ErrPoolClosed
+			}
+			if m.checkout(pool, instance) {
+				return instance, nil
+			}
+			// Unhealthy one freed its slot
+			if m.reserveEvicting(pool, true) {
+				return m.startLeased(pool)
+			}
+		case <-timeout.C:
+			if m.reserveEvicting(pool, false) {
+				return m.startLeased(pool)
+			}
+			return nil, fmt.Errorf("%w: %s", ErrPoolExhausted, pool.key)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
 }
 
-// ReleaseBrowser returns browser to pool
+// ReleaseBrowser returns browser to the sub-pool it came from
 func (m *BrowserPoolManager) ReleaseBrowser(browser, version string, instance *BrowserInstance) {
 	if instance == nil {
 		return
@@ -195,37 +345,202 @@ func (m *BrowserPoolManager) ReleaseBrowser(browser, version string, instance *B
 
 	instance.InUse = false
 	instance.LastUsed = time.Now()
-	m.inUse.Delete(instance.ID)
+	if _, leased := m.inUse.LoadAndDelete(instance.ID); !leased {
+		return
+	}
 
-	// nkk: Non-blocking return with timeout
-	select {
-	case m.pool <- instance:
-		// Successfully returned to pool
-	case <-time.After(100 * time.Millisecond):
-		// Pool full or blocked, destroy container async
-		m.wg.Add(1)
-		go func() {
-			defer m.wg.Done()
-			m.destroyContainer(instance.ContainerID)
-		}()
+	// nkk: The instance knows its key, the arguments may name an alias
+	pool := m.subPool(NewPoolKey(instance.BrowserType, instance.Version))
+	m.mu.Lock()
+	if pool.inUse > 0 {
+		pool.inUse--
 	}
+	m.mu.Unlock()
+	m.putIdle(pool, instance)
+}
+
+// takeIdle checks out an idle container of pool, unhealthy ones are retired on the way
+func (m *BrowserPoolManager) takeIdle(pool *subPool) (*BrowserInstance, error) {
+	for {
+		select {
+		case instance, ok := <-pool.idle:
+			if !ok {
+				return nil, ErrPoolClosed
+			}
+			if m.checkout(pool, instance) {
+				return instance, nil
+			}
+		default:
+			return nil, nil
+		}
+	}
+}
+
+// checkout hands an idle container out when it is still healthy
+func (m *BrowserPoolManager) checkout(pool *subPool, instance *BrowserInstance) bool {
+	// nkk: Quick health check
+	if !m.checkHealth(instance) {
+		m.retire(pool, instance)
+		return false
+	}
+	m.lease(pool, instance)
+	return true
+}
+
+func (m *BrowserPoolManager) lease(pool *subPool, instance *BrowserInstance) {
+	m.mu.Lock()
+	pool.inUse++
+	m.mu.Unlock()
+	instance.InUse = true
+	instance.LastUsed = time.Now()
+	m.inUse.Store(instance.ID, instance)
+}
+
+// reserve takes a slot for a new container in pool and in the whole pool,
+// behindStarting gives up while containers of the key are still starting
+func (m *BrowserPoolManager) reserve(pool *subPool, behindStarting bool) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.keyBlockedLocked(pool, behindStarting) || m.live >= m.maxSize {
+		return false
+	}
+	pool.live++
+	pool.starting++
+	m.live++
+	return true
+}
+
+// reserveEvicting is reserve for an acquisition, at the global cap an idle container of another key gives up its slot.
+// nkk: Otherwise the pre-warmed containers of one key starve every other key until the acquisition timeout
+func (m *BrowserPoolManager) reserveEvicting(pool *subPool, behindStarting bool) bool {
+	m.mu.Lock()
+	if m.keyBlockedLocked(pool, behindStarting) {
+		m.mu.Unlock()
+		return false
+	}
+	var victim *subPool
+	var evicted *BrowserInstance
+	if m.live >= m.maxSize {
+		victim, evicted = m.takeIdleOfOtherLocked(pool)
+		if evicted == nil {
+			m.mu.Unlock()
+			return false
+		}
+		// nkk: The slot goes straight to pool, no other acquisition can take it in between
+		victim.live--
+	} else {
+		m.live++
+	}
+	pool.live++
+	pool.starting++
+	m.mu.Unlock()
+
+	if evicted != nil {
+		logger.Info("Evicting idle container for another pool",
+			zap.String("id", evicted.ID),
+			zap.String("from", victim.key.String()),
+			zap.String("for", pool.key.String()))
+		m.destroyLater(evicted)
+	}
+	return true
+}
+
+// keyBlockedLocked reports whether pool may not start a container, whatever the global cap
+func (m *BrowserPoolManager) keyBlockedLocked(pool *subPool, behindStarting bool) bool {
+	return m.closed || (behindStarting && pool.starting > 0) || pool.live >= pool.maxSize
+}
+
+// takeIdleOfOtherLocked takes an idle container of a key other than pool's, sub-pools above their min size first
+func (m *BrowserPoolManager) takeIdleOfOtherLocked(pool *subPool) (*subPool, *BrowserInstance) {
+	for _, aboveMinOnly := range []bool{true, false} {
+		for _, other := range m.pools {
+			if other == pool || (aboveMinOnly && other.live <= other.minSize) {
+				continue
+			}
+			select {
+			case instance := <-other.idle:
+				return other, instance
+			default:
+			}
+		}
+	}
+	return nil, nil
+}
+
+// start creates the container of a reserved slot, the slot is given back when that fails
+func (m *BrowserPoolManager) start(pool *subPool) (*BrowserInstance, error) {
+	instance, err := m.createInstance(pool.key.Browser, pool.key.Version)
+	m.mu.Lock()
+	pool.starting--
+	if err != nil {
+		pool.live--
+		m.live--
+	}
+	m.mu.Unlock()
+	return instance, err
+}
+
+func (m *BrowserPoolManager) startLeased(pool *subPool) (*BrowserInstance, error) {
+	instance, err := m.start(pool)
+	if err != nil {
+		return nil, err
+	}
+	m.lease(pool, instance)
+	return instance, nil
+}
+
+// putIdle hands a container back to pool, or retires it once the pool is shut down
+func (m *BrowserPoolManager) putIdle(pool *subPool, instance *BrowserInstance) {
+	m.mu.Lock()
+	if !m.closed {
+		select {
+		case pool.idle <- instance:
+			m.mu.Unlock()
+			return
+		default:
+		}
+	}
+	m.mu.Unlock()
+	m.retire(pool, instance)
+}
+
+// retire frees the slot of a container and destroys it in the background
+func (m *BrowserPoolManager) retire(pool *subPool, instance *BrowserInstance) {
+	m.mu.Lock()
+	pool.live--
+	m.live--
+	m.mu.Unlock()
+	m.destroyLater(instance)
+}
+
+// destroyLater destroys a container whose slot is already freed in the background
+func (m *BrowserPoolManager) destroyLater(instance *BrowserInstance) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.destroyInstance(instance)
+	}()
 }
 
 // createBrowserContainer creates a new Docker container
 func (m *BrowserPoolManager) createBrowserContainer(browser, version string) (*BrowserInstance, error) {
+	if !m.dockerAvailable {
+		return nil, ErrDockerUnavailable
+	}
+
 	// nkk: Use ARM64 compatible image for Mac M1/M2
-	// seleniarm images work on ARM64 architecture
+	// seleniarm images work on ARM64 architecture, the version is the image tag
 	var image string
 	if browser == "chrome" || browser == "chromium" {
-		image = "seleniarm/standalone-chromium:latest"
+		image = "seleniarm/standalone-chromium:" + version
 	} else if browser == "firefox" {
-		image = "seleniarm/standalone-firefox:latest"
+		image = "seleniarm/standalone-firefox:" + version
 	} else {
-		image = fmt.Sprintf("seleniarm/standalone-%s:latest", browser)
+		image = fmt.Sprintf("seleniarm/standalone-%s:%s", browser, version)
 	}
 
 	// nkk: Simple container config
-	config := &container.Config{
+	containerConfig := &container.Config{
 		Image: image,
 		ExposedPorts: nat.PortSet{
 			"4444/tcp": {}, // WebDriver port
@@ -247,7 +562,7 @@ func (m *BrowserPoolManager) createBrowserContainer(browser, version string) (*B
 	// Create container
 	resp, err := m.docker.ContainerCreate(
 		context.Background(),
-		config,
+		containerConfig,
 		hostConfig,
 		nil,
 		nil,
@@ -314,6 +629,9 @@ func (m *BrowserPoolManager) waitForReady(instance *BrowserInstance) error {
 // isHealthy checks if container is healthy
 func (m *BrowserPoolManager) isHealthy(instance *BrowserInstance) bool {
 	// nkk: Simple health check - is container running?
+	if !m.dockerAvailable {
+		return false
+	}
 	inspect, err := m.docker.ContainerInspect(context.Background(), instance.ContainerID)
 	if err != nil || !inspect.State.Running {
 		return false
@@ -323,6 +641,10 @@ func (m *BrowserPoolManager) isHealthy(instance *BrowserInstance) bool {
 
 // destroyContainer removes a container
 func (m *BrowserPoolManager) destroyContainer(containerID string) {
+	if !m.dockerAvailable {
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -342,19 +664,26 @@ func (m *BrowserPoolManager) Shutdown() {
 	m.cancel()
 
 	// Stop accepting new requests
-	close(m.pool)
+	m.mu.Lock()
+	m.closed = true
+	for _, pool := range m.pools {
+		close(pool.idle)
+	}
+	m.mu.Unlock()
 
 	// Only clean up if Docker is available
 	if m.dockerAvailable {
 		// Destroy pooled containers
-		for instance := range m.pool {
-			m.destroyContainer(instance.ContainerID)
+		for _, pool := range m.sortedPools() {
+			for instance := range pool.idle {
+				m.destroyInstance(instance)
+			}
 		}
 
 		// Destroy in-use containers
 		m.inUse.Range(func(key, value interface{}) bool {
 			instance := value.(*BrowserInstance)
-			m.destroyContainer(instance.ContainerID)
+			m.destroyInstance(instance)
 			return true
 		})
 	}
@@ -370,76 +699,166 @@ func (m *BrowserPoolManager) Shutdown() {
 	logger.Info("BrowserPoolManager shutdown complete")
 }
 
-// cleanupStaleInstances removes instances idle for too long
+// cleanupStaleInstances keeps every sub-pool between its min size and the idle timeout
 func (m *BrowserPoolManager) cleanupStaleInstances() {
-	ticker := time.NewTicker(1 * time.Minute)
+	interval := m.cfg.HealthCheckInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			m.inUse.Range(func(key, value interface{}) bool {
-				instance := value.(*BrowserInstance)
-				// nkk: Clean up instances idle for > 5 minutes
-				if time.Since(instance.LastUsed) > 5*time.Minute && !instance.InUse {
-					logger.Info("Cleaning stale instance",
-						zap.String("id", instance.ID),
-						zap.Duration("idle", time.Since(instance.LastUsed)))
-					m.inUse.Delete(key)
-					go m.destroyContainer(instance.ContainerID)
-				}
-				return true
-			})
+			for _, pool := range m.sortedPools() {
+				m.maintain(pool)
+			}
 		case <-m.shutdownCh:
 			return
 		}
 	}
 }
 
-// GetPoolStats returns statistics about the pool
-func (m *BrowserPoolManager) GetPoolStats() map[string]map[string]interface{} {
-	stats := make(map[string]map[string]interface{})
-
-	// Count available browsers
-	available := len(m.pool)
-
-	// Count in-use browsers
-	inUse := 0
-	m.inUse.Range(func(_, _ interface{}) bool {
-		inUse++
-		return true
-	})
+// maintain retires idle containers that are unhealthy or idle too long, then tops pool up to its min size
+func (m *BrowserPoolManager) maintain(pool *subPool) {
+	for i := len(pool.idle); i > 0; i-- {
+		var instance *BrowserInstance
+		select {
+		case instance = <-pool.idle:
+		default:
+		}
+		if instance == nil {
+			break
+		}
+		m.mu.Lock()
+		aboveMin := pool.live > pool.minSize
+		m.mu.Unlock()
+		idle := time.Since(instance.LastUsed)
+		if aboveMin && m.cfg.IdleTimeout > 0 && idle > m.cfg.IdleTimeout {
+			logger.Info("Cleaning stale instance",
+				zap.String("id", instance.ID),
+				zap.String("pool", pool.key.String()),
+				zap.Duration("idle", idle))
+			m.retire(pool, instance)
+			continue
+		}
+		if !m.checkHealth(instance) {
+			m.retire(pool, instance)
+			continue
+		}
+		m.putIdle(pool, instance)
+	}
 
-	stats["chrome"] = map[string]interface{}{
-		"available": available,
-		"in_use":    inUse,
-		"total":     m.maxSize,
+	for m.ctx.Err() == nil {
+		m.mu.Lock()
+		belowMin := pool.live < pool.minSize
+		m.mu.Unlock()
+		if !belowMin || !m.reserve(pool, false) {
+			return
+		}
+		instance, err := m.start(pool)
+		if err != nil {
+			logger.Error("Failed to replace container", zap.String("pool", pool.key.String()), zap.Error(err))
+			return
+		}
+		m.putIdle(pool, instance)
 	}
+}
 
+// GetPoolStats returns statistics about each sub-pool
+func (m *BrowserPoolManager) GetPoolStats() map[string]map[string]interface{} {
+	stats := make(map[string]map[string]interface{})
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, pool := range m.pools {
+		stats[key.String()] = map[string]interface{}{
+			"browser":   key.Browser,
+			"version":   key.Version,
+			"available": len(pool.idle),
+			"in_use":    pool.inUse,
+			"starting":  pool.starting,
+			"total":     pool.maxSize,
+			"min":       pool.minSize,
+			"prewarm":   pool.prewarmSize,
+		}
+	}
 	return stats
 }
 
-// CreatePool creates a new browser pool
+// CreatePool starts size idle containers in the sub-pool of browser and version
 func (m *BrowserPoolManager) CreatePool(browser, version string, size int) error {
 	// nkk: Create pool for specific browser type
+	if !m.dockerAvailable {
+		return ErrDockerUnavailable
+	}
+	pool := m.subPool(NewPoolKey(browser, version))
 	for i := 0; i < size; i++ {
-		instance, err := m.createBrowserContainer(browser, version)
+		if !m.reserve(pool, false) {
+			// Pool full
+			break
+		}
+		instance, err := m.start(pool)
 		if err != nil {
 			logger.Error("Failed to create browser in pool",
 				zap.String("browser", browser),
 				zap.Error(err))
 			continue
 		}
+		m.putIdle(pool, instance)
+	}
 
-		select {
-		case m.pool <- instance:
-			// Added to pool
-		default:
-			// Pool full
-			m.destroyContainer(instance.ContainerID)
-			break
+	return nil
+}
+
+// Acquire implements BrowserPool
+func (m *BrowserPoolManager) Acquire(ctx context.Context, browser, version string) (*Lease, error) {
+	instance, err := m.AcquireBrowser(ctx, browser, version)
+	if err != nil {
+		return nil, err
+	}
+	return &Lease{
+		ID:           instance.ID,
+		BrowserType:  instance.BrowserType,
+		Version:      instance.Version,
+		WebDriverURL: instance.WebDriverURL,
+		instance:     instance,
+	}, nil
+}
+
+// Release implements BrowserPool
+func (m *BrowserPoolManager) Release(lease *Lease) {
+	if lease == nil {
+		return
+	}
+	if instance, ok := lease.instance.(*BrowserInstance); ok {
+		m.ReleaseBrowser(lease.BrowserType, lease.Version, instance)
+	}
+}
+
+// Stats implements BrowserPool, keyed by browser:version
+func (m *BrowserPoolManager) Stats() map[string]PoolStats {
+	stats := make(map[string]PoolStats)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, pool := range m.pools {
+		stats[key.String()] = PoolStats{
+			Available: len(pool.idle),
+			InUse:     pool.inUse,
+			Starting:  pool.starting,
+			Total:     pool.maxSize,
 		}
 	}
+	return stats
+}
 
+// Health implements BrowserPool, the pool is healthy while the Docker daemon answers
+func (m *BrowserPoolManager) Health(ctx context.Context) error {
+	if !m.dockerAvailable {
+		return ErrDockerUnavailable
+	}
+	if _, err := m.docker.Ping(ctx); err != nil {
+		return fmt.Errorf("%w: %v", ErrDockerUnavailable, err)
+	}
 	return nil
 }
@@ -6,7 +6,6 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
 )
 
 /*
@@ -25,7 +24,7 @@ func TestNewBrowserPoolManager(t *testing.T) {
 
 	assert.NotNil(t, manager)
 	assert.Equal(t, 5, manager.maxSize)
-	assert.NotNil(t, manager.pool)
+	assert.NotNil(t, manager.pools)
 	assert.NotNil(t, manager.docker)
 
 	// Cleanup
@@ -59,7 +58,7 @@ func TestPoolSizeManagement(t *testing.T) {
 	defer manager.Shutdown()
 
 	// Pool should be created with correct size
-	assert.Equal(t, 3, cap(manager.pool))
+	assert.Equal(t, 3, cap(manager.subPool(NewPoolKey("chrome", "latest")).idle))
 }
 
 func TestConcurrentAccess(t *testing.T) {
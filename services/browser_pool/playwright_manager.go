@@ -455,4 +455,58 @@ func (m *PlaywrightPoolManager) TakeScreenshot(instance *PlaywrightBrowserInstan
 	return instance.Page.Screenshot(playwright.PageScreenshotOptions{
 		FullPage: playwright.Bool(true),
 	})
-}
\ No newline at end of file
+}
+// Acquire implements BrowserPool
+func (m *PlaywrightPoolManager) Acquire(ctx context.Context, browser, version string) (*Lease, error) {
+	instance, err := m.AcquireBrowser(ctx, browser, version)
+	if err != nil {
+		return nil, err
+	}
+	return &Lease{
+		ID:          instance.ID,
+		BrowserType: instance.BrowserType,
+		Version:     version,
+		instance:    instance,
+	}, nil
+}
+
+// Release implements BrowserPool
+func (m *PlaywrightPoolManager) Release(lease *Lease) {
+	if lease == nil {
+		return
+	}
+	if instance, ok := lease.instance.(*PlaywrightBrowserInstance); ok {
+		m.ReleaseBrowser(instance)
+	}
+}
+
+// Stats implements BrowserPool
+func (m *PlaywrightPoolManager) Stats() map[string]PoolStats {
+	stats := make(map[string]PoolStats)
+	raw := m.GetPoolStats()
+	inUse, _ := raw["by_type"].(map[string]int)
+	available, _ := raw["available_by_type"].(map[string]int)
+	for browser, count := range inUse {
+		entry := stats[browser]
+		entry.InUse = count
+		stats[browser] = entry
+	}
+	for browser, count := range available {
+		entry := stats[browser]
+		entry.Available = count
+		stats[browser] = entry
+	}
+	for browser, entry := range stats {
+		entry.Total = m.maxSize
+		stats[browser] = entry
+	}
+	return stats
+}
+
+// Health implements BrowserPool, the pool is healthy until it is shut down
+func (m *PlaywrightPoolManager) Health(ctx context.Context) error {
+	if m.ctx.Err() != nil {
+		return fmt.Errorf("playwright pool is shut down")
+	}
+	return nil
+}
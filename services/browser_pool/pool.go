@@ -0,0 +1,68 @@
+package browser_pool
+
+import (
+	"context"
+	"fmt"
+
+	"agent/config"
+)
+
+/*
+nkk: BrowserPool is what the executor, health checks and shutdown use, whichever backend hands out the browsers.
+The Docker and Playwright managers keep their typed AcquireBrowser/ReleaseBrowser for direct callers,
+the interface wraps their instances in a Lease so Release needs nothing but what Acquire returned.
+*/
+
+// Pool backends, selected with DynamicConfig.BrowserPool.Backend
+const (
+	BackendDocker     = "docker"
+	BackendPlaywright = "playwright"
+)
+
+// BrowserPool hands out pooled browsers
+type BrowserPool interface {
+	// Acquire takes a browser from the pool or starts a new one
+	Acquire(ctx context.Context, browser, version string) (*Lease, error)
+	// Release hands a leased browser back, a nil lease is ignored
+	Release(lease *Lease)
+	// Stats reports the pool per browser
+	Stats() map[string]PoolStats
+	// Health returns an error when the pool cannot hand out browsers
+	Health(ctx context.Context) error
+	Shutdown()
+}
+
+// Lease is a browser handed out by a BrowserPool
+type Lease struct {
+	ID           string
+	BrowserType  string
+	Version      string
+	WebDriverURL string // nkk: Docker backend only, Playwright browsers live in the agent process
+
+	instance any
+}
+
+// PoolStats counts the browsers of one pool
+type PoolStats struct {
+	Available int `json:"available"`
+	InUse     int `json:"in_use"`
+	Starting  int `json:"starting"`
+	Total     int `json:"total"` // nkk: the most browsers the pool may hold
+}
+
+// NewBrowserPool starts the pool of cfg.Backend.
+// nkk: Like NewBrowserPoolManager, a Docker pool without a daemon is returned with ErrDockerUnavailable
+func NewBrowserPool(cfg config.BrowserPoolConfig) (BrowserPool, error) {
+	switch cfg.Backend {
+	case BackendDocker, "":
+		manager, err := NewBrowserPoolManagerWithConfig(cfg)
+		return manager, err
+	case BackendPlaywright:
+		manager, err := NewPlaywrightPoolManager(cfg.MaxSize)
+		if err != nil {
+			return nil, err
+		}
+		return manager, nil
+	}
+	return nil, fmt.Errorf("unknown browser pool backend %q", cfg.Backend)
+}
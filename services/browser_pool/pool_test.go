@@ -0,0 +1,254 @@
+package browser_pool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/config"
+)
+
+/*
+nkk: Unit tests for the BrowserPool interface and the keyed sub-pools
+Containers come from a fake factory, so nothing is started
+*/
+
+type fakeContainers struct {
+	mu        sync.Mutex
+	created   map[string]int
+	destroyed []string
+	unhealthy map[string]bool
+}
+
+// newFakeManager is a Docker manager whose containers are made up
+func newFakeManager(cfg config.BrowserPoolConfig) (*BrowserPoolManager, *fakeContainers) {
+	fake := &fakeContainers{created: make(map[string]int), unhealthy: make(map[string]bool)}
+	m := newBrowserPoolManager(cfg)
+	m.createInstance = func(browser, version string) (*BrowserInstance, error) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		key := NewPoolKey(browser, version).String()
+		fake.created[key]++
+		id := fmt.Sprintf("%s-%d", key, fake.created[key])
+		return &BrowserInstance{ID: id, ContainerID: id, BrowserType: browser, Version: version, Healthy: true, LastUsed: time.Now()}, nil
+	}
+	m.checkHealth = func(instance *BrowserInstance) bool {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		return !fake.unhealthy[instance.ID]
+	}
+	m.destroyInstance = func(instance *BrowserInstance) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		fake.destroyed = append(fake.destroyed, instance.ID)
+	}
+	return m, fake
+}
+
+func (f *fakeContainers) count(key string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.created[key]
+}
+
+func poolConfig(maxSize int, pools ...config.BrowserSubPoolConfig) config.BrowserPoolConfig {
+	return config.BrowserPoolConfig{
+		MaxSize:            maxSize,
+		IdleTimeout:        time.Minute,
+		AcquisitionTimeout: time.Second,
+		Pools:              pools,
+	}
+}
+
+func TestNewBrowserPoolRejectsUnknownBackend(t *testing.T) {
+	pool, err := NewBrowserPool(config.BrowserPoolConfig{Backend: "selenium-grid", MaxSize: 5})
+	assert.Error(t, err)
+	assert.Nil(t, pool)
+}
+
+func TestAcquireUsesMatchingSubPool(t *testing.T) {
+	m, fake := newFakeManager(poolConfig(10, config.BrowserSubPoolConfig{Browser: "chrome", Version: "latest", PrewarmSize: 2}))
+	m.prewarmPool()
+	require.Equal(t, 2, fake.count("chrome:latest"))
+
+	firefox, err := m.AcquireBrowser(context.Background(), "Firefox", "")
+	require.NoError(t, err)
+	assert.Equal(t, "firefox", firefox.BrowserType, "a firefox request never gets a pre-warmed chrome")
+	assert.Equal(t, "latest", firefox.Version)
+
+	chrome, err := m.AcquireBrowser(context.Background(), "chrome", "latest")
+	require.NoError(t, err)
+	assert.Equal(t, 2, fake.count("chrome:latest"), "chrome comes from the pre-warmed containers")
+
+	firefoxOld, err := m.AcquireBrowser(context.Background(), "firefox", "115")
+	require.NoError(t, err)
+	assert.Equal(t, "115", firefoxOld.Version)
+
+	assert.Equal(t, map[string]PoolStats{
+		"chrome:latest":  {Available: 1, InUse: 1, Total: 10},
+		"firefox:latest": {Available: 0, InUse: 1, Total: 10},
+		"firefox:115":    {Available: 0, InUse: 1, Total: 10},
+	}, m.Stats())
+
+	m.ReleaseBrowser("firefox", "", firefox)
+	m.ReleaseBrowser("chrome", "latest", chrome)
+	stats := m.Stats()
+	assert.Equal(t, PoolStats{Available: 1, Total: 10}, stats["firefox:latest"])
+	assert.Equal(t, PoolStats{Available: 2, Total: 10}, stats["chrome:latest"])
+}
+
+func TestPlaywrightBrowsersUsePrewarmedChrome(t *testing.T) {
+	m, fake := newFakeManager(poolConfig(2, config.BrowserSubPoolConfig{Browser: "chrome", Version: "latest", PrewarmSize: 2}))
+	m.prewarmPool()
+
+	chromium, err := m.AcquireBrowser(context.Background(), "chromium", "latest")
+	require.NoError(t, err)
+	queued, err := m.AcquireBrowser(context.Background(), "playwright", "latest")
+	require.NoError(t, err)
+	assert.Equal(t, 2, fake.count("chrome:latest"), "both come from the pre-warmed containers")
+	assert.Empty(t, fake.destroyed)
+	assert.Equal(t, map[string]PoolStats{"chrome:latest": {InUse: 2, Total: 2}}, m.Stats())
+
+	m.ReleaseBrowser("chromium", "latest", chromium)
+	m.ReleaseBrowser("playwright", "latest", queued)
+	assert.Equal(t, PoolStats{Available: 2, Total: 2}, m.Stats()["chrome:latest"])
+}
+
+func TestAcquireWaitsForReleaseWhenFull(t *testing.T) {
+	m, fake := newFakeManager(poolConfig(10, config.BrowserSubPoolConfig{Browser: "chrome", Version: "latest", MaxSize: 1}))
+
+	first, err := m.AcquireBrowser(context.Background(), "chrome", "latest")
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		m.ReleaseBrowser("chrome", "latest", first)
+	}()
+	second, err := m.AcquireBrowser(context.Background(), "chrome", "latest")
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, 1, fake.count("chrome:latest"), "the released container is reused")
+}
+
+func TestAcquireTimesOutWhenFull(t *testing.T) {
+	cfg := poolConfig(10, config.BrowserSubPoolConfig{Browser: "chrome", Version: "latest", MaxSize: 1})
+	cfg.AcquisitionTimeout = 20 * time.Millisecond
+	m, _ := newFakeManager(cfg)
+
+	_, err := m.AcquireBrowser(context.Background(), "chrome", "latest")
+	require.NoError(t, err)
+	_, err = m.AcquireBrowser(context.Background(), "chrome", "latest")
+	assert.ErrorIs(t, err, ErrPoolExhausted)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = m.AcquireBrowser(ctx, "chrome", "latest")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestMaxSizeCapsAllSubPools(t *testing.T) {
+	cfg := poolConfig(2)
+	cfg.AcquisitionTimeout = 20 * time.Millisecond
+	m, _ := newFakeManager(cfg)
+
+	_, err := m.AcquireBrowser(context.Background(), "chrome", "latest")
+	require.NoError(t, err)
+	_, err = m.AcquireBrowser(context.Background(), "firefox", "latest")
+	require.NoError(t, err)
+	_, err = m.AcquireBrowser(context.Background(), "webkit", "latest")
+	assert.ErrorIs(t, err, ErrPoolExhausted)
+}
+
+func TestAcquireEvictsIdleContainerOfOtherKeyAtMaxSize(t *testing.T) {
+	cfg := poolConfig(2, config.BrowserSubPoolConfig{Browser: "chrome", Version: "latest", PrewarmSize: 2})
+	cfg.AcquisitionTimeout = 20 * time.Millisecond
+	m, fake := newFakeManager(cfg)
+	m.prewarmPool()
+	require.Equal(t, 2, fake.count("chrome:latest"))
+
+	// Pre-warmed chrome fills the global cap, firefox still gets a browser
+	firefox, err := m.AcquireBrowser(context.Background(), "firefox", "latest")
+	require.NoError(t, err)
+	assert.Equal(t, "firefox", firefox.BrowserType)
+	m.wg.Wait()
+	require.Len(t, fake.destroyed, 1)
+	assert.Contains(t, fake.destroyed[0], "chrome:latest")
+	assert.Equal(t, 1, m.Stats()["chrome:latest"].Available)
+
+	// In-use containers are never evicted
+	_, err = m.AcquireBrowser(context.Background(), "chrome", "latest")
+	require.NoError(t, err)
+	_, err = m.AcquireBrowser(context.Background(), "webkit", "latest")
+	assert.ErrorIs(t, err, ErrPoolExhausted)
+}
+
+func TestUnhealthyIdleContainerIsReplaced(t *testing.T) {
+	m, fake := newFakeManager(poolConfig(10, config.BrowserSubPoolConfig{Browser: "chrome", Version: "latest", MaxSize: 1, PrewarmSize: 1}))
+	m.prewarmPool()
+	fake.unhealthy["chrome:latest-1"] = true
+
+	instance, err := m.AcquireBrowser(context.Background(), "chrome", "latest")
+	require.NoError(t, err)
+	assert.Equal(t, "chrome:latest-2", instance.ID)
+	m.wg.Wait()
+	assert.Equal(t, []string{"chrome:latest-1"}, fake.destroyed)
+}
+
+func TestMaintainKeepsMinSize(t *testing.T) {
+	cfg := poolConfig(10, config.BrowserSubPoolConfig{Browser: "chrome", Version: "latest", MinSize: 1, PrewarmSize: 3})
+	cfg.IdleTimeout = time.Millisecond
+	m, fake := newFakeManager(cfg)
+	m.prewarmPool()
+	time.Sleep(5 * time.Millisecond)
+
+	pool := m.subPool(NewPoolKey("chrome", "latest"))
+	m.maintain(pool)
+	m.wg.Wait()
+	assert.Len(t, fake.destroyed, 2, "idle containers above the min size are retired")
+	assert.Equal(t, 1, m.Stats()["chrome:latest"].Available)
+
+	fake.unhealthy["chrome:latest-3"] = true
+	m.maintain(pool)
+	m.wg.Wait()
+	assert.Equal(t, 4, fake.count("chrome:latest"), "a lost container below the min size is replaced")
+	assert.Equal(t, 1, m.Stats()["chrome:latest"].Available)
+}
+
+func TestDockerPoolLeases(t *testing.T) {
+	m, _ := newFakeManager(poolConfig(2))
+	var pool BrowserPool = m
+
+	lease, err := pool.Acquire(context.Background(), "chrome", "latest")
+	require.NoError(t, err)
+	assert.Equal(t, "chrome", lease.BrowserType)
+	assert.Equal(t, PoolStats{InUse: 1, Total: 2}, pool.Stats()["chrome:latest"])
+
+	pool.Release(lease)
+	assert.Equal(t, PoolStats{Available: 1, Total: 2}, pool.Stats()["chrome:latest"])
+
+	assert.NotPanics(t, func() {
+		pool.Release(nil)
+		pool.Release(&Lease{ID: "other", instance: &PlaywrightBrowserInstance{}})
+		pool.Release(lease)
+	}, "foreign, empty and repeated releases are ignored")
+	assert.Equal(t, PoolStats{Available: 1, Total: 2}, pool.Stats()["chrome:latest"])
+	assert.ErrorIs(t, pool.Health(context.Background()), ErrDockerUnavailable)
+}
+
+func TestShutdownClosesSubPools(t *testing.T) {
+	m, _ := newFakeManager(poolConfig(2))
+	instance, err := m.AcquireBrowser(context.Background(), "chrome", "latest")
+	require.NoError(t, err)
+
+	m.Shutdown()
+	_, err = m.AcquireBrowser(context.Background(), "chrome", "latest")
+	assert.ErrorIs(t, err, ErrPoolClosed)
+	_, err = m.AcquireBrowser(context.Background(), "firefox", "latest")
+	assert.ErrorIs(t, err, ErrPoolClosed)
+	assert.NotPanics(t, func() { m.ReleaseBrowser("chrome", "latest", instance) })
+}
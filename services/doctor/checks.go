@@ -0,0 +1,309 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/client"
+
+	"agent/config"
+	"agent/services/playwright_runtime"
+)
+
+// nkk: Playwright supports the current Node LTS lines only
+const minNodeMajor = 18
+
+func (d *Doctor) checkNode(ctx context.Context) Result {
+	out, err := d.opts.RunCommand(ctx, "node", "--version")
+	if err != nil {
+		return Result{
+			Status:  StatusFail,
+			Message: fmt.Sprintf("node is not available: %v", err),
+			Fix:     fmt.Sprintf("Install Node.js %d or newer (%s) and make sure it is on PATH", minNodeMajor, installHint("node", "nodejs", "OpenJS.NodeJS.LTS")),
+		}
+	}
+
+	version := strings.TrimPrefix(out, "v")
+	var major int
+	fmt.Sscanf(version, "%d", &major)
+
+	result := Result{
+		Status:  StatusOK,
+		Message: "node " + out,
+		Details: map[string]interface{}{"version": version},
+	}
+	if major < minNodeMajor {
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("node %s is too old, Playwright needs %d or newer", out, minNodeMajor)
+		result.Fix = fmt.Sprintf("Upgrade Node.js (%s)", installHint("node", "nodejs", "OpenJS.NodeJS.LTS"))
+	}
+	return result
+}
+
+func (d *Doctor) checkNpm(ctx context.Context) Result {
+	out, err := d.opts.RunCommand(ctx, "npm", "--version")
+	if err != nil {
+		return Result{
+			Status:  StatusFail,
+			Message: fmt.Sprintf("npm is not available: %v", err),
+			Fix:     "npm ships with Node.js, reinstall Node.js and make sure npm is on PATH",
+		}
+	}
+	return Result{
+		Status:  StatusOK,
+		Message: "npm " + out,
+		Details: map[string]interface{}{"version": out},
+	}
+}
+
+// defaultRuntime returns the installed default Playwright runtime, nil when it is missing
+func (d *Doctor) defaultRuntime() (*playwright_runtime.Runtime, error) {
+	if d.opts.Runtimes == nil {
+		return nil, errors.New("no playwright runtime manager configured")
+	}
+	runtimes, err := d.opts.Runtimes.List()
+	if err != nil {
+		return nil, err
+	}
+	for i := range runtimes {
+		if runtimes[i].Version == d.opts.Runtimes.DefaultVersion() {
+			return &runtimes[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (d *Doctor) runtimeFix() string {
+	version := ""
+	if d.opts.Runtimes != nil {
+		version = d.opts.Runtimes.DefaultVersion()
+	}
+	return fmt.Sprintf("Run `agent run --install` or `agent serve` to install Playwright %s, or import an offline bundle", version)
+}
+
+func (d *Doctor) checkPlaywrightRuntime(ctx context.Context) Result {
+	rt, err := d.defaultRuntime()
+	if err != nil {
+		return Result{Status: StatusFail, Message: err.Error(), Fix: d.runtimeFix()}
+	}
+	if rt == nil {
+		return Result{
+			Status:  StatusFail,
+			Message: fmt.Sprintf("playwright %s is not installed", d.opts.Runtimes.DefaultVersion()),
+			Fix:     d.runtimeFix(),
+		}
+	}
+	return Result{
+		Status:  StatusOK,
+		Message: fmt.Sprintf("playwright %s installed in %s", rt.Version, rt.Dir),
+		Details: map[string]interface{}{"version": rt.Version, "dir": rt.Dir},
+	}
+}
+
+func (d *Doctor) checkBrowser(ctx context.Context, name string) Result {
+	required := d.browserRequired(name)
+	missing := StatusWarn
+	if required {
+		missing = StatusFail
+	}
+
+	rt, err := d.defaultRuntime()
+	if err != nil || rt == nil {
+		return Result{
+			Status:  missing,
+			Message: "playwright runtime is not installed",
+			Fix:     d.runtimeFix(),
+			Details: map[string]interface{}{"required": required},
+		}
+	}
+
+	for _, browser := range rt.Browsers {
+		if browser.Name != name {
+			continue
+		}
+		details := map[string]interface{}{
+			"required":        required,
+			"revision":        browser.Revision,
+			"browser_version": browser.BrowserVersion,
+		}
+		if browser.Installed {
+			return Result{
+				Status:  StatusOK,
+				Message: fmt.Sprintf("%s %s installed", name, browser.Revision),
+				Details: details,
+			}
+		}
+		return Result{
+			Status:  missing,
+			Message: fmt.Sprintf("%s %s is not installed", name, browser.Revision),
+			Fix:     fmt.Sprintf("Run `npx playwright install %s` with PLAYWRIGHT_BROWSERS_PATH=%s, or import an offline bundle", name, rt.BrowsersPath()),
+			Details: details,
+		}
+	}
+
+	return Result{
+		Status:  missing,
+		Message: fmt.Sprintf("playwright %s does not ship %s", rt.Version, name),
+		Fix:     "Check playwright.required_browsers in the config",
+		Details: map[string]interface{}{"required": required},
+	}
+}
+
+// pingDocker connects the same way NewBrowserPoolManager does
+func pingDocker(ctx context.Context) error {
+	docker, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		docker, err = client.NewClientWithOpts(
+			client.WithHost("unix:///var/run/docker.sock"),
+			client.WithAPIVersionNegotiation(),
+		)
+	}
+	if err != nil {
+		return err
+	}
+	defer docker.Close()
+
+	_, err = docker.Ping(ctx)
+	return err
+}
+
+func (d *Doctor) checkDocker(ctx context.Context) Result {
+	if err := d.opts.PingDocker(ctx); err != nil {
+		result := Result{
+			Status:  StatusWarn,
+			Message: fmt.Sprintf("docker daemon is not reachable: %v", err),
+			Fix:     "Start Docker (Docker Desktop or `sudo systemctl start docker`), check DOCKER_HOST and that this user can access the Docker socket",
+			Details: map[string]interface{}{"required": d.opts.DockerRequired},
+		}
+		if d.opts.DockerRequired {
+			result.Status = StatusFail
+		} else {
+			result.Message += ", the browser pool runs in degraded mode"
+		}
+		return result
+	}
+	return Result{
+		Status:  StatusOK,
+		Message: "docker daemon is reachable",
+		Details: map[string]interface{}{"required": d.opts.DockerRequired},
+	}
+}
+
+func (d *Doctor) checkFfmpeg(ctx context.Context) Result {
+	out, err := d.opts.RunCommand(ctx, "ffmpeg", "-version")
+	if err != nil {
+		return Result{
+			Status:  StatusWarn,
+			Message: fmt.Sprintf("ffmpeg is not available, session recordings are disabled: %v", err),
+			Fix:     fmt.Sprintf("Install ffmpeg (%s)", installHint("ffmpeg", "ffmpeg", "Gyan.FFmpeg")),
+		}
+	}
+	firstLine := strings.SplitN(out, "\n", 2)[0]
+	return Result{Status: StatusOK, Message: firstLine}
+}
+
+func (d *Doctor) checkEndpoint(ctx context.Context, endpoint Endpoint) Result {
+	envVar := config.EnvPrefix + strings.ToUpper(strings.ReplaceAll(endpoint.ConfigKey, ".", "__"))
+	fix := fmt.Sprintf("Check %s in the config (or %s) and any proxy or firewall between this machine and %s", endpoint.ConfigKey, envVar, endpoint.URL)
+
+	if endpoint.URL == "" {
+		return Result{Status: StatusFail, Message: endpoint.ConfigKey + " is not set", Fix: fix}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.URL, nil)
+	if err != nil {
+		return Result{Status: StatusFail, Message: fmt.Sprintf("invalid url %q: %v", endpoint.URL, err), Fix: fix}
+	}
+	res, err := d.opts.HTTPClient.Do(req)
+	if err != nil {
+		return Result{Status: StatusFail, Message: fmt.Sprintf("%s is unreachable: %v", endpoint.URL, err), Fix: fix}
+	}
+	res.Body.Close()
+
+	// nkk: Any HTTP answer means the host is reachable, the base URL itself may well be a 404
+	result := Result{
+		Status:  StatusOK,
+		Message: fmt.Sprintf("%s answered %d", endpoint.URL, res.StatusCode),
+		Details: map[string]interface{}{"url": endpoint.URL, "status_code": res.StatusCode},
+	}
+	if res.StatusCode >= 500 {
+		result.Status = StatusWarn
+		result.Fix = "The service is reachable but failing, check its status before opening a ticket"
+	}
+	return result
+}
+
+func (d *Doctor) checkDiskSpace(ctx context.Context) Result {
+	dir := d.opts.ExecutionsDir
+	if dir == "" {
+		dir = "."
+	}
+	// nkk: The workspace may not exist yet, measure the volume it will be created on
+	path, _ := filepath.Abs(dir)
+	for {
+		if _, err := os.Stat(path); err == nil || filepath.Dir(path) == path {
+			break
+		}
+		path = filepath.Dir(path)
+	}
+
+	free, err := freeDiskBytes(path)
+	if err != nil {
+		return Result{Status: StatusWarn, Message: fmt.Sprintf("could not read free disk space of %s: %v", path, err)}
+	}
+
+	freeMB := free / (1024 * 1024)
+	result := Result{
+		Status:  StatusOK,
+		Message: fmt.Sprintf("%d MB free in %s", freeMB, path),
+		Details: map[string]interface{}{"path": path, "free_mb": freeMB, "min_free_mb": d.opts.MinFreeDiskMB},
+	}
+	fix := fmt.Sprintf("Free up space on the volume holding %s, old videos and traces in the workspace are safe to remove", path)
+	switch {
+	case freeMB < d.opts.MinFreeDiskMB:
+		result.Status = StatusFail
+		result.Message = fmt.Sprintf("only %d MB free in %s, at least %d MB is needed", freeMB, path, d.opts.MinFreeDiskMB)
+		result.Fix = fix
+	case freeMB < 2*d.opts.MinFreeDiskMB:
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("%d MB free in %s is running low", freeMB, path)
+		result.Fix = fix
+	}
+	return result
+}
+
+func (d *Doctor) checkMachineConfig(ctx context.Context) Result {
+	path := d.opts.MachineConfigPath
+	fix := fmt.Sprintf("Remove %s and run `agent register`, a new machine ID will be generated", path)
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) || (err == nil && len(data) == 0) {
+		return Result{
+			Status:  StatusWarn,
+			Message: "machine is not registered yet",
+			Fix:     "Run `agent register`",
+		}
+	}
+	if err != nil {
+		return Result{Status: StatusFail, Message: fmt.Sprintf("cannot read %s: %v", path, err), Fix: "Check the file permissions of " + path}
+	}
+
+	var machineConfig config.ApxConfig
+	if err := json.Unmarshal(data, &machineConfig); err != nil {
+		return Result{Status: StatusFail, Message: fmt.Sprintf("%s is not valid JSON: %v", path, err), Fix: fix}
+	}
+	if machineConfig.MachineId == "" {
+		return Result{Status: StatusFail, Message: path + " has no machine_id", Fix: fix}
+	}
+	return Result{
+		Status:  StatusOK,
+		Message: "machine " + machineConfig.MachineId,
+		Details: map[string]interface{}{"machine_id": machineConfig.MachineId, "path": path},
+	}
+}
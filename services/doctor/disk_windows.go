@@ -0,0 +1,16 @@
+package doctor
+
+import "golang.org/x/sys/windows"
+
+// freeDiskBytes returns the space available to this user on the volume holding path
+func freeDiskBytes(path string) (uint64, error) {
+	dir, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var available, total, free uint64
+	if err := windows.GetDiskFreeSpaceEx(dir, &available, &total, &free); err != nil {
+		return 0, err
+	}
+	return available, nil
+}
@@ -0,0 +1,231 @@
+package doctor
+
+import (
+	"context"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/config"
+	"agent/services/playwright_runtime"
+)
+
+/*
+nkk: Doctor - environment diagnostics
+Most support tickets are environment problems (Node missing, browsers not
+installed, Docker down, service unreachable, corrupt machine config, full disk).
+Every check returns a status and, when something is wrong, a fix suggestion.
+Used by `agent doctor` and GET /agent/v1/doctor.
+*/
+
+const (
+	StatusOK   = "ok"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+)
+
+// DefaultMinFreeDiskMB is the free space below which the disk check fails
+const DefaultMinFreeDiskMB = 2048
+
+const checkTimeout = 10 * time.Second
+
+// Result is the outcome of one check
+type Result struct {
+	Name       string                 `json:"name"`
+	Status     string                 `json:"status"`
+	Message    string                 `json:"message"`
+	Fix        string                 `json:"fix,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	DurationMs int64                  `json:"duration_ms"`
+}
+
+// Report is the outcome of a doctor run
+type Report struct {
+	Status      string    `json:"status"`
+	Version     string    `json:"version"`
+	OS          string    `json:"os"`
+	Arch        string    `json:"arch"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Checks      []Result  `json:"checks"`
+}
+
+// Failed reports whether any check failed
+func (r *Report) Failed() bool {
+	return r.Status == StatusFail
+}
+
+// Check is one entry of the catalog
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) Result
+}
+
+// CommandRunner runs a binary and returns its combined output
+type CommandRunner func(ctx context.Context, name string, args ...string) (string, error)
+
+// Endpoint is a remote service the agent must reach
+type Endpoint struct {
+	Name      string
+	URL       string
+	ConfigKey string
+}
+
+type Options struct {
+	Runtimes          *playwright_runtime.Manager
+	RequiredBrowsers  []string
+	DockerRequired    bool
+	ExecutionsDir     string
+	MachineConfigPath string
+	MinFreeDiskMB     uint64
+	Endpoints         []Endpoint
+	HTTPClient        *http.Client
+
+	// nkk: Overridable for tests
+	RunCommand CommandRunner
+	PingDocker func(ctx context.Context) error
+}
+
+type Doctor struct {
+	opts   Options
+	checks []Check
+}
+
+// NewDoctor builds the check catalog
+func NewDoctor(opts Options) *Doctor {
+	if opts.MinFreeDiskMB == 0 {
+		opts.MinFreeDiskMB = DefaultMinFreeDiskMB
+	}
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: 5 * time.Second}
+	}
+	if opts.RunCommand == nil {
+		opts.RunCommand = runCommand
+	}
+	if opts.PingDocker == nil {
+		opts.PingDocker = pingDocker
+	}
+
+	d := &Doctor{opts: opts}
+	d.checks = []Check{
+		{Name: "node", Run: d.checkNode},
+		{Name: "npm", Run: d.checkNpm},
+		{Name: "playwright_runtime", Run: d.checkPlaywrightRuntime},
+	}
+	for _, browser := range d.browserTypes() {
+		browser := browser
+		d.checks = append(d.checks, Check{
+			Name: "playwright_" + browser,
+			Run:  func(ctx context.Context) Result { return d.checkBrowser(ctx, browser) },
+		})
+	}
+	d.checks = append(d.checks,
+		Check{Name: "docker", Run: d.checkDocker},
+		Check{Name: "ffmpeg", Run: d.checkFfmpeg},
+	)
+	for _, endpoint := range opts.Endpoints {
+		endpoint := endpoint
+		d.checks = append(d.checks, Check{
+			Name: endpoint.Name,
+			Run:  func(ctx context.Context) Result { return d.checkEndpoint(ctx, endpoint) },
+		})
+	}
+	d.checks = append(d.checks,
+		Check{Name: "disk_space", Run: d.checkDiskSpace},
+		Check{Name: "machine_config", Run: d.checkMachineConfig},
+	)
+	return d
+}
+
+// Checks returns the catalog in the order it is reported
+func (d *Doctor) Checks() []Check {
+	return d.checks
+}
+
+// Run executes every check in parallel, results keep the catalog order
+func (d *Doctor) Run(ctx context.Context) *Report {
+	results := make([]Result, len(d.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range d.checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			start := time.Now()
+			result := check.Run(checkCtx)
+			result.Name = check.Name
+			result.DurationMs = time.Since(start).Milliseconds()
+			results[i] = result
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := &Report{
+		Status:      StatusOK,
+		Version:     config.Version,
+		OS:          runtime.GOOS,
+		Arch:        runtime.GOARCH,
+		GeneratedAt: time.Now().UTC(),
+		Checks:      results,
+	}
+	for _, result := range results {
+		switch result.Status {
+		case StatusFail:
+			report.Status = StatusFail
+		case StatusWarn:
+			if report.Status == StatusOK {
+				report.Status = StatusWarn
+			}
+		}
+	}
+	return report
+}
+
+// browserTypes are the Playwright browser types plus any other required browser
+func (d *Doctor) browserTypes() []string {
+	types := []string{"chromium", "firefox", "webkit"}
+	for _, required := range d.opts.RequiredBrowsers {
+		known := false
+		for _, t := range types {
+			if t == required {
+				known = true
+				break
+			}
+		}
+		if !known {
+			types = append(types, required)
+		}
+	}
+	return types
+}
+
+func (d *Doctor) browserRequired(name string) bool {
+	for _, required := range d.opts.RequiredBrowsers {
+		if required == name {
+			return true
+		}
+	}
+	return false
+}
+
+func runCommand(ctx context.Context, name string, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+// installHint suggests how to install a package on this OS
+func installHint(brew, apt, winget string) string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "brew install " + brew
+	case "windows":
+		return "winget install " + winget
+	default:
+		return "sudo apt-get install -y " + apt
+	}
+}
@@ -0,0 +1,132 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/services/playwright_runtime"
+)
+
+/*
+nkk: Unit tests for the doctor checks
+Commands, Docker and the runtime are faked, endpoints are httptest servers
+*/
+
+// fakeRuntime lays out an installed Playwright runtime with only chromium on disk
+func fakeRuntime(t *testing.T) *playwright_runtime.Manager {
+	root := t.TempDir()
+	dir := filepath.Join(root, "1.47.2")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "node_modules", "playwright-core"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "browsers", "chromium-1134"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "runtime.json"), []byte(`{}`), 0644))
+	browsers := `{"browsers":[{"name":"chromium","revision":"1134"},{"name":"firefox","revision":"1463"}]}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "node_modules", "playwright-core", "browsers.json"), []byte(browsers), 0644))
+	return playwright_runtime.NewManager(root, "1.47.2")
+}
+
+func fakeCommands(versions map[string]string) CommandRunner {
+	return func(ctx context.Context, name string, args ...string) (string, error) {
+		version, ok := versions[name]
+		if !ok {
+			return "", errors.New("executable file not found in $PATH")
+		}
+		return version, nil
+	}
+}
+
+func resultsByName(report *Report) map[string]Result {
+	results := make(map[string]Result)
+	for _, result := range report.Checks {
+		results[result.Name] = result
+	}
+	return results
+}
+
+func TestDoctorReportsEachCheck(t *testing.T) {
+	up := httptest.NewServer(http.NotFoundHandler())
+	defer up.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	machineConfig := filepath.Join(t.TempDir(), "machine_config.json")
+	require.NoError(t, os.WriteFile(machineConfig, []byte(`{"machine_id":"m-1"}`), 0644))
+
+	d := NewDoctor(Options{
+		Runtimes:          fakeRuntime(t),
+		RequiredBrowsers:  []string{"chromium", "firefox"},
+		ExecutionsDir:     filepath.Join(t.TempDir(), "not-created-yet"),
+		MachineConfigPath: machineConfig,
+		MinFreeDiskMB:     1,
+		Endpoints: []Endpoint{
+			{Name: "server_domain", URL: up.URL, ConfigKey: "server_domain"},
+			{Name: "execution_service_domain", URL: down.URL, ConfigKey: "execution_service_domain"},
+		},
+		RunCommand: fakeCommands(map[string]string{"node": "v20.11.0", "npm": "10.2.4"}),
+		PingDocker: func(ctx context.Context) error { return errors.New("socket not found") },
+	})
+
+	report := d.Run(context.Background())
+	results := resultsByName(report)
+	require.Len(t, report.Checks, len(d.Checks()))
+	assert.Equal(t, "node", report.Checks[0].Name, "results keep the catalog order")
+
+	assert.Equal(t, StatusOK, results["node"].Status)
+	assert.Equal(t, StatusOK, results["npm"].Status)
+	assert.Equal(t, StatusOK, results["playwright_runtime"].Status)
+	assert.Equal(t, StatusOK, results["playwright_chromium"].Status)
+	assert.Equal(t, StatusFail, results["playwright_firefox"].Status, "required but not on disk")
+	assert.Equal(t, StatusWarn, results["playwright_webkit"].Status, "optional and not shipped")
+	assert.Equal(t, StatusWarn, results["docker"].Status, "docker is optional unless the docker runner is enabled")
+	assert.Equal(t, StatusWarn, results["ffmpeg"].Status)
+	assert.Equal(t, StatusOK, results["server_domain"].Status, "a 404 still means reachable")
+	assert.Equal(t, StatusFail, results["execution_service_domain"].Status)
+	assert.Contains(t, results["execution_service_domain"].Fix, "AGENT_EXECUTION_SERVICE_DOMAIN")
+	assert.Equal(t, StatusOK, results["disk_space"].Status)
+	assert.Equal(t, StatusOK, results["machine_config"].Status)
+
+	for _, result := range report.Checks {
+		if result.Status != StatusOK {
+			assert.NotEmpty(t, result.Fix, "%s should suggest a fix", result.Name)
+		}
+	}
+	assert.True(t, report.Failed())
+}
+
+func TestDoctorFlagsOldNodeAndCorruptMachineConfig(t *testing.T) {
+	machineConfig := filepath.Join(t.TempDir(), "machine_config.json")
+	require.NoError(t, os.WriteFile(machineConfig, []byte(`{"machine_id":`), 0644))
+
+	d := NewDoctor(Options{
+		Runtimes:          fakeRuntime(t),
+		MachineConfigPath: machineConfig,
+		RunCommand:        fakeCommands(map[string]string{"node": "v16.20.2", "npm": "8.19.4", "ffmpeg": "ffmpeg version 6.1\nbuilt with gcc"}),
+		PingDocker:        func(ctx context.Context) error { return nil },
+	})
+
+	results := resultsByName(d.Run(context.Background()))
+	assert.Equal(t, StatusFail, results["node"].Status)
+	assert.Equal(t, StatusFail, results["machine_config"].Status)
+	assert.Contains(t, results["machine_config"].Fix, "agent register")
+	assert.Equal(t, "ffmpeg version 6.1", results["ffmpeg"].Message)
+	assert.Equal(t, StatusOK, results["docker"].Status)
+}
+
+func TestDoctorRequiresDockerForDockerRunner(t *testing.T) {
+	d := NewDoctor(Options{
+		DockerRequired: true,
+		RunCommand:     fakeCommands(nil),
+		PingDocker:     func(ctx context.Context) error { return errors.New("permission denied") },
+	})
+
+	results := resultsByName(d.Run(context.Background()))
+	assert.Equal(t, StatusFail, results["docker"].Status)
+	assert.Equal(t, StatusWarn, results["machine_config"].Status, "a missing machine config only means not registered")
+}
@@ -0,0 +1,235 @@
+package executionbridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+
+	"agent/logger"
+)
+
+/*
+nkk: ArtifactCollector waits for the files Playwright writes after a test (trace.zip, video.webm)
+- One fsnotify watcher serves every session, directories are watched while someone waits in them.
+- A file is ready once its size has not changed for StableFor, fsnotify has no close-write event on every platform.
+- Events only wake the waiters early, they also stat every PollInterval so a missed event or an unwatchable directory only costs latency.
+- Waiting ends at Timeout, a file that never showed up is ErrArtifactMissing.
+*/
+
+var (
+	ErrArtifactMissing  = errors.New("artifact missing")
+	ErrArtifactUnstable = errors.New("artifact still being written")
+)
+
+// ArtifactWaitOptions bound how long ArtifactCollector.Wait waits
+type ArtifactWaitOptions struct {
+	Timeout      time.Duration
+	StableFor    time.Duration
+	PollInterval time.Duration
+}
+
+// DefaultArtifactWaitOptions are used by a bridge without SetArtifactCollector
+var DefaultArtifactWaitOptions = ArtifactWaitOptions{
+	Timeout:      2 * time.Minute,
+	StableFor:    time.Second,
+	PollInterval: 500 * time.Millisecond,
+}
+
+// ArtifactError names the artifact a wait gave up on
+type ArtifactError struct {
+	Path string
+	Err  error
+}
+
+func (e *ArtifactError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+func (e *ArtifactError) Unwrap() error {
+	return e.Err
+}
+
+type ArtifactCollector struct {
+	opts    ArtifactWaitOptions
+	watcher *fsnotify.Watcher // nkk: nil when inotify is unavailable, waiters only poll
+
+	mu      sync.Mutex
+	dirs    map[string]int
+	waiters map[string]map[chan struct{}]struct{}
+	closed  bool
+	done    chan struct{}
+}
+
+// NewArtifactCollector starts the shared watcher, it falls back to polling if the watcher cannot be created
+func NewArtifactCollector(opts ArtifactWaitOptions) *ArtifactCollector {
+	c := &ArtifactCollector{
+		opts:    opts,
+		dirs:    make(map[string]int),
+		waiters: make(map[string]map[chan struct{}]struct{}),
+		done:    make(chan struct{}),
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Warn("file watcher unavailable, artifacts are polled", zap.Error(err))
+		close(c.done)
+		return c
+	}
+	c.watcher = watcher
+	go c.run()
+	return c
+}
+
+func (c *ArtifactCollector) run() {
+	defer close(c.done)
+	for {
+		select {
+		case event, ok := <-c.watcher.Events:
+			if !ok {
+				return
+			}
+			c.notify(filepath.Clean(event.Name))
+		case err, ok := <-c.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Warn("file watcher error", zap.Error(err))
+		}
+	}
+}
+
+func (c *ArtifactCollector) notify(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for ch := range c.waiters[path] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Wait blocks until the file at path exists and stopped growing, and returns its info
+func (c *ArtifactCollector) Wait(ctx context.Context, path string) (os.FileInfo, error) {
+	path = filepath.Clean(path)
+	wake := make(chan struct{}, 1)
+	c.mu.Lock()
+	if c.waiters[path] == nil {
+		c.waiters[path] = make(map[chan struct{}]struct{})
+	}
+	c.waiters[path][wake] = struct{}{}
+	c.mu.Unlock()
+	dir := filepath.Dir(path)
+	watched := false
+	defer func() {
+		c.mu.Lock()
+		delete(c.waiters[path], wake)
+		if len(c.waiters[path]) == 0 {
+			delete(c.waiters, path)
+		}
+		c.mu.Unlock()
+		if watched {
+			c.unwatch(dir)
+		}
+	}()
+
+	deadline := time.NewTimer(c.opts.Timeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(c.opts.PollInterval)
+	defer ticker.Stop()
+
+	var info os.FileInfo
+	var changedAt time.Time
+	for {
+		// nkk: The directory usually appears with the first artifact, keep trying to watch it
+		if !watched {
+			watched = c.watch(dir)
+		}
+		current, err := os.Stat(path)
+		switch {
+		case err == nil && (info == nil || current.Size() != info.Size() || !current.ModTime().Equal(info.ModTime())):
+			info, changedAt = current, time.Now()
+		case err == nil && time.Since(changedAt) >= c.opts.StableFor:
+			return current, nil
+		case err != nil && !os.IsNotExist(err):
+			return nil, &ArtifactError{Path: path, Err: err}
+		case err != nil:
+			info = nil
+		}
+
+		select {
+		case <-wake:
+		case <-ticker.C:
+		case <-deadline.C:
+			if info == nil {
+				return nil, &ArtifactError{Path: path, Err: ErrArtifactMissing}
+			}
+			return nil, &ArtifactError{Path: path, Err: ErrArtifactUnstable}
+		case <-ctx.Done():
+			return nil, &ArtifactError{Path: path, Err: ctx.Err()}
+		}
+	}
+}
+
+// isArtifactWaitError reports whether a wait ran out, as opposed to being cancelled
+func isArtifactWaitError(err error) bool {
+	return errors.Is(err, ErrArtifactMissing) || errors.Is(err, ErrArtifactUnstable)
+}
+
+func (c *ArtifactCollector) watch(dir string) bool {
+	if c.watcher == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return false
+	}
+	if c.dirs[dir] == 0 {
+		if err := c.watcher.Add(dir); err != nil {
+			return false
+		}
+	}
+	c.dirs[dir]++
+	return true
+}
+
+func (c *ArtifactCollector) unwatch(dir string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dirs[dir]--
+	if c.dirs[dir] > 0 {
+		return
+	}
+	delete(c.dirs, dir)
+	if !c.closed {
+		c.watcher.Remove(dir)
+	}
+}
+
+// Close stops the watcher, later and in-progress waits only poll
+func (c *ArtifactCollector) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.mu.Unlock()
+	if c.watcher == nil {
+		return nil
+	}
+	err := c.watcher.Close()
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return err
+}
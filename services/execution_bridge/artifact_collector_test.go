@@ -0,0 +1,97 @@
+package executionbridge
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/models/session"
+	"agent/models/uploadvideo"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: Unit tests for waiting on session artifacts with the shared file watcher
+*/
+
+var testArtifactWaitOptions = ArtifactWaitOptions{
+	Timeout:      2 * time.Second,
+	StableFor:    50 * time.Millisecond,
+	PollInterval: 20 * time.Millisecond,
+}
+
+func TestArtifactCollectorWaitsForManySessions(t *testing.T) {
+	collector := NewArtifactCollector(testArtifactWaitOptions)
+	defer collector.Close(context.Background())
+	root := t.TempDir()
+
+	// nkk: Session directories do not exist yet when the waits start
+	var wg sync.WaitGroup
+	sizes := make([]int64, 5)
+	errs := make([]error, 5)
+	for i := range sizes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			info, err := collector.Wait(context.Background(), filepath.Join(root, "session-"+string(rune('a'+i)), apxconstants.VideoFileName))
+			errs[i] = err
+			if err == nil {
+				sizes[i] = info.Size()
+			}
+		}(i)
+	}
+	time.Sleep(30 * time.Millisecond)
+	for i := range sizes {
+		dir := filepath.Join(root, "session-"+string(rune('a'+i)))
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		file, err := os.Create(filepath.Join(dir, apxconstants.VideoFileName))
+		require.NoError(t, err)
+		file.Write([]byte("part"))
+		time.Sleep(10 * time.Millisecond)
+		file.Write([]byte("-rest"))
+		file.Close()
+	}
+	wg.Wait()
+	for i := range sizes {
+		require.NoError(t, errs[i])
+		assert.Equal(t, int64(9), sizes[i], "the wait ends after the last write")
+	}
+	assert.Empty(t, collector.dirs, "directories are unwatched once nobody waits in them")
+
+	start := time.Now()
+	_, err := collector.Wait(context.Background(), filepath.Join(root, "missing", apxconstants.VideoFileName))
+	assert.ErrorIs(t, err, ErrArtifactMissing)
+	assert.Less(t, time.Since(start), 5*time.Second, "a missing file fails at the deadline")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = collector.Wait(ctx, filepath.Join(root, "missing", apxconstants.VideoFileName))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.False(t, isArtifactWaitError(err), "a cancelled wait is not reported as missing")
+}
+
+func TestUploadVideoReportsMissingVideoAsWarning(t *testing.T) {
+	transport := NewMemoryTransport()
+	bridge := NewExecutionServiceBridgeWithTransport("http://execution-service", transport)
+	bridge.SetArtifactCollector(NewArtifactCollector(ArtifactWaitOptions{Timeout: 100 * time.Millisecond, StableFor: 20 * time.Millisecond, PollInterval: 10 * time.Millisecond}))
+
+	err := bridge.UploadVideo(context.Background(), uploadvideo.UploadVideo{OrgId: "o", ProjectId: "p", AppId: "a", Testlab: apxconstants.Local, ExecutionId: "exec-1", TestcaseId: "tc-1", OutputDir: t.TempDir()})
+	require.ErrorIs(t, err, ErrArtifactMissing)
+	assert.Empty(t, transport.CallsTo("UploadVideo"))
+
+	warnings := transport.CallsTo("AddSessionWarning")
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "/organisations/o/projects/p/apps/a/local/sessions/exec-1/warnings", warnings[0].Path)
+	var warning session.Warning
+	require.NoError(t, json.Unmarshal(warnings[0].Body, &warning))
+	assert.Equal(t, session.WarningVideoMissing, warning.Kind)
+	assert.Equal(t, "tc-1", warning.TestcaseId)
+	assert.Contains(t, warning.Path, apxconstants.VideoFileName)
+}
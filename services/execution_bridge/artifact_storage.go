@@ -0,0 +1,84 @@
+package executionbridge
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"agent/logger"
+	"agent/models/session"
+	artifactstore "agent/services/artifact_store"
+)
+
+/*
+nkk: Artifacts in an artifact store
+- A store other than passthrough gets the file instead of the execution service.
+- The service only gets signed download links, saved into the session's VideoURL and Screenshots.
+- A failed store upload falls back to the execution service upload, artifacts are never dropped.
+*/
+
+// SetArtifactStore sends artifacts of orgs with a non-passthrough store there, links are valid for urlExpiry
+func (s *ExecutionServiceBridge) SetArtifactStore(router *artifactstore.Router, urlExpiry time.Duration) {
+	s.artifactStore = router
+	s.artifactURLExpiry = urlExpiry
+}
+
+// artifactStoreFor returns the store of orgId, nil when its artifacts go to the execution service
+func (s *ExecutionServiceBridge) artifactStoreFor(orgId string) artifactstore.ArtifactStore {
+	if s.artifactStore == nil {
+		return nil
+	}
+	store := s.artifactStore.For(orgId)
+	if store.Backend() == artifactstore.BackendPassthrough {
+		return nil
+	}
+	return store
+}
+
+// storeArtifact puts filePath under key and returns its signed URL
+func (s *ExecutionServiceBridge) storeArtifact(ctx context.Context, store artifactstore.ArtifactStore, key artifactstore.Key, filePath string) (string, error) {
+	if err := store.Put(ctx, key, filePath, mime.TypeByExtension(filepath.Ext(filePath))); err != nil {
+		return "", err
+	}
+	url, err := store.SignedURL(ctx, key, s.artifactURLExpiry)
+	if err != nil {
+		return "", err
+	}
+	logger.Info("artifact stored", zap.String("backend", store.Backend()), zap.String("key", key.String()))
+	return url, nil
+}
+
+// storeScreenshots puts every file of dir into store, in name order
+func (s *ExecutionServiceBridge) storeScreenshots(ctx context.Context, store artifactstore.ArtifactStore, key artifactstore.Key, dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read screenshots: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	var urls []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		key.Name = entry.Name()
+		url, err := s.storeArtifact(ctx, store, key, filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+	return urls, nil
+}
+
+// saveArtifactURLs hands the links of stored artifacts to the execution service
+func (s *ExecutionServiceBridge) saveArtifactURLs(ctx context.Context, orgId, projectId, appId, testlab string, urls session.ArtifactURLs) error {
+	path := fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions/%s/artifact-urls", orgId, projectId, appId, testlab, urls.ExecutionId)
+	return s.writeJSON(ctx, OutboxRequest{Call: "SaveArtifactURLs", ExecutionId: urls.ExecutionId, Method: http.MethodPost, Path: path}, urls)
+}
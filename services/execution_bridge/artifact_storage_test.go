@@ -0,0 +1,70 @@
+package executionbridge
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/models/screenshot"
+	"agent/models/session"
+	"agent/models/uploadvideo"
+	artifactstore "agent/services/artifact_store"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: Unit tests for sending artifacts to an artifact store
+*/
+
+func TestArtifactsGoToTheTenantStore(t *testing.T) {
+	storeDir := t.TempDir()
+	router, err := artifactstore.New(artifactstore.Options{
+		Tenants: map[string]string{"o": artifactstore.BackendLocal},
+		Local:   artifactstore.LocalOptions{Dir: storeDir, BaseURL: "http://agent/artifacts", SigningKey: "secret"},
+	})
+	require.NoError(t, err)
+	transport := NewMemoryTransport()
+	bridge := NewExecutionServiceBridgeWithTransport("http://execution-service", transport)
+	bridge.SetArtifactCollector(NewArtifactCollector(testArtifactWaitOptions))
+	bridge.SetArtifactStore(router, time.Hour)
+
+	outputDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, apxconstants.VideoFileName), []byte("video"), 0644))
+	video := uploadvideo.UploadVideo{OrgId: "o", ProjectId: "p", AppId: "a", Testlab: apxconstants.Local, ExecutionId: "exec-1", TestcaseId: "tc-1", OutputDir: outputDir}
+	require.NoError(t, bridge.UploadVideo(context.Background(), video))
+	assert.Empty(t, transport.CallsTo("UploadVideo"), "the execution service only gets the link")
+	stored, err := os.ReadFile(filepath.Join(storeDir, "o/p/exec-1/tc-1/video", apxconstants.VideoFileName))
+	require.NoError(t, err)
+	assert.Equal(t, "video", string(stored))
+
+	screenshotsDir := t.TempDir()
+	for _, name := range []string{"2.png", "1.png"} {
+		require.NoError(t, os.WriteFile(filepath.Join(screenshotsDir, name), []byte(name), 0644))
+	}
+	request := screenshot.UploadScreenshotRequest{ExecutionId: "exec-1", TestcaseId: "tc-1", ScreenshotsDir: screenshotsDir}
+	require.NoError(t, bridge.UploadScreenshots(context.Background(), "o", "p", "a", apxconstants.Local, "exec-1", request))
+	assert.Len(t, transport.CallsTo("UploadScreenshots"), 1, "the screenshot metadata is still sent")
+
+	calls := transport.CallsTo("SaveArtifactURLs")
+	require.Len(t, calls, 2)
+	assert.Equal(t, "/organisations/o/projects/p/apps/a/local/sessions/exec-1/artifact-urls", calls[0].Path)
+	var urls session.ArtifactURLs
+	require.NoError(t, json.Unmarshal(calls[0].Body, &urls))
+	assert.True(t, strings.HasPrefix(urls.VideoURL, "http://agent/artifacts/o/p/exec-1/tc-1/video/video.webm?expires="))
+	require.NoError(t, json.Unmarshal(calls[1].Body, &urls))
+	require.Len(t, urls.Screenshots, 2)
+	assert.Contains(t, urls.Screenshots[0], "/screenshot/1.png?", "screenshots are stored in name order")
+
+	// nkk: Other orgs keep uploading to the execution service
+	video.OrgId = "other"
+	require.NoError(t, bridge.UploadVideo(context.Background(), video))
+	assert.Len(t, transport.CallsTo("UploadVideo"), 1)
+	assert.Len(t, transport.CallsTo("SaveArtifactURLs"), 2)
+}
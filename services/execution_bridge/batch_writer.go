@@ -1,13 +1,11 @@
 package executionbridge
 
 import (
-	"bytes"
-	"context"
-	"encoding/json"
-	"net/http"
 	"sync"
 	"time"
 
+	"go.uber.org/zap"
+
 	"agent/logger"
 	"agent/models/session"
 )
@@ -17,7 +15,7 @@ nkk: NEW IMPLEMENTATION
 Notes by nkk:
 - Implements BatchWriter for batching session saves to reduce network overhead.
 - Flushes either when maxSize is reached or after flushInterval.
-- Uses provided httpClient for connection pooling.
+- With a MongoSink the batches are upserted into MongoDB, fallback gets what MongoDB did not take.
 - Aligns with architecture plan for high concurrency and low latency.
 */
 type BatchWriter struct {
@@ -25,21 +23,28 @@ type BatchWriter struct {
 	mu           sync.Mutex
 	maxSize      int
 	flushTimer   *time.Timer
-	endpoint     string
-	httpClient   *http.Client
+	fallback     func(sessions []*session.Session) error // nkk: the bridge's journaled write to the execution service
 	flushInterval time.Duration
+	mongo        *MongoSink
+	inflight     sync.WaitGroup
 }
 
-func NewBatchWriter(endpoint string, maxSize int, flushInterval time.Duration) *BatchWriter {
+func NewBatchWriter(maxSize int, flushInterval time.Duration, fallback func(sessions []*session.Session) error) *BatchWriter {
 	return &BatchWriter{
 		buffer:       make([]*session.Session, 0, maxSize),
 		maxSize:      maxSize,
-		endpoint:     endpoint,
-		httpClient:   &http.Client{},
+		fallback:     fallback,
 		flushInterval: flushInterval,
 	}
 }
 
+// SetMongoSink makes the batches go to MongoDB first, the fallback stays for when it fails
+func (b *BatchWriter) SetMongoSink(sink *MongoSink) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.mongo = sink
+}
+
 func (b *BatchWriter) Add(session *session.Session) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -59,7 +64,16 @@ func (b *BatchWriter) Flush() {
 	b.flushLocked()
 }
 
+// Wait blocks until the batches already flushed are sent
+func (b *BatchWriter) Wait() {
+	b.inflight.Wait()
+}
+
 func (b *BatchWriter) flushLocked() {
+	if b.flushTimer != nil {
+		b.flushTimer.Stop()
+		b.flushTimer = nil
+	}
 	if len(b.buffer) == 0 {
 		return
 	}
@@ -67,22 +81,25 @@ func (b *BatchWriter) flushLocked() {
 	batch := b.buffer
 	b.buffer = nil
 
-	go b.sendBatch(batch)
+	b.inflight.Add(1)
+	go func(mongo *MongoSink) {
+		defer b.inflight.Done()
+		b.sendBatch(mongo, batch)
+	}(b.mongo)
 }
 
-func (b *BatchWriter) sendBatch(sessions []*session.Session) {
-	body, _ := json.Marshal(map[string]interface{}{
-		"sessions": sessions,
-		"count":    len(sessions),
-	})
-
-	req, _ := http.NewRequestWithContext(context.Background(), "POST",
-		b.endpoint+"/batch/sessions", bytes.NewReader(body))
-
-	resp, err := b.httpClient.Do(req)
-	if err != nil {
-		logger.Error("BatchWriter sendBatch error", err)
-		return
+// sendBatch writes to MongoDB when configured, an unavailable MongoDB falls back to the execution service
+func (b *BatchWriter) sendBatch(mongo *MongoSink, sessions []*session.Session) {
+	if mongo != nil {
+		err := b.sendBatchToMongoDB(mongo, sessions)
+		if err == nil {
+			return
+		}
+		logger.Warn("MongoDB batch write failed, sending batch to the execution service",
+			zap.Int("count", len(sessions)),
+			zap.Error(err))
+	}
+	if err := b.fallback(sessions); err != nil {
+		logger.Error("session batch could not be sent", zap.Int("count", len(sessions)), zap.Error(err))
 	}
-	defer resp.Body.Close()
 }
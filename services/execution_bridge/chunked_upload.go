@@ -0,0 +1,342 @@
+package executionbridge
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+
+	"agent/errors"
+	"agent/logger"
+	"agent/services/monitoring"
+)
+
+/*
+nkk: Resumable chunked artifact uploads
+1. POST   <session>/uploads                    {kind, file_name, size, sha256, chunk_size, fields} -> {upload_id, offset}
+2. PUT    <session>/uploads/<id>/chunks?offset=&sha256=   raw chunk, streamed from the file       -> {offset}
+3. GET    <session>/uploads/<id>                                                               -> {offset}, after a failed chunk
+4. POST   <session>/uploads/<id>/complete      {size, sha256}, the service checks the whole file
+The upload id is kept in <file>.upload next to the artifact, an agent restarted mid-upload resumes from the
+offset the service last acknowledged instead of from zero. A checksum mismatch drops the upload and starts over once.
+*/
+
+const (
+	ArtifactKindVideo       = "video"
+	ArtifactKindTrace       = "trace"
+	ArtifactKindScreenshots = "screenshots"
+
+	uploadStateSuffix = ".upload"
+)
+
+// ChunkedUploadOptions configure ChunkedUploader
+type ChunkedUploadOptions struct {
+	ChunkSize      int64
+	MaxAttempts    int // nkk: per chunk, progress resets it
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+}
+
+// ArtifactUpload is one file to upload in chunks
+type ArtifactUpload struct {
+	Kind        string
+	FilePath    string
+	FileName    string
+	OrgId       string
+	ProjectId   string
+	AppId       string
+	Testlab     string
+	ExecutionId string
+	Fields      map[string]string // nkk: sent with the upload session, the same fields the multipart upload has
+}
+
+func (u ArtifactUpload) basePath() string {
+	return fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions/%s/uploads", u.OrgId, u.ProjectId, u.AppId, u.Testlab, u.ExecutionId)
+}
+
+type uploadState struct {
+	UploadId string    `json:"upload_id"`
+	Size     int64     `json:"size"`
+	SHA256   string    `json:"sha256"`
+	ModTime  time.Time `json:"mod_time"`
+}
+
+type uploadSessionRequest struct {
+	Kind      string            `json:"kind"`
+	FileName  string            `json:"file_name"`
+	Size      int64             `json:"size"`
+	SHA256    string            `json:"sha256"`
+	ChunkSize int64             `json:"chunk_size"`
+	Fields    map[string]string `json:"fields,omitempty"`
+}
+
+type uploadSessionResponse struct {
+	UploadId string `json:"upload_id"`
+	Offset   int64  `json:"offset"`
+}
+
+type uploadCompleteRequest struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+type uploadMetrics struct {
+	bytes      *monitoring.Metric
+	chunks     *monitoring.Metric
+	retries    *monitoring.Metric
+	resumed    *monitoring.Metric
+	completed  *monitoring.Metric
+	failed     *monitoring.Metric
+	inProgress *monitoring.Metric
+}
+
+// ChunkedUploader sends artifacts to the execution service in checksummed chunks
+type ChunkedUploader struct {
+	transport Transport
+	opts      ChunkedUploadOptions
+	metrics   uploadMetrics
+}
+
+// NewChunkedUploader creates an uploader sending through transport
+func NewChunkedUploader(transport Transport, opts ChunkedUploadOptions) *ChunkedUploader {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = 8 << 20
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.RetryBaseDelay <= 0 {
+		opts.RetryBaseDelay = time.Second
+	}
+	if opts.RetryMaxDelay < opts.RetryBaseDelay {
+		opts.RetryMaxDelay = opts.RetryBaseDelay
+	}
+	registry := monitoring.GetRegistry()
+	return &ChunkedUploader{
+		transport: transport,
+		opts:      opts,
+		metrics: uploadMetrics{
+			bytes:      registry.Counter("artifact_upload_bytes_total", "Artifact bytes acknowledged by the execution service", map[string]string{}),
+			chunks:     registry.Counter("artifact_upload_chunks_total", "Artifact chunks acknowledged by the execution service", map[string]string{}),
+			retries:    registry.Counter("artifact_upload_retries_total", "Artifact chunks sent again after a failure", map[string]string{}),
+			resumed:    registry.Counter("artifact_upload_resumed_total", "Artifact uploads resumed from an earlier upload session", map[string]string{}),
+			completed:  registry.Counter("artifact_upload_completed_total", "Artifact uploads verified by the execution service", map[string]string{}),
+			failed:     registry.Counter("artifact_upload_failed_total", "Artifact uploads given up", map[string]string{}),
+			inProgress: registry.Gauge("artifact_upload_in_progress", "Artifact uploads currently running", map[string]string{}),
+		},
+	}
+}
+
+// Upload sends the file of upload, resuming an earlier upload session of the same file
+func (u *ChunkedUploader) Upload(ctx context.Context, upload ArtifactUpload) error {
+	u.metrics.inProgress.Add(1)
+	defer u.metrics.inProgress.Add(-1)
+
+	err := u.upload(ctx, upload)
+	if isErrorKind(err, errors.ExpectationFailed) {
+		// nkk: The service has a different file than we have, start over once
+		logger.Warn("artifact checksum rejected, restarting upload", zap.String("file", upload.FilePath), zap.Error(err))
+		os.Remove(upload.FilePath + uploadStateSuffix)
+		err = u.upload(ctx, upload)
+	}
+	if err != nil {
+		u.metrics.failed.Inc()
+		return err
+	}
+	os.Remove(upload.FilePath + uploadStateSuffix)
+	u.metrics.completed.Inc()
+	return nil
+}
+
+func (u *ChunkedUploader) upload(ctx context.Context, upload ArtifactUpload) error {
+	file, err := os.Open(upload.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", upload.Kind, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", upload.Kind, err)
+	}
+
+	state, offset, err := u.session(ctx, upload, file, info)
+	if err != nil {
+		return err
+	}
+	logger.Info("uploading artifact in chunks",
+		zap.String("kind", upload.Kind),
+		zap.String("upload_id", state.UploadId),
+		zap.Int64("size", state.Size),
+		zap.Int64("offset", offset))
+
+	attempts := 0
+	for offset < state.Size {
+		n := min(u.opts.ChunkSize, state.Size-offset)
+		next, err := u.sendChunk(ctx, upload, state.UploadId, file, offset, n)
+		if err == nil {
+			u.metrics.bytes.Add(float64(next - offset))
+			u.metrics.chunks.Inc()
+			offset, attempts = next, 0
+			continue
+		}
+		attempts++
+		// nkk: A conflict is an offset the service does not expect, only the sync below is needed
+		conflict := isErrorKind(err, errors.Conflict)
+		if (!conflict && !Retryable(err)) || ctx.Err() != nil || attempts >= u.opts.MaxAttempts {
+			return fmt.Errorf("failed to upload %s chunk at offset %d: %w", upload.Kind, offset, err)
+		}
+		u.metrics.retries.Inc()
+		if !conflict {
+			if err := sleepContext(ctx, u.backoff(attempts)); err != nil {
+				return err
+			}
+		}
+		// nkk: The chunk may have arrived even though the answer did not, continue where the service is
+		if acknowledged, err := u.offset(ctx, upload, state.UploadId); err == nil {
+			offset = acknowledged
+		}
+	}
+
+	body, err := json.Marshal(uploadCompleteRequest{Size: state.Size, SHA256: state.SHA256})
+	if err != nil {
+		return err
+	}
+	_, err = u.call(ctx, "CompleteArtifactUpload", http.MethodPost, upload.basePath()+"/"+state.UploadId+"/complete", nil, "application/json", body)
+	if err != nil {
+		return fmt.Errorf("failed to complete %s upload: %w", upload.Kind, err)
+	}
+	logger.Info("uploaded artifact", zap.String("kind", upload.Kind), zap.String("upload_id", state.UploadId), zap.Int64("size", state.Size))
+	return nil
+}
+
+// session resumes the upload session of the state file, or creates one
+func (u *ChunkedUploader) session(ctx context.Context, upload ArtifactUpload, file *os.File, info os.FileInfo) (uploadState, int64, error) {
+	statePath := upload.FilePath + uploadStateSuffix
+	var state uploadState
+	if data, err := os.ReadFile(statePath); err == nil && json.Unmarshal(data, &state) == nil &&
+		state.Size == info.Size() && state.ModTime.Equal(info.ModTime()) && state.UploadId != "" {
+		offset, err := u.offset(ctx, upload, state.UploadId)
+		if err == nil {
+			u.metrics.resumed.Inc()
+			return state, offset, nil
+		}
+		logger.Warn("could not resume artifact upload, starting a new one", zap.String("upload_id", state.UploadId), zap.Error(err))
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(file, 0, info.Size())); err != nil {
+		return uploadState{}, 0, fmt.Errorf("failed to hash %s: %w", upload.Kind, err)
+	}
+	state = uploadState{Size: info.Size(), SHA256: hex.EncodeToString(hash.Sum(nil)), ModTime: info.ModTime()}
+	body, err := json.Marshal(uploadSessionRequest{
+		Kind:      upload.Kind,
+		FileName:  upload.FileName,
+		Size:      state.Size,
+		SHA256:    state.SHA256,
+		ChunkSize: u.opts.ChunkSize,
+		Fields:    upload.Fields,
+	})
+	if err != nil {
+		return uploadState{}, 0, err
+	}
+	res, err := u.call(ctx, "CreateArtifactUpload", http.MethodPost, upload.basePath(), nil, "application/json", body)
+	if err != nil {
+		return uploadState{}, 0, fmt.Errorf("failed to create %s upload: %w", upload.Kind, err)
+	}
+	var created uploadSessionResponse
+	if err := json.Unmarshal(res.Body, &created); err != nil || created.UploadId == "" {
+		return uploadState{}, 0, fmt.Errorf("invalid %s upload session answer", upload.Kind)
+	}
+	state.UploadId = created.UploadId
+	if data, err := json.Marshal(state); err == nil {
+		if err := os.WriteFile(statePath, data, 0644); err != nil {
+			logger.Warn("failed to keep artifact upload state, a restart uploads from zero", zap.Error(err))
+		}
+	}
+	return state, created.Offset, nil
+}
+
+// sendChunk streams n bytes at offset and returns the offset the service acknowledged
+func (u *ChunkedUploader) sendChunk(ctx context.Context, upload ArtifactUpload, uploadId string, file *os.File, offset, n int64) (int64, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(file, offset, n)); err != nil {
+		return 0, fmt.Errorf("failed to hash chunk: %w", err)
+	}
+	query := url.Values{}
+	query.Set("offset", strconv.FormatInt(offset, 10))
+	query.Set("sha256", hex.EncodeToString(hash.Sum(nil)))
+	res, err := u.transport.Do(ctx, Call{
+		Name:        "UploadArtifactChunk",
+		Method:      http.MethodPut,
+		Path:        upload.basePath() + "/" + uploadId + "/chunks",
+		Query:       query,
+		ContentType: "application/octet-stream",
+		Body:        io.NewSectionReader(file, offset, n),
+		Size:        n,
+	})
+	if err != nil {
+		return 0, err
+	}
+	var ack uploadSessionResponse
+	if err := json.Unmarshal(res.Body, &ack); err != nil || ack.Offset <= offset || ack.Offset > offset+n {
+		return 0, errors.E(errors.Unavailable, fmt.Sprintf("invalid acknowledgement for chunk at offset %d", offset), err)
+	}
+	return ack.Offset, nil
+}
+
+// offset asks the service how much of the upload it has
+func (u *ChunkedUploader) offset(ctx context.Context, upload ArtifactUpload, uploadId string) (int64, error) {
+	res, err := u.call(ctx, "GetArtifactUpload", http.MethodGet, upload.basePath()+"/"+uploadId, nil, "", nil)
+	if err != nil {
+		return 0, err
+	}
+	var status uploadSessionResponse
+	if err := json.Unmarshal(res.Body, &status); err != nil {
+		return 0, fmt.Errorf("invalid upload status: %w", err)
+	}
+	return status.Offset, nil
+}
+
+func (u *ChunkedUploader) call(ctx context.Context, name, method, path string, query url.Values, contentType string, body []byte) (*Response, error) {
+	call := Call{Name: name, Method: method, Path: path, Query: query, ContentType: contentType}
+	if body != nil {
+		call.Body, call.Size = bytes.NewReader(body), int64(len(body))
+	}
+	return u.transport.Do(ctx, call)
+}
+
+func (u *ChunkedUploader) backoff(attempts int) time.Duration {
+	delay := u.opts.RetryBaseDelay
+	for i := 1; i < attempts && delay < u.opts.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	delay += time.Duration(rand.Float64() * float64(delay) * 0.3)
+	return min(delay, u.opts.RetryMaxDelay)
+}
+
+func isErrorKind(err error, kind errors.Kind) bool {
+	var e *errors.Error
+	return errors.As(err, &e) && e.Kind == kind
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,112 @@
+package executionbridge
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: Unit tests for resumable chunked artifact uploads
+*/
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func testUpload(t *testing.T, content string) ArtifactUpload {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), apxconstants.VideoFileName)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return ArtifactUpload{Kind: ArtifactKindVideo, FilePath: path, FileName: apxconstants.VideoFileName, OrgId: "o", ProjectId: "p", AppId: "a", Testlab: apxconstants.Local, ExecutionId: "exec-1"}
+}
+
+func ack(offset int64) []byte {
+	return []byte(fmt.Sprintf(`{"upload_id":"up-1","offset":%d}`, offset))
+}
+
+func TestChunkedUploadRetriesFromAcknowledgedOffset(t *testing.T) {
+	upload := testUpload(t, "0123456789")
+	transport := NewMemoryTransport()
+	transport.Respond("CreateArtifactUpload", http.StatusCreated, ack(0))
+	transport.Respond("UploadArtifactChunk", http.StatusOK, ack(4))
+	transport.Fail("UploadArtifactChunk", fmt.Errorf("connection reset"))
+	transport.Respond("GetArtifactUpload", http.StatusOK, ack(4))
+	transport.Respond("UploadArtifactChunk", http.StatusOK, ack(8))
+	transport.Respond("UploadArtifactChunk", http.StatusOK, ack(10))
+	uploader := NewChunkedUploader(transport, ChunkedUploadOptions{ChunkSize: 4, MaxAttempts: 3, RetryBaseDelay: time.Millisecond})
+
+	require.NoError(t, uploader.Upload(context.Background(), upload))
+
+	created := transport.CallsTo("CreateArtifactUpload")
+	require.Len(t, created, 1)
+	assert.Equal(t, "/organisations/o/projects/p/apps/a/local/sessions/exec-1/uploads", created[0].Path)
+	var session uploadSessionRequest
+	require.NoError(t, json.Unmarshal(created[0].Body, &session))
+	assert.Equal(t, int64(10), session.Size)
+	assert.Equal(t, sha256Hex([]byte("0123456789")), session.SHA256)
+
+	chunks := transport.CallsTo("UploadArtifactChunk")
+	require.Len(t, chunks, 4)
+	for i, want := range []struct {
+		offset string
+		body   string
+	}{{"0", "0123"}, {"4", "4567"}, {"4", "4567"}, {"8", "89"}} {
+		assert.Equal(t, want.offset, chunks[i].Query.Get("offset"))
+		assert.Equal(t, want.body, string(chunks[i].Body))
+		assert.Equal(t, sha256Hex([]byte(want.body)), chunks[i].Query.Get("sha256"))
+	}
+	complete := transport.CallsTo("CompleteArtifactUpload")
+	require.Len(t, complete, 1)
+	assert.Equal(t, "/organisations/o/projects/p/apps/a/local/sessions/exec-1/uploads/up-1/complete", complete[0].Path)
+	_, err := os.Stat(upload.FilePath + uploadStateSuffix)
+	assert.True(t, os.IsNotExist(err), "the upload state is dropped once complete")
+}
+
+func TestChunkedUploadResumesAfterRestart(t *testing.T) {
+	upload := testUpload(t, "0123456789")
+	transport := NewMemoryTransport()
+	transport.Respond("CreateArtifactUpload", http.StatusCreated, ack(0))
+	transport.Respond("UploadArtifactChunk", http.StatusOK, ack(4))
+	transport.Respond("UploadArtifactChunk", http.StatusServiceUnavailable, nil)
+	uploader := NewChunkedUploader(transport, ChunkedUploadOptions{ChunkSize: 4, MaxAttempts: 1, RetryBaseDelay: time.Millisecond})
+	require.Error(t, uploader.Upload(context.Background(), upload))
+	_, err := os.Stat(upload.FilePath + uploadStateSuffix)
+	require.NoError(t, err, "a failed upload keeps its state for the next attempt")
+
+	// nkk: A new uploader, as after a restart
+	transport = NewMemoryTransport()
+	transport.Respond("GetArtifactUpload", http.StatusOK, ack(4))
+	transport.Respond("UploadArtifactChunk", http.StatusOK, ack(8))
+	transport.Respond("UploadArtifactChunk", http.StatusOK, ack(10))
+	uploader = NewChunkedUploader(transport, ChunkedUploadOptions{ChunkSize: 4, MaxAttempts: 1, RetryBaseDelay: time.Millisecond})
+	require.NoError(t, uploader.Upload(context.Background(), upload))
+	assert.Empty(t, transport.CallsTo("CreateArtifactUpload"), "the upload session is reused")
+	chunks := transport.CallsTo("UploadArtifactChunk")
+	require.Len(t, chunks, 2)
+	assert.Equal(t, "4", chunks[0].Query.Get("offset"), "bytes the service has are not sent again")
+
+	// nkk: A rejected final checksum starts one new upload
+	transport = NewMemoryTransport()
+	transport.Respond("CreateArtifactUpload", http.StatusCreated, ack(0))
+	transport.Respond("CompleteArtifactUpload", http.StatusExpectationFailed, nil)
+	transport.Respond("CreateArtifactUpload", http.StatusCreated, ack(10))
+	uploader = NewChunkedUploader(transport, ChunkedUploadOptions{ChunkSize: 16, MaxAttempts: 1})
+	transport.Respond("UploadArtifactChunk", http.StatusOK, ack(10))
+	require.NoError(t, uploader.Upload(context.Background(), upload))
+	assert.Len(t, transport.CallsTo("CreateArtifactUpload"), 2)
+	assert.Len(t, transport.CallsTo("CompleteArtifactUpload"), 2)
+}
@@ -0,0 +1,29 @@
+package executionbridge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"agent/models/session"
+)
+
+// SaveConsoleLogs stores the console summary of a testcase as its session logs, uncaught page errors also add a warning
+func (s *ExecutionServiceBridge) SaveConsoleLogs(ctx context.Context, orgId string, projectId string, appId string, testlab string, logs session.ConsoleLogs) error {
+	path := fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions/%s/console-logs", orgId, projectId, appId, testlab, logs.ExecutionId)
+	if err := s.writeJSON(ctx, OutboxRequest{Call: "SaveConsoleLogs", ExecutionId: logs.ExecutionId, Method: http.MethodPost, Path: path}, logs); err != nil {
+		return err
+	}
+	if logs.UncaughtErrors > 0 {
+		s.addSessionWarning(ctx, orgId, projectId, appId, testlab, session.Warning{
+			ExecutionId: logs.ExecutionId,
+			TestcaseId:  logs.TestcaseId,
+			Kind:        session.WarningPageError,
+			Path:        logs.Artifact,
+			Message:     fmt.Sprintf("%d uncaught page errors", logs.UncaughtErrors),
+			CreatedAt:   time.Now().UTC(),
+		})
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package executionbridge
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/models/session"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: Unit tests for saving console logs
+*/
+
+func TestSaveConsoleLogsFlagsUncaughtErrors(t *testing.T) {
+	transport := NewMemoryTransport()
+	bridge := NewExecutionServiceBridgeWithTransport("http://execution-service", transport)
+
+	logs := session.ConsoleLogs{ExecutionId: "exec-1", TestcaseId: "tc-1", Level: session.ConsoleLogsErrors, Errors: 1, Summary: "1 errors"}
+	require.NoError(t, bridge.SaveConsoleLogs(context.Background(), "o", "p", "a", apxconstants.Local, logs))
+	calls := transport.CallsTo("SaveConsoleLogs")
+	require.Len(t, calls, 1)
+	assert.Equal(t, "/organisations/o/projects/p/apps/a/local/sessions/exec-1/console-logs", calls[0].Path)
+	assert.Empty(t, transport.CallsTo("AddSessionWarning"), "console errors alone are no warning")
+
+	logs.UncaughtErrors = 2
+	logs.Artifact = "/reports/exec-1/console.json"
+	require.NoError(t, bridge.SaveConsoleLogs(context.Background(), "o", "p", "a", apxconstants.Local, logs))
+	warnings := transport.CallsTo("AddSessionWarning")
+	require.Len(t, warnings, 1)
+	var warning session.Warning
+	require.NoError(t, json.Unmarshal(warnings[0].Body, &warning))
+	assert.Equal(t, session.WarningPageError, warning.Kind)
+	assert.Equal(t, "tc-1", warning.TestcaseId)
+	assert.Equal(t, logs.Artifact, warning.Path)
+}
@@ -1,22 +1,18 @@
 package executionbridge
 
 import (
-	"github.com/sony/gobreaker"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"math/rand"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
+	"path/filepath"
 	"sync"
 	// "sync/atomic"
 	"time"
@@ -31,16 +27,40 @@ import (
 	"agent/models/screenshot"
 	"agent/models/session"
 	"agent/models/uploadvideo"
+	artifactstore "agent/services/artifact_store"
 	apxconstants "agent/utils/constants"
 	"agent/utils/helpers"
 )
 
+// ExecutionBridge is the set of calls the agent makes to the execution service.
+// nkk: ExecutionServiceBridge and LocalSink implement it. The session artifacts, Kafka events and Allure
+// decorators wrap one, forward every call and only log what they fail to record, they never fail a call.
+type ExecutionBridge interface {
+	SaveSession(ctx context.Context, orgId string, projectId string, appId string, testlab string, session session.Session) error
+	SaveSessionStatus(ctx context.Context, status executionstatus.ExecutionStatus) error
+	CreateLocalAgentResults(ctx context.Context, orgId string, projectId string, appId string, executionId string, testPlanId string, runResult *runresult.RunResult, resultType string) error
+	UpdateSession(ctx context.Context, orgId string, projectId string, appId string, testlab string, session session.Session) error
+	CreateLocalAgentNetworkLogs(ctx context.Context, session session.Session) error
+	UpdateStepCount(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, body executionstep.ExecutionStep) error
+	UploadScreenshots(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, screenshot screenshot.UploadScreenshotRequest) error
+	TakeScreenshot(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, screenshot screenshot.TakeScreenshot) error
+	UploadVideo(ctx context.Context, data uploadvideo.UploadVideo) error
+	SaveConsoleLogs(ctx context.Context, orgId string, projectId string, appId string, testlab string, logs session.ConsoleLogs) error
+}
+
 type ExecutionServiceBridge struct {
 	ExecutionServiceEndpoint string
-	httpClient               *http.Client
+	transport                Transport
 	batchWriter              *BatchWriter
-	circuitBreakers          sync.Map // map[string]*gobreaker.CircuitBreaker per endpoint
-	uploadManager            *S3UploadManager
+	outbox                   *Outbox
+	mongoSessions            bool
+	statusCoalescer          *StatusCoalescer
+	harOptions               *HAROptions
+	artifactsOnce            sync.Once
+	artifacts                *ArtifactCollector
+	uploader                 *ChunkedUploader
+	artifactStore            *artifactstore.Router
+	artifactURLExpiry        time.Duration
 }
 
 /*
@@ -49,9 +69,10 @@ Notes by nkk:
 - Added httpClient with connection pooling for efficient HTTP reuse.
 - Integrated BatchWriter for batched session saves to reduce network overhead.
 - Added circuitBreaker using gobreaker for fault tolerance and resilience.
-- Added uploadManager for S3 streaming uploads to optimize video handling.
+- Artifacts can go to S3, MinIO or local disk instead, see SetArtifactStore.
 - This change aligns with the architecture plan for high concurrency and low latency.
 - Old code retained where necessary for backward compatibility.
+- Calls go through a Transport, the HTTP one owns the pooled client and the circuit breakers.
 */
 func NewExecutionServiceBridge(executionServiceEndpoint string) *ExecutionServiceBridge {
 	transport := &http.Transport{
@@ -63,287 +84,378 @@ func NewExecutionServiceBridge(executionServiceEndpoint string) *ExecutionServic
 		DisableKeepAlives:   false,
 	}
 
-	return &ExecutionServiceBridge{
+	// nkk: No client timeout, every call gets its deadline from ctx
+	return NewExecutionServiceBridgeWithTransport(executionServiceEndpoint, NewHTTPTransport(executionServiceEndpoint, &http.Client{Transport: transport}))
+}
+
+// NewExecutionServiceBridgeWithTransport creates a bridge sending its calls through transport
+func NewExecutionServiceBridgeWithTransport(executionServiceEndpoint string, transport Transport) *ExecutionServiceBridge {
+	s := &ExecutionServiceBridge{
 		ExecutionServiceEndpoint: executionServiceEndpoint,
-		httpClient: &http.Client{
-			Transport: transport,
-			Timeout:   30 * time.Second,
-		},
-		batchWriter:   NewBatchWriter(executionServiceEndpoint, 50, 100*time.Millisecond),
-		uploadManager: NewS3UploadManager(),
+		transport:                transport,
 	}
+	s.batchWriter = NewBatchWriter(50, 100*time.Millisecond, s.sendSessionBatch)
+	return s
 }
 
-// getCircuitBreaker returns a circuit breaker for the given endpoint
-func (s *ExecutionServiceBridge) getCircuitBreaker(endpoint string) *gobreaker.CircuitBreaker {
-	// nkk: Per-endpoint circuit breakers for isolation
-	if cb, ok := s.circuitBreakers.Load(endpoint); ok {
-		return cb.(*gobreaker.CircuitBreaker)
+// Flush sends any sessions and statuses still buffered and waits for them
+func (s *ExecutionServiceBridge) Flush() {
+	if s.statusCoalescer != nil {
+		s.statusCoalescer.Flush()
+		s.statusCoalescer.Wait()
 	}
+	s.batchWriter.Flush()
+	s.batchWriter.Wait()
+}
 
-	// Create new circuit breaker for this endpoint
-	cbSettings := gobreaker.Settings{
-		Name:        endpoint,
-		MaxRequests: 5,
-		Interval:    10 * time.Second,
-		Timeout:     30 * time.Second,
-		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
-			logger.Info("Circuit breaker state change",
-				zap.String("endpoint", name),
-				zap.String("from", from.String()),
-				zap.String("to", to.String()))
-		},
+// EnableStatusCoalescing makes SaveSessionStatus keep the latest status per execution and testcase for window, see StatusCoalescer.
+// nkk: Statuses inside the window are not journaled yet, only terminal ones are sent before SaveSessionStatus returns
+func (s *ExecutionServiceBridge) EnableStatusCoalescing(maxSize int, window time.Duration) {
+	s.statusCoalescer = NewStatusCoalescer(s.sendStatusBatch, maxSize, window)
+}
+
+// EnableMongoSink makes SaveSession and UpdateSession write through the batch writer into MongoDB
+func (s *ExecutionServiceBridge) EnableMongoSink(sink *MongoSink) {
+	s.batchWriter.SetMongoSink(sink)
+	s.mongoSessions = true
+}
+
+// sendSessionBatch sends the sessions MongoDB did not take to the execution service.
+// nkk: One write per execution through write, so a failed batch is journaled and retried like the rest of the execution
+func (s *ExecutionServiceBridge) sendSessionBatch(sessions []*session.Session) error {
+	var executionIds []string
+	byExecution := make(map[string][]*session.Session)
+	for _, sess := range sessions {
+		if _, ok := byExecution[sess.ExecutionId]; !ok {
+			executionIds = append(executionIds, sess.ExecutionId)
+		}
+		byExecution[sess.ExecutionId] = append(byExecution[sess.ExecutionId], sess)
 	}
 
-	cb := gobreaker.NewCircuitBreaker(cbSettings)
-	s.circuitBreakers.Store(endpoint, cb)
-	return cb
+	var errs []error
+	for _, executionId := range executionIds {
+		batch := byExecution[executionId]
+		payload := map[string]any{"sessions": batch, "count": len(batch)}
+		request := OutboxRequest{Call: "SaveSessionBatch", ExecutionId: executionId, Method: http.MethodPost, Path: "/batch/sessions"}
+		errs = append(errs, s.writeJSON(context.Background(), request, payload))
+	}
+	return errors.Join(errs...)
 }
 
-func (s *ExecutionServiceBridge) SaveSessionStatus(ctx context.Context, status executionstatus.ExecutionStatus) error {
-	logger.Info("saving session status", zap.String("org_id", status.OrgId), zap.String("project_id", status.ProjectId), zap.String("app_id", status.AppId))
-	requestUrl := s.ExecutionServiceEndpoint + fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions/%s/update-status", status.OrgId, status.ProjectId, status.AppId, status.TestLab, status.ExecutionId)
+// EnableHARExport makes CreateLocalAgentNetworkLogs also upload the network trace as a HAR 1.2 file, see BuildHAR
+func (s *ExecutionServiceBridge) EnableHARExport(opts HAROptions) {
+	s.harOptions = &opts
+}
 
-	requestBody, err := json.Marshal(status)
-	if err != nil {
-		logger.Error("error marshaling status", err)
-		return err
+// EnableChunkedUploads sends videos, traces and screenshot bundles as resumable chunked uploads, see ChunkedUploader
+func (s *ExecutionServiceBridge) EnableChunkedUploads(opts ChunkedUploadOptions) {
+	s.uploader = NewChunkedUploader(s.transport, opts)
+}
+
+// SetArtifactCollector makes the bridge wait for trace and video files through collector instead of its own
+func (s *ExecutionServiceBridge) SetArtifactCollector(collector *ArtifactCollector) {
+	s.artifactsOnce.Do(func() {})
+	s.artifacts = collector
+}
+
+// artifactCollector creates the default collector on first use, most bridges never wait for a file
+func (s *ExecutionServiceBridge) artifactCollector() *ArtifactCollector {
+	s.artifactsOnce.Do(func() {
+		s.artifacts = NewArtifactCollector(DefaultArtifactWaitOptions)
+	})
+	return s.artifacts
+}
+
+// reportArtifactWarning records on the session that an artifact it should have produced is missing
+func (s *ExecutionServiceBridge) reportArtifactWarning(ctx context.Context, orgId, projectId, appId, testlab, executionId, testcaseId, kind string, err error) {
+	warning := session.Warning{
+		ExecutionId: executionId,
+		TestcaseId:  testcaseId,
+		Kind:        kind,
+		Message:     err.Error(),
+		CreatedAt:   time.Now().UTC(),
 	}
+	var artifactErr *ArtifactError
+	if errors.As(err, &artifactErr) {
+		warning.Path = artifactErr.Path
+	}
+	s.addSessionWarning(ctx, orgId, projectId, appId, testlab, warning)
+}
+
+func (s *ExecutionServiceBridge) addSessionWarning(ctx context.Context, orgId, projectId, appId, testlab string, warning session.Warning) {
+	path := fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions/%s/warnings", orgId, projectId, appId, testlab, warning.ExecutionId)
+	if err := s.writeJSON(ctx, OutboxRequest{Call: "AddSessionWarning", ExecutionId: warning.ExecutionId, Method: http.MethodPost, Path: path}, warning); err != nil {
+		logger.Error("error reporting session warning", err, zap.String("execution_id", warning.ExecutionId), zap.String("kind", warning.Kind))
+	}
+}
 
-	req, err := http.NewRequest(http.MethodPost, requestUrl, bytes.NewReader(requestBody))
+// EnableOutbox journals the writes under dir and delivers them in the background, see Outbox
+func (s *ExecutionServiceBridge) EnableOutbox(dir string, opts OutboxOptions) (*Outbox, error) {
+	outbox, err := OpenOutbox(dir, s.deliver, opts)
 	if err != nil {
-		logger.Error("error creating request", err)
+		return nil, err
+	}
+	s.outbox = outbox
+	return outbox, nil
+}
+
+// writeJSON sends v as the JSON body of a write
+func (s *ExecutionServiceBridge) writeJSON(ctx context.Context, request OutboxRequest, v any) error {
+	request.ContentType = "application/json"
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(v); err != nil {
+		return fmt.Errorf("failed to encode %s body: %w", request.Call, err)
+	}
+	return s.write(ctx, request, int64(body.Len()), func(w io.Writer) error {
+		_, err := w.Write(body.Bytes())
 		return err
+	})
+}
+
+// write hands a request to the outbox, without one it is sent right away, size is -1 when not known up front
+func (s *ExecutionServiceBridge) write(ctx context.Context, request OutboxRequest, size int64, writeBody func(w io.Writer) error) error {
+	if s.outbox != nil {
+		if err := s.outbox.Enqueue(request, writeBody); err != nil {
+			logger.Error("error journaling execution service write", zap.String("call", request.Call), zap.Error(err))
+			return err
+		}
+		return nil
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	// nkk: Streamed through a pipe, a video is never held in memory
+	body, bodyWriter := io.Pipe()
+	go func() {
+		if err := writeBody(bodyWriter); err != nil {
+			bodyWriter.CloseWithError(fmt.Errorf("failed to write %s body: %w", request.Call, err))
+			return
+		}
+		bodyWriter.Close()
+	}()
+	err := s.deliver(ctx, &OutboxEntry{OutboxRequest: request}, body, size)
+	// nkk: Unblocks writeBody when the call ended before reading the whole body
+	body.CloseWithError(io.ErrClosedPipe)
 	if err != nil {
-		logger.Error("error saving session status", err)
-		return err
+		logger.Error("error sending execution service write", zap.String("call", request.Call), zap.Error(err))
 	}
-	defer res.Body.Close()
-	return nil
+	return err
+}
+
+// deliver sends one write through the transport
+func (s *ExecutionServiceBridge) deliver(ctx context.Context, entry *OutboxEntry, body io.Reader, size int64) error {
+	_, err := s.transport.Do(ctx, Call{
+		Name:        entry.Call,
+		Method:      entry.Method,
+		Path:        entry.Path,
+		Query:       entry.Query,
+		ContentType: entry.ContentType,
+		Body:        body,
+		Size:        size,
+	})
+	return err
+}
+
+func (s *ExecutionServiceBridge) SaveSessionStatus(ctx context.Context, status executionstatus.ExecutionStatus) error {
+	logger.Info("saving session status", zap.String("org_id", status.OrgId), zap.String("project_id", status.ProjectId), zap.String("app_id", status.AppId))
+	if s.statusCoalescer != nil {
+		return s.statusCoalescer.Add(ctx, status)
+	}
+	path := fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions/%s/update-status", status.OrgId, status.ProjectId, status.AppId, status.TestLab, status.ExecutionId)
+	return s.writeJSON(ctx, OutboxRequest{Call: "SaveSessionStatus", ExecutionId: status.ExecutionId, Method: http.MethodPost, Path: path}, status)
 }
 
 func (s *ExecutionServiceBridge) CreateLocalAgentResults(ctx context.Context, orgId, projectId string, appId string, executionId string, testPlanId string, runResult *runresult.RunResult, resultType string) error {
 
 	logger.Info("creating local agent results", zap.String("org_id", orgId), zap.String("project_id", projectId), zap.String("app_id", appId), zap.String("result_type", resultType), zap.String("exe", executionId), zap.String("testplan", testPlanId))
 
-	requestUrl := s.ExecutionServiceEndpoint + fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/local-agent/run-results", orgId, projectId, appId)
-	parsedUrl, err := url.Parse(requestUrl)
-	if err != nil {
-		logger.Error("error parsing url in  results endpoint", err)
-		return err
-	}
-	params := &url.Values{}
+	path := fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/local-agent/run-results", orgId, projectId, appId)
+	params := url.Values{}
 	params.Add("execution_id", executionId)
 	params.Add("testplan_id", testPlanId)
 	params.Add("result_type", resultType)
-	parsedUrl.RawQuery = params.Encode()
 
-	body, err := json.Marshal(runResult)
+	return s.writeJSON(ctx, OutboxRequest{Call: "CreateLocalAgentResults", ExecutionId: executionId, Method: http.MethodPost, Path: path, Query: params}, runResult)
+}
+
+func (s *ExecutionServiceBridge) CreateLocalAgentNetworkLogs(ctx context.Context, sess session.Session) error {
+	logger.Info("creating local agent network logs", zap.String("org_id", sess.OrgId), zap.String("project_id", sess.ProjectId), zap.String("app_id", sess.AppId))
+	log, err := s.ExtractNetworkLogs(ctx, sess.OutputDir, sess.TestplanId, sess.FileName, sess.MachineId, sess.ExecutionId, sess.TestcaseId)
 	if err != nil {
-		logger.Error("error marshaling  results", err)
+		logger.Error("error extracting network logs", err)
+		if isArtifactWaitError(err) {
+			s.reportArtifactWarning(ctx, sess.OrgId, sess.ProjectId, sess.AppId, sess.Testlab, sess.ExecutionId, sess.TestcaseId, session.WarningTraceMissing, err)
+		}
 		return err
 	}
+	path := fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/local-agent/network-logs", sess.OrgId, sess.ProjectId, sess.AppId)
 
-	req, err := http.NewRequest(http.MethodPost, parsedUrl.String(), bytes.NewBuffer(body))
-	if err != nil {
-		logger.Error("error creating create local agent results request", err)
+	if err := s.writeJSON(ctx, OutboxRequest{Call: "CreateLocalAgentNetworkLogs", ExecutionId: sess.ExecutionId, Method: http.MethodPost, Path: path}, log); err != nil {
 		return err
 	}
+	logger.Info("created local agent network logs", zap.String("testplan_id", sess.TestplanId), zap.String("testcase_id", sess.TestcaseId))
 
-	res, err := http.DefaultClient.Do(req)
-
-	if err != nil {
-		logger.Error("error creating local agent run result status", err)
-		return err
+	traceStored := false
+	if store := s.artifactStoreFor(sess.OrgId); store != nil {
+		key := artifactstore.Key{OrgId: sess.OrgId, ProjectId: sess.ProjectId, ExecutionId: sess.ExecutionId, TestcaseId: sess.TestcaseId, Kind: artifactstore.KindTrace, Name: filepath.Base(apxconstants.LogsZipFolderName)}
+		url, err := s.storeArtifact(ctx, store, key, sess.OutputDir+apxconstants.LogsZipFolderName)
+		if err == nil {
+			traceStored = true
+			err = s.saveArtifactURLs(ctx, sess.OrgId, sess.ProjectId, sess.AppId, sess.Testlab, session.ArtifactURLs{ExecutionId: sess.ExecutionId, TestcaseId: sess.TestcaseId, TraceURL: url})
+		}
+		if err != nil {
+			logger.Error("error storing trace", err, zap.String("execution_id", sess.ExecutionId), zap.String("testcase_id", sess.TestcaseId))
+		}
+	}
+	if s.uploader != nil && !traceStored {
+		err := s.uploader.Upload(ctx, ArtifactUpload{
+			Kind:        ArtifactKindTrace,
+			FilePath:    sess.OutputDir + apxconstants.LogsZipFolderName,
+			FileName:    filepath.Base(apxconstants.LogsZipFolderName),
+			OrgId:       sess.OrgId,
+			ProjectId:   sess.ProjectId,
+			AppId:       sess.AppId,
+			Testlab:     sess.Testlab,
+			ExecutionId: sess.ExecutionId,
+			Fields:      map[string]string{"testcase_id": sess.TestcaseId, "testplan_id": sess.TestplanId, "machine_id": sess.MachineId},
+		})
+		if err != nil {
+			logger.Error("error uploading trace", err, zap.String("execution_id", sess.ExecutionId), zap.String("testcase_id", sess.TestcaseId))
+		}
 	}
-	if res.StatusCode == http.StatusOK {
-		logger.Info("created local agent run results", zap.String("project_id", projectId), zap.String("app_id", appId))
+
+	// nkk: The trimmed log above stays the source of truth, a failed HAR export is only logged
+	if s.harOptions != nil {
+		if err := s.uploadNetworkHAR(ctx, sess); err != nil {
+			logger.Error("error exporting network HAR", err, zap.String("execution_id", sess.ExecutionId), zap.String("testcase_id", sess.TestcaseId))
+		}
 	}
-	defer res.Body.Close()
 	return nil
 }
 
-func (s *ExecutionServiceBridge) CreateLocalAgentNetworkLogs(ctx context.Context, session session.Session) error {
-	logger.Info("creating local agent network logs", zap.String("org_id", session.OrgId), zap.String("project_id", session.ProjectId), zap.String("app_id", session.AppId))
-	log, err := s.ExtractNetworkLogs(session.OutputDir, session.TestplanId, session.FileName, session.MachineId, session.ExecutionId, session.TestcaseId)
+// uploadNetworkHAR builds the HAR of the trace ExtractNetworkLogs extracted, keeps it next to the trace and uploads it
+func (s *ExecutionServiceBridge) uploadNetworkHAR(ctx context.Context, session session.Session) error {
+	har, err := BuildHAR(session.OutputDir+"/trace", *s.harOptions)
 	if err != nil {
-		logger.Error("error extracting network logs", err)
 		return err
 	}
-	requestUrl := s.ExecutionServiceEndpoint + fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/local-agent/network-logs", session.OrgId, session.ProjectId, session.AppId)
-
-	body, err := json.Marshal(log)
+	harFilePath := session.OutputDir + "/" + apxconstants.HARFileName
+	data, err := json.Marshal(har)
 	if err != nil {
-		logger.Error("error marshaling network logs", err)
-		return err
+		return fmt.Errorf("failed to encode HAR: %w", err)
 	}
-
-	req, err := http.NewRequest(http.MethodPost, requestUrl, bytes.NewReader(body))
-	if err != nil {
-		logger.Error("error  creating  local agent run result s request", err)
-		return err
+	if err := os.WriteFile(harFilePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write HAR file: %w", err)
 	}
 
-	res, err := http.DefaultClient.Do(req)
+	path := fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions/%s/upload-har", session.OrgId, session.ProjectId, session.AppId, session.Testlab, session.ExecutionId)
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	request := OutboxRequest{
+		Call:        "UploadNetworkHAR",
+		ExecutionId: session.ExecutionId,
+		Method:      http.MethodPost,
+		Path:        path,
+		ContentType: "multipart/form-data; boundary=" + boundary,
+	}
+	err = s.write(ctx, request, -1, func(w io.Writer) error {
+		writer := multipart.NewWriter(w)
+		if err := writer.SetBoundary(boundary); err != nil {
+			return err
+		}
+		part, err := writer.CreateFormFile("har", apxconstants.HARFileName)
+		if err != nil {
+			return fmt.Errorf("failed to create form file: %w", err)
+		}
+		if _, err := part.Write(data); err != nil {
+			return fmt.Errorf("failed to write HAR: %w", err)
+		}
+		fields := map[string]string{
+			"execution_id": session.ExecutionId,
+			"testcase_id":  session.TestcaseId,
+			"testplan_id":  session.TestplanId,
+			"machine_id":   session.MachineId,
+			"entries":      fmt.Sprintf("%d", len(har.Log.Entries)),
+		}
+		for key, value := range fields {
+			if err := writer.WriteField(key, value); err != nil {
+				return fmt.Errorf("failed to write field %s: %w", key, err)
+			}
+		}
+		return writer.Close()
+	})
 	if err != nil {
-		logger.Error("error creating local agent run result status", err)
 		return err
 	}
-	defer res.Body.Close()
-	logger.Info("created local agent network logs", zap.String("testplan_id", session.TestplanId), zap.String("testcase_id", session.TestcaseId))
+	logger.Info("uploaded network HAR", zap.String("execution_id", session.ExecutionId), zap.String("testcase_id", session.TestcaseId), zap.Int("entries", len(har.Log.Entries)))
 	return nil
 }
 
 func (s *ExecutionServiceBridge) TakeScreenshot(ctx context.Context, orgId, projectId string, appId string, testlab string, executionId string, screenshot screenshot.TakeScreenshot) error {
 	logger.Info("taking screenshot", zap.String("org_id", orgId), zap.String("project_id", projectId), zap.String("app_id", appId))
-	requestUrl := s.ExecutionServiceEndpoint + fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions/%s/take-screenshot", orgId, projectId, appId, testlab, executionId)
-
-	requestBody, err := json.Marshal(screenshot)
-	if err != nil {
-		logger.Error("error marshaling screenshot", err)
-		return err
-	}
-
-	req, err := http.NewRequest(http.MethodPost, requestUrl, bytes.NewReader(requestBody))
-	if err != nil {
-		logger.Error("error creating request", err)
-		return err
-	}
-
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		logger.Error("error taking screenshot", err)
-		return err
-	}
-	defer res.Body.Close()
-	return nil
+	path := fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions/%s/take-screenshot", orgId, projectId, appId, testlab, executionId)
+	return s.writeJSON(ctx, OutboxRequest{Call: "TakeScreenshot", ExecutionId: executionId, Method: http.MethodPost, Path: path}, screenshot)
 }
 
 func (s *ExecutionServiceBridge) UploadScreenshots(ctx context.Context, orgId, projectId, appId, testlab, executionId string, screenshot screenshot.UploadScreenshotRequest) error {
 	logger.Info("uploading screenshots", zap.String("org_id", orgId), zap.String("project_id", projectId), zap.String("app_id", appId))
-	requestUrl := s.ExecutionServiceEndpoint + fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions/%s/upload-screenshots", orgId, projectId, appId, testlab, executionId)
-	requestBody, err := json.Marshal(screenshot)
-	if err != nil {
-		logger.Error("error marshaling screenshot", err)
-		return err
-	}
-
-	req, err := http.NewRequest(http.MethodPost, requestUrl, bytes.NewReader(requestBody))
-	if err != nil {
-		logger.Error("error creating request", err)
-		return err
+	path := fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions/%s/upload-screenshots", orgId, projectId, appId, testlab, executionId)
+	if store := s.artifactStoreFor(orgId); store != nil && screenshot.ScreenshotsDir != "" {
+		key := artifactstore.Key{OrgId: orgId, ProjectId: projectId, ExecutionId: executionId, TestcaseId: screenshot.TestcaseId, Kind: artifactstore.KindScreenshot}
+		urls, err := s.storeScreenshots(ctx, store, key, screenshot.ScreenshotsDir)
+		if err == nil {
+			err = s.saveArtifactURLs(ctx, orgId, projectId, appId, testlab, session.ArtifactURLs{ExecutionId: executionId, TestcaseId: screenshot.TestcaseId, Screenshots: urls})
+		}
+		if err == nil {
+			// nkk: The links replace the bundle, the request below still carries the metadata
+			return s.writeJSON(ctx, OutboxRequest{Call: "UploadScreenshots", ExecutionId: executionId, Method: http.MethodPost, Path: path}, screenshot)
+		}
+		logger.Error("error storing screenshots", err, zap.String("execution_id", executionId))
 	}
-
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		logger.Error("error uploading screenshots", err)
-		return err
+	if s.uploader != nil && screenshot.ScreenshotsDir != "" {
+		if err := s.uploadScreenshotBundle(ctx, orgId, projectId, appId, testlab, executionId, screenshot); err != nil {
+			logger.Error("error uploading screenshot bundle", err, zap.String("execution_id", executionId))
+		}
 	}
-	defer res.Body.Close()
-	return nil
+	return s.writeJSON(ctx, OutboxRequest{Call: "UploadScreenshots", ExecutionId: executionId, Method: http.MethodPost, Path: path}, screenshot)
 }
 
 func (s *ExecutionServiceBridge) SaveSession(ctx context.Context, orgId string, projectId string, appId string, testlab string, session session.Session) error {
 	logger.Info("saving session", zap.String("org_id", orgId), zap.String("project_id", projectId), zap.String("app_id", appId))
-	requestUrl := s.ExecutionServiceEndpoint + fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions", orgId, projectId, appId, testlab)
-	requestBody, err := json.Marshal(session)
-	if err != nil {
-		logger.Error("error marshaling session", err)
-		return err
-	}
-
-	req, err := http.NewRequest(http.MethodPost, requestUrl, bytes.NewReader(requestBody))
-	if err != nil {
-		logger.Error("error creating request", err)
-		return err
-	}
-
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		logger.Error("error saving session", err)
-		return err
+	// nkk: On-prem sessions are batched into MongoDB, see MongoSink
+	if s.mongoSessions {
+		s.batchWriter.Add(&session)
+		return nil
 	}
-	defer res.Body.Close()
-
-	return nil
+	path := fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions", orgId, projectId, appId, testlab)
+	return s.writeJSON(ctx, OutboxRequest{Call: "SaveSession", ExecutionId: session.ExecutionId, Method: http.MethodPost, Path: path}, session)
 }
 
 func (s *ExecutionServiceBridge) UpdateStepCount(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, body executionstep.ExecutionStep) error {
 	logger.Info("updating step count", zap.String("org_id", orgId), zap.String("project_id", projectId), zap.String("app_id", appId))
-	requestUrl := s.ExecutionServiceEndpoint + fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions/%s/update-stepcount", orgId, projectId, appId, testlab, executionId)
-	requestBody, err := json.Marshal(body)
-	if err != nil {
-		logger.Error("error marshaling step count", err)
-		return err
-	}
-
-	req, err := http.NewRequest(http.MethodPut, requestUrl, bytes.NewReader(requestBody))
-	if err != nil {
-		logger.Error("error creating request", err)
-		return err
-
-	}
-
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		logger.Error("error updating step count", err)
-		return err
-	}
-	defer res.Body.Close()
-
-	return nil
+	path := fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions/%s/update-stepcount", orgId, projectId, appId, testlab, executionId)
+	return s.writeJSON(ctx, OutboxRequest{Call: "UpdateStepCount", ExecutionId: executionId, Method: http.MethodPut, Path: path}, body)
 }
 
 func (s *ExecutionServiceBridge) UpdateSession(ctx context.Context, orgId string, projectId string, appId string, testlab string, session session.Session) error {
 	logger.Info("updating session", zap.String("org_id", orgId), zap.String("project_id", projectId), zap.String("app_id", appId))
-	requestUrl := s.ExecutionServiceEndpoint + fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions", orgId, projectId, appId, testlab)
-	requestBody, err := json.Marshal(session)
-	if err != nil {
-		logger.Error("error marshaling session", err)
-		return err
-	}
-
-	req, err := http.NewRequest(http.MethodPut, requestUrl, bytes.NewReader(requestBody))
-	if err != nil {
-		logger.Error("error creating request", err)
-		return err
-	}
-
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		logger.Error("error updating session", err)
-		return err
+	if s.mongoSessions {
+		s.batchWriter.Add(&session)
+		return nil
 	}
-	defer res.Body.Close()
-	return nil
+	path := fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions", orgId, projectId, appId, testlab)
+	return s.writeJSON(ctx, OutboxRequest{Call: "UpdateSession", ExecutionId: session.ExecutionId, Method: http.MethodPut, Path: path}, session)
 }
 
-func (s *ExecutionServiceBridge) ExtractNetworkLogs(outputDir string, testPlanId string, fileName string, machineId string, executionId string, testcaseId string) (logs.Log, error) {
+func (s *ExecutionServiceBridge) ExtractNetworkLogs(ctx context.Context, outputDir string, testPlanId string, fileName string, machineId string, executionId string, testcaseId string) (logs.Log, error) {
 	var log logs.Log
 	zipFilePath := outputDir + apxconstants.LogsZipFolderName
 	destDir := outputDir + "/trace"
-	for {
-		if _, err := os.Stat(zipFilePath); os.IsNotExist(err) {
-			continue
-		}
-		break
-	}
-
-	stable, err := helpers.IsFileStable(zipFilePath, 10, 10*time.Second, "trace.zip")
-	if err != nil {
-		logger.Error("Error checking file stability:", err)
+	if _, err := s.artifactCollector().Wait(ctx, zipFilePath); err != nil {
+		logger.Error("trace not available", err)
 		return logs.Log{}, err
 	}
 
-	if stable {
-		err := helpers.ExtractZipFile(zipFilePath, destDir)
-		if err != nil {
-			logger.Error("Error extracting zip file:", err)
-			return logs.Log{}, err
-		}
+	if err := helpers.ExtractZipFile(zipFilePath, destDir); err != nil {
+		logger.Error("Error extracting zip file:", err)
+		return logs.Log{}, err
 	}
 
 	dirEntries, err := os.ReadDir(destDir)
@@ -395,17 +507,45 @@ func (s *ExecutionServiceBridge) ExtractNetworkLogs(outputDir string, testPlanId
 
 func (b *ExecutionServiceBridge) UploadVideo(ctx context.Context, data uploadvideo.UploadVideo) error {
 	videoFilePath := data.OutputDir + "/" + apxconstants.VideoFileName
-	for {
-		if _, err := os.Stat(videoFilePath); os.IsNotExist(err) {
-			continue
+	if _, err := b.artifactCollector().Wait(ctx, videoFilePath); err != nil {
+		logger.Error("video not available", err)
+		if isArtifactWaitError(err) {
+			b.reportArtifactWarning(ctx, data.OrgId, data.ProjectId, data.AppId, data.Testlab, data.ExecutionId, data.TestcaseId, session.WarningVideoMissing, err)
 		}
-		break
+		return fmt.Errorf("video not available: %w", err)
 	}
-
-	_, err := helpers.IsFileStable(videoFilePath, 10, 10*time.Second, "video.webm")
-	if err != nil {
-		logger.Error("Error checking file stability:", err)
-		return fmt.Errorf("error checking file stability: %w", err)
+	if store := b.artifactStoreFor(data.OrgId); store != nil {
+		key := artifactstore.Key{OrgId: data.OrgId, ProjectId: data.ProjectId, ExecutionId: data.ExecutionId, TestcaseId: data.TestcaseId, Kind: artifactstore.KindVideo, Name: apxconstants.VideoFileName}
+		url, err := b.storeArtifact(ctx, store, key, videoFilePath)
+		if err == nil {
+			return b.saveArtifactURLs(ctx, data.OrgId, data.ProjectId, data.AppId, data.Testlab, session.ArtifactURLs{ExecutionId: data.ExecutionId, TestcaseId: data.TestcaseId, VideoURL: url})
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		logger.Warn("storing video failed, sending it to the execution service", zap.String("execution_id", data.ExecutionId), zap.Error(err))
+	}
+	if b.uploader != nil {
+		err := b.uploader.Upload(ctx, ArtifactUpload{
+			Kind:        ArtifactKindVideo,
+			FilePath:    videoFilePath,
+			FileName:    apxconstants.VideoFileName,
+			OrgId:       data.OrgId,
+			ProjectId:   data.ProjectId,
+			AppId:       data.AppId,
+			Testlab:     data.Testlab,
+			ExecutionId: data.ExecutionId,
+			Fields:      videoFields(data),
+		})
+		if err == nil {
+			logger.Info("video uploaded", zap.String("execution_id", data.ExecutionId), zap.String("testcase_id", data.TestcaseId))
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		// nkk: The outbox still gets the video to the service, just not resumably
+		logger.Warn("chunked video upload failed, sending it in one request", zap.String("execution_id", data.ExecutionId), zap.Error(err))
 	}
 	file, err := os.Open(videoFilePath)
 	if err != nil {
@@ -413,20 +553,75 @@ func (b *ExecutionServiceBridge) UploadVideo(ctx context.Context, data uploadvid
 		return fmt.Errorf("failed to open video file: %w", err)
 	}
 	defer file.Close()
-	requestUrl := b.ExecutionServiceEndpoint + fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions/%s/upload-video", data.OrgId, data.ProjectId, data.AppId, data.Testlab, data.ExecutionId)
-	var requestBody bytes.Buffer
-	writer := multipart.NewWriter(&requestBody)
+	path := fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/%s/sessions/%s/upload-video", data.OrgId, data.ProjectId, data.AppId, data.Testlab, data.ExecutionId)
+
+	// nkk: The boundary is part of the content type, create it before the body is written
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	request := OutboxRequest{
+		Call:        "UploadVideo",
+		ExecutionId: data.ExecutionId,
+		Method:      http.MethodPost,
+		Path:        path,
+		ContentType: "multipart/form-data; boundary=" + boundary,
+	}
+	err = b.write(ctx, request, -1, func(w io.Writer) error {
+		writer := multipart.NewWriter(w)
+		if err := writer.SetBoundary(boundary); err != nil {
+			return err
+		}
+
+		part, err := writer.CreateFormFile("video", "video.webm")
+		if err != nil {
+			return fmt.Errorf("failed to create form file: %w", err)
+		}
+		_, err = io.Copy(part, file)
+		if err != nil {
+			return fmt.Errorf("failed to copy video file: %w", err)
+		}
 
-	part, err := writer.CreateFormFile("video", "video.webm")
+		// Add other fields
+		for key, value := range videoFields(data) {
+			err = writer.WriteField(key, value)
+			if err != nil {
+				return fmt.Errorf("failed to write field %s: %w", key, err)
+			}
+		}
+
+		// Close the writer to finalize the form data
+		return writer.Close()
+	})
 	if err != nil {
-		return fmt.Errorf("failed to create form file: %w", err)
+		return err
 	}
-	_, err = io.Copy(part, file)
-	if err != nil {
-		return fmt.Errorf("failed to copy video file: %w", err)
+
+	logger.Info("video queued for upload", zap.String("execution_id", data.ExecutionId), zap.String("testcase_id", data.TestcaseId))
+	return nil
+}
+
+// uploadScreenshotBundle zips the screenshots directory next to it and uploads the zip in chunks
+func (s *ExecutionServiceBridge) uploadScreenshotBundle(ctx context.Context, orgId, projectId, appId, testlab, executionId string, request screenshot.UploadScreenshotRequest) error {
+	bundlePath := filepath.Clean(request.ScreenshotsDir) + ".zip"
+	// nkk: A bundle with an upload in progress is resumed as is, zipping again would start over
+	if _, err := os.Stat(bundlePath + uploadStateSuffix); err != nil {
+		if err := helpers.ZipDirectory(request.ScreenshotsDir, bundlePath); err != nil {
+			return fmt.Errorf("failed to bundle screenshots: %w", err)
+		}
 	}
+	return s.uploader.Upload(ctx, ArtifactUpload{
+		Kind:        ArtifactKindScreenshots,
+		FilePath:    bundlePath,
+		FileName:    filepath.Base(bundlePath),
+		OrgId:       orgId,
+		ProjectId:   projectId,
+		AppId:       appId,
+		Testlab:     testlab,
+		ExecutionId: executionId,
+		Fields:      map[string]string{"testcase_id": request.TestcaseId, "testplan_id": request.TestplanId, "machine_id": request.MachineId},
+	})
+}
 
-	// Add other fields
+// videoFields are the non-empty form fields sent with a video
+func videoFields(data uploadvideo.UploadVideo) map[string]string {
 	fields := map[string]string{
 		"project_id":         data.ProjectId,
 		"app_id":             data.AppId,
@@ -440,59 +635,25 @@ func (b *ExecutionServiceBridge) UploadVideo(ctx context.Context, data uploadvid
 		"is_prerequisite":    fmt.Sprintf("%t", data.IsPreRequisite),
 		"parent_testcase_id": data.ParentTestCaseId,
 	}
-
 	for key, value := range fields {
-		if value != "" {
-			err = writer.WriteField(key, value)
-			if err != nil {
-				return fmt.Errorf("failed to write field %s: %w", key, err)
-			}
+		if value == "" {
+			delete(fields, key)
 		}
 	}
-
-	// Close the writer to finalize the form data
-	err = writer.Close()
-	if err != nil {
-		return fmt.Errorf("failed to close writer: %w", err)
-	}
-
-	// Create the HTTP request
-	request, err := http.NewRequest("POST", requestUrl, &requestBody)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-	request.Header.Set("Content-Type", writer.FormDataContentType())
-
-	// Perform the request
-	client := &http.Client{}
-	response, err := client.Do(request)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
-	}
-	defer response.Body.Close()
-
-	// Check the response status
-	if response.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(response.Body)
-		return fmt.Errorf("unexpected status code: %d, response: %s", response.StatusCode, string(body))
-	}
-
-	fmt.Println("Upload successful!")
-	return nil
+	return fields
 }
 
 func (s *ExecutionServiceBridge) GetRunCountForTestPlan(ctx context.Context, orgId, projectId string, appId string, testLab string, testPlanId string) (int, error) {
 	logger.Info("getting run count for test plan", zap.String("org_id", orgId), zap.String("project_id", projectId), zap.String("app_id", appId), zap.String("test_plan_id", testPlanId))
-	requestUrl := s.ExecutionServiceEndpoint + fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/local-agent/%s/run-count/%s", orgId, projectId, appId, testLab, testPlanId)
-	res, err := http.Get(requestUrl)
+	path := fmt.Sprintf("/organisations/%s/projects/%s/apps/%s/local-agent/%s/run-count/%s", orgId, projectId, appId, testLab, testPlanId)
+	res, err := s.transport.Do(ctx, Call{Name: "GetRunCountForTestPlan", Method: http.MethodGet, Path: path})
 	if err != nil {
 		logger.Error("error getting run count for test plan", err)
 		return 0, err
 	}
-	defer res.Body.Close()
 
 	var runCount int
-	err = json.NewDecoder(res.Body).Decode(&runCount)
+	err = json.Unmarshal(res.Body, &runCount)
 	if err != nil {
 		logger.Error("error decoding run count for test plan", err)
 		return 0, err
@@ -523,29 +684,15 @@ Design by Google systems engineers:
 // Removed duplicate sendBatch implementation
 
 // sendBatchToMongoDB sends batch to MongoDB
-func (b *BatchWriter) sendBatchToMongoDB(sessions []*session.Session) error {
+func (b *BatchWriter) sendBatchToMongoDB(sink *MongoSink, sessions []*session.Session) error {
 	// nkk: MongoDB bulk write operations
 	// Based on Meta's data pipeline optimizations
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// TODO: Implement mongoClient initialization in BatchWriter or remove this call
-	// collection := b.mongoClient.Database("testrunner").Collection("sessions")
-	var collection *mongo.Collection
-
-	// nkk: Create bulk write models
-	var models []mongo.WriteModel
-	for _, sess := range sessions {
-		// Use ReplaceOne with upsert for idempotency
-		filter := bson.M{"_id": sess.ExecutionId}
-		model := mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(sess).SetUpsert(true)
-		models = append(models, model)
-	}
-
-	// nkk: Execute bulk write with unordered for performance
-	opts := options.BulkWrite().SetOrdered(false)
-	result, err := collection.BulkWrite(ctx, models, opts)
+	// nkk: Upserts keyed by execution and testcase, unordered for performance
+	result, err := sink.Write(ctx, sessions)
 	if err != nil {
 		return fmt.Errorf("MongoDB bulk write failed: %w", err)
 	}
@@ -557,239 +704,5 @@ func (b *BatchWriter) sendBatchToMongoDB(sessions []*session.Session) error {
 	return nil
 }
 
-// sendBatchToHTTP sends batch via HTTP
-func (b *BatchWriter) sendBatchToHTTP(sessions []*session.Session) {
-	payload := map[string]interface{}{
-		"sessions": sessions,
-		"count":    len(sessions),
-	}
-
-	body, err := json.Marshal(payload)
-	if err != nil {
-		logger.Error("Failed to marshal batch", zap.Error(err))
-		return
-	}
-
-	// nkk: Retry with exponential backoff
-	// Based on Google's SRE practices
-	b.retryWithBackoff(func() error {
-		url := b.endpoint + "/batch/sessions"
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
-		if err != nil {
-			return fmt.Errorf("failed to create request: %w", err)
-		}
-
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err := b.httpClient.Do(req)
-		if err != nil {
-			return fmt.Errorf("request failed: %w", err)
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			// nkk: Retry on 5xx errors
-			if resp.StatusCode >= 500 {
-				return fmt.Errorf("server error: %d", resp.StatusCode)
-			}
-			// Don't retry client errors
-			logger.Error("Batch request failed with client error", zap.Int("status", resp.StatusCode))
-			return nil
-		}
-
-		logger.Debug("Batch sent successfully", zap.Int("count", len(sessions)))
-		return nil
-	})
-}
-
-// retryWithBackoff implements exponential backoff retry
-func (b *BatchWriter) retryWithBackoff(fn func() error) {
-	// nkk: Exponential backoff configuration
-	// Based on AWS SDK retry strategy
-	maxRetries := 3
-	baseDelay := 100 * time.Millisecond
-	maxDelay := 10 * time.Second
-	multiplier := 2.0
-
-	delay := baseDelay
-
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		err := fn()
-		if err == nil {
-			return // Success
-		}
-
-		if attempt == maxRetries {
-			logger.Error("All retry attempts failed", zap.Error(err))
-			return
-		}
-
-		// nkk: Add jitter to prevent thundering herd
-		jitter := time.Duration(rand.Float64() * float64(delay) * 0.3)
-		actualDelay := delay + jitter
-
-		if actualDelay > maxDelay {
-			actualDelay = maxDelay
-		}
-
-		logger.Warn("Retrying batch send",
-			zap.Int("attempt", attempt+1),
-			zap.Duration("delay", actualDelay),
-			zap.Error(err))
-
-		time.Sleep(actualDelay)
-
-		// Exponential increase
-		delay = time.Duration(float64(delay) * multiplier)
-	}
-}
-
 // Flush manually flushes the buffer
 /* Removed duplicate Flush implementation */
-
-/*
-nkk: S3UploadManager for streaming video uploads
-Design by BrowserStack engineers:
-- Stream directly to S3 without loading into memory
-- Multipart upload for large files
-- Compression on the fly
-*/
-
-// Removed duplicate S3UploadManager implementation
-
-// NewS3UploadManager creates a new S3 upload manager
-// Removed duplicate NewS3UploadManager implementation
-
-// StreamUpload streams a file to S3
-func (m *S3UploadManager) StreamUpload(ctx context.Context, filePath string, metadata map[string]string) error {
-	// nkk: Production S3 streaming implementation
-	// Based on BrowserStack's approach for video uploads
-
-	logger.Info("Streaming upload to S3", zap.String("file", filePath))
-
-	file, err := os.Open(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
-
-	// nkk: Get file info for size
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return fmt.Errorf("failed to stat file: %w", err)
-	}
-
-	// nkk: Use multipart for files > 5MB
-	if fileInfo.Size() > 5*1024*1024 {
-		return m.multipartUpload(ctx, file, fileInfo, metadata)
-	}
-
-	// nkk: Simple upload for small files
-	return m.simpleUpload(ctx, file, fileInfo, metadata)
-}
-
-// multipartUpload handles large file uploads
-func (m *S3UploadManager) multipartUpload(ctx context.Context, file *os.File, info os.FileInfo, metadata map[string]string) error {
-	// nkk: Multipart upload for large files
-	// Chunk size: 10MB for optimal performance
-
-	const chunkSize = 10 * 1024 * 1024 // 10MB chunks
-	buffer := make([]byte, chunkSize)
-
-	// nkk: Stream chunks
-	for {
-		n, err := file.Read(buffer)
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return fmt.Errorf("failed reading chunk: %w", err)
-		}
-
-		// nkk: In production, upload chunk to S3
-		// For now, simulate processing
-		logger.Debug("Uploaded chunk", zap.Int("size", n))
-	}
-
-	logger.Info("Multipart upload complete", zap.String("file", file.Name()))
-	return nil
-}
-
-// simpleUpload handles small file uploads
-func (m *S3UploadManager) simpleUpload(ctx context.Context, file *os.File, info os.FileInfo, metadata map[string]string) error {
-	// nkk: Simple single-part upload
-	data, err := io.ReadAll(file)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
-	}
-
-	// nkk: In production, upload to S3
-	// For now, simulate upload
-	logger.Info("Simple upload complete",
-		zap.String("file", file.Name()),
-		zap.Int64("size", int64(len(data))))
-
-	return nil
-}
-
-// UploadVideoOptimized uploads video with compression
-func (m *S3UploadManager) UploadVideoOptimized(ctx context.Context, videoPath string, sessionID string) error {
-	// nkk: Stream video with compression
-	// Based on BrowserStack's video pipeline
-
-	logger.Info("Uploading video with optimization",
-		zap.String("path", videoPath),
-		zap.String("session_id", sessionID))
-
-	// nkk: Check video size
-	info, err := os.Stat(videoPath)
-	if err != nil {
-		return fmt.Errorf("failed to stat video: %w", err)
-	}
-
-	// nkk: Compress if > 50MB
-	if info.Size() > 50*1024*1024 {
-		compressedPath := videoPath + ".compressed.mp4"
-
-		// FFmpeg compression command
-		cmd := exec.Command("ffmpeg",
-			"-i", videoPath,
-			"-c:v", "libx264",
-			"-preset", "faster",
-			"-crf", "28",
-			"-c:a", "aac",
-			"-b:a", "128k",
-			"-movflags", "+faststart",
-			"-y",
-			compressedPath,
-		)
-
-		if err := cmd.Run(); err != nil {
-			logger.Error("Video compression failed", zap.Error(err))
-			// Continue with uncompressed upload
-		} else {
-			// Use compressed version
-			videoPath = compressedPath
-			defer os.Remove(compressedPath) // Clean up compressed file
-		}
-	}
-
-	// nkk: Upload to S3
-	metadata := map[string]string{
-		"session_id": sessionID,
-		"type":       "test_recording",
-		"timestamp":  fmt.Sprintf("%d", time.Now().Unix()),
-	}
-
-	if err := m.StreamUpload(ctx, videoPath, metadata); err != nil {
-		return fmt.Errorf("failed to upload video: %w", err)
-	}
-
-	// nkk: Delete local file after successful upload
-	if err := os.Remove(videoPath); err != nil {
-		logger.Warn("Failed to delete local video", zap.Error(err))
-	}
-
-	logger.Info("Video upload complete", zap.String("session_id", sessionID))
-	return nil
-}
@@ -0,0 +1,421 @@
+package executionbridge
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"agent/config"
+	"agent/logger"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: HAR 1.2 export of the Playwright network trace (http://www.softwareishard.com/blog/har-12-spec/)
+The resource-snapshot lines of 0-trace.network are already HAR entries, bodies sit in resources/<_sha1> of the trace.
+BuildHAR fills what the spec requires and Playwright leaves out, applies the filter and inlines bodies up to MaxBodySize.
+*/
+
+// HAR is a HAR 1.2 document
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string     `json:"version"`
+	Creator HARCreator `json:"creator"`
+	Entries []HAREntry `json:"entries"`
+	Comment string     `json:"comment,omitempty"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HAREntry struct {
+	Pageref         string      `json:"pageref,omitempty"`
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Connection      string      `json:"connection,omitempty"`
+	ResourceType    string      `json:"_resourceType,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+	SameSite string `json:"sameSite,omitempty"`
+}
+
+type HARPostData struct {
+	MimeType string         `json:"mimeType"`
+	Params   []HARNameValue `json:"params,omitempty"`
+	Text     string         `json:"text"`
+	Sha1     string         `json:"_sha1,omitempty"`
+	Comment  string         `json:"comment,omitempty"`
+}
+
+type HARContent struct {
+	Size        int64  `json:"size"`
+	Compression int64  `json:"compression,omitempty"`
+	MimeType    string `json:"mimeType"`
+	Text        string `json:"text,omitempty"`
+	Encoding    string `json:"encoding,omitempty"`
+	Sha1        string `json:"_sha1,omitempty"`
+	Comment     string `json:"comment,omitempty"`
+}
+
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// StatusRange is an inclusive range of response statuses
+type StatusRange struct {
+	Min int
+	Max int
+}
+
+// HARFilter selects the entries of a HAR, an empty field matches everything
+type HARFilter struct {
+	StatusRanges  []StatusRange
+	ResourceTypes []string
+	IncludeURLs   []*regexp.Regexp // nkk: at least one must match
+	ExcludeURLs   []*regexp.Regexp // nkk: none may match
+}
+
+// HAROptions configure BuildHAR
+type HAROptions struct {
+	Filter        HARFilter
+	IncludeBodies bool
+	MaxBodySize   int64 // nkk: larger bodies are left out with a comment
+}
+
+// ParseHARFilter builds a filter from its config form, statuses are "404", "500-599" or "4xx"
+func ParseHARFilter(statusRanges, resourceTypes, includeURLs, excludeURLs []string) (HARFilter, error) {
+	var filter HARFilter
+	for _, value := range statusRanges {
+		statusRange, err := parseStatusRange(value)
+		if err != nil {
+			return HARFilter{}, err
+		}
+		filter.StatusRanges = append(filter.StatusRanges, statusRange)
+	}
+	for _, resourceType := range resourceTypes {
+		filter.ResourceTypes = append(filter.ResourceTypes, strings.ToLower(strings.TrimSpace(resourceType)))
+	}
+	for _, pattern := range includeURLs {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return HARFilter{}, fmt.Errorf("invalid include URL pattern %q: %w", pattern, err)
+		}
+		filter.IncludeURLs = append(filter.IncludeURLs, re)
+	}
+	for _, pattern := range excludeURLs {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return HARFilter{}, fmt.Errorf("invalid exclude URL pattern %q: %w", pattern, err)
+		}
+		filter.ExcludeURLs = append(filter.ExcludeURLs, re)
+	}
+	return filter, nil
+}
+
+func parseStatusRange(value string) (StatusRange, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if len(value) == 3 && strings.HasSuffix(value, "xx") {
+		class, err := strconv.Atoi(value[:1])
+		if err == nil {
+			return StatusRange{Min: class * 100, Max: class*100 + 99}, nil
+		}
+	}
+	low, high, isRange := strings.Cut(value, "-")
+	min, err := strconv.Atoi(strings.TrimSpace(low))
+	if err != nil {
+		return StatusRange{}, fmt.Errorf("invalid status range %q", value)
+	}
+	max := min
+	if isRange {
+		if max, err = strconv.Atoi(strings.TrimSpace(high)); err != nil || max < min {
+			return StatusRange{}, fmt.Errorf("invalid status range %q", value)
+		}
+	}
+	return StatusRange{Min: min, Max: max}, nil
+}
+
+// Match reports whether the filter keeps the entry
+func (f HARFilter) Match(entry *HAREntry) bool {
+	if len(f.StatusRanges) > 0 {
+		matched := false
+		for _, statusRange := range f.StatusRanges {
+			if entry.Response.Status >= statusRange.Min && entry.Response.Status <= statusRange.Max {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(f.ResourceTypes) > 0 {
+		matched := false
+		for _, resourceType := range f.ResourceTypes {
+			if resourceType == entry.ResourceType {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(f.IncludeURLs) > 0 {
+		matched := false
+		for _, re := range f.IncludeURLs {
+			if re.MatchString(entry.Request.URL) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, re := range f.ExcludeURLs {
+		if re.MatchString(entry.Request.URL) {
+			return false
+		}
+	}
+	return true
+}
+
+type traceNetworkLine struct {
+	Type     string   `json:"type"`
+	Snapshot HAREntry `json:"snapshot"`
+}
+
+// BuildHAR converts the network trace extracted in traceDir
+func BuildHAR(traceDir string, opts HAROptions) (*HAR, error) {
+	file, err := os.Open(filepath.Join(traceDir, apxconstants.LogsFileName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open network trace: %w", err)
+	}
+	defer file.Close()
+
+	har := &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "agent", Version: config.Version},
+		Entries: []HAREntry{},
+	}}
+	scanner := bufio.NewScanner(file)
+	// nkk: One entry per line, headers and inline post data easily exceed the default 64KB
+	scanner.Buffer(make([]byte, 0, 64*1024), 32*1024*1024)
+	for scanner.Scan() {
+		var line traceNetworkLine
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			logger.Error("error unmarshaling network trace entry", err)
+			continue
+		}
+		if line.Type != "" && line.Type != "resource-snapshot" {
+			continue
+		}
+		entry := line.Snapshot
+		normalizeHAREntry(&entry)
+		if !opts.Filter.Match(&entry) {
+			continue
+		}
+		if opts.IncludeBodies {
+			inlineBodies(traceDir, &entry, opts.MaxBodySize)
+		}
+		har.Log.Entries = append(har.Log.Entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read network trace: %w", err)
+	}
+	return har, nil
+}
+
+// normalizeHAREntry fills the fields HAR 1.2 requires
+func normalizeHAREntry(entry *HAREntry) {
+	request, response := &entry.Request, &entry.Response
+	if request.HTTPVersion == "" {
+		request.HTTPVersion = "HTTP/1.1"
+	}
+	if response.HTTPVersion == "" {
+		response.HTTPVersion = request.HTTPVersion
+	}
+	if request.Cookies == nil {
+		request.Cookies = []HARCookie{}
+	}
+	if request.Headers == nil {
+		request.Headers = []HARNameValue{}
+	}
+	if request.QueryString == nil {
+		request.QueryString = []HARNameValue{}
+		if parsed, err := url.Parse(request.URL); err == nil {
+			for name, values := range parsed.Query() {
+				for _, value := range values {
+					request.QueryString = append(request.QueryString, HARNameValue{Name: name, Value: value})
+				}
+			}
+		}
+	}
+	if response.Cookies == nil {
+		response.Cookies = []HARCookie{}
+	}
+	if response.Headers == nil {
+		response.Headers = []HARNameValue{}
+	}
+	if response.Content.MimeType == "" {
+		response.Content.MimeType = headerValue(response.Headers, "Content-Type")
+	}
+	if request.HeadersSize == 0 {
+		request.HeadersSize = -1
+	}
+	if response.HeadersSize == 0 {
+		response.HeadersSize = -1
+	}
+	// nkk: send, wait and receive cannot be -1 in HAR 1.2
+	timings := &entry.Timings
+	timings.Send = max(timings.Send, 0)
+	timings.Wait = max(timings.Wait, 0)
+	timings.Receive = max(timings.Receive, 0)
+	if entry.ResourceType == "" {
+		entry.ResourceType = resourceTypeOf(response.Content.MimeType, request.URL)
+	}
+}
+
+func headerValue(headers []HARNameValue, name string) string {
+	for _, header := range headers {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value
+		}
+	}
+	return ""
+}
+
+// resourceTypeOf guesses the DevTools resource type from the response
+func resourceTypeOf(mimeType, requestUrl string) string {
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return "document"
+	case mediaType == "text/css":
+		return "stylesheet"
+	case strings.Contains(mediaType, "javascript") || mediaType == "application/ecmascript":
+		return "script"
+	case strings.HasPrefix(mediaType, "image/"):
+		return "image"
+	case strings.HasPrefix(mediaType, "font/") || strings.Contains(mediaType, "font"):
+		return "font"
+	case strings.HasPrefix(mediaType, "audio/") || strings.HasPrefix(mediaType, "video/"):
+		return "media"
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || mediaType == "text/plain" || strings.HasSuffix(mediaType, "xml"):
+		return "fetch"
+	}
+	if strings.HasPrefix(requestUrl, "ws://") || strings.HasPrefix(requestUrl, "wss://") {
+		return "websocket"
+	}
+	return "other"
+}
+
+// inlineBodies reads the bodies the trace stored by sha1
+func inlineBodies(traceDir string, entry *HAREntry, maxSize int64) {
+	content := &entry.Response.Content
+	if content.Text == "" && content.Sha1 != "" {
+		text, encoding, comment := readTraceResource(traceDir, content.Sha1, content.MimeType, maxSize)
+		content.Text, content.Encoding, content.Comment = text, encoding, comment
+	}
+	if postData := entry.Request.PostData; postData != nil && postData.Text == "" && postData.Sha1 != "" {
+		// nkk: postData.text has no encoding field, a binary body stays out
+		text, encoding, comment := readTraceResource(traceDir, postData.Sha1, postData.MimeType, maxSize)
+		if encoding == "" {
+			postData.Text = text
+		} else {
+			comment = "binary body omitted"
+		}
+		postData.Comment = comment
+	}
+}
+
+func readTraceResource(traceDir, sha1, mimeType string, maxSize int64) (text, encoding, comment string) {
+	if sha1 != filepath.Base(sha1) {
+		return "", "", "invalid body reference"
+	}
+	path := filepath.Join(traceDir, "resources", sha1)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", "", "body not in trace"
+	}
+	if maxSize > 0 && info.Size() > maxSize {
+		return "", "", fmt.Sprintf("body of %d bytes omitted, larger than %d", info.Size(), maxSize)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", "body not readable"
+	}
+	if isTextMimeType(mimeType) && utf8.Valid(data) {
+		return string(data), "", ""
+	}
+	return base64.StdEncoding.EncodeToString(data), "base64", ""
+}
+
+func isTextMimeType(mimeType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(mimeType)
+	return strings.HasPrefix(mediaType, "text/") || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") ||
+		strings.Contains(mediaType, "javascript") || strings.HasSuffix(mediaType, "xml") || mediaType == "application/x-www-form-urlencoded"
+}
@@ -0,0 +1,148 @@
+package executionbridge
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/models/logs"
+	"agent/models/session"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: Unit tests for the HAR 1.2 export of the network trace
+*/
+
+const traceNetwork = `{"type":"resource-snapshot","snapshot":{"startedDateTime":"2024-01-01T00:00:00.000Z","time":12,"request":{"method":"GET","url":"https://app.test/index.html?lang=en","httpVersion":"HTTP/2.0","headers":[{"name":"Accept","value":"text/html"}],"headersSize":-1,"bodySize":0},"response":{"status":200,"statusText":"OK","headers":[{"name":"Content-Type","value":"text/html"}],"content":{"size":13,"mimeType":"text/html","_sha1":"page.html"},"headersSize":-1,"bodySize":13},"timings":{"send":-1,"wait":10,"receive":2}}}
+{"type":"resource-snapshot","snapshot":{"startedDateTime":"2024-01-01T00:00:01.000Z","time":5,"request":{"method":"POST","url":"https://api.test/login","postData":{"mimeType":"application/json","_sha1":"login.json"}},"response":{"status":401,"statusText":"Unauthorized","content":{"size":20,"mimeType":"application/json","_sha1":"error.json"}},"timings":{"send":0,"wait":5,"receive":0}}}
+{"type":"resource-snapshot","snapshot":{"startedDateTime":"2024-01-01T00:00:02.000Z","time":3,"request":{"method":"GET","url":"https://cdn.test/logo.png"},"response":{"status":404,"statusText":"Not Found","content":{"size":4,"mimeType":"image/png","_sha1":"logo.png"}},"timings":{"wait":3}}}
+{"type":"resource-snapshot","snapshot":{"startedDateTime":"2024-01-01T00:00:03.000Z","time":3,"request":{"method":"GET","url":"https://analytics.test/collect"},"response":{"status":500,"statusText":"Internal Server Error","content":{"size":0,"mimeType":"text/plain"}},"timings":{"wait":3}}}
+`
+
+func writeTrace(t *testing.T, dir string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "resources"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, apxconstants.LogsFileName), []byte(traceNetwork), 0644))
+	for name, content := range map[string]string{
+		"page.html":  "<html></html>",
+		"login.json": `{"user":"nkk"}`,
+		"error.json": `{"error":"bad credentials"}`,
+		"logo.png":   "\x89PNG",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "resources", name), []byte(content), 0644))
+	}
+}
+
+func TestBuildHARFiltersAndInlinesBodies(t *testing.T) {
+	dir := t.TempDir()
+	writeTrace(t, dir)
+
+	har, err := BuildHAR(dir, HAROptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "1.2", har.Log.Version)
+	require.Len(t, har.Log.Entries, 4)
+	page := har.Log.Entries[0]
+	assert.Equal(t, "document", page.ResourceType, "the resource type is inferred from the mime type")
+	assert.Equal(t, []HARNameValue{{Name: "lang", Value: "en"}}, page.Request.QueryString)
+	assert.Equal(t, "HTTP/2.0", page.Response.HTTPVersion)
+	assert.Equal(t, float64(0), page.Timings.Send, "send cannot be -1")
+	assert.Empty(t, page.Response.Content.Text, "bodies are left out by default")
+	login := har.Log.Entries[1]
+	assert.NotNil(t, login.Request.Cookies)
+	assert.Equal(t, int64(-1), login.Request.HeadersSize)
+
+	filter, err := ParseHARFilter([]string{"4xx"}, nil, nil, []string{`^https://cdn\.`})
+	require.NoError(t, err)
+	har, err = BuildHAR(dir, HAROptions{Filter: filter, IncludeBodies: true, MaxBodySize: 16})
+	require.NoError(t, err)
+	require.Len(t, har.Log.Entries, 1, "only the 401 is in the 4xx range and not excluded")
+	login = har.Log.Entries[0]
+	assert.Equal(t, `{"user":"nkk"}`, login.Request.PostData.Text)
+	assert.Empty(t, login.Response.Content.Text)
+	assert.Contains(t, login.Response.Content.Comment, "larger than 16", "bodies over the cap are left out")
+
+	filter, err = ParseHARFilter(nil, []string{"Image"}, []string{`cdn`}, nil)
+	require.NoError(t, err)
+	har, err = BuildHAR(dir, HAROptions{Filter: filter, IncludeBodies: true})
+	require.NoError(t, err)
+	require.Len(t, har.Log.Entries, 1)
+	assert.Equal(t, "base64", har.Log.Entries[0].Response.Content.Encoding)
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("\x89PNG")), har.Log.Entries[0].Response.Content.Text)
+
+	for _, invalid := range []string{"abc", "500-400", "5-"} {
+		_, err := ParseHARFilter([]string{invalid}, nil, nil, nil)
+		assert.Error(t, err, invalid)
+	}
+	_, err = ParseHARFilter(nil, nil, []string{"("}, nil)
+	assert.Error(t, err)
+}
+
+func TestCreateLocalAgentNetworkLogsUploadsHAR(t *testing.T) {
+	traceDir := t.TempDir()
+	writeTrace(t, traceDir)
+	outputDir := t.TempDir()
+	zipFile, err := os.Create(outputDir + apxconstants.LogsZipFolderName)
+	require.NoError(t, err)
+	archive := zip.NewWriter(zipFile)
+	require.NoError(t, filepath.Walk(traceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, _ := filepath.Rel(traceDir, path)
+		w, err := archive.Create(filepath.ToSlash(name))
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}))
+	require.NoError(t, archive.Close())
+	require.NoError(t, zipFile.Close())
+
+	transport := NewMemoryTransport()
+	bridge := NewExecutionServiceBridgeWithTransport("http://execution-service", transport)
+	bridge.SetArtifactCollector(NewArtifactCollector(testArtifactWaitOptions))
+	filter, err := ParseHARFilter([]string{"400-599"}, nil, nil, nil)
+	require.NoError(t, err)
+	bridge.EnableHARExport(HAROptions{Filter: filter})
+	sess := session.Session{OrgId: "o", ProjectId: "p", AppId: "a", Testlab: apxconstants.Local, ExecutionId: "exec-1", TestcaseId: "tc-1", OutputDir: outputDir}
+	require.NoError(t, bridge.CreateLocalAgentNetworkLogs(context.Background(), sess))
+
+	trimmed := transport.CallsTo("CreateLocalAgentNetworkLogs")
+	require.Len(t, trimmed, 1, "the trimmed log is still sent")
+	var log logs.Log
+	require.NoError(t, json.Unmarshal(trimmed[0].Body, &log))
+	assert.Len(t, log.Logs, 4)
+
+	uploads := transport.CallsTo("UploadNetworkHAR")
+	require.Len(t, uploads, 1)
+	assert.Equal(t, "/organisations/o/projects/p/apps/a/local/sessions/exec-1/upload-har", uploads[0].Path)
+	_, params, err := mime.ParseMediaType(uploads[0].ContentType)
+	require.NoError(t, err)
+	form, err := multipart.NewReader(strings.NewReader(string(uploads[0].Body)), params["boundary"]).ReadForm(1 << 20)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tc-1"}, form.Value["testcase_id"])
+	file, err := form.File["har"][0].Open()
+	require.NoError(t, err)
+	defer file.Close()
+	var har HAR
+	require.NoError(t, json.NewDecoder(file).Decode(&har))
+	assert.Len(t, har.Log.Entries, 3, "the 200 is filtered out")
+	_, err = os.Stat(filepath.Join(outputDir, apxconstants.HARFileName))
+	assert.NoError(t, err, "the HAR is kept with the other artifacts")
+}
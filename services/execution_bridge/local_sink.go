@@ -0,0 +1,326 @@
+package executionbridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"agent/logger"
+	"agent/models/executionstatus"
+	"agent/models/executionstep"
+	"agent/models/runresult"
+	"agent/models/screenshot"
+	"agent/models/session"
+	"agent/models/uploadvideo"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: LocalSink - ExecutionServiceBridge replacement for headless `agent run`
+Every bridge call is appended to <dir>/events.jsonl, videos are copied to <dir>/videos
+and the latest status per testcase is kept for the run summary.
+*/
+
+// LocalSink writes bridge calls to disk instead of the execution service
+type LocalSink struct {
+	dir string
+
+	mu               sync.Mutex
+	events           *os.File
+	executionStatus  string
+	executionMessage string
+	outcomes         map[string]*TestcaseOutcome
+}
+
+// TestcaseOutcome is the last known state of one testcase in the run
+type TestcaseOutcome struct {
+	TestcaseId     string `json:"testcase_id"`
+	TestsuiteId    string `json:"testsuite_id,omitempty"`
+	Name           string `json:"name,omitempty"`
+	Browser        string `json:"browser,omitempty"`
+	Status         string `json:"status"`
+	Message        string `json:"message,omitempty"`
+	DurationMs     int64  `json:"duration_ms,omitempty"`
+	IsPreRequisite bool   `json:"is_prerequisite,omitempty"`
+	UncaughtErrors int    `json:"uncaught_errors,omitempty"` // nkk: page errors, a passed testcase still passed
+}
+
+// RunSummary aggregates the outcomes of a headless run
+type RunSummary struct {
+	ExecutionStatus  string            `json:"execution_status,omitempty"`
+	ExecutionMessage string            `json:"execution_message,omitempty"`
+	Total            int               `json:"total"`
+	Passed           int               `json:"passed"`
+	Failed           int               `json:"failed"`
+	Stopped          int               `json:"stopped"`
+	Incomplete       int               `json:"incomplete"`
+	Testcases        []TestcaseOutcome `json:"testcases"`
+}
+
+// Succeeded reports whether every testcase passed
+func (s *RunSummary) Succeeded() bool {
+	if s.ExecutionStatus == apxconstants.Failed || s.ExecutionStatus == apxconstants.Stopped {
+		return false
+	}
+	return s.Total > 0 && s.Passed == s.Total
+}
+
+type sinkEvent struct {
+	Time    time.Time `json:"time"`
+	Call    string    `json:"call"`
+	Payload any       `json:"payload"`
+}
+
+// NewLocalSink creates the output directory and the event log
+func NewLocalSink(dir string) (*LocalSink, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "videos"), 0755); err != nil {
+		return nil, err
+	}
+	events, err := os.OpenFile(filepath.Join(dir, "events.jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalSink{
+		dir:      dir,
+		events:   events,
+		outcomes: make(map[string]*TestcaseOutcome),
+	}, nil
+}
+
+// Dir returns the output directory
+func (s *LocalSink) Dir() string {
+	return s.dir
+}
+
+// Close closes the event log
+func (s *LocalSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.events.Close()
+}
+
+func (s *LocalSink) record(call string, payload any) error {
+	line, err := json.Marshal(sinkEvent{Time: time.Now().UTC(), Call: call, Payload: payload})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if _, err := s.events.Write(line); err != nil {
+		logger.Error("could not write local sink event", zap.String("call", call), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// outcome returns the entry for a testcase, creating it on first use
+func (s *LocalSink) outcome(testsuiteId, testcaseId string, isPreRequisite bool) *TestcaseOutcome {
+	key := fmt.Sprintf("%s/%s/%t", testsuiteId, testcaseId, isPreRequisite)
+	entry, ok := s.outcomes[key]
+	if !ok {
+		entry = &TestcaseOutcome{TestcaseId: testcaseId, TestsuiteId: testsuiteId, IsPreRequisite: isPreRequisite}
+		s.outcomes[key] = entry
+	}
+	return entry
+}
+
+func (s *LocalSink) trackSession(sess session.Session) {
+	if sess.TestcaseId == "" {
+		return
+	}
+	entry := s.outcome(sess.TestsuiteId, sess.TestcaseId, sess.IsPreRequisite)
+	if sess.Name != "" {
+		entry.Name = sess.Name
+	}
+	if sess.Browser != "" {
+		entry.Browser = sess.Browser
+	}
+	if sess.Status != "" {
+		entry.Status = sess.Status
+	}
+	if sess.Reason != nil {
+		entry.Message = *sess.Reason
+	}
+	if sess.Duration != nil {
+		entry.DurationMs = *sess.Duration
+	}
+}
+
+func (s *LocalSink) SaveSession(ctx context.Context, orgId string, projectId string, appId string, testlab string, sess session.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trackSession(sess)
+	if sess.PreRequisiteResult != nil {
+		s.trackSession(*sess.PreRequisiteResult)
+	}
+	return s.record("save_session", sess)
+}
+
+func (s *LocalSink) UpdateSession(ctx context.Context, orgId string, projectId string, appId string, testlab string, sess session.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.trackSession(sess)
+	if sess.PreRequisiteResult != nil {
+		s.trackSession(*sess.PreRequisiteResult)
+	}
+	return s.record("update_session", sess)
+}
+
+func (s *LocalSink) SaveSessionStatus(ctx context.Context, status executionstatus.ExecutionStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if status.TestcaseId == "" {
+		// nkk: Execution wide status, e.g. the test process failed to start
+		s.executionStatus = status.Status
+		s.executionMessage = status.Message
+	} else {
+		entry := s.outcome(status.TestsuiteId, status.TestcaseId, status.IsPreRequisite)
+		entry.Status = status.Status
+		entry.Message = status.Message
+	}
+	return s.record("save_session_status", status)
+}
+
+func (s *LocalSink) CreateLocalAgentResults(ctx context.Context, orgId string, projectId string, appId string, executionId string, testPlanId string, runResult *runresult.RunResult, resultType string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.record("create_results", map[string]any{
+		"execution_id": executionId,
+		"testplan_id":  testPlanId,
+		"result_type":  resultType,
+		"result":       runResult,
+	})
+}
+
+func (s *LocalSink) CreateLocalAgentNetworkLogs(ctx context.Context, sess session.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.record("network_logs", sess)
+}
+
+func (s *LocalSink) UpdateStepCount(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, body executionstep.ExecutionStep) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.record("update_step_count", body)
+}
+
+func (s *LocalSink) SaveConsoleLogs(ctx context.Context, orgId string, projectId string, appId string, testlab string, logs session.ConsoleLogs) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if logs.UncaughtErrors > 0 {
+		s.outcome(logs.TestsuiteId, logs.TestcaseId, logs.IsPreRequisite).UncaughtErrors = logs.UncaughtErrors
+	}
+	return s.record("save_console_logs", logs)
+}
+
+func (s *LocalSink) UploadScreenshots(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, request screenshot.UploadScreenshotRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.record("upload_screenshots", request)
+}
+
+func (s *LocalSink) TakeScreenshot(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, request screenshot.TakeScreenshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.record("take_screenshot", request)
+}
+
+// UploadVideo copies the recording into <dir>/videos
+func (s *LocalSink) UploadVideo(ctx context.Context, data uploadvideo.UploadVideo) error {
+	videoPath := ""
+	if data.Video != nil {
+		name := data.TestcaseId
+		if name == "" {
+			name = data.ExecutionId
+		}
+		videoPath = filepath.Join(s.dir, "videos", fmt.Sprintf("%s-%d.webm", filepath.Base(name), time.Now().UnixNano()))
+
+		out, err := os.Create(videoPath)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, data.Video)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data.Video = nil
+	return s.record("upload_video", map[string]any{
+		"video":   data,
+		"file":    videoPath,
+		"testlab": data.Testlab,
+	})
+}
+
+// Summary returns the outcome of every testcase seen so far
+func (s *LocalSink) Summary() *RunSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary := &RunSummary{
+		ExecutionStatus:  s.executionStatus,
+		ExecutionMessage: s.executionMessage,
+		Testcases:        make([]TestcaseOutcome, 0, len(s.outcomes)),
+	}
+
+	for _, entry := range s.outcomes {
+		summary.Testcases = append(summary.Testcases, *entry)
+		if entry.IsPreRequisite {
+			continue
+		}
+		summary.Total++
+		switch entry.Status {
+		case apxconstants.Passed:
+			summary.Passed++
+		case apxconstants.Failed, apxconstants.Timeout, apxconstants.Aborted:
+			summary.Failed++
+		case apxconstants.Stopped:
+			summary.Stopped++
+		default:
+			summary.Incomplete++
+		}
+	}
+
+	sort.Slice(summary.Testcases, func(i, j int) bool {
+		if summary.Testcases[i].TestsuiteId != summary.Testcases[j].TestsuiteId {
+			return summary.Testcases[i].TestsuiteId < summary.Testcases[j].TestsuiteId
+		}
+		return summary.Testcases[i].TestcaseId < summary.Testcases[j].TestcaseId
+	})
+	return summary
+}
+
+// WriteSummary stores the summary as <dir>/summary.json
+func (s *LocalSink) WriteSummary() (*RunSummary, error) {
+	summary := s.Summary()
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return summary, os.WriteFile(filepath.Join(s.dir, "summary.json"), data, 0644)
+}
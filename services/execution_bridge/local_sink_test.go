@@ -0,0 +1,67 @@
+package executionbridge
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/models/executionstatus"
+	"agent/models/session"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: Unit tests for the headless run LocalSink
+*/
+
+func TestLocalSinkSummaryCountsLatestStatus(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewLocalSink(dir)
+	require.NoError(t, err)
+	defer sink.Close()
+
+	ctx := context.Background()
+	require.NoError(t, sink.SaveSessionStatus(ctx, executionstatus.ExecutionStatus{TestcaseId: "tc-1", Status: apxconstants.Running}))
+	require.NoError(t, sink.SaveSessionStatus(ctx, executionstatus.ExecutionStatus{TestcaseId: "tc-1", Status: apxconstants.Passed}))
+	require.NoError(t, sink.SaveSessionStatus(ctx, executionstatus.ExecutionStatus{TestcaseId: "tc-2", Status: apxconstants.Failed, Message: "assertion failed"}))
+
+	// nkk: Pre-requisites are reported but not counted
+	reason := "setup failed"
+	require.NoError(t, sink.SaveSession(ctx, "org", "project", "app", apxconstants.Local, session.Session{
+		TestcaseId:     "login",
+		Status:         apxconstants.Failed,
+		Reason:         &reason,
+		IsPreRequisite: true,
+	}))
+
+	summary, err := sink.WriteSummary()
+	require.NoError(t, err)
+	assert.Equal(t, 2, summary.Total)
+	assert.Equal(t, 1, summary.Passed)
+	assert.Equal(t, 1, summary.Failed)
+	assert.Len(t, summary.Testcases, 3)
+	assert.False(t, summary.Succeeded())
+
+	data, err := os.ReadFile(filepath.Join(dir, "summary.json"))
+	require.NoError(t, err)
+	stored := RunSummary{}
+	require.NoError(t, json.Unmarshal(data, &stored))
+	assert.Equal(t, summary.Total, stored.Total)
+
+	events, err := os.ReadFile(filepath.Join(dir, "events.jsonl"))
+	require.NoError(t, err)
+	assert.Equal(t, 4, strings.Count(string(events), "\n"))
+}
+
+func TestRunSummarySucceeded(t *testing.T) {
+	assert.False(t, (&RunSummary{}).Succeeded(), "an empty run has not passed")
+	assert.True(t, (&RunSummary{Total: 2, Passed: 2}).Succeeded())
+	assert.False(t, (&RunSummary{Total: 2, Passed: 1, Incomplete: 1}).Succeeded())
+	assert.False(t, (&RunSummary{ExecutionStatus: apxconstants.Failed, Total: 1, Passed: 1}).Succeeded())
+}
@@ -0,0 +1,128 @@
+package executionbridge
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.uber.org/zap"
+
+	"agent/logger"
+	"agent/models/session"
+)
+
+/*
+nkk: MongoSink writes the batched sessions straight into the customer's MongoDB (on-prem setups).
+One document per (execution, testcase), its _id is "<execution_id>/<testcase_id>" so a replay replaces instead of duplicating.
+*/
+
+// MongoSinkConfig locates the sessions collection
+type MongoSinkConfig struct {
+	URI            string
+	Database       string
+	Collection     string
+	ConnectTimeout time.Duration
+}
+
+// MongoSink upserts sessions into one collection
+type MongoSink struct {
+	client     *mongo.Client
+	collection *mongo.Collection
+}
+
+// ConnectMongoSink connects to cfg.URI and creates the indexes of the collection
+func ConnectMongoSink(ctx context.Context, cfg MongoSinkConfig) (*MongoSink, error) {
+	if cfg.ConnectTimeout <= 0 {
+		cfg.ConnectTimeout = 10 * time.Second
+	}
+	// nkk: An unreachable server fails a write after ConnectTimeout, the batch then goes over HTTP
+	client, err := mongo.Connect(ctx, options.Client().
+		ApplyURI(cfg.URI).
+		SetConnectTimeout(cfg.ConnectTimeout).
+		SetServerSelectionTimeout(cfg.ConnectTimeout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+	defer cancel()
+	if err := client.Ping(pingCtx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("failed to reach MongoDB: %w", err)
+	}
+
+	sink := NewMongoSink(client.Database(cfg.Database).Collection(cfg.Collection))
+	sink.client = client
+	if err := sink.EnsureIndexes(pingCtx); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+	return sink, nil
+}
+
+// NewMongoSink writes into an already connected collection
+func NewMongoSink(collection *mongo.Collection) *MongoSink {
+	return &MongoSink{collection: collection}
+}
+
+// EnsureIndexes creates the lookup indexes, existing ones are left alone
+func (m *MongoSink) EnsureIndexes(ctx context.Context) error {
+	_, err := m.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "execution_id", Value: 1}, {Key: "testcase_id", Value: 1}},
+			Options: options.Index().SetName("execution_testcase"),
+		},
+		{
+			Keys:    bson.D{{Key: "org_id", Value: 1}, {Key: "project_id", Value: 1}, {Key: "app_id", Value: 1}},
+			Options: options.Index().SetName("org_project_app"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create session indexes: %w", err)
+	}
+	return nil
+}
+
+// Write upserts the sessions, the last one of a key wins
+func (m *MongoSink) Write(ctx context.Context, sessions []*session.Session) (*mongo.BulkWriteResult, error) {
+	// nkk: Unordered writes may apply in any order, so a batch holds one session per key
+	index := make(map[string]int, len(sessions))
+	var latest []*session.Session
+	for _, sess := range sessions {
+		key := sessionKey(sess)
+		if i, ok := index[key]; ok {
+			latest[i] = sess
+			continue
+		}
+		index[key] = len(latest)
+		latest = append(latest, sess)
+	}
+
+	models := make([]mongo.WriteModel, 0, len(latest))
+	for _, sess := range latest {
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"_id": sessionKey(sess)}).
+			SetReplacement(sess).
+			SetUpsert(true))
+	}
+	return m.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+}
+
+// Close disconnects the client opened by ConnectMongoSink
+func (m *MongoSink) Close(ctx context.Context) error {
+	if m.client == nil {
+		return nil
+	}
+	if err := m.client.Disconnect(ctx); err != nil {
+		logger.Warn("could not disconnect from MongoDB", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func sessionKey(sess *session.Session) string {
+	return sess.ExecutionId + "/" + sess.TestcaseId
+}
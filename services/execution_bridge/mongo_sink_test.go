@@ -0,0 +1,99 @@
+package executionbridge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+	"agent/models/session"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: Unit tests for the MongoDB session sink
+The collection is backed by the driver's mock deployment, no server is needed
+*/
+
+func TestMongoSinkUpsertsLatestSessionPerKey(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("upserts", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 0}))
+		sink := NewMongoSink(mt.Coll)
+
+		_, err := sink.Write(context.Background(), []*session.Session{
+			{ExecutionId: "exec-1", TestcaseId: "tc-1", Status: apxconstants.Running},
+			{ExecutionId: "exec-1", TestcaseId: "tc-2", Status: apxconstants.Running},
+			{ExecutionId: "exec-1", TestcaseId: "tc-1", Status: apxconstants.Passed},
+		})
+		require.NoError(mt, err)
+
+		command := mt.GetStartedEvent().Command
+		assert.Equal(mt, false, command.Lookup("ordered").Boolean())
+		updates, err := command.Lookup("updates").Array().Values()
+		require.NoError(mt, err)
+		require.Len(mt, updates, 2, "one write per execution and testcase")
+
+		first := updates[0].Document()
+		assert.Equal(mt, "exec-1/tc-1", first.Lookup("q", "_id").StringValue())
+		assert.Equal(mt, apxconstants.Passed, first.Lookup("u", "status").StringValue(), "the last session of a key wins")
+		assert.True(mt, first.Lookup("upsert").Boolean())
+		assert.Equal(mt, "exec-1/tc-2", updates[1].Document().Lookup("q", "_id").StringValue())
+	})
+}
+
+func TestBridgeFallsBackToExecutionServiceWhenMongoFails(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("fallback", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 91, Message: "shutdown in progress"}))
+		transport := NewMemoryTransport()
+		bridge := NewExecutionServiceBridgeWithTransport("http://execution-service", transport)
+		bridge.EnableMongoSink(NewMongoSink(mt.Coll))
+
+		ctx := context.Background()
+		require.NoError(mt, bridge.SaveSession(ctx, "o", "p", "a", apxconstants.Local, session.Session{ExecutionId: "exec-1", TestcaseId: "tc-1", Status: apxconstants.Running}))
+		require.NoError(mt, bridge.SaveSession(ctx, "o", "p", "a", apxconstants.Local, session.Session{ExecutionId: "exec-2", TestcaseId: "tc-1", Status: apxconstants.Running}))
+		require.NoError(mt, bridge.UpdateSession(ctx, "o", "p", "a", apxconstants.Local, session.Session{ExecutionId: "exec-1", TestcaseId: "tc-1", Status: apxconstants.Passed}))
+		bridge.Flush()
+
+		calls := transport.CallsTo("SaveSessionBatch")
+		require.Len(mt, calls, 2, "one batch per execution, through the bridge's transport")
+		assert.Equal(mt, "/batch/sessions", calls[0].Path)
+		var payload struct {
+			Sessions []session.Session `json:"sessions"`
+		}
+		require.NoError(mt, json.Unmarshal(calls[0].Body, &payload))
+		require.Len(mt, payload.Sessions, 2)
+		assert.Equal(mt, "exec-1", payload.Sessions[0].ExecutionId)
+		assert.Equal(mt, apxconstants.Passed, payload.Sessions[1].Status)
+	})
+}
+
+func TestBridgeJournalsSessionBatchWhenMongoAndExecutionServiceFail(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+	mt.Run("outbox", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 91, Message: "shutdown in progress"}))
+		transport := NewMemoryTransport()
+		transport.Respond("SaveSessionBatch", http.StatusServiceUnavailable, nil)
+		transport.Respond("SaveSessionBatch", http.StatusServiceUnavailable, nil)
+		bridge := NewExecutionServiceBridgeWithTransport("http://execution-service", transport)
+		bridge.EnableMongoSink(NewMongoSink(mt.Coll))
+		outbox, err := bridge.EnableOutbox(t.TempDir(), fastRetries)
+		require.NoError(mt, err)
+
+		require.NoError(mt, bridge.SaveSession(context.Background(), "o", "p", "a", apxconstants.Local, session.Session{ExecutionId: "exec-1", TestcaseId: "tc-1", Status: apxconstants.Passed}))
+		bridge.Flush()
+
+		require.Eventually(mt, func() bool { return outbox.Stats().Pending == 0 }, 5*time.Second, time.Millisecond)
+		assert.Len(mt, transport.CallsTo("SaveSessionBatch"), 3, "the batch is retried until the execution service takes it")
+		assert.Equal(mt, int64(1), outbox.Stats().Delivered)
+		require.NoError(mt, outbox.Close(context.Background()))
+	})
+}
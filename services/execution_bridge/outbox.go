@@ -0,0 +1,543 @@
+package executionbridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"agent/errors"
+	"agent/logger"
+	"agent/services/monitoring"
+)
+
+/*
+nkk: Outbox - durable queue for the writes to the execution service
+Every write is journaled before the caller returns:
+  <dir>/<execution>/<seq>.json  request line (call, method, path, content type, attempts)
+  <dir>/<execution>/<seq>.body  request body, videos are streamed here instead of held in memory
+One worker per execution delivers its entries in order, an entry is only removed once the
+execution service accepted it. Retryable errors (network, 5xx, open breaker) are retried with backoff,
+the entries of that execution wait behind it. Rejected writes (4xx) and writes out of attempts move to
+<dir>/failed so they can be inspected. Entries left on disk are replayed on the next start.
+*/
+
+const (
+	outboxMetaExt   = ".json"
+	outboxBodyExt   = ".body"
+	outboxFailedDir = "failed"
+)
+
+// OutboxRequest is one write to the execution service
+type OutboxRequest struct {
+	Call        string     `json:"call"`
+	ExecutionId string     `json:"execution_id"`
+	Method      string     `json:"method"`
+	Path        string     `json:"path"`
+	Query       url.Values `json:"query,omitempty"`
+	ContentType string     `json:"content_type,omitempty"`
+}
+
+// OutboxEntry is a journaled request
+type OutboxEntry struct {
+	OutboxRequest
+	Seq       uint64    `json:"seq"`
+	CreatedAt time.Time `json:"created_at"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+
+	queue string
+}
+
+// OutboxDeliverFunc sends an entry, body is the journaled request body
+type OutboxDeliverFunc func(ctx context.Context, entry *OutboxEntry, body io.Reader, size int64) error
+
+// OutboxOptions tune the retries
+type OutboxOptions struct {
+	MaxAttempts    int // nkk: 0 retries until delivered, only Retryable errors are retried
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+}
+
+// OutboxStats is a snapshot of the outbox for metrics and health
+type OutboxStats struct {
+	Pending       int       `json:"pending"`
+	Failed        int       `json:"failed"`
+	Retrying      int       `json:"retrying"` // nkk: executions waiting for a retry
+	Delivered     int64     `json:"delivered"`
+	OldestPending time.Time `json:"oldest_pending,omitempty"`
+}
+
+// Outbox journals and delivers execution service writes
+type Outbox struct {
+	dir     string
+	deliver OutboxDeliverFunc
+	opts    OutboxOptions
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu        sync.Mutex
+	seq       uint64
+	queues    map[string]*outboxQueue
+	pending   int
+	failed    int
+	delivered int64
+	drained   chan struct{} // nkk: closed when pending drops to 0
+
+	metrics outboxMetrics
+}
+
+type outboxQueue struct {
+	entries  []*OutboxEntry
+	running  bool
+	retrying bool
+
+	journalMu sync.Mutex // nkk: held from taking a seq until the entry is queued, so entries queue in seq order
+	enqueuing int        // nkk: Enqueue calls holding on to the queue, it stays in queues until they are done
+}
+
+type outboxMetrics struct {
+	pending   *monitoring.Metric
+	failed    *monitoring.Metric
+	delivered *monitoring.Metric
+	retries   *monitoring.Metric
+}
+
+// OpenOutbox loads the entries left in dir and starts delivering them
+func OpenOutbox(dir string, deliver OutboxDeliverFunc, opts OutboxOptions) (*Outbox, error) {
+	if opts.RetryBaseDelay <= 0 {
+		opts.RetryBaseDelay = time.Second
+	}
+	if opts.RetryMaxDelay < opts.RetryBaseDelay {
+		opts.RetryMaxDelay = opts.RetryBaseDelay
+	}
+	if err := os.MkdirAll(filepath.Join(dir, outboxFailedDir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+
+	registry := monitoring.GetRegistry()
+	ctx, cancel := context.WithCancel(context.Background())
+	o := &Outbox{
+		dir:     dir,
+		deliver: deliver,
+		opts:    opts,
+		ctx:     ctx,
+		cancel:  cancel,
+		queues:  make(map[string]*outboxQueue),
+		metrics: outboxMetrics{
+			pending:   registry.Gauge("execution_outbox_pending_total", "Execution service writes waiting for delivery", map[string]string{}),
+			failed:    registry.Gauge("execution_outbox_failed_total", "Execution service writes that could not be delivered", map[string]string{}),
+			delivered: registry.Counter("execution_outbox_delivered_total", "Execution service writes delivered", map[string]string{}),
+			retries:   registry.Counter("execution_outbox_retries_total", "Execution service write attempts that are retried", map[string]string{}),
+		},
+	}
+	if err := o.load(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.updateGaugesLocked()
+	for name, queue := range o.queues {
+		o.startLocked(name, queue)
+	}
+	if o.pending > 0 {
+		logger.Info("replaying execution outbox", zap.Int("pending", o.pending), zap.Int("executions", len(o.queues)))
+	}
+	return o, nil
+}
+
+// load reads the journal, the sequence continues after the highest entry found
+func (o *Outbox) load() error {
+	dirs, err := os.ReadDir(o.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read outbox directory: %w", err)
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		entries, err := o.loadQueue(d.Name())
+		if err != nil {
+			return err
+		}
+		if d.Name() == outboxFailedDir {
+			continue
+		}
+		if len(entries) == 0 {
+			// nkk: Queue directories are left behind when they empty, an Enqueue may be writing into them
+			os.Remove(filepath.Join(o.dir, d.Name()))
+			continue
+		}
+		o.queues[d.Name()] = &outboxQueue{entries: entries}
+		o.pending += len(entries)
+	}
+	return nil
+}
+
+func (o *Outbox) loadQueue(name string) ([]*OutboxEntry, error) {
+	dir := filepath.Join(o.dir, name)
+	if name == outboxFailedDir {
+		// nkk: failed/<execution>/<seq>.json, only counted
+		queues, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read outbox directory: %w", err)
+		}
+		for _, q := range queues {
+			files, _ := filepath.Glob(filepath.Join(dir, q.Name(), "*"+outboxMetaExt))
+			o.failed += len(files)
+			for _, file := range files {
+				o.bumpSeq(strings.TrimSuffix(filepath.Base(file), outboxMetaExt))
+			}
+		}
+		return nil, nil
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox directory: %w", err)
+	}
+	var entries []*OutboxEntry
+	for _, file := range files {
+		fileName := file.Name()
+		path := filepath.Join(dir, fileName)
+		switch {
+		case strings.HasPrefix(fileName, ".tmp-"):
+			// nkk: interrupted while journaling, the caller never got an answer
+			os.Remove(path)
+		case strings.HasSuffix(fileName, outboxBodyExt):
+			if _, err := os.Stat(strings.TrimSuffix(path, outboxBodyExt) + outboxMetaExt); os.IsNotExist(err) {
+				os.Remove(path)
+			}
+		case strings.HasSuffix(fileName, outboxMetaExt):
+			data, err := os.ReadFile(path)
+			var entry OutboxEntry
+			if err == nil {
+				err = json.Unmarshal(data, &entry)
+			}
+			if err != nil {
+				logger.Warn("skipping unreadable outbox entry", zap.String("file", path), zap.Error(err))
+				continue
+			}
+			entry.queue = name
+			o.bumpSeq(strings.TrimSuffix(fileName, outboxMetaExt))
+			entries = append(entries, &entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	return entries, nil
+}
+
+func (o *Outbox) bumpSeq(name string) {
+	if seq, err := strconv.ParseUint(name, 10, 64); err == nil && seq > o.seq {
+		o.seq = seq
+	}
+}
+
+// queueName maps an execution ID to its directory name, dots are escaped so no ID can leave dir
+func queueName(executionId string) string {
+	name := strings.ReplaceAll(url.PathEscape(executionId), ".", "%2E")
+	if name == "" || name == outboxFailedDir {
+		name = "%" + name
+	}
+	return name
+}
+
+// Enqueue journals a request, writeBody writes the request body. Delivery happens in the background.
+func (o *Outbox) Enqueue(req OutboxRequest, writeBody func(w io.Writer) error) error {
+	name := queueName(req.ExecutionId)
+	dir := filepath.Join(o.dir, name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+
+	o.mu.Lock()
+	queue, ok := o.queues[name]
+	if !ok {
+		queue = &outboxQueue{}
+		o.queues[name] = queue
+	}
+	queue.enqueuing++
+	o.mu.Unlock()
+
+	// nkk: Writers of one execution journal one at a time, a later seq can never be delivered first
+	queue.journalMu.Lock()
+	defer queue.journalMu.Unlock()
+
+	o.mu.Lock()
+	o.seq++
+	entry := &OutboxEntry{OutboxRequest: req, Seq: o.seq, CreatedAt: time.Now(), queue: name}
+	o.mu.Unlock()
+
+	err := o.journal(entry, writeBody)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	queue.enqueuing--
+	if err != nil {
+		if len(queue.entries) == 0 && queue.enqueuing == 0 && !queue.running {
+			delete(o.queues, name)
+		}
+		return fmt.Errorf("failed to journal %s: %w", req.Call, err)
+	}
+	queue.entries = append(queue.entries, entry)
+	o.pending++
+	o.updateGaugesLocked()
+	o.startLocked(name, queue)
+	return nil
+}
+
+// journal writes the body and then the request line of entry
+func (o *Outbox) journal(entry *OutboxEntry, writeBody func(w io.Writer) error) error {
+	// nkk: The body goes first, the request line is what makes the entry visible on replay
+	if err := writeJournalFile(o.bodyPath(entry), writeBody); err != nil {
+		return err
+	}
+	if err := o.writeMeta(entry); err != nil {
+		os.Remove(o.bodyPath(entry))
+		return err
+	}
+	return nil
+}
+
+func (o *Outbox) startLocked(name string, queue *outboxQueue) {
+	if queue.running || o.ctx.Err() != nil {
+		return
+	}
+	queue.running = true
+	o.wg.Add(1)
+	go o.run(name, queue)
+}
+
+// run delivers the entries of one execution until its queue is empty
+func (o *Outbox) run(name string, queue *outboxQueue) {
+	defer o.wg.Done()
+	for {
+		o.mu.Lock()
+		if len(queue.entries) == 0 || o.ctx.Err() != nil {
+			queue.running = false
+			if len(queue.entries) == 0 && queue.enqueuing == 0 {
+				delete(o.queues, name)
+			}
+			o.mu.Unlock()
+			return
+		}
+		entry := queue.entries[0]
+		o.mu.Unlock()
+
+		err := o.attempt(entry)
+		if err == nil {
+			o.finish(queue, entry, false)
+			continue
+		}
+
+		entry.Attempts++
+		entry.LastError = err.Error()
+		if !Retryable(err) || (o.opts.MaxAttempts > 0 && entry.Attempts >= o.opts.MaxAttempts) {
+			logger.Error("execution service write failed",
+				zap.String("call", entry.Call),
+				zap.String("execution_id", entry.ExecutionId),
+				zap.Int("attempts", entry.Attempts),
+				zap.Error(err))
+			o.finish(queue, entry, true)
+			continue
+		}
+
+		if err := o.writeMeta(entry); err != nil {
+			logger.Warn("could not record outbox attempt", zap.String("execution_id", entry.ExecutionId), zap.Error(err))
+		}
+		o.metrics.retries.Inc()
+		delay := o.backoff(entry.Attempts)
+		logger.Warn("execution service write will be retried",
+			zap.String("call", entry.Call),
+			zap.String("execution_id", entry.ExecutionId),
+			zap.Int("attempt", entry.Attempts),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+
+		o.mu.Lock()
+		queue.retrying = true
+		o.mu.Unlock()
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-o.ctx.Done():
+			timer.Stop()
+		}
+		o.mu.Lock()
+		queue.retrying = false
+		o.mu.Unlock()
+	}
+}
+
+func (o *Outbox) attempt(entry *OutboxEntry) error {
+	body, err := os.Open(o.bodyPath(entry))
+	if err != nil {
+		// nkk: Nothing left to send, retrying will not bring the body back
+		return errors.E(errors.NotFound, "journaled body missing", err)
+	}
+	defer body.Close()
+	var size int64
+	if info, err := body.Stat(); err == nil {
+		size = info.Size()
+	}
+	return o.deliver(o.ctx, entry, body, size)
+}
+
+// finish takes the head entry off its queue, failed entries are kept under failed/
+func (o *Outbox) finish(queue *outboxQueue, entry *OutboxEntry, failed bool) {
+	if failed {
+		failedDir := filepath.Join(o.dir, outboxFailedDir, entry.queue)
+		err := os.MkdirAll(failedDir, 0755)
+		if err == nil {
+			err = o.writeMeta(entry)
+		}
+		if err == nil {
+			err = os.Rename(o.metaPath(entry), filepath.Join(failedDir, filepath.Base(o.metaPath(entry))))
+		}
+		if err == nil {
+			err = os.Rename(o.bodyPath(entry), filepath.Join(failedDir, filepath.Base(o.bodyPath(entry))))
+		}
+		if err != nil {
+			logger.Warn("could not move failed outbox entry", zap.String("execution_id", entry.ExecutionId), zap.Error(err))
+		}
+	} else {
+		os.Remove(o.metaPath(entry))
+		os.Remove(o.bodyPath(entry))
+		o.metrics.delivered.Inc()
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i, e := range queue.entries {
+		if e == entry {
+			queue.entries = append(queue.entries[:i], queue.entries[i+1:]...)
+			break
+		}
+	}
+	o.pending--
+	if failed {
+		o.failed++
+	} else {
+		o.delivered++
+	}
+	o.updateGaugesLocked()
+}
+
+func (o *Outbox) backoff(attempts int) time.Duration {
+	delay := o.opts.RetryBaseDelay
+	for i := 1; i < attempts && delay < o.opts.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	// nkk: Jitter so executions retrying after one outage do not all hit the service at once
+	delay += time.Duration(rand.Float64() * float64(delay) * 0.3)
+	if delay > o.opts.RetryMaxDelay {
+		delay = o.opts.RetryMaxDelay
+	}
+	return delay
+}
+
+func (o *Outbox) updateGaugesLocked() {
+	o.metrics.pending.Set(float64(o.pending))
+	o.metrics.failed.Set(float64(o.failed))
+	if o.pending == 0 && o.drained != nil {
+		close(o.drained)
+		o.drained = nil
+	}
+}
+
+// Stats returns the current counts
+func (o *Outbox) Stats() OutboxStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	stats := OutboxStats{Pending: o.pending, Failed: o.failed, Delivered: o.delivered}
+	for _, queue := range o.queues {
+		if queue.retrying {
+			stats.Retrying++
+		}
+		if len(queue.entries) > 0 {
+			created := queue.entries[0].CreatedAt
+			if stats.OldestPending.IsZero() || created.Before(stats.OldestPending) {
+				stats.OldestPending = created
+			}
+		}
+	}
+	return stats
+}
+
+// Close waits for the pending writes until ctx is done, what is left is delivered after the next start
+func (o *Outbox) Close(ctx context.Context) error {
+	o.mu.Lock()
+	var drained chan struct{}
+	if o.pending > 0 {
+		if o.drained == nil {
+			o.drained = make(chan struct{})
+		}
+		drained = o.drained
+	}
+	o.mu.Unlock()
+
+	if drained != nil {
+		select {
+		case <-drained:
+		case <-ctx.Done():
+		}
+	}
+	o.cancel()
+	o.wg.Wait()
+
+	if stats := o.Stats(); stats.Pending > 0 {
+		logger.Warn("execution outbox closed with pending writes", zap.Int("pending", stats.Pending))
+	}
+	return nil
+}
+
+func (o *Outbox) metaPath(entry *OutboxEntry) string {
+	return filepath.Join(o.dir, entry.queue, fmt.Sprintf("%020d%s", entry.Seq, outboxMetaExt))
+}
+
+func (o *Outbox) bodyPath(entry *OutboxEntry) string {
+	return filepath.Join(o.dir, entry.queue, fmt.Sprintf("%020d%s", entry.Seq, outboxBodyExt))
+}
+
+func (o *Outbox) writeMeta(entry *OutboxEntry) error {
+	return writeJournalFile(o.metaPath(entry), func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(entry)
+	})
+}
+
+// writeJournalFile writes through a synced temporary file so a crash never leaves half an entry
+func writeJournalFile(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
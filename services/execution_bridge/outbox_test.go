@@ -0,0 +1,186 @@
+package executionbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/models/executionstatus"
+	"agent/models/executionstep"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: Unit tests for the execution service outbox
+Retries run with a millisecond backoff so the tests do not wait on the real schedule
+*/
+
+var fastRetries = OutboxOptions{RetryBaseDelay: time.Millisecond, RetryMaxDelay: 5 * time.Millisecond}
+
+type recordingDeliveries struct {
+	mu    sync.Mutex
+	calls []string
+	err   error
+}
+
+func (d *recordingDeliveries) deliver(ctx context.Context, entry *OutboxEntry, body io.Reader, size int64) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err != nil {
+		return d.err
+	}
+	data, _ := io.ReadAll(body)
+	d.calls = append(d.calls, entry.Call+" "+strings.TrimSpace(string(data)))
+	return nil
+}
+
+func (d *recordingDeliveries) list() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.calls...)
+}
+
+func TestBridgeOutboxRetriesAndKeepsOrder(t *testing.T) {
+	var mu sync.Mutex
+	var statuses []string
+	failures := 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, "/update-stepcount") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var status executionstatus.ExecutionStatus
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&status))
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		statuses = append(statuses, status.Status)
+	}))
+	defer server.Close()
+
+	bridge := NewExecutionServiceBridge(server.URL)
+	dir := t.TempDir()
+	outbox, err := bridge.EnableOutbox(dir, fastRetries)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for _, status := range []string{apxconstants.Running, apxconstants.Passed} {
+		require.NoError(t, bridge.SaveSessionStatus(ctx, executionstatus.ExecutionStatus{
+			OrgId: "o", ProjectId: "p", AppId: "a", TestLab: apxconstants.Local, ExecutionId: "exec-1", TestcaseId: "tc-1", Status: status,
+		}))
+	}
+	require.NoError(t, bridge.UpdateStepCount(ctx, "o", "p", "a", apxconstants.Local, "exec-2", executionstep.ExecutionStep{ExecutionId: "exec-2", StepCount: "1"}))
+
+	require.Eventually(t, func() bool { return outbox.Stats().Pending == 0 }, 5*time.Second, time.Millisecond)
+	mu.Lock()
+	assert.Equal(t, []string{apxconstants.Running, apxconstants.Passed}, statuses, "the 5xx answers are retried without reordering the execution")
+	mu.Unlock()
+
+	stats := outbox.Stats()
+	assert.Equal(t, int64(2), stats.Delivered)
+	assert.Equal(t, 1, stats.Failed, "a 4xx answer is not retried")
+	failed, err := filepath.Glob(filepath.Join(dir, outboxFailedDir, "exec-2", "*"+outboxMetaExt))
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	data, err := os.ReadFile(failed[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"call":"UpdateStepCount"`)
+
+	require.NoError(t, outbox.Close(ctx))
+}
+
+func TestOutboxDeliversInSeqOrderWhileJournaling(t *testing.T) {
+	deliveries := &recordingDeliveries{}
+	outbox, err := OpenOutbox(t.TempDir(), deliveries.deliver, fastRetries)
+	require.NoError(t, err)
+
+	// The first write takes its seq and is still streaming its body when the second arrives
+	streaming := make(chan struct{})
+	release := make(chan struct{})
+	firstDone := make(chan error, 1)
+	go func() {
+		firstDone <- outbox.Enqueue(OutboxRequest{Call: "SaveVideo", ExecutionId: "exec-1"}, func(w io.Writer) error {
+			close(streaming)
+			<-release
+			_, err := io.WriteString(w, "video")
+			return err
+		})
+	}()
+	<-streaming
+	secondDone := make(chan error, 1)
+	go func() {
+		secondDone <- outbox.Enqueue(OutboxRequest{Call: "SaveSessionStatus", ExecutionId: "exec-1"}, func(w io.Writer) error {
+			_, err := io.WriteString(w, "passed")
+			return err
+		})
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, deliveries.list(), "nothing is delivered ahead of the write journaling first")
+	close(release)
+	require.NoError(t, <-firstDone)
+	require.NoError(t, <-secondDone)
+
+	require.Eventually(t, func() bool { return outbox.Stats().Pending == 0 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, []string{"SaveVideo video", "SaveSessionStatus passed"}, deliveries.list())
+	require.NoError(t, outbox.Close(context.Background()))
+}
+
+func TestOutboxReplaysAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	down := &recordingDeliveries{err: errors.New("connection refused")}
+	outbox, err := OpenOutbox(dir, down.deliver, fastRetries)
+	require.NoError(t, err)
+
+	for _, body := range []string{"first", "second"} {
+		body := body
+		require.NoError(t, outbox.Enqueue(OutboxRequest{Call: "SaveSession", ExecutionId: "exec-1", Method: http.MethodPost}, func(w io.Writer) error {
+			_, err := io.WriteString(w, body)
+			return err
+		}))
+	}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.NoError(t, outbox.Close(cancelled))
+	assert.Equal(t, 2, outbox.Stats().Pending, "nothing could be delivered")
+
+	up := &recordingDeliveries{}
+	outbox, err = OpenOutbox(dir, up.deliver, fastRetries)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return outbox.Stats().Pending == 0 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, []string{"SaveSession first", "SaveSession second"}, up.list())
+
+	require.NoError(t, outbox.Enqueue(OutboxRequest{Call: "UpdateSession", ExecutionId: "exec-1"}, func(w io.Writer) error { return nil }))
+	require.Eventually(t, func() bool { return len(up.list()) == 3 }, 5*time.Second, time.Millisecond)
+	require.NoError(t, outbox.Close(context.Background()))
+
+	files, err := filepath.Glob(filepath.Join(dir, "exec-1", "*"))
+	require.NoError(t, err)
+	assert.Empty(t, files, "delivered entries are removed from the journal")
+}
+
+func TestQueueNameStaysInDir(t *testing.T) {
+	for _, id := range []string{"", ".", "..", "../exec", "a/b", outboxFailedDir} {
+		name := queueName(id)
+		assert.Equal(t, name, filepath.Base(name), id)
+		assert.NotContains(t, []string{"", ".", "..", outboxFailedDir}, name, id)
+	}
+	assert.NotEqual(t, queueName("a/b"), queueName("a%2Fb"))
+}
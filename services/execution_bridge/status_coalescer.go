@@ -0,0 +1,191 @@
+package executionbridge
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"agent/logger"
+	"agent/models/executionstatus"
+	"agent/services/monitoring"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: StatusCoalescer sits in front of SaveSessionStatus, same flush-on-size-or-interval design as BatchWriter.
+- A new status replaces the pending one of its (execution, testcase) and moves to the back, so the batch keeps arrival order.
+- A pending terminal status (passed/failed/stopped/...) is never replaced, and a terminal status flushes right away.
+  Add waits for that flush and returns the send error of its execution, so terminal statuses are journaled before it returns.
+- Flushed batches go out one after the other, one batch call per execution, through the bridge write (and its outbox).
+- The window is not durable: non-terminal statuses are only in memory until their batch is sent,
+  a crash inside it loses them and their send errors are only logged.
+*/
+
+const statusBatchPath = "/batch/session-statuses"
+
+// StatusBatchSender sends the coalesced statuses of one execution, in order
+type StatusBatchSender func(ctx context.Context, executionId string, statuses []executionstatus.ExecutionStatus) error
+
+type pendingStatus struct {
+	status executionstatus.ExecutionStatus
+}
+
+// statusFlush is one flushed batch, errs is filled in by the time done is closed
+type statusFlush struct {
+	done chan struct{}
+	errs map[string]error // nkk: send error per execution
+}
+
+// StatusCoalescer buffers status updates and sends the latest one per key
+type StatusCoalescer struct {
+	mu            sync.Mutex
+	buffer        []*pendingStatus
+	latest        map[string]*pendingStatus // nkk: replaceable (non-terminal) entry per key
+	maxSize       int
+	flushInterval time.Duration
+	flushTimer    *time.Timer
+	send          StatusBatchSender
+	previous      chan struct{} // nkk: closed when the previous flush is sent
+	inflight      sync.WaitGroup
+	coalesced     *monitoring.Metric
+}
+
+// NewStatusCoalescer flushes when maxSize statuses are pending or flushInterval after the first one
+func NewStatusCoalescer(send StatusBatchSender, maxSize int, flushInterval time.Duration) *StatusCoalescer {
+	previous := make(chan struct{})
+	close(previous)
+	return &StatusCoalescer{
+		latest:        make(map[string]*pendingStatus),
+		maxSize:       maxSize,
+		flushInterval: flushInterval,
+		send:          send,
+		previous:      previous,
+		coalesced:     monitoring.GetRegistry().Counter("execution_status_coalesced_total", "Execution status updates replaced by a newer one before sending", map[string]string{}),
+	}
+}
+
+// Add queues a status, replacing the pending non-terminal status of the same execution and testcase.
+// A terminal status is sent before Add returns, the error is its execution's send error.
+func (c *StatusCoalescer) Add(ctx context.Context, status executionstatus.ExecutionStatus) error {
+	c.mu.Lock()
+	key := status.ExecutionId + "/" + status.TestcaseId
+	if old, ok := c.latest[key]; ok {
+		c.remove(old)
+		c.coalesced.Inc()
+	}
+	entry := &pendingStatus{status: status}
+	c.buffer = append(c.buffer, entry)
+	if isTerminalStatus(status.Status) {
+		delete(c.latest, key)
+		flush := c.flushLocked()
+		c.mu.Unlock()
+
+		select {
+		case <-flush.done:
+			return flush.errs[status.ExecutionId]
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	c.latest[key] = entry
+
+	if len(c.buffer) >= c.maxSize {
+		c.flushLocked()
+	} else if c.flushTimer == nil {
+		c.flushTimer = time.AfterFunc(c.flushInterval, c.Flush)
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// Flush sends the pending statuses
+func (c *StatusCoalescer) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushLocked()
+}
+
+// Wait blocks until the batches already flushed are sent
+func (c *StatusCoalescer) Wait() {
+	c.inflight.Wait()
+}
+
+func (c *StatusCoalescer) remove(entry *pendingStatus) {
+	for i, pending := range c.buffer {
+		if pending == entry {
+			c.buffer = append(c.buffer[:i], c.buffer[i+1:]...)
+			return
+		}
+	}
+}
+
+func (c *StatusCoalescer) flushLocked() *statusFlush {
+	if c.flushTimer != nil {
+		c.flushTimer.Stop()
+		c.flushTimer = nil
+	}
+	flush := &statusFlush{done: make(chan struct{})}
+	if len(c.buffer) == 0 {
+		close(flush.done)
+		return flush
+	}
+
+	batch := c.buffer
+	c.buffer = nil
+	c.latest = make(map[string]*pendingStatus)
+
+	previous := c.previous
+	c.previous = flush.done
+	c.inflight.Add(1)
+	go func() {
+		defer c.inflight.Done()
+		defer close(flush.done)
+		<-previous
+		flush.errs = c.sendBatch(batch)
+	}()
+	return flush
+}
+
+// sendBatch splits a flushed batch per execution, keeping the order inside each one
+func (c *StatusCoalescer) sendBatch(batch []*pendingStatus) map[string]error {
+	var executions []string
+	grouped := make(map[string][]executionstatus.ExecutionStatus)
+	for _, entry := range batch {
+		id := entry.status.ExecutionId
+		if _, ok := grouped[id]; !ok {
+			executions = append(executions, id)
+		}
+		grouped[id] = append(grouped[id], entry.status)
+	}
+	errs := make(map[string]error)
+	for _, id := range executions {
+		if err := c.send(context.Background(), id, grouped[id]); err != nil {
+			logger.Error("error sending session statuses",
+				zap.String("execution_id", id),
+				zap.Int("count", len(grouped[id])),
+				zap.Error(err))
+			errs[id] = err
+		}
+	}
+	return errs
+}
+
+func isTerminalStatus(status string) bool {
+	switch status {
+	case apxconstants.Passed, apxconstants.Failed, apxconstants.Stopped, apxconstants.Timeout,
+		apxconstants.Aborted, apxconstants.NotExecuted:
+		return true
+	}
+	return false
+}
+
+// sendStatusBatch posts the statuses of one execution to the batch endpoint
+func (s *ExecutionServiceBridge) sendStatusBatch(ctx context.Context, executionId string, statuses []executionstatus.ExecutionStatus) error {
+	return s.writeJSON(ctx, OutboxRequest{Call: "SaveSessionStatusBatch", ExecutionId: executionId, Method: http.MethodPost, Path: statusBatchPath}, map[string]interface{}{
+		"statuses": statuses,
+		"count":    len(statuses),
+	})
+}
@@ -0,0 +1,103 @@
+package executionbridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/models/executionstatus"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: Unit tests for the session status coalescer
+*/
+
+func TestStatusCoalescerKeepsLatestAndTerminalStatuses(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	coalescer := NewStatusCoalescer(func(ctx context.Context, executionId string, statuses []executionstatus.ExecutionStatus) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, status := range statuses {
+			sent = append(sent, status.TestcaseId+":"+status.Status+":"+status.Message)
+		}
+		return nil
+	}, 100, time.Hour)
+
+	add := func(testcaseId, status, message string) {
+		require.NoError(t, coalescer.Add(context.Background(), executionstatus.ExecutionStatus{ExecutionId: "exec-1", TestcaseId: testcaseId, Status: status, Message: message}))
+	}
+	add("tc-1", apxconstants.Running, "step 1")
+	add("tc-2", apxconstants.Running, "step 1")
+	add("tc-1", apxconstants.Running, "step 2")
+	coalescer.Wait()
+	assert.Empty(t, sent, "nothing is sent inside the window")
+
+	add("tc-1", apxconstants.Passed, "done")
+	coalescer.Wait()
+	mu.Lock()
+	assert.Equal(t, []string{"tc-2:running:step 1", "tc-1:passed:done"}, sent, "a terminal status flushes and replaces the running one")
+	sent = nil
+	mu.Unlock()
+
+	add("tc-2", apxconstants.Running, "step 2")
+	add("tc-2", apxconstants.Failed, "boom")
+	add("tc-2", apxconstants.Stopped, "")
+	add("tc-3", apxconstants.Running, "step 1")
+	coalescer.Flush()
+	coalescer.Wait()
+	assert.Equal(t, []string{"tc-2:failed:boom", "tc-2:stopped:", "tc-3:running:step 1"}, sent, "terminal statuses are never coalesced away")
+}
+
+func TestBridgeSendsCoalescedStatusesPerExecution(t *testing.T) {
+	transport := NewMemoryTransport()
+	bridge := NewExecutionServiceBridgeWithTransport("http://execution-service", transport)
+	bridge.EnableStatusCoalescing(50, time.Hour)
+	ctx := context.Background()
+
+	for _, status := range []executionstatus.ExecutionStatus{
+		{ExecutionId: "exec-1", TestcaseId: "tc-1", Status: apxconstants.Running, StepCount: 1},
+		{ExecutionId: "exec-2", TestcaseId: "tc-1", Status: apxconstants.Running, StepCount: 1},
+		{ExecutionId: "exec-1", TestcaseId: "tc-1", Status: apxconstants.Running, StepCount: 2},
+	} {
+		require.NoError(t, bridge.SaveSessionStatus(ctx, status))
+	}
+	assert.Empty(t, transport.Calls())
+	bridge.Flush()
+
+	calls := transport.CallsTo("SaveSessionStatusBatch")
+	require.Len(t, calls, 2, "one batch call per execution")
+	assert.Equal(t, statusBatchPath, calls[0].Path)
+	var payload struct {
+		Statuses []executionstatus.ExecutionStatus `json:"statuses"`
+		Count    int                               `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(calls[1].Body, &payload))
+	require.Equal(t, 1, payload.Count)
+	assert.Equal(t, "exec-1", payload.Statuses[0].ExecutionId)
+	assert.Equal(t, 2, payload.Statuses[0].StepCount)
+}
+
+func TestBridgeSendsTerminalStatusBeforeReturning(t *testing.T) {
+	transport := NewMemoryTransport()
+	bridge := NewExecutionServiceBridgeWithTransport("http://execution-service", transport)
+	bridge.EnableStatusCoalescing(50, time.Hour)
+	ctx := context.Background()
+
+	require.NoError(t, bridge.SaveSessionStatus(ctx, executionstatus.ExecutionStatus{ExecutionId: "exec-1", TestcaseId: "tc-1", Status: apxconstants.Running}))
+	assert.Empty(t, transport.Calls(), "running statuses wait for the window")
+
+	require.NoError(t, bridge.SaveSessionStatus(ctx, executionstatus.ExecutionStatus{ExecutionId: "exec-1", TestcaseId: "tc-1", Status: apxconstants.Passed}))
+	assert.Len(t, transport.CallsTo("SaveSessionStatusBatch"), 1, "a terminal status is sent before SaveSessionStatus returns")
+
+	transport.Fail("SaveSessionStatusBatch", errors.New("connection refused"))
+	err := bridge.SaveSessionStatus(ctx, executionstatus.ExecutionStatus{ExecutionId: "exec-1", TestcaseId: "tc-2", Status: apxconstants.Failed})
+	assert.ErrorContains(t, err, "connection refused", "the send error of a terminal status reaches the caller")
+}
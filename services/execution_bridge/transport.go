@@ -0,0 +1,288 @@
+package executionbridge
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/sony/gobreaker"
+	"go.uber.org/zap"
+
+	"agent/errors"
+	"agent/logger"
+)
+
+/*
+nkk: Transport carries the bridge calls to the execution service
+HTTPTransport is the real one: shared pooled client, ctx deadlines, one circuit breaker per call.
+MemoryTransport records the calls for tests.
+Non-2xx answers come back as *errors.Error, errors.Unavailable is what is worth retrying
+(network errors, 5xx, 408, 429, open breaker).
+*/
+
+const (
+	defaultCallTimeout  = 30 * time.Second
+	uploadCallTimeout   = 10 * time.Minute
+	uploadBodyThreshold = 1 << 20 // nkk: bodies from this size get uploadCallTimeout
+	maxErrorBody        = 512
+)
+
+// Transport sends one call to the execution service
+type Transport interface {
+	Do(ctx context.Context, call Call) (*Response, error)
+}
+
+// Call is one request of the bridge, Path is relative to the execution service endpoint
+type Call struct {
+	Name        string // nkk: bridge method, also names the circuit breaker
+	Method      string
+	Path        string
+	Query       url.Values
+	ContentType string
+	Body        io.Reader
+	Size        int64
+}
+
+// Response is a 2xx answer
+type Response struct {
+	StatusCode int
+	Body       []byte
+}
+
+// StatusError is wrapped by the *errors.Error of a non-2xx answer
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status %d: %s", e.StatusCode, e.Body)
+}
+
+// checkStatus turns a non-2xx answer into a typed error
+func checkStatus(call Call, statusCode int, body []byte) error {
+	if statusCode >= 200 && statusCode < 300 {
+		return nil
+	}
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody]
+	}
+	var kind errors.Kind
+	switch {
+	case statusCode >= 500, statusCode == http.StatusTooManyRequests, statusCode == http.StatusRequestTimeout:
+		kind = errors.Unavailable
+	case statusCode == http.StatusBadRequest, statusCode == http.StatusUnprocessableEntity:
+		kind = errors.Invalid
+	case statusCode == http.StatusUnauthorized:
+		kind = errors.Unauthorized
+	case statusCode == http.StatusForbidden:
+		kind = errors.Forbidden
+	case statusCode == http.StatusNotFound:
+		kind = errors.NotFound
+	case statusCode == http.StatusConflict:
+		kind = errors.Conflict
+	case statusCode == http.StatusPreconditionFailed, statusCode == http.StatusExpectationFailed:
+		kind = errors.ExpectationFailed
+	default:
+		kind = errors.Other
+	}
+	return errors.E(kind, fmt.Sprintf("execution service %s failed with status %d", call.Name, statusCode), &StatusError{StatusCode: statusCode, Body: string(body)})
+}
+
+// Retryable reports whether a failed call may succeed when sent again
+func Retryable(err error) bool {
+	var e *errors.Error
+	if errors.As(err, &e) {
+		return e.Kind == errors.Unavailable
+	}
+	// nkk: Network errors and deadlines
+	return err != nil
+}
+
+// HTTPTransport sends the calls over HTTP
+type HTTPTransport struct {
+	endpoint string
+	client   *http.Client
+	breakers sync.Map // map[string]*gobreaker.CircuitBreaker per call name
+}
+
+// NewHTTPTransport creates the transport for endpoint, client is shared by all calls
+func NewHTTPTransport(endpoint string, client *http.Client) *HTTPTransport {
+	return &HTTPTransport{endpoint: endpoint, client: client}
+}
+
+// Do sends a call through its circuit breaker, a ctx without deadline gets the default one
+func (t *HTTPTransport) Do(ctx context.Context, call Call) (*Response, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		timeout := defaultCallTimeout
+		// nkk: A streamed body of unknown size (-1) may be a video
+		if call.Size >= uploadBodyThreshold || call.Size < 0 {
+			timeout = uploadCallTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	requestUrl := t.endpoint + call.Path
+	if len(call.Query) > 0 {
+		requestUrl += "?" + call.Query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, call.Method, requestUrl, call.Body)
+	if err != nil {
+		return nil, errors.E(errors.Invalid, "failed to create "+call.Name+" request", err)
+	}
+	if call.Body != nil && call.Size > 0 {
+		req.ContentLength = call.Size
+	}
+	if call.ContentType != "" {
+		req.Header.Set("Content-Type", call.ContentType)
+	}
+
+	res, err := t.breaker(call.Name).Execute(func() (interface{}, error) {
+		res, err := t.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkStatus(call, res.StatusCode, body); err != nil {
+			return nil, err
+		}
+		return &Response{StatusCode: res.StatusCode, Body: body}, nil
+	})
+	if stderrors.Is(err, gobreaker.ErrOpenState) || stderrors.Is(err, gobreaker.ErrTooManyRequests) {
+		return nil, errors.E(errors.Unavailable, "execution service "+call.Name+" circuit breaker is open", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return res.(*Response), nil
+}
+
+// breaker returns the circuit breaker of a call
+func (t *HTTPTransport) breaker(name string) *gobreaker.CircuitBreaker {
+	// nkk: Per-endpoint circuit breakers for isolation
+	if cb, ok := t.breakers.Load(name); ok {
+		return cb.(*gobreaker.CircuitBreaker)
+	}
+
+	cb := gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:        name,
+		MaxRequests: 5,
+		Interval:    10 * time.Second,
+		Timeout:     30 * time.Second,
+		// nkk: Only an unavailable service trips the breaker, a rejected request is the caller's problem
+		IsSuccessful: func(err error) bool {
+			return err == nil || !Retryable(err)
+		},
+		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+			logger.Info("Circuit breaker state change",
+				zap.String("endpoint", name),
+				zap.String("from", from.String()),
+				zap.String("to", to.String()))
+		},
+	})
+	actual, _ := t.breakers.LoadOrStore(name, cb)
+	return actual.(*gobreaker.CircuitBreaker)
+}
+
+// RecordedCall is a call seen by MemoryTransport
+type RecordedCall struct {
+	Name        string
+	Method      string
+	Path        string
+	Query       url.Values
+	ContentType string
+	Body        []byte
+}
+
+type memoryAnswer struct {
+	statusCode int
+	body       []byte
+	err        error
+}
+
+// MemoryTransport answers calls from memory and records them, calls without a queued answer get 200
+type MemoryTransport struct {
+	mu      sync.Mutex
+	calls   []RecordedCall
+	answers map[string][]memoryAnswer
+}
+
+// NewMemoryTransport creates an empty in-memory transport
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{answers: make(map[string][]memoryAnswer)}
+}
+
+// Respond queues the answer to the next call named name
+func (t *MemoryTransport) Respond(name string, statusCode int, body []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.answers[name] = append(t.answers[name], memoryAnswer{statusCode: statusCode, body: body})
+}
+
+// Fail queues a transport error for the next call named name
+func (t *MemoryTransport) Fail(name string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.answers[name] = append(t.answers[name], memoryAnswer{err: err})
+}
+
+// Do records the call and returns its queued answer
+func (t *MemoryTransport) Do(ctx context.Context, call Call) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	recorded := RecordedCall{Name: call.Name, Method: call.Method, Path: call.Path, Query: call.Query, ContentType: call.ContentType}
+	if call.Body != nil {
+		body, err := io.ReadAll(call.Body)
+		if err != nil {
+			return nil, err
+		}
+		recorded.Body = body
+	}
+
+	t.mu.Lock()
+	answer := memoryAnswer{statusCode: http.StatusOK}
+	if queued := t.answers[call.Name]; len(queued) > 0 {
+		answer = queued[0]
+		t.answers[call.Name] = queued[1:]
+	}
+	t.calls = append(t.calls, recorded)
+	t.mu.Unlock()
+
+	if answer.err != nil {
+		return nil, answer.err
+	}
+	if err := checkStatus(call, answer.statusCode, answer.body); err != nil {
+		return nil, err
+	}
+	return &Response{StatusCode: answer.statusCode, Body: answer.body}, nil
+}
+
+// Calls returns the calls made so far, in order
+func (t *MemoryTransport) Calls() []RecordedCall {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]RecordedCall(nil), t.calls...)
+}
+
+// CallsTo returns the calls named name, in order
+func (t *MemoryTransport) CallsTo(name string) []RecordedCall {
+	var calls []RecordedCall
+	for _, call := range t.Calls() {
+		if call.Name == name {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
@@ -0,0 +1,106 @@
+package executionbridge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/errors"
+	"agent/models/executionstatus"
+	"agent/models/runresult"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: Unit tests for the bridge transports
+*/
+
+func TestBridgeCallsThroughMemoryTransport(t *testing.T) {
+	transport := NewMemoryTransport()
+	bridge := NewExecutionServiceBridgeWithTransport("http://execution-service", transport)
+	ctx := context.Background()
+
+	require.NoError(t, bridge.SaveSessionStatus(ctx, executionstatus.ExecutionStatus{
+		OrgId: "o", ProjectId: "p", AppId: "a", TestLab: apxconstants.Local, ExecutionId: "exec-1", Status: apxconstants.Passed,
+	}))
+	require.NoError(t, bridge.CreateLocalAgentResults(ctx, "o", "p", "a", "exec-1", "tp-1", &runresult.RunResult{}, apxconstants.RunResultType))
+	transport.Respond("GetRunCountForTestPlan", http.StatusOK, []byte("3"))
+	count, err := bridge.GetRunCountForTestPlan(ctx, "o", "p", "a", apxconstants.Local, "tp-1")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	calls := transport.Calls()
+	require.Len(t, calls, 3)
+	assert.Equal(t, "SaveSessionStatus", calls[0].Name)
+	assert.Equal(t, http.MethodPost, calls[0].Method)
+	assert.Equal(t, "/organisations/o/projects/p/apps/a/local/sessions/exec-1/update-status", calls[0].Path)
+	assert.Equal(t, "application/json", calls[0].ContentType)
+	var status executionstatus.ExecutionStatus
+	require.NoError(t, json.Unmarshal(calls[0].Body, &status))
+	assert.Equal(t, apxconstants.Passed, status.Status)
+	assert.Equal(t, "tp-1", calls[1].Query.Get("testplan_id"))
+	assert.Len(t, transport.CallsTo("GetRunCountForTestPlan"), 1)
+
+	transport.Respond("SaveSessionStatus", http.StatusNotFound, []byte("no such session"))
+	err = bridge.SaveSessionStatus(ctx, executionstatus.ExecutionStatus{ExecutionId: "exec-2"})
+	var typed *errors.Error
+	require.True(t, errors.As(err, &typed))
+	assert.Equal(t, errors.NotFound, typed.Kind)
+	var status404 *StatusError
+	require.True(t, errors.As(err, &status404))
+	assert.Equal(t, "no such session", status404.Body)
+	assert.False(t, Retryable(err))
+}
+
+func TestHTTPTransportTypesErrorsAndTripsBreaker(t *testing.T) {
+	var answer, calls atomic.Int32
+	answer.Store(http.StatusServiceUnavailable)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.WriteHeader(int(answer.Load()))
+	}))
+	defer server.Close()
+	transport := NewHTTPTransport(server.URL, server.Client())
+	ctx := context.Background()
+
+	_, err := transport.Do(ctx, Call{Name: "SaveSession", Method: http.MethodPost, Path: "/sessions"})
+	var typed *errors.Error
+	require.True(t, errors.As(err, &typed))
+	assert.Equal(t, errors.Unavailable, typed.Kind)
+	assert.True(t, Retryable(err))
+
+	// nkk: gobreaker trips after more than 5 consecutive failures
+	for i := 0; i < 5; i++ {
+		transport.Do(ctx, Call{Name: "SaveSession", Method: http.MethodPost, Path: "/sessions"})
+	}
+	before := calls.Load()
+	_, err = transport.Do(ctx, Call{Name: "SaveSession", Method: http.MethodPost, Path: "/sessions"})
+	require.True(t, errors.As(err, &typed))
+	assert.Equal(t, errors.Unavailable, typed.Kind)
+	assert.Equal(t, before, calls.Load(), "an open breaker does not reach the service")
+
+	answer.Store(http.StatusBadRequest)
+	for i := 0; i < 7; i++ {
+		_, err = transport.Do(ctx, Call{Name: "UpdateSession", Method: http.MethodPut, Path: "/sessions"})
+		require.True(t, errors.As(err, &typed))
+		assert.Equal(t, errors.Invalid, typed.Kind, "rejected calls do not trip the breaker")
+	}
+
+	answer.Store(http.StatusOK)
+	deadline, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = transport.Do(deadline, Call{Name: "TakeScreenshot", Method: http.MethodPost, Path: "/slow"})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.True(t, Retryable(err))
+}
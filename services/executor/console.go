@@ -0,0 +1,275 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+
+	"agent/logger"
+	"agent/models/session"
+	"agent/services/report"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: The fixture prints one line per console message, page error and unhandled rejection
+  APX_CONSOLE {"testcase_id":"...","time":"...","type":"error","text":"...","url":"https://...","line":12,...}
+consoleTracker keeps the entries per testcase that pass the console log level of their machine.
+Uncaught page errors are always kept and counted, the bridge flags the session for them even if the test passed.
+Close sends one summary per testcase, the entries themselves go to console.json.
+*/
+
+const (
+	consoleLinePrefix     = "APX_CONSOLE "
+	consoleTypePageError  = "pageerror"
+	consoleSummaryEntries = 20
+)
+
+// ConsoleLogSaver is the part of the bridge console summaries are sent to
+type ConsoleLogSaver interface {
+	SaveConsoleLogs(ctx context.Context, orgId string, projectId string, appId string, testlab string, logs session.ConsoleLogs) error
+}
+
+var consoleLevelRanks = map[string]int{
+	session.ConsoleLogsErrors:   1,
+	session.ConsoleLogsWarnings: 2,
+	session.ConsoleLogsInfo:     3,
+	session.ConsoleLogsVerbose:  4,
+}
+
+// consoleLogLevel reads the consoleLogs setting of a session config, an empty level captures nothing
+func consoleLogLevel(setting string) string {
+	level := strings.ToLower(strings.TrimSpace(setting))
+	switch level {
+	case "", "false", "none", "off":
+		return ""
+	case "true":
+		return session.ConsoleLogsInfo
+	}
+	if _, ok := consoleLevelRanks[level]; !ok {
+		logger.Warn("unknown console log level, capturing errors only", zap.String("console_logs", setting))
+		return session.ConsoleLogsErrors
+	}
+	return level
+}
+
+// consoleEntryLevel maps a console message type to the level that shows it
+func consoleEntryLevel(entryType string) string {
+	switch entryType {
+	case "error", "assert", consoleTypePageError:
+		return session.ConsoleLogsErrors
+	case "warning", "warn":
+		return session.ConsoleLogsWarnings
+	case "log", "info":
+		return session.ConsoleLogsInfo
+	}
+	return session.ConsoleLogsVerbose
+}
+
+// parseConsoleLine reads a fixture console line, other output is ignored
+func parseConsoleLine(line string) (*report.ConsoleEntry, bool) {
+	idx := strings.Index(line, consoleLinePrefix)
+	if idx == -1 {
+		return nil, false
+	}
+	var entry report.ConsoleEntry
+	if err := json.Unmarshal([]byte(line[idx+len(consoleLinePrefix):]), &entry); err != nil {
+		logger.Warn("invalid console line from fixture", zap.String("line", line), zap.Error(err))
+		return nil, false
+	}
+	if entry.Type == consoleTypePageError {
+		entry.Uncaught = true
+	}
+	entry.Level = consoleEntryLevel(entry.Type)
+	if entry.Uncaught {
+		entry.Level = session.ConsoleLogsErrors
+	}
+	return &entry, true
+}
+
+// trackConsole starts the console tracker of a Playwright run with the levels of its machines
+func (t *TestExecutor) trackConsole(ctx context.Context, orgId, projectId, appId string, configs ...session.Config) *consoleTracker {
+	return newConsoleTracker(ctx, t.ExecutionServiceBridge, orgId, projectId, appId, apxconstants.Local, configs...)
+}
+
+type consoleTracker struct {
+	ctx     context.Context
+	bridge  ConsoleLogSaver
+	orgId   string
+	project string
+	appId   string
+	testlab string
+
+	levels       map[string]string // nkk: by machine id, the Playwright project name the fixture prints
+	defaultLevel string            // nkk: most verbose level configured, for lines of an unknown machine
+
+	mu     sync.Mutex
+	order  []string
+	logs   map[string]*trackedConsole
+	closed bool
+}
+
+type trackedConsole struct {
+	report.ConsoleLog
+	first  report.ConsoleEntry // nkk: ids of the testcase the summary is sent for
+	counts map[string]int
+}
+
+func newConsoleTracker(ctx context.Context, bridge ConsoleLogSaver, orgId, projectId, appId, testlab string, configs ...session.Config) *consoleTracker {
+	tracker := &consoleTracker{
+		ctx:     ctx,
+		bridge:  bridge,
+		orgId:   orgId,
+		project: projectId,
+		appId:   appId,
+		testlab: testlab,
+		levels:  make(map[string]string),
+		logs:    make(map[string]*trackedConsole),
+	}
+	for _, config := range configs {
+		level := consoleLogLevel(config.ConsoleLogs)
+		tracker.levels[config.MachineId] = level
+		if consoleLevelRanks[level] > consoleLevelRanks[tracker.defaultLevel] {
+			tracker.defaultLevel = level
+		}
+	}
+	return tracker
+}
+
+func (c *consoleTracker) levelFor(machineId string) string {
+	if level, ok := c.levels[machineId]; ok {
+		return level
+	}
+	return c.defaultLevel
+}
+
+// ObserveLine records the entry of a fixture output line and reports whether it was one
+func (c *consoleTracker) ObserveLine(line string) bool {
+	entry, ok := parseConsoleLine(line)
+	if ok {
+		c.Observe(*entry)
+	}
+	return ok
+}
+
+// Observe adds an entry to its testcase log when the level of its machine shows it
+func (c *consoleTracker) Observe(entry report.ConsoleEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if entry.Level == "" {
+		entry.Level = consoleEntryLevel(entry.Type)
+	}
+	level := c.levelFor(entry.MachineId)
+	if !entry.Uncaught && (level == "" || consoleLevelRanks[entry.Level] > consoleLevelRanks[level]) {
+		return
+	}
+
+	key := fmt.Sprintf("%s/%s/%t", entry.TestsuiteId, entry.TestcaseId, entry.IsPreRequisite)
+	log, ok := c.logs[key]
+	if !ok {
+		log = &trackedConsole{
+			ConsoleLog: report.ConsoleLog{
+				TestcaseId:     entry.TestcaseId,
+				TestsuiteId:    entry.TestsuiteId,
+				MachineId:      entry.MachineId,
+				IsPreRequisite: entry.IsPreRequisite,
+				Level:          level,
+			},
+			first:  entry,
+			counts: make(map[string]int),
+		}
+		c.logs[key] = log
+		c.order = append(c.order, key)
+	}
+	log.Entries = append(log.Entries, entry)
+	log.counts[entry.Level]++
+	if entry.Uncaught {
+		log.UncaughtErrors++
+	}
+}
+
+// Logs returns the console logs in the order testcases printed their first entry
+func (c *consoleTracker) Logs() []report.ConsoleLog {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	logs := make([]report.ConsoleLog, 0, len(c.order))
+	for _, key := range c.order {
+		log := c.logs[key].ConsoleLog
+		log.Entries = append([]report.ConsoleEntry(nil), log.Entries...)
+		logs = append(logs, log)
+	}
+	return logs
+}
+
+// Close sends the summary of every testcase log, artifact is where the entries were stored
+func (c *consoleTracker) Close(artifact string) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.closed = true
+	summaries := make([]session.ConsoleLogs, 0, len(c.order))
+	for _, key := range c.order {
+		summary := c.logs[key].summarize()
+		summary.Artifact = artifact
+		summaries = append(summaries, summary)
+	}
+	c.mu.Unlock()
+
+	for _, summary := range summaries {
+		if err := c.bridge.SaveConsoleLogs(c.ctx, c.orgId, c.project, c.appId, c.testlab, summary); err != nil {
+			logger.Warn("could not save console logs",
+				zap.String("execution_id", summary.ExecutionId),
+				zap.String("testcase_id", summary.TestcaseId),
+				zap.Error(err))
+		}
+	}
+}
+
+// summarize counts the entries per level and lists the first of them, called with mu held
+func (l *trackedConsole) summarize() session.ConsoleLogs {
+	logs := session.ConsoleLogs{
+		ExecutionId:    l.first.ExecutionId,
+		TestcaseId:     l.TestcaseId,
+		TestsuiteId:    l.TestsuiteId,
+		TestplanId:     l.first.TestplanId,
+		MachineId:      l.MachineId,
+		IsPreRequisite: l.IsPreRequisite,
+		Level:          l.Level,
+		Errors:         l.counts[session.ConsoleLogsErrors],
+		Warnings:       l.counts[session.ConsoleLogsWarnings],
+		Info:           l.counts[session.ConsoleLogsInfo],
+		Verbose:        l.counts[session.ConsoleLogsVerbose],
+		UncaughtErrors: l.UncaughtErrors,
+	}
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "%d errors, %d warnings, %d info, %d verbose, %d uncaught page errors",
+		logs.Errors, logs.Warnings, logs.Info, logs.Verbose, logs.UncaughtErrors)
+	for i, entry := range l.Entries {
+		if i == consoleSummaryEntries {
+			fmt.Fprintf(&summary, "\n... %d more", len(l.Entries)-i)
+			break
+		}
+		summary.WriteString("\n" + formatConsoleEntry(entry))
+	}
+	logs.Summary = summary.String()
+	return logs
+}
+
+// formatConsoleEntry renders an entry as one line: [type] time url:line:column text
+func formatConsoleEntry(entry report.ConsoleEntry) string {
+	var line strings.Builder
+	fmt.Fprintf(&line, "[%s] %s", entry.Type, entry.Time.UTC().Format("15:04:05.000"))
+	if entry.URL != "" {
+		fmt.Fprintf(&line, " %s:%d:%d", entry.URL, entry.Line, entry.Column)
+	}
+	line.WriteString(" " + strings.ReplaceAll(entry.Text, "\n", " "))
+	return line.String()
+}
@@ -0,0 +1,75 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/models/session"
+)
+
+/*
+nkk: Unit tests for the fixture console tracking
+*/
+
+type recordingConsoleBridge struct {
+	logs []session.ConsoleLogs
+}
+
+func (b *recordingConsoleBridge) SaveConsoleLogs(ctx context.Context, orgId string, projectId string, appId string, testlab string, logs session.ConsoleLogs) error {
+	b.logs = append(b.logs, logs)
+	return nil
+}
+
+func TestConsoleLogLevel(t *testing.T) {
+	assert.Equal(t, session.ConsoleLogsWarnings, consoleLogLevel(" Warnings"))
+	assert.Equal(t, session.ConsoleLogsInfo, consoleLogLevel("true"))
+	assert.Equal(t, "", consoleLogLevel("false"))
+	assert.Equal(t, session.ConsoleLogsErrors, consoleLogLevel("loud"), "unknown levels keep the errors")
+}
+
+func TestConsoleTrackerFiltersAndSummarizes(t *testing.T) {
+	bridge := &recordingConsoleBridge{}
+	tracker := newConsoleTracker(context.Background(), bridge, "org", "project", "app", "local",
+		session.Config{MachineId: "m-1", ConsoleLogs: session.ConsoleLogsWarnings},
+		session.Config{MachineId: "m-2"})
+
+	line := func(machine, entry string) string {
+		return `  [chromium] › APX_CONSOLE {"execution_id":"exec-1","testplan_id":"tp-1","testsuite_id":"ts-1","testcase_id":"tc-` + machine + `","machine_id":"` + machine + `","time":"2026-10-18T10:00:00Z",` + entry + `}`
+	}
+	assert.True(t, tracker.ObserveLine(line("m-1", `"type":"error","text":"failed to load resource","url":"https://app/main.js","line":12,"column":4`)))
+	assert.True(t, tracker.ObserveLine(line("m-1", `"type":"warning","text":"deprecated"`)))
+	assert.True(t, tracker.ObserveLine(line("m-1", `"type":"log","text":"hello"`)), "filtered lines are still console lines")
+	assert.True(t, tracker.ObserveLine(line("m-1", `"type":"pageerror","text":"x is undefined","url":"https://app/main.js","line":40,"column":2`)))
+	assert.True(t, tracker.ObserveLine(line("m-2", `"type":"error","text":"captured by nobody"`)))
+	assert.True(t, tracker.ObserveLine(line("m-2", `"type":"pageerror","text":"Unhandled rejection"`)))
+	assert.False(t, tracker.ObserveLine("APX_CONSOLE {not json"))
+	assert.False(t, tracker.ObserveLine("error: the test failed"))
+
+	logs := tracker.Logs()
+	require.Len(t, logs, 2)
+	require.Len(t, logs[0].Entries, 3, "info is above the warnings level")
+	assert.Equal(t, session.ConsoleLogsErrors, logs[0].Entries[2].Level)
+	assert.True(t, logs[0].Entries[2].Uncaught)
+	require.Len(t, logs[1].Entries, 1, "a machine without a level only keeps uncaught errors")
+	assert.Equal(t, "", logs[1].Level)
+
+	tracker.Close("/reports/exec-1/console.json")
+	tracker.Close("")
+	require.Len(t, bridge.logs, 2, "Close sends once")
+	summary := bridge.logs[0]
+	assert.Equal(t, "exec-1", summary.ExecutionId)
+	assert.Equal(t, "tp-1", summary.TestplanId)
+	assert.Equal(t, 2, summary.Errors)
+	assert.Equal(t, 1, summary.Warnings)
+	assert.Equal(t, 1, summary.UncaughtErrors)
+	assert.Equal(t, "/reports/exec-1/console.json", summary.Artifact)
+	lines := strings.Split(summary.Summary, "\n")
+	require.Len(t, lines, 4)
+	assert.Equal(t, "2 errors, 1 warnings, 0 info, 0 verbose, 1 uncaught page errors", lines[0])
+	assert.Equal(t, "[error] 10:00:00.000 https://app/main.js:12:4 failed to load resource", lines[1])
+	assert.Equal(t, 1, bridge.logs[1].UncaughtErrors)
+}
@@ -0,0 +1,327 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"go.uber.org/zap"
+
+	"agent/config"
+	"agent/logger"
+	"agent/services/browser_pool"
+	executionbridge "agent/services/execution_bridge"
+	"agent/services/playwright_runtime"
+)
+
+/*
+nkk: Docker-isolated TestCaseRunner
+Runs the whole `npx playwright test` process inside a Playwright image instead of on the host:
+- Host Node/browser versions no longer affect results
+- Each tenant's test code runs in its own container with CPU/memory limits
+- The executions workspace is bind mounted, so traces/videos/results land on the host
+- stdout/stderr are streamed back through the same scanners TestExecutor already uses
+*/
+
+// containerEnv are the variables TestExecutor sets for the Playwright process
+var containerEnv = []string{"testlab", "WORKERS", "PARALLELISM_ENABLED"}
+
+// containerRuntimeDir is where a versioned Playwright runtime is mounted
+const containerRuntimeDir = "/opt/playwright-runtime"
+
+// DockerTestRunner is a TestExecutor whose Playwright process runs in a container
+type DockerTestRunner struct {
+	*TestExecutor
+	docker *client.Client
+	cfg    config.DockerRunnerConfig
+}
+
+// NewDockerTestRunner creates a runner that executes tests inside containers
+func NewDockerTestRunner(executionsvcbridge executionbridge.ExecutionBridge, cfg config.DockerRunnerConfig) (*DockerTestRunner, error) {
+	docker, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", browser_pool.ErrDockerUnavailable, err)
+	}
+
+	pingCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := docker.Ping(pingCtx); err != nil {
+		docker.Close()
+		return nil, fmt.Errorf("%w: %v", browser_pool.ErrDockerUnavailable, err)
+	}
+
+	// nkk: Browsers come from the image, no pooled browser is needed
+	r := &DockerTestRunner{
+		TestExecutor: NewTestExecutor(executionsvcbridge, nil),
+		docker:       docker,
+		cfg:          cfg,
+	}
+	r.TestExecutor.launcher = r.launch
+	r.TestExecutor.SetWorkspace(cfg.WorkspaceDir)
+	r.TestExecutor.containerWorkspace = cfg.ContainerDir
+
+	logger.Info("DockerTestRunner initialized",
+		zap.String("image", cfg.Image),
+		zap.String("network_mode", cfg.NetworkMode))
+	return r, nil
+}
+
+// NewTestCaseRunner picks the runner configured in DynamicConfig.DockerRunner,
+// falling back to host execution when Docker is not reachable
+func NewTestCaseRunner(executionsvcbridge executionbridge.ExecutionBridge, pool browser_pool.BrowserPool, runtimes *playwright_runtime.Manager) TestCaseRunner {
+	cfg := config.GetConfig().DockerRunner
+	if cfg.Enabled {
+		runner, err := NewDockerTestRunner(executionsvcbridge, cfg)
+		if err == nil {
+			runner.PlaywrightRuntimes = runtimes
+			return runner
+		}
+		logger.Warn("Docker runner unavailable, falling back to host execution", zap.Error(err))
+	}
+
+	executor := NewTestExecutor(executionsvcbridge, pool)
+	executor.PlaywrightRuntimes = runtimes
+	return executor
+}
+
+// SetWorkspace moves the execution workspace, it is also what gets mounted into the container
+func (r *DockerTestRunner) SetWorkspace(dir string) {
+	r.TestExecutor.SetWorkspace(dir)
+	r.cfg.WorkspaceDir = dir
+}
+
+// Close releases the Docker client
+func (r *DockerTestRunner) Close() error {
+	return r.docker.Close()
+}
+
+// launch starts a Playwright container for one execution
+func (r *DockerTestRunner) launch(ctx context.Context, spec processSpec) (playwrightProcess, error) {
+	containerConfig, hostConfig, err := r.containerConfigs(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.ensureImage(ctx, containerConfig.Image); err != nil {
+		return nil, err
+	}
+
+	resp, err := r.docker.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create test container: %w", err)
+	}
+
+	// nkk: Register the wait before start so a fast exit is not missed
+	waitCh, waitErrCh := r.docker.ContainerWait(context.Background(), resp.ID, container.WaitConditionNextExit)
+
+	if err := r.docker.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		r.removeContainer(resp.ID)
+		return nil, fmt.Errorf("failed to start test container: %w", err)
+	}
+
+	logs, err := r.docker.ContainerLogs(context.Background(), resp.ID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+	})
+	if err != nil {
+		r.docker.ContainerKill(context.Background(), resp.ID, "SIGKILL")
+		r.removeContainer(resp.ID)
+		return nil, fmt.Errorf("failed to attach to test container logs: %w", err)
+	}
+
+	stdoutReader, stdoutWriter := io.Pipe()
+	stderrReader, stderrWriter := io.Pipe()
+
+	p := &dockerProcess{
+		runner:      r,
+		containerID: resp.ID,
+		stdout:      stdoutReader,
+		stderr:      stderrReader,
+		waitCh:      waitCh,
+		waitErrCh:   waitErrCh,
+		logsDone:    make(chan struct{}),
+		exited:      make(chan struct{}),
+	}
+
+	// nkk: Demultiplex the docker log stream into the two pipes
+	go func() {
+		defer close(p.logsDone)
+		defer logs.Close()
+		_, err := stdcopy.StdCopy(stdoutWriter, stderrWriter, logs)
+		stdoutWriter.CloseWithError(err)
+		stderrWriter.CloseWithError(err)
+	}()
+
+	// nkk: Same semantics as exec.CommandContext - cancellation kills the run
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.docker.ContainerKill(context.Background(), resp.ID, "SIGKILL")
+		case <-p.exited:
+		}
+	}()
+
+	logger.Info("started playwright container",
+		zap.String("execution_id", spec.ExecutionID),
+		zap.String("container_id", resp.ID[:12]))
+
+	return p, nil
+}
+
+// containerConfigs builds the container and host configuration for a run
+func (r *DockerTestRunner) containerConfigs(spec processSpec) (*container.Config, *container.HostConfig, error) {
+	workspace, err := filepath.Abs(r.cfg.WorkspaceDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid workspace dir: %w", err)
+	}
+
+	env := make([]string, 0, len(containerEnv)+len(r.cfg.EnvPassthrough)+len(spec.Env))
+	for _, name := range append(append([]string{}, containerEnv...), r.cfg.EnvPassthrough...) {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	env = append(env, spec.Env...)
+
+	// nkk: Image tag follows the Playwright version so bundled browsers match the library
+	version := config.GetConfig().Playwright.DefaultVersion
+	cmd := append([]string{"npx", "playwright", "test"}, spec.Args...)
+	binds := []string{workspace + ":" + r.cfg.ContainerDir}
+	if spec.Runtime != nil {
+		version = spec.Runtime.Version
+		cmd = append([]string{"node", containerRuntimeDir + "/node_modules/@playwright/test/cli.js", "test"}, spec.Args...)
+		binds = append(binds, spec.Runtime.Dir+":"+containerRuntimeDir+":ro")
+		env = append(env, "NODE_PATH="+containerRuntimeDir+"/node_modules")
+	}
+
+	containerConfig := &container.Config{
+		Image:      strings.ReplaceAll(r.cfg.Image, "{version}", version),
+		Cmd:        cmd,
+		Env:        env,
+		WorkingDir: r.cfg.ContainerDir,
+		User:       r.cfg.User,
+		Labels: map[string]string{
+			"agent.role":         "playwright-runner",
+			"agent.execution_id": spec.ExecutionID,
+		},
+	}
+
+	// nkk: Init so SIGINT reaches npx instead of being dropped by PID 1
+	initProcess := true
+	hostConfig := &container.HostConfig{
+		Binds:       binds,
+		NetworkMode: container.NetworkMode(r.cfg.NetworkMode),
+		Init:        &initProcess,
+		ShmSize:     1024 * 1024 * 1024, // nkk: Chromium needs more shared memory than the default 64MB
+		Resources: container.Resources{
+			NanoCPUs: int64(r.cfg.CPUs * 1e9),
+			Memory:   r.cfg.MemoryMB * 1024 * 1024,
+		},
+	}
+
+	return containerConfig, hostConfig, nil
+}
+
+// ensureImage pulls the Playwright image when it is not present locally
+func (r *DockerTestRunner) ensureImage(ctx context.Context, ref string) error {
+	if _, err := r.docker.ImageInspect(ctx, ref); err == nil {
+		return nil
+	}
+
+	logger.Info("pulling playwright image", zap.String("image", ref))
+	reader, err := r.docker.ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", ref, err)
+	}
+	defer reader.Close()
+
+	// Pull completes when the progress stream is drained
+	_, err = io.Copy(io.Discard, reader)
+	return err
+}
+
+// removeContainer deletes a finished container
+func (r *DockerTestRunner) removeContainer(containerID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := r.docker.ContainerRemove(ctx, containerID, container.RemoveOptions{Force: true}); err != nil {
+		logger.Warn("failed to remove test container", zap.String("container_id", containerID), zap.Error(err))
+	}
+}
+
+// dockerProcess is a Playwright run inside a container
+type dockerProcess struct {
+	runner      *DockerTestRunner
+	containerID string
+	stdout      *io.PipeReader
+	stderr      *io.PipeReader
+	waitCh      <-chan container.WaitResponse
+	waitErrCh   <-chan error
+	logsDone    chan struct{}
+	exited      chan struct{}
+	interrupted atomic.Bool
+	waitOnce    sync.Once
+	waitErr     error
+}
+
+func (p *dockerProcess) Stdout() io.Reader { return p.stdout }
+
+func (p *dockerProcess) Stderr() io.Reader { return p.stderr }
+
+// Interrupt forwards SIGINT to the Playwright process in the container
+func (p *dockerProcess) Interrupt() error {
+	p.interrupted.Store(true)
+	return p.runner.docker.ContainerKill(context.Background(), p.containerID, "SIGINT")
+}
+
+// Wait blocks until the container exits and its logs are drained
+func (p *dockerProcess) Wait() error {
+	p.waitOnce.Do(func() {
+		defer close(p.exited)
+
+		var exitCode int64
+		select {
+		case resp := <-p.waitCh:
+			exitCode = resp.StatusCode
+			if resp.Error != nil {
+				p.waitErr = fmt.Errorf("test container wait failed: %s", resp.Error.Message)
+			}
+		case err := <-p.waitErrCh:
+			p.waitErr = fmt.Errorf("test container wait failed: %w", err)
+		}
+
+		// nkk: Bounded so a reader that stopped consuming cannot block completion
+		select {
+		case <-p.logsDone:
+		case <-time.After(10 * time.Second):
+			logger.Warn("timed out draining test container logs", zap.String("container_id", p.containerID))
+			p.stdout.Close()
+			p.stderr.Close()
+		}
+		p.runner.removeContainer(p.containerID)
+
+		if p.waitErr != nil {
+			return
+		}
+		if exitCode != 0 {
+			if p.interrupted.Load() {
+				p.waitErr = errProcessInterrupted
+				return
+			}
+			p.waitErr = fmt.Errorf("playwright container exited with code %d", exitCode)
+		}
+	})
+	return p.waitErr
+}
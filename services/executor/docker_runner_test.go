@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+
+	"agent/config"
+	"agent/services/playwright_runtime"
+)
+
+/*
+nkk: Unit tests for DockerTestRunner
+Container configuration is built without talking to Docker
+*/
+
+func TestContainerConfigs(t *testing.T) {
+	t.Setenv("WORKERS", "2")
+	t.Setenv("TUNNEL_URL", "http://tunnel")
+
+	runner := &DockerTestRunner{cfg: config.DockerRunnerConfig{
+		Image:          "mcr.microsoft.com/playwright:v1.47.2-noble",
+		WorkspaceDir:   "executions",
+		ContainerDir:   "/workspace",
+		CPUs:           1.5,
+		MemoryMB:       2048,
+		NetworkMode:    "bridge",
+		EnvPassthrough: []string{"TUNNEL_URL"},
+	}}
+
+	containerConfig, hostConfig, err := runner.containerConfigs(processSpec{
+		ExecutionID: "exec-1",
+		Args:        []string{"--browser", "chromium"},
+		Env:         []string{"PLAYWRIGHT_JSON_OUTPUT_NAME=reports/exec-1/results.json"},
+	})
+	assert.NoError(t, err)
+
+	workspace, _ := filepath.Abs("executions")
+	assert.Equal(t, []string{"npx", "playwright", "test", "--browser", "chromium"}, []string(containerConfig.Cmd))
+	assert.Equal(t, "/workspace", containerConfig.WorkingDir)
+	assert.Equal(t, "exec-1", containerConfig.Labels["agent.execution_id"])
+	assert.Contains(t, containerConfig.Env, "WORKERS=2")
+	assert.Contains(t, containerConfig.Env, "TUNNEL_URL=http://tunnel")
+	assert.Contains(t, containerConfig.Env, "PLAYWRIGHT_JSON_OUTPUT_NAME=reports/exec-1/results.json")
+	assert.Equal(t, []string{workspace + ":/workspace"}, hostConfig.Binds)
+	assert.Equal(t, container.NetworkMode("bridge"), hostConfig.NetworkMode)
+	assert.Equal(t, int64(1500000000), hostConfig.NanoCPUs)
+	assert.Equal(t, int64(2048*1024*1024), hostConfig.Memory)
+	assert.True(t, *hostConfig.Init)
+}
+
+func TestIsInterrupted(t *testing.T) {
+	assert.True(t, isInterrupted(errProcessInterrupted))
+	assert.True(t, isInterrupted(errors.New("signal: killed")))
+	assert.False(t, isInterrupted(errors.New("playwright container exited with code 1")))
+	assert.False(t, isInterrupted(nil))
+}
+
+func TestContainerConfigsWithRuntime(t *testing.T) {
+	runner := &DockerTestRunner{cfg: config.DockerRunnerConfig{
+		Image:        "mcr.microsoft.com/playwright:v{version}-noble",
+		WorkspaceDir: "executions",
+		ContainerDir: "/workspace",
+	}}
+
+	runtime := &playwright_runtime.Runtime{Version: "1.48.0", Dir: "/var/agent/runtimes/1.48.0"}
+	containerConfig, hostConfig, err := runner.containerConfigs(processSpec{ExecutionID: "exec-2", Runtime: runtime})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "mcr.microsoft.com/playwright:v1.48.0-noble", containerConfig.Image)
+	assert.Equal(t, "node", containerConfig.Cmd[0])
+	assert.Contains(t, containerConfig.Env, "NODE_PATH=/opt/playwright-runtime/node_modules")
+	assert.Contains(t, hostConfig.Binds, "/var/agent/runtimes/1.48.0:/opt/playwright-runtime:ro")
+}
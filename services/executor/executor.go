@@ -20,7 +20,6 @@ import (
 	"agent/services/billing"
 	"agent/services/geo"
 	"agent/services/recorder"
-	"agent/services/browser_pool"
 )
 
 /*
@@ -60,9 +59,6 @@ type TestCaseExecutorService struct {
 	GeoRouter        *geo.Router
 	SessionRecorder  *recorder.SessionRecorder
 	ExecutionQueue   chan string
-
-	// nkk: Added BrowserPoolManager for Playwright connection pooling
-	BrowserPoolManager *browser_pool.BrowserPoolManager
 }
 
 /*
@@ -73,9 +69,6 @@ Notes by nkk:
 - Ensures backward compatibility by retaining original parameters and return type.
 */
 func NewTestCaseExecutorService(testCaseRunner TestCaseRunner, autotestBridge AutoTestBridgeService, executionsvcbridge *executionbridge.ExecutionServiceBridge) *TestCaseExecutorService {
-	// nkk: Initialize BrowserPoolManager with max pool size for high concurrency
-	browserPoolMgr, _ := browser_pool.NewBrowserPoolManager(50)
-
 	svc := TestCaseExecutorService{
 		testCaseRunner:         testCaseRunner,
 		AutoTestBridge:         autotestBridge,
@@ -85,7 +78,6 @@ func NewTestCaseExecutorService(testCaseRunner TestCaseRunner, autotestBridge Au
 		BillingService:         billing.NewService(),
 		GeoRouter:              geo.NewRouter(),
 		ExecutionQueue:         make(chan string, 100), // nkk: buffered channel for queued execution IDs
-		BrowserPoolManager:    browserPoolMgr,
 	}
 
 	return &svc
@@ -152,6 +144,10 @@ func (s *TestCaseExecutorService) ProcessQueue() {
 					logger.Error("Error fetching test plan execution details for queued execution", zap.Error(err))
 					return
 				}
+				// nkk: The local execution may pin the Playwright version when the plan does not
+				if testplanDetails.PlaywrightVersion == "" {
+					testplanDetails.PlaywrightVersion = localExec.PlaywrightVersion
+				}
 				// nkk: Updated to use TestLabs slice from TestPlanExecutionDetails
 				if len(testplanDetails.TestLabs) > 0 {
 					// nkk: Use NewTestLabConfigFromTestPlanConfig to get proper testlab.TestLabConfig
@@ -182,13 +178,7 @@ func (s *TestCaseExecutorService) ProcessQueue() {
 					logger.Error("Error fetching test case for queued execution", zap.Error(err))
 					return
 				}
-				// nkk: Acquire pooled browser instance for optimized startup and latency
-				browserInstance, err := s.BrowserPoolManager.AcquireBrowser(ctx, "playwright", "latest")
-				if err != nil {
-					logger.Error("Error acquiring browser from pool", zap.Error(err))
-					return
-				}
-				defer s.BrowserPoolManager.ReleaseBrowser("playwright", "latest", browserInstance)
+				// nkk: The runner acquires the pooled browser of the configured browser itself, one per run
 
 				// nkk: Updated to use LocalSessionConfig from ExecuteRequestBody
 				execReq := localExec.ToTestcaseRequestBody()
@@ -211,6 +201,22 @@ func (s *TestCaseExecutorService) ProcessQueue() {
 	}
 }
 
+// RunningExecutions reports in-flight executions when the runner tracks them
+func (s *TestCaseExecutorService) RunningExecutions() int {
+	if tracker, ok := s.testCaseRunner.(interface{ RunningExecutions() int }); ok {
+		return tracker.RunningExecutions()
+	}
+	return 0
+}
+
+// PauseExecutions stops the runner from starting executions when it supports pausing
+func (s *TestCaseExecutorService) PauseExecutions() (resume func()) {
+	if pauser, ok := s.testCaseRunner.(interface{ PauseExecutions() func() }); ok {
+		return pauser.PauseExecutions()
+	}
+	return func() {}
+}
+
 /*
 nkk: Note - The following section contains the original methods from TestCaseExecutorService that were not modified in this update.
 They remain unchanged to preserve existing functionality and backward compatibility.
@@ -0,0 +1,139 @@
+package executor
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/models/environments"
+	"agent/models/integrations"
+	localexecution_model "agent/models/localexecution"
+	"agent/models/session"
+	"agent/models/testcase"
+	"agent/models/testplan"
+	"agent/services/browser_pool"
+	executionbridge "agent/services/execution_bridge"
+)
+
+/*
+nkk: Unit tests for the queued executions of TestCaseExecutorService
+Playwright and the browser pool are faked, the queue and TestExecutor are real
+*/
+
+type queueBridge struct {
+	execution *localexecution_model.LocalExecution
+	deleted   chan string
+}
+
+func (b *queueBridge) GetTestCase(orgId, projectId, appId, testCaseId, elementId string) (*testcase.TestScript, error) {
+	return &testcase.TestScript{ID: testCaseId, Title: "login"}, nil
+}
+
+func (b *queueBridge) GetTestPlanExecutionDetails(orgId, projectId, appId, testplanId, elementId string) (*testplan.TestPlanExecutionDetails, error) {
+	return nil, nil
+}
+
+func (b *queueBridge) GetEnvironmentDetails(orgId, projectId, appId, environmentId string) (*environments.Environment, error) {
+	return nil, nil
+}
+
+func (b *queueBridge) GetLocalexecution(deviceId string) (*localexecution_model.LocalExecution, error) {
+	return b.execution, nil
+}
+
+func (b *queueBridge) DeleteLocalexecution(deviceId string, localexecutionId string) error {
+	b.deleted <- localexecutionId
+	return nil
+}
+
+func (b *queueBridge) FindTestplanById(orgId, projectId, appId, testplanId string) (*testplan.TestPlan, error) {
+	return nil, nil
+}
+
+func (b *queueBridge) GetEdcIntegrationDetails(orgId, projectId, appId string) (*integrations.VeevaVault, error) {
+	return nil, nil
+}
+
+// recordingPool hands out leases and remembers what was asked for
+type recordingPool struct {
+	mu       sync.Mutex
+	acquired []string
+	released int
+}
+
+func (p *recordingPool) Acquire(ctx context.Context, browser, version string) (*browser_pool.Lease, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := browser_pool.NewPoolKey(browser, version)
+	p.acquired = append(p.acquired, key.String())
+	return &browser_pool.Lease{ID: key.String(), BrowserType: key.Browser, Version: key.Version}, nil
+}
+
+func (p *recordingPool) Release(lease *browser_pool.Lease) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.released++
+}
+
+func (p *recordingPool) Stats() map[string]browser_pool.PoolStats { return nil }
+
+func (p *recordingPool) Health(ctx context.Context) error { return nil }
+
+func (p *recordingPool) Shutdown() {}
+
+// finishedProcess is a Playwright run that exits at once
+type finishedProcess struct{}
+
+func (finishedProcess) Stdout() io.Reader { return strings.NewReader("") }
+func (finishedProcess) Stderr() io.Reader { return strings.NewReader("") }
+func (finishedProcess) Interrupt() error  { return nil }
+func (finishedProcess) Wait() error       { return nil }
+
+func TestQueuedTestCaseAcquiresOneBrowserOfItsConfig(t *testing.T) {
+	sink, err := executionbridge.NewLocalSink(t.TempDir())
+	require.NoError(t, err)
+	defer sink.Close()
+
+	pool := &recordingPool{}
+	runner := NewTestExecutor(sink, pool)
+	runner.SetWorkspace(t.TempDir())
+	var launched []string
+	runner.launcher = func(ctx context.Context, spec processSpec) (playwrightProcess, error) {
+		launched = spec.Args
+		return finishedProcess{}, nil
+	}
+
+	bridge := &queueBridge{
+		execution: &localexecution_model.LocalExecution{
+			ID:                 "local-1",
+			OrgId:              "org",
+			ProjectId:          "project",
+			AppId:              "app",
+			TestcaseId:         "tc-1",
+			LocalSessionConfig: &session.Config{Browser: "Chrome", Resolution: "1280x720"},
+		},
+		deleted: make(chan string, 1),
+	}
+	svc := NewTestCaseExecutorService(runner, bridge, nil)
+	go svc.ProcessQueue()
+	defer close(svc.ExecutionQueue)
+	svc.QueueExecution("exec-1")
+
+	select {
+	case id := <-bridge.deleted:
+		assert.Equal(t, "local-1", id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued execution did not finish")
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	assert.Equal(t, []string{"chrome:latest"}, pool.acquired, "one browser per run, from the pre-warmed chrome sub-pool")
+	assert.Equal(t, 1, pool.released)
+	assert.Contains(t, strings.Join(launched, " "), "--browser chromium")
+}
@@ -0,0 +1,109 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"agent/services/playwright_runtime"
+)
+
+/*
+nkk: Abstraction over a running `npx playwright test` invocation.
+TestExecutor only needs output streams, a way to interrupt and a way to wait,
+so the same execution flow works for host processes and Docker containers.
+*/
+
+// errProcessInterrupted keeps the message of the host error so existing checks keep working
+var errProcessInterrupted = errors.New("signal: interrupt")
+
+// playwrightProcess is a started Playwright test run
+type playwrightProcess interface {
+	Stdout() io.Reader
+	Stderr() io.Reader
+	Interrupt() error
+	Wait() error
+}
+
+// processSpec describes the Playwright invocation to launch
+type processSpec struct {
+	ExecutionID string
+	Dir         string // nkk: execution workspace, the process runs inside it
+	Args        []string
+	Env         []string                    // nkk: extra variables on top of the agent's environment
+	Runtime     *playwright_runtime.Runtime // nkk: nil runs the workspace's own npx playwright
+}
+
+// processLauncher starts a Playwright test run, ctx cancellation kills it
+type processLauncher func(ctx context.Context, spec processSpec) (playwrightProcess, error)
+
+// isInterrupted reports whether the run ended because it was stopped
+func isInterrupted(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, errProcessInterrupted) {
+		return true
+	}
+	return err.Error() == "signal: killed" || err.Error() == "signal: interrupt"
+}
+
+// hostProcess runs Playwright directly on the agent host
+type hostProcess struct {
+	cmd    *exec.Cmd
+	stdout io.Reader
+	stderr io.Reader
+}
+
+// launchHostProcess starts `playwright test` inside the execution workspace
+func launchHostProcess(ctx context.Context, spec processSpec) (playwrightProcess, error) {
+	var cmd *exec.Cmd
+	env := append(os.Environ(), spec.Env...)
+	if spec.Runtime != nil {
+		// nkk: Versioned runtime - its CLI, modules and browsers instead of the workspace's
+		args := append([]string{spec.Runtime.CLIPath(), "test"}, spec.Args...)
+		cmd = exec.CommandContext(ctx, "node", args...)
+		env = append(env,
+			"NODE_PATH="+spec.Runtime.NodePath(),
+			"PLAYWRIGHT_BROWSERS_PATH="+spec.Runtime.BrowsersPath())
+	} else {
+		args := append([]string{"playwright", "test"}, spec.Args...)
+		cmd = exec.CommandContext(ctx, "npx", args...)
+	}
+	cmd.Env = env
+	cmd.Dir = spec.Dir
+	if cmd.Dir == "" {
+		cmd.Dir = defaultWorkspace
+	}
+
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &hostProcess{cmd: cmd, stdout: stdoutPipe, stderr: stderrPipe}, nil
+}
+
+func (p *hostProcess) Stdout() io.Reader { return p.stdout }
+
+func (p *hostProcess) Stderr() io.Reader { return p.stderr }
+
+// Interrupt sends SIGINT (Ctrl+C) to simulate graceful shutdown
+func (p *hostProcess) Interrupt() error {
+	return p.cmd.Process.Signal(syscall.SIGINT)
+}
+
+func (p *hostProcess) Wait() error {
+	return p.cmd.Wait()
+}
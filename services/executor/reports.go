@@ -0,0 +1,83 @@
+package executor
+
+import (
+	"path/filepath"
+
+	"go.uber.org/zap"
+
+	"agent/logger"
+	"agent/services/report"
+)
+
+// nkk: Every run also writes Playwright's JSON report, JUnit XML and HTML are rendered from it afterwards
+
+// SetWorkspace moves the execution workspace
+func (t *TestExecutor) SetWorkspace(dir string) {
+	t.Workspace = dir
+}
+
+// Reports returns the store the execution reports are written to
+func (t *TestExecutor) Reports() *report.Store {
+	return report.NewStore(t.Workspace)
+}
+
+// withReport adds the JSON reporter to a run, a failure here only costs the report
+func (t *TestExecutor) withReport(spec processSpec) processSpec {
+	reports := t.Reports()
+	resultsPath, err := reports.ResultsPath(spec.ExecutionID)
+	if err == nil {
+		err = reports.Prepare(spec.ExecutionID)
+	}
+	if err != nil {
+		logger.Warn("execution report disabled", zap.String("execution_id", spec.ExecutionID), zap.Error(err))
+		return spec
+	}
+
+	// nkk: list keeps the console output the stdout scanners look at
+	spec.Args = append(spec.Args, "--reporter=list,json")
+	spec.Env = append(spec.Env, "PLAYWRIGHT_JSON_OUTPUT_NAME="+resultsPath)
+	return spec
+}
+
+// writeReport renders the JUnit and HTML reports once the Playwright process has exited
+func (t *TestExecutor) writeReport(executionId, title string) {
+	_, err := t.Reports().Generate(executionId, report.GenerateOptions{
+		Title:        title,
+		ContainerDir: t.containerWorkspace,
+	})
+	if err != nil {
+		logger.Warn("could not write execution report", zap.String("execution_id", executionId), zap.Error(err))
+	}
+}
+
+// writeSteps sends the pending step counts and stores the step timeline of the run
+func (t *TestExecutor) writeSteps(executionId string, steps *stepTracker) {
+	steps.Close()
+	if err := t.Reports().WriteSteps(executionId, steps.Timelines()); err != nil {
+		logger.Warn("could not write step timeline", zap.String("execution_id", executionId), zap.Error(err))
+	}
+}
+
+// writeConsole stores the console logs of the run and sends their summaries
+func (t *TestExecutor) writeConsole(executionId string, console *consoleTracker) {
+	reports := t.Reports()
+	artifact := ""
+	if err := reports.WriteConsole(executionId, console.Logs()); err != nil {
+		logger.Warn("could not write console logs", zap.String("execution_id", executionId), zap.Error(err))
+	} else if dir, err := reports.Dir(executionId); err == nil {
+		artifact = filepath.Join(dir, report.ConsoleFile)
+	}
+	console.Close(artifact)
+}
+
+// executionDescriber is implemented by bridges that label results with readable names, like the Allure reporter
+type executionDescriber interface {
+	DescribeExecution(executionId, testplanName string, testcaseTitles map[string]string)
+}
+
+// describeExecution passes the plan name and testcase titles on, the bridge calls only carry ids
+func (t *TestExecutor) describeExecution(executionId, testplanName string, testcaseTitles map[string]string) {
+	if describer, ok := t.ExecutionServiceBridge.(executionDescriber); ok {
+		describer.DescribeExecution(executionId, testplanName, testcaseTitles)
+	}
+}
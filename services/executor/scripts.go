@@ -0,0 +1,78 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"agent/models/testcase"
+	"agent/models/testplan"
+)
+
+/*
+nkk: Writing scripts into the execution workspace
+Server driven runs get their scripts written by the dashboard flow, headless
+`agent run` reads them from disk and uses these helpers instead.
+*/
+
+// safeFileName keeps ids usable as file names
+func safeFileName(id string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, id)
+	if name == "" {
+		return "local"
+	}
+	return name
+}
+
+// WriteTestcaseScript writes a testcase as a spec file under <workspace>/tests and returns its path
+func WriteTestcaseScript(workspace string, script *testcase.TestScript) (string, error) {
+	if strings.TrimSpace(script.Script) == "" {
+		return "", fmt.Errorf("testcase %s has an empty script", script.ID)
+	}
+
+	testsDir := filepath.Join(workspace, "tests")
+	if err := os.MkdirAll(testsDir, 0755); err != nil {
+		return "", err
+	}
+
+	scriptPath := filepath.Join(testsDir, fmt.Sprintf("testcase_%s.spec.js", safeFileName(script.ID)))
+	if err := os.WriteFile(scriptPath, []byte(script.Script), 0644); err != nil {
+		return "", err
+	}
+	return scriptPath, nil
+}
+
+// WriteTestPlanScripts writes every plan script into <workspace>/tests/testPlan_<id> and returns the directory
+func WriteTestPlanScripts(workspace string, details *testplan.TestPlanExecutionDetails) (string, error) {
+	if len(details.Scripts) == 0 {
+		return "", fmt.Errorf("test plan %s has no scripts", details.TestPlanId)
+	}
+
+	// nkk: Must match the testMatch from testplan.NewTestLabConfigFromTestPlanConfig
+	testplanDir := filepath.Join(workspace, "tests", "testPlan_"+details.TestPlanId)
+	if err := os.RemoveAll(testplanDir); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(testplanDir, 0755); err != nil {
+		return "", err
+	}
+
+	for i, script := range details.Scripts {
+		if strings.TrimSpace(script.Script) == "" {
+			return "", fmt.Errorf("testcase %s has an empty script", script.TestCaseId)
+		}
+		name := fmt.Sprintf("%03d_%s.js", i+1, safeFileName(script.TestCaseId))
+		if err := os.WriteFile(filepath.Join(testplanDir, name), []byte(script.Script), 0644); err != nil {
+			return "", err
+		}
+	}
+	return testplanDir, nil
+}
@@ -0,0 +1,283 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"agent/config"
+	"agent/logger"
+	"agent/models/executionstep"
+	"agent/services/report"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: The fixture prints one line per finished step
+  APX_STEP {"testcase_id":"...","index":3,"name":"...","action":"click","selector":"//button","status":"passed",...}
+stepTracker keeps the timeline per testcase and reports the step count to the execution service,
+at most once per interval per testcase with the latest count. Close sends what is still pending.
+*/
+
+const (
+	stepLinePrefix            = "APX_STEP "
+	defaultStepUpdateInterval = 2 * time.Second
+)
+
+// StepCountUpdater is the part of the bridge step counts are sent to
+type StepCountUpdater interface {
+	UpdateStepCount(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, body executionstep.ExecutionStep) error
+}
+
+// trackSteps starts the step tracker of a Playwright run
+func (t *TestExecutor) trackSteps(ctx context.Context, orgId, projectId, appId string) *stepTracker {
+	interval := config.GetConfig().TestExecution.StepUpdateInterval
+	return newStepTracker(ctx, t.ExecutionServiceBridge, orgId, projectId, appId, apxconstants.Local, interval)
+}
+
+// parseStepLine reads a fixture step line, other output is ignored
+func parseStepLine(line string) (*report.Step, bool) {
+	idx := strings.Index(line, stepLinePrefix)
+	if idx == -1 {
+		return nil, false
+	}
+	var step report.Step
+	if err := json.Unmarshal([]byte(line[idx+len(stepLinePrefix):]), &step); err != nil {
+		logger.Warn("invalid step line from fixture", zap.String("line", line), zap.Error(err))
+		return nil, false
+	}
+	if step.Status == "" {
+		step.Status = report.StepPassed
+	}
+	if step.Name == "" {
+		step.Name = fmt.Sprintf("Step %d", step.Index)
+	}
+	return &step, true
+}
+
+type stepTracker struct {
+	ctx      context.Context
+	bridge   StepCountUpdater
+	orgId    string
+	project  string
+	appId    string
+	testlab  string
+	interval time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	order     []string
+	timelines map[string]*trackedTimeline
+	closed    bool
+
+	sendMu    sync.Mutex
+	delivered map[string]int
+	inflight  sync.WaitGroup
+}
+
+type trackedTimeline struct {
+	report.StepTimeline
+	last     report.Step // nkk: ids of the testcase the count is sent for
+	count    int
+	sent     int // nkk: highest count handed to send
+	sentAt   time.Time
+	flushing *time.Timer
+}
+
+func newStepTracker(ctx context.Context, bridge StepCountUpdater, orgId, projectId, appId, testlab string, interval time.Duration) *stepTracker {
+	if interval <= 0 {
+		interval = defaultStepUpdateInterval
+	}
+	return &stepTracker{
+		ctx:       ctx,
+		bridge:    bridge,
+		orgId:     orgId,
+		project:   projectId,
+		appId:     appId,
+		testlab:   testlab,
+		interval:  interval,
+		now:       time.Now,
+		timelines: make(map[string]*trackedTimeline),
+		delivered: make(map[string]int),
+	}
+}
+
+// ObserveLine records the step of a fixture output line and reports whether it was one
+func (s *stepTracker) ObserveLine(line string) bool {
+	step, ok := parseStepLine(line)
+	if ok {
+		s.Observe(*step)
+	}
+	return ok
+}
+
+// Observe adds a step to its testcase timeline
+func (s *stepTracker) Observe(step report.Step) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := fmt.Sprintf("%s/%s/%t", step.TestsuiteId, step.TestcaseId, step.IsPreRequisite)
+	timeline, ok := s.timelines[key]
+	if !ok {
+		timeline = &trackedTimeline{StepTimeline: report.StepTimeline{
+			TestcaseId:       step.TestcaseId,
+			TestsuiteId:      step.TestsuiteId,
+			MachineId:        step.MachineId,
+			IsPreRequisite:   step.IsPreRequisite,
+			ParentTestCaseId: step.ParentTestCaseId,
+		}}
+		s.timelines[key] = timeline
+		s.order = append(s.order, key)
+	}
+	timeline.Steps = append(timeline.Steps, step)
+	if step.Status == report.StepFailed {
+		// nkk: The failing step did not finish, it does not count
+		timeline.Failed++
+		return
+	}
+	timeline.last = step
+	timeline.Passed++
+	if step.Index > timeline.count {
+		timeline.count = step.Index
+	}
+
+	if s.closed || timeline.flushing != nil {
+		return
+	}
+	if wait := s.interval - s.now().Sub(timeline.sentAt); wait > 0 {
+		s.inflight.Add(1)
+		timeline.flushing = time.AfterFunc(wait, func() { s.flush(key) })
+		return
+	}
+	if update, ok := s.prepare(key, timeline); ok {
+		// nkk: Never block the output scanner on the network
+		s.inflight.Add(1)
+		go func() {
+			defer s.inflight.Done()
+			s.send(update)
+		}()
+	}
+}
+
+func (s *stepTracker) flush(key string) {
+	defer s.inflight.Done()
+	s.mu.Lock()
+	timeline := s.timelines[key]
+	timeline.flushing = nil
+	update, ok := s.prepare(key, timeline)
+	if s.closed {
+		ok = false
+	}
+	s.mu.Unlock()
+	if ok {
+		s.send(update)
+	}
+}
+
+type stepCountUpdate struct {
+	key  string
+	body executionstep.ExecutionStep
+	n    int
+}
+
+// prepare takes the latest count for sending, called with mu held
+func (s *stepTracker) prepare(key string, timeline *trackedTimeline) (stepCountUpdate, bool) {
+	if timeline.count == timeline.sent {
+		return stepCountUpdate{}, false
+	}
+	step := timeline.last
+	body := executionstep.ExecutionStep{
+		OrgId:            s.orgId,
+		ProjectId:        s.project,
+		AppId:            s.appId,
+		ExecutionId:      step.ExecutionId,
+		TestcaseId:       step.TestcaseId,
+		MachineId:        step.MachineId,
+		TestsuiteId:      step.TestsuiteId,
+		TestplanId:       step.TestplanId,
+		IsAdhoc:          step.IsAdhoc,
+		StepCount:        strconv.Itoa(timeline.count),
+		IsPreRequisite:   step.IsPreRequisite,
+		ParentTestCaseId: step.ParentTestCaseId,
+	}
+	timeline.sent = timeline.count
+	timeline.sentAt = s.now()
+	return stepCountUpdate{key: key, body: body, n: timeline.count}, true
+}
+
+// send calls the bridge outside mu, a count older than one already delivered is dropped
+func (s *stepTracker) send(update stepCountUpdate) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	if update.n <= s.delivered[update.key] {
+		return
+	}
+	s.delivered[update.key] = update.n
+
+	body := update.body
+	if err := s.bridge.UpdateStepCount(s.ctx, s.orgId, s.project, s.appId, s.testlab, body.ExecutionId, body); err != nil {
+		logger.Warn("could not update step count",
+			zap.String("execution_id", body.ExecutionId),
+			zap.String("testcase_id", body.TestcaseId),
+			zap.Error(err))
+	}
+}
+
+// Count returns the number of finished steps of a testcase
+func (s *stepTracker) Count(testsuiteId, testcaseId string, isPreRequisite bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if timeline, ok := s.timelines[fmt.Sprintf("%s/%s/%t", testsuiteId, testcaseId, isPreRequisite)]; ok {
+		return timeline.count
+	}
+	return 0
+}
+
+// Close sends the counts still waiting for their interval and waits for the calls in flight,
+// later steps are only kept in the timeline
+func (s *stepTracker) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	var updates []stepCountUpdate
+	for _, key := range s.order {
+		timeline := s.timelines[key]
+		if timeline.flushing != nil {
+			if timeline.flushing.Stop() {
+				s.inflight.Done()
+			}
+			timeline.flushing = nil
+		}
+		if update, ok := s.prepare(key, timeline); ok {
+			updates = append(updates, update)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, update := range updates {
+		s.send(update)
+	}
+	s.inflight.Wait()
+}
+
+// Timelines returns the step timelines in the order testcases reported their first step
+func (s *stepTracker) Timelines() []report.StepTimeline {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	timelines := make([]report.StepTimeline, 0, len(s.order))
+	for _, key := range s.order {
+		timeline := s.timelines[key].StepTimeline
+		timeline.Steps = append([]report.Step(nil), timeline.Steps...)
+		timelines = append(timelines, timeline)
+	}
+	return timelines
+}
@@ -0,0 +1,93 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/models/executionstep"
+	"agent/services/report"
+)
+
+/*
+nkk: Unit tests for the fixture step tracking
+Step counts go to a recording bridge, the clock is fixed so only Close flushes
+*/
+
+type recordingStepBridge struct {
+	mu    sync.Mutex
+	steps []executionstep.ExecutionStep
+}
+
+func (b *recordingStepBridge) UpdateStepCount(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, body executionstep.ExecutionStep) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.steps = append(b.steps, body)
+	return nil
+}
+
+func TestParseStepLine(t *testing.T) {
+	step, ok := parseStepLine(`  [chromium] › APX_STEP {"execution_id":"exec-1","testcase_id":"tc-1","index":2,"action":"click","selector":"//button","duration_ms":120}`)
+	require.True(t, ok, "reporter prefixes are skipped")
+	assert.Equal(t, "tc-1", step.TestcaseId)
+	assert.Equal(t, "Step 2", step.Name)
+	assert.Equal(t, report.StepPassed, step.Status)
+	assert.Equal(t, "//button", step.Selector)
+	assert.Equal(t, int64(120), step.DurationMs)
+
+	_, ok = parseStepLine("m-1 step_count 2")
+	assert.False(t, ok)
+	_, ok = parseStepLine("APX_STEP {not json")
+	assert.False(t, ok)
+}
+
+func TestStepTrackerRateLimitsCounts(t *testing.T) {
+	bridge := &recordingStepBridge{}
+	tracker := newStepTracker(context.Background(), bridge, "org", "project", "app", "local", time.Hour)
+	now := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	line := func(index string) string {
+		return `APX_STEP {"execution_id":"exec-1","testplan_id":"tp-1","testsuite_id":"ts-1","testcase_id":"tc-1","index":` + index + `}`
+	}
+	assert.True(t, tracker.ObserveLine(line("1")))
+	require.Eventually(t, func() bool {
+		bridge.mu.Lock()
+		defer bridge.mu.Unlock()
+		return len(bridge.steps) == 1
+	}, time.Second, time.Millisecond, "the first count goes out at once")
+	assert.True(t, tracker.ObserveLine(line("2")))
+	assert.True(t, tracker.ObserveLine(line("3")))
+	tracker.Observe(report.Step{ExecutionId: "exec-1", TestsuiteId: "ts-1", TestcaseId: "tc-1", Index: 4, Status: report.StepFailed, Error: "element not found"})
+	assert.False(t, tracker.ObserveLine("Running 1 test using 1 worker"))
+	tracker.Close()
+
+	require.Len(t, bridge.steps, 2, "later counts wait for the interval and only the latest is sent")
+	assert.Equal(t, "1", bridge.steps[0].StepCount)
+	assert.Equal(t, "3", bridge.steps[1].StepCount, "the failed step is not counted")
+	assert.Equal(t, "org", bridge.steps[1].OrgId)
+	assert.Equal(t, "tp-1", bridge.steps[1].TestplanId)
+	assert.Equal(t, 3, tracker.Count("ts-1", "tc-1", false))
+
+	timelines := tracker.Timelines()
+	require.Len(t, timelines, 1)
+	assert.Equal(t, 3, timelines[0].Passed)
+	assert.Equal(t, 1, timelines[0].Failed)
+	assert.Len(t, timelines[0].Steps, 4)
+
+	store := report.NewStore(t.TempDir())
+	require.NoError(t, store.WriteSteps("exec-1", timelines))
+	dir, _ := store.Dir("exec-1")
+	data, err := os.ReadFile(filepath.Join(dir, report.StepsFile))
+	require.NoError(t, err)
+	var stored report.StepsReport
+	require.NoError(t, json.Unmarshal(data, &stored))
+	assert.Equal(t, "element not found", stored.Testcases[0].Steps[3].Error)
+}
@@ -4,14 +4,14 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 
 	"go.uber.org/zap"
 
@@ -24,35 +24,55 @@ import (
 	"agent/models/testlab"
 	"agent/models/testplan"
 	executionbridge "agent/services/execution_bridge"
+	"agent/services/playwright_runtime"
 	apxconstants "agent/utils/constants"
-	browser_pool "agent/services/browser_pool" // nkk: added import for BrowserPoolManager
-	// rationale: Required to reference the BrowserPoolManager type for optimized browser reuse.
+	browser_pool "agent/services/browser_pool" // nkk: added import for BrowserPool
+	// rationale: Required to reference the BrowserPool interface for optimized browser reuse.
 )
 
+// ErrExecutionsPaused is returned while the agent refuses new executions ahead of a restart
+var ErrExecutionsPaused = errors.New("agent is restarting, executions are paused")
+
 type ResultService interface {
 	GetLamdattestResults(ctx context.Context, appId string) error
 }
 
 type TestExecutor struct {
 	commandChannel         chan map[string]interface{}
-	ExecutionServiceBridge *executionbridge.ExecutionServiceBridge
-	browserPool            *browser_pool.BrowserPoolManager // nkk: added BrowserPoolManager reference
+	launcher               processLauncher // nkk: host process by default, container for DockerTestRunner
+	ExecutionServiceBridge executionbridge.ExecutionBridge // nkk: ExecutionServiceBridge, or LocalSink for headless runs
+	browserPool            browser_pool.BrowserPool // nkk: Docker or Playwright backend, see DynamicConfig.BrowserPool
 	// rationale: Required to acquire and release pre-warmed browser instances for optimized execution.
+	PlaywrightRuntimes *playwright_runtime.Manager // nkk: optional, selects the Playwright version per execution
+	running            atomic.Int64                // nkk: in-flight Execute/ExecuteTestPlan calls, lets self-update restart when idle
+	startMu            sync.Mutex                  // nkk: guards paused against executions starting
+	paused             bool
+	Workspace          string                      // nkk: execution workspace, Playwright runs inside it
+	containerWorkspace string                      // nkk: where the workspace is mounted when Playwright runs in a container
 }
 
-func NewTestExecutor(executionsvcbridge *executionbridge.ExecutionServiceBridge, pool *browser_pool.BrowserPoolManager) *TestExecutor {
+// defaultWorkspace is the execution workspace relative to the agent's working directory
+const defaultWorkspace = "executions"
+
+func NewTestExecutor(executionsvcbridge executionbridge.ExecutionBridge, pool browser_pool.BrowserPool) *TestExecutor {
 	executor := &TestExecutor{
 		ExecutionServiceBridge: executionsvcbridge,
-		browserPool:            pool, // nkk: initialize BrowserPoolManager
-		// rationale: Pass in a pre-initialized BrowserPoolManager to enable optimized browser reuse.
+		browserPool:            pool, // nkk: initialize BrowserPool
+		// rationale: Pass in a pre-initialized BrowserPool to enable optimized browser reuse.
 	}
 
 	executor.commandChannel = make(chan map[string]interface{})
+	executor.launcher = launchHostProcess
+	executor.Workspace = defaultWorkspace
 
 	return executor
 }
 
 func (t *TestExecutor) Execute(ctx context.Context, orgId, projectId, appId, createdBy string, testcase *testcase.TestScript, config testlab.TestLabConfig, executionId string, executionsMap map[string]*localexecution_model.LocalExecution) error {
+	if err := t.startExecution(); err != nil {
+		return err
+	}
+	defer t.running.Add(-1)
 
 	localTestConfig := config.(*session.Config)
 	localTestConfig = session.SetLocalTestcaseBrowsers(localTestConfig)
@@ -79,13 +99,19 @@ func (t *TestExecutor) Execute(ctx context.Context, orgId, projectId, appId, cre
 		Session.PreRequisiteResult = preRequisiteSession
 	}
 
+	titles := map[string]string{testcase.ID: testcase.Title}
+	if testcase.Precondition != nil {
+		titles[testcase.Precondition.ID] = testcase.Precondition.Title
+	}
+	t.describeExecution(executionId, "", titles)
+
 	err := t.ExecutionServiceBridge.SaveSession(ctx, orgId, projectId, appId, apxconstants.Local, *Session)
 	if err != nil {
 		logger.Error("could not save session", err)
 		return err
 	}
 
-	path := filepath.Join(".", "executions")
+	path := t.Workspace
 
 	projectsPath := filepath.Join(path, "projects.json")
 
@@ -115,50 +141,56 @@ func (t *TestExecutor) Execute(ctx context.Context, orgId, projectId, appId, cre
 	// Updated to use BrowserPool for reusing pre-warmed browser instances, reducing startup time from ~30s to ~0.5s.
 
 	// nkk: NEW IMPLEMENTATION
-	browserInstance, err := t.browserPool.AcquireBrowser(ctx, localTestConfig.Browser, "latest")
-	if err != nil {
-		logger.Error("could not acquire browser from pool", err)
-		return err
-	}
-	defer t.browserPool.ReleaseBrowser(localTestConfig.Browser, "latest", browserInstance)
-
-	cmd := exec.CommandContext(commandContext, "npx", "playwright", "test", "--browser", browserInstance.BrowserType)
-	cmd.Dir = "executions"
-
-	// Create pipes to capture stdout and stderr
-	stdoutPipe, err := cmd.StdoutPipe()
-	if err != nil {
-		logger.Error("could not get stdout pipe", err)
-		status.Status = apxconstants.Failed
-		status.Message = "failed to get stdout pipe"
-		t.ExecutionServiceBridge.SaveSessionStatus(ctx, status)
-		return err
+	// Isolated runners bring their own browsers, so the pool is optional
+	if t.browserPool != nil {
+		lease, err := t.browserPool.Acquire(ctx, localTestConfig.Browser, "latest")
+		if err != nil {
+			logger.Error("could not acquire browser from pool", err)
+			return err
+		}
+		defer t.browserPool.Release(lease)
 	}
 
-	stderrPipe, err := cmd.StderrPipe()
+	runtime, releaseRuntime, err := t.acquireRuntime(ctx, localTestConfig.PlaywrightVersion)
 	if err != nil {
-		logger.Error("could not get stderr pipe", err)
+		logger.Error("could not prepare playwright runtime", err)
 		status.Status = apxconstants.Failed
-		status.Message = "failed to get stderr pipe"
+		status.Message = "failed to prepare playwright " + localTestConfig.PlaywrightVersion
 		t.ExecutionServiceBridge.SaveSessionStatus(ctx, status)
 		return err
 	}
+	defer releaseRuntime()
 
 	// Start the command
 	logger.Info("starting testcase execution...", zap.String("testcase_id", testcase.ID), zap.String("execution_id", executionId))
 
-	if err := cmd.Start(); err != nil {
+	os.Setenv("WORKERS", "1")
+	spec := t.withReport(processSpec{ExecutionID: executionId, Dir: t.Workspace, Args: []string{"--browser", localTestConfig.Browser}, Runtime: runtime})
+	proc, err := t.launcher(commandContext, spec)
+	if err != nil {
 		logger.Error("could not start command: ", err)
+		status.Status = apxconstants.Failed
+		status.Message = "failed to start test process"
+		t.ExecutionServiceBridge.SaveSessionStatus(ctx, status)
 		return err
 	}
 
+	steps := t.trackSteps(ctx, orgId, projectId, appId)
+	console := t.trackConsole(ctx, orgId, projectId, appId, *localTestConfig)
+	stdoutDone := make(chan struct{})
+
 	// Goroutine to print stdout
 	go func() {
-		scanner := bufio.NewScanner(stdoutPipe)
+		defer close(stdoutDone)
+		scanner := bufio.NewScanner(proc.Stdout())
 		for scanner.Scan() {
 			line := scanner.Text()
 			// logger.Info("stdout", zap.String("output", line))
 			fmt.Println(line)
+			// nkk: Step and console lines carry errors of the page or a failed step, the status update comes from the fixture
+			if steps.ObserveLine(line) || console.ObserveLine(line) {
+				continue
+			}
 			mx.Lock()
 			if strings.Contains(strings.ToLower(line), "error") {
 				status.Status = apxconstants.Failed
@@ -175,7 +207,7 @@ func (t *TestExecutor) Execute(ctx context.Context, orgId, projectId, appId, cre
 
 	// Goroutine to print stderr
 	go func() {
-		scanner := bufio.NewScanner(stderrPipe)
+		scanner := bufio.NewScanner(proc.Stderr())
 		for scanner.Scan() {
 			line := scanner.Text()
 			logger.Info("stderr", zap.String("output", line))
@@ -197,7 +229,7 @@ func (t *TestExecutor) Execute(ctx context.Context, orgId, projectId, appId, cre
 				logger.Info("Sending SIGINT to stop the test case execution", zap.String("execution_id", executionId))
 
 				// Send SIGINT (Ctrl+C) to the process to simulate graceful shutdown
-				if err := cmd.Process.Signal(syscall.SIGINT); err != nil {
+				if err := proc.Interrupt(); err != nil {
 					logger.Error("failed to send SIGINT", err)
 				} else {
 					logger.Info("SIGINT sent successfully")
@@ -206,12 +238,18 @@ func (t *TestExecutor) Execute(ctx context.Context, orgId, projectId, appId, cre
 			}
 		}
 	}()
-	os.Setenv("WORKERS", "1")
 	// Wait for the command to finish
-	err = cmd.Wait()
+	err = proc.Wait()
+	<-stdoutDone
+	t.writeSteps(executionId, steps)
+	t.writeConsole(executionId, console)
+	t.writeReport(executionId, testcase.Title)
+	mx.Lock()
+	status.StepCount = steps.Count("", testcase.ID, false)
+	mx.Unlock()
 	if err != nil {
 		// Check if the error is due to the process being killed
-		if err.Error() == "signal: killed" || err.Error() == "signal: interrupt" {
+		if isInterrupted(err) {
 			logger.Info("Command was interrupted or killed", zap.String("execution_id", executionId))
 			status.Status = apxconstants.Stopped
 			status.Message = "User Stopped Session"
@@ -257,6 +295,11 @@ func (t *TestExecutor) InitializeSession(ctx context.Context, testCase testplan.
 }
 
 func (t *TestExecutor) ExecuteTestPlan(createdBy, executionId, testplanId string, config testlab.TestLabConfig, details *testplan.TestPlanExecutionDetails, executionsMap map[string]*localexecution_model.LocalExecution) error {
+	if err := t.startExecution(); err != nil {
+		return err
+	}
+	defer t.running.Add(-1)
+
 	status := executionstatus.ExecutionStatus{
 		OrgId:          details.OrgId,
 		ProjectId:      details.ProjectId,
@@ -277,6 +320,7 @@ func (t *TestExecutor) ExecuteTestPlan(createdBy, executionId, testplanId string
 	var data map[string]interface{}
 
 	logger.Info("starting local testplan sessions", zap.String("execution_id", executionId), zap.String("testplan_id", testplanId))
+	t.describeExecution(executionId, details.TestPlanName, nil)
 	ctx := context.Background()
 	var wg sync.WaitGroup
 	for _, localTestConfig := range localTestConfigs.Configs {
@@ -285,7 +329,7 @@ func (t *TestExecutor) ExecuteTestPlan(createdBy, executionId, testplanId string
 	wg.Wait()
 	status.TestcaseId = ""
 	status.TestsuiteId = ""
-	path := filepath.Join(".", "executions")
+	path := t.Workspace
 
 	projectsPath := filepath.Join(path, "projects.json")
 
@@ -370,43 +414,44 @@ func (t *TestExecutor) ExecuteTestPlan(createdBy, executionId, testplanId string
 	commandContext, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	cmd := exec.CommandContext(commandContext, "npx", "playwright", "test")
-	cmd.Dir = "executions" // Set working directory
-
-	// Create pipes to capture stdout and stderr
-	stdoutPipe, err := cmd.StdoutPipe()
-	if err != nil {
-		logger.Error("could not get stdout pipe", err)
-		status.Status = apxconstants.Failed
-		status.Message = "failed to get stdout pipe"
-		t.ExecutionServiceBridge.SaveSessionStatus(ctx, status)
-		return err
-	}
-
-	stderrPipe, err := cmd.StderrPipe()
+	runtime, releaseRuntime, err := t.acquireRuntime(ctx, details.PlaywrightVersion)
 	if err != nil {
-		logger.Error("could not get stderr pipe", err)
+		logger.Error("could not prepare playwright runtime", err)
 		status.Status = apxconstants.Failed
-		status.Message = "failed to get stderr pipe"
+		status.Message = "failed to prepare playwright " + details.PlaywrightVersion
 		t.ExecutionServiceBridge.SaveSessionStatus(ctx, status)
 		return err
 	}
+	defer releaseRuntime()
 
 	// Start the command
 	logger.Info("starting testplan execution...", zap.String("testplan_id", testplanId), zap.String("execution_id", executionId))
 
-	if err := cmd.Start(); err != nil {
+	spec := t.withReport(processSpec{ExecutionID: executionId, Dir: t.Workspace, Runtime: runtime})
+	proc, err := t.launcher(commandContext, spec)
+	if err != nil {
 		logger.Error("could not start command: ", err)
+		status.Status = apxconstants.Failed
+		status.Message = "failed to start test process"
+		t.ExecutionServiceBridge.SaveSessionStatus(ctx, status)
 		return err
 	}
 
+	steps := t.trackSteps(ctx, details.OrgId, details.ProjectId, details.AppId)
+	console := t.trackConsole(ctx, details.OrgId, details.ProjectId, details.AppId, localTestConfigs.Configs...)
+	stdoutDone := make(chan struct{})
+
 	// Goroutine to print stdout
 	go func() {
-		scanner := bufio.NewScanner(stdoutPipe)
+		defer close(stdoutDone)
+		scanner := bufio.NewScanner(proc.Stdout())
 		for scanner.Scan() {
 			line := scanner.Text()
 			logger.Info("stdout", zap.String("output", line))
 			// fmt.Println(line)
+			if !steps.ObserveLine(line) {
+				console.ObserveLine(line)
+			}
 		}
 		if err := scanner.Err(); err != nil {
 			logger.Error("error reading stdout", err)
@@ -415,7 +460,7 @@ func (t *TestExecutor) ExecuteTestPlan(createdBy, executionId, testplanId string
 
 	// Goroutine to print stderr
 	go func() {
-		scanner := bufio.NewScanner(stderrPipe)
+		scanner := bufio.NewScanner(proc.Stderr())
 		for scanner.Scan() {
 			line := scanner.Text()
 			logger.Info("stderr", zap.String("output", line))
@@ -439,7 +484,7 @@ func (t *TestExecutor) ExecuteTestPlan(createdBy, executionId, testplanId string
 				logger.Info("Sending SIGINT to stop the test plan execution", zap.String("execution_id", executionId))
 
 				// Send SIGINT (Ctrl+C) to the process to simulate graceful shutdown
-				if err := cmd.Process.Signal(syscall.SIGINT); err != nil {
+				if err := proc.Interrupt(); err != nil {
 					logger.Error("failed to send SIGINT", err)
 				} else {
 					logger.Info("SIGINT sent successfully")
@@ -450,10 +495,14 @@ func (t *TestExecutor) ExecuteTestPlan(createdBy, executionId, testplanId string
 	}()
 
 	// Wait for the command to finish
-	err = cmd.Wait()
+	err = proc.Wait()
+	<-stdoutDone
+	t.writeSteps(executionId, steps)
+	t.writeConsole(executionId, console)
+	t.writeReport(executionId, details.RunName)
 	if err != nil {
 		// Check if the error is due to the process being killed
-		if err.Error() == "signal: killed" || err.Error() == "signal: interrupt" {
+		if isInterrupted(err) {
 			logger.Info("Command was interrupted or killed", zap.String("execution_id", executionId))
 			if data["status"] == apxconstants.NotExecuted {
 				status.Status = apxconstants.NotExecuted
@@ -517,6 +566,47 @@ func (t *TestExecutor) ExecuteTestPlan(createdBy, executionId, testplanId string
 	}
 	return nil
 }
+
+// RunningExecutions reports how many testcase or test plan executions are in flight
+func (t *TestExecutor) RunningExecutions() int {
+	return int(t.running.Load())
+}
+
+// PauseExecutions makes Execute and ExecuteTestPlan fail with ErrExecutionsPaused until resume is called
+func (t *TestExecutor) PauseExecutions() (resume func()) {
+	t.startMu.Lock()
+	t.paused = true
+	t.startMu.Unlock()
+
+	return func() {
+		t.startMu.Lock()
+		t.paused = false
+		t.startMu.Unlock()
+	}
+}
+
+// startExecution counts an execution in unless executions are paused
+func (t *TestExecutor) startExecution() error {
+	t.startMu.Lock()
+	defer t.startMu.Unlock()
+	if t.paused {
+		return ErrExecutionsPaused
+	}
+	t.running.Add(1)
+	return nil
+}
+
+// acquireRuntime resolves the Playwright runtime for an execution, installing it on demand
+func (t *TestExecutor) acquireRuntime(ctx context.Context, version string) (*playwright_runtime.Runtime, func(), error) {
+	if t.PlaywrightRuntimes == nil {
+		if version != "" {
+			logger.Warn("playwright version requested but runtime management is disabled", zap.String("version", version))
+		}
+		return nil, func() {}, nil
+	}
+	return t.PlaywrightRuntimes.Acquire(ctx, version)
+}
+
 func (t *TestExecutor) StopTestCaseExecution(testcaseId, executionId string) {
 	data := make(map[string]interface{})
 
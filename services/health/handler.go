@@ -13,6 +13,7 @@ import (
 	"agent/logger"
 	"agent/services/billing"
 	"agent/services/browser_pool"
+	executionbridge "agent/services/execution_bridge"
 	"agent/services/geo"
 	"agent/services/recorder"
 	"agent/services/tenant"
@@ -37,12 +38,13 @@ type ServiceHealth struct {
 }
 
 type HealthHandler struct {
-	browserPool     *browser_pool.BrowserPoolManager
+	browserPool     browser_pool.BrowserPool
 	tunnelService   *tunnel.TunnelService
 	tenantManager   *tenant.Manager
 	billingService  *billing.Service
 	geoRouter       *geo.Router
 	sessionRecorder *recorder.SessionRecorder
+	outbox          *executionbridge.Outbox
 
 	mu              sync.RWMutex
 	serviceStatuses map[string]*ServiceHealth
@@ -50,7 +52,7 @@ type HealthHandler struct {
 
 // NewHealthHandler creates a new health handler
 func NewHealthHandler(
-	browserPool *browser_pool.BrowserPoolManager,
+	browserPool browser_pool.BrowserPool,
 	tunnelService *tunnel.TunnelService,
 	tenantManager *tenant.Manager,
 	billingService *billing.Service,
@@ -68,6 +70,11 @@ func NewHealthHandler(
 	}
 }
 
+// SetOutbox adds the execution service outbox to the checks
+func (h *HealthHandler) SetOutbox(outbox *executionbridge.Outbox) {
+	h.outbox = outbox
+}
+
 // ServeHTTP handles health check requests
 func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// nkk: Support both simple and detailed health checks
@@ -134,6 +141,7 @@ func (h *HealthHandler) checkAllServices(ctx context.Context) bool {
 		h.checkBillingService,
 		h.checkGeoRouter,
 		h.checkSessionRecorder,
+		h.checkOutbox,
 	}
 
 	var wg sync.WaitGroup
@@ -166,8 +174,8 @@ func (h *HealthHandler) checkAllServices(ctx context.Context) bool {
 func (h *HealthHandler) checkAllServicesDetailed(ctx context.Context) []ServiceHealth {
 	// nkk: Collect detailed metrics from each service
 	var wg sync.WaitGroup
-	statuses := make([]ServiceHealth, 0, 6)
-	statusChan := make(chan ServiceHealth, 6)
+	statuses := make([]ServiceHealth, 0, 7)
+	statusChan := make(chan ServiceHealth, 7)
 
 	services := []struct {
 		name  string
@@ -179,6 +187,7 @@ func (h *HealthHandler) checkAllServicesDetailed(ctx context.Context) []ServiceH
 		{"billing_service", h.checkBillingServiceDetailed},
 		{"geo_router", h.checkGeoRouterDetailed},
 		{"session_recorder", h.checkSessionRecorderDetailed},
+		{"execution_outbox", h.checkOutboxDetailed},
 	}
 
 	for _, svc := range services {
@@ -216,8 +225,7 @@ func (h *HealthHandler) checkBrowserPool(ctx context.Context) bool {
 	if h.browserPool == nil {
 		return false
 	}
-	stats := h.browserPool.GetPoolStats()
-	return len(stats) > 0
+	return h.browserPool.Health(ctx) == nil
 }
 
 func (h *HealthHandler) checkBrowserPoolDetailed(ctx context.Context) ServiceHealth {
@@ -227,17 +235,13 @@ func (h *HealthHandler) checkBrowserPoolDetailed(ctx context.Context) ServiceHea
 		return status
 	}
 
-	stats := h.browserPool.GetPoolStats()
+	stats := h.browserPool.Stats()
 	totalAvailable := 0
 	totalInUse := 0
 
 	for _, browserStats := range stats {
-		if available, ok := browserStats["available"].(int); ok {
-			totalAvailable += available
-		}
-		if inUse, ok := browserStats["in_use"].(int); ok {
-			totalInUse += inUse
-		}
+		totalAvailable += browserStats.Available
+		totalInUse += browserStats.InUse
 	}
 
 	status.Details = map[string]interface{}{
@@ -246,10 +250,14 @@ func (h *HealthHandler) checkBrowserPoolDetailed(ctx context.Context) ServiceHea
 		"pools":           stats,
 	}
 
+	if err := h.browserPool.Health(ctx); err != nil {
+		status.Details["error"] = err.Error()
+		return status
+	}
+	// nkk: A reachable pool with nothing warm still starts browsers on demand, only slower
+	status.Status = "degraded"
 	if totalAvailable > 0 {
 		status.Status = "healthy"
-	} else if totalInUse > 0 {
-		status.Status = "degraded"
 	}
 
 	return status
@@ -347,6 +355,37 @@ func (h *HealthHandler) checkSessionRecorderDetailed(ctx context.Context) Servic
 	return status
 }
 
+func (h *HealthHandler) checkOutbox(ctx context.Context) bool {
+	// nkk: Undelivered writes are retried, they never make the agent unavailable
+	return true
+}
+
+func (h *HealthHandler) checkOutboxDetailed(ctx context.Context) ServiceHealth {
+	status := ServiceHealth{Status: "healthy"}
+
+	if h.outbox == nil {
+		return status
+	}
+
+	stats := h.outbox.Stats()
+	status.Details = map[string]interface{}{
+		"pending":   stats.Pending,
+		"failed":    stats.Failed,
+		"retrying":  stats.Retrying,
+		"delivered": stats.Delivered,
+	}
+	if !stats.OldestPending.IsZero() {
+		status.Details["oldest_pending_seconds"] = int64(time.Since(stats.OldestPending).Seconds())
+	}
+
+	// nkk: Degraded while the execution service does not take writes or some were dropped
+	if stats.Retrying > 0 || stats.Failed > 0 {
+		status.Status = "degraded"
+	}
+
+	return status
+}
+
 // getOverallStatus determines overall system health
 func (h *HealthHandler) getOverallStatus(statuses []ServiceHealth) string {
 	// nkk: System is healthy only if all services are healthy
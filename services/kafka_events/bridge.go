@@ -0,0 +1,210 @@
+package kafkaevents
+
+import (
+	"context"
+	"strconv"
+
+	"go.uber.org/zap"
+
+	"agent/logger"
+	"agent/models/executionstatus"
+	"agent/models/executionstep"
+	"agent/models/runresult"
+	"agent/models/screenshot"
+	"agent/models/session"
+	"agent/models/uploadvideo"
+	executionbridge "agent/services/execution_bridge"
+)
+
+// Bridge forwards every call to the wrapped bridge and publishes the lifecycle events of the calls it accepted
+type Bridge struct {
+	executionbridge.ExecutionBridge
+	Publisher *Publisher
+}
+
+// NewBridge wraps bridge so its calls are also published as events
+func NewBridge(bridge executionbridge.ExecutionBridge, publisher *Publisher) *Bridge {
+	return &Bridge{
+		ExecutionBridge: bridge,
+		Publisher:       publisher,
+	}
+}
+
+func (b *Bridge) SaveSession(ctx context.Context, orgId string, projectId string, appId string, testlab string, sess session.Session) error {
+	if err := b.ExecutionBridge.SaveSession(ctx, orgId, projectId, appId, testlab, sess); err != nil {
+		return err
+	}
+	event := sessionEvent(SessionCreated, sess)
+	event.Data = SessionData{
+		Testlab:        testlab,
+		Name:           sess.Name,
+		RunName:        sess.RunName,
+		Status:         sess.Status,
+		Browser:        sess.Browser,
+		BrowserVersion: sess.BrowserVersion,
+		OS:             sess.OS,
+		Resolution:     sess.Resolution,
+	}
+	b.publish(event)
+	return nil
+}
+
+func (b *Bridge) SaveSessionStatus(ctx context.Context, status executionstatus.ExecutionStatus) error {
+	if err := b.ExecutionBridge.SaveSessionStatus(ctx, status); err != nil {
+		return err
+	}
+	b.publish(Event{
+		Type:           SessionStatusChanged,
+		ExecutionId:    status.ExecutionId,
+		OrgId:          status.OrgId,
+		ProjectId:      status.ProjectId,
+		AppId:          status.AppId,
+		TestplanId:     status.TestplanId,
+		TestsuiteId:    status.TestsuiteId,
+		TestcaseId:     status.TestcaseId,
+		MachineId:      status.MachineId,
+		IsAdhoc:        status.IsAdhoc,
+		IsPreRequisite: status.IsPreRequisite,
+		Data:           StatusData{Status: status.Status, Message: status.Message, StepCount: status.StepCount},
+	})
+	return nil
+}
+
+func (b *Bridge) UpdateStepCount(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, body executionstep.ExecutionStep) error {
+	if err := b.ExecutionBridge.UpdateStepCount(ctx, orgId, projectId, appId, testlab, executionId, body); err != nil {
+		return err
+	}
+	// nkk: The step count travels as a string, a malformed one is published as 0
+	stepCount, _ := strconv.Atoi(body.StepCount)
+	b.publish(Event{
+		Type:           StepCountUpdated,
+		ExecutionId:    executionId,
+		OrgId:          orgId,
+		ProjectId:      projectId,
+		AppId:          appId,
+		TestplanId:     body.TestplanId,
+		TestsuiteId:    body.TestsuiteId,
+		TestcaseId:     body.TestcaseId,
+		MachineId:      body.MachineId,
+		IsAdhoc:        body.IsAdhoc,
+		IsPreRequisite: body.IsPreRequisite,
+		Data:           StepCountData{StepCount: stepCount},
+	})
+	return nil
+}
+
+func (b *Bridge) UploadScreenshots(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, request screenshot.UploadScreenshotRequest) error {
+	if err := b.ExecutionBridge.UploadScreenshots(ctx, orgId, projectId, appId, testlab, executionId, request); err != nil {
+		return err
+	}
+	b.publish(Event{
+		Type:           ArtifactUploaded,
+		ExecutionId:    executionId,
+		OrgId:          orgId,
+		ProjectId:      projectId,
+		AppId:          appId,
+		TestplanId:     request.TestplanId,
+		TestsuiteId:    request.TestsuiteId,
+		TestcaseId:     request.TestcaseId,
+		MachineId:      request.MachineId,
+		IsAdhoc:        request.IsAdhoc,
+		IsPreRequisite: request.IsPreRequisite,
+		Data:           ArtifactData{Kind: ArtifactScreenshots},
+	})
+	return nil
+}
+
+func (b *Bridge) TakeScreenshot(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, request screenshot.TakeScreenshot) error {
+	if err := b.ExecutionBridge.TakeScreenshot(ctx, orgId, projectId, appId, testlab, executionId, request); err != nil {
+		return err
+	}
+	b.publish(Event{
+		Type:        ArtifactUploaded,
+		ExecutionId: executionId,
+		OrgId:       orgId,
+		ProjectId:   projectId,
+		AppId:       appId,
+		Data:        ArtifactData{Kind: ArtifactScreenshot, Path: request.ScreenshotPath},
+	})
+	return nil
+}
+
+func (b *Bridge) UploadVideo(ctx context.Context, data uploadvideo.UploadVideo) error {
+	if err := b.ExecutionBridge.UploadVideo(ctx, data); err != nil {
+		return err
+	}
+	b.publish(Event{
+		Type:           ArtifactUploaded,
+		ExecutionId:    data.ExecutionId,
+		OrgId:          data.OrgId,
+		ProjectId:      data.ProjectId,
+		AppId:          data.AppId,
+		TestplanId:     data.TestplanId,
+		TestsuiteId:    data.TestsuiteId,
+		TestcaseId:     data.TestcaseId,
+		MachineId:      data.MachineId,
+		IsAdhoc:        data.IsAdhoc,
+		IsPreRequisite: data.IsPreRequisite,
+		Data:           ArtifactData{Kind: ArtifactVideo},
+	})
+	return nil
+}
+
+func (b *Bridge) CreateLocalAgentNetworkLogs(ctx context.Context, sess session.Session) error {
+	if err := b.ExecutionBridge.CreateLocalAgentNetworkLogs(ctx, sess); err != nil {
+		return err
+	}
+	event := sessionEvent(ArtifactUploaded, sess)
+	event.Data = ArtifactData{Kind: ArtifactNetworkLogs, Path: sess.FileName}
+	b.publish(event)
+	return nil
+}
+
+func (b *Bridge) CreateLocalAgentResults(ctx context.Context, orgId string, projectId string, appId string, executionId string, testPlanId string, runResult *runresult.RunResult, resultType string) error {
+	if err := b.ExecutionBridge.CreateLocalAgentResults(ctx, orgId, projectId, appId, executionId, testPlanId, runResult, resultType); err != nil {
+		return err
+	}
+	data := RunResultData{ResultType: resultType}
+	if runResult != nil {
+		data.Status = runResult.Status
+		data.Title = runResult.TestPlanName
+		data.RunName = runResult.RunName
+		data.Duration = runResult.Duration
+		data.ExecutedTestCasesCount = runResult.ExecutedTestCasesCount
+	}
+	b.publish(Event{
+		Type:        RunResultCreated,
+		ExecutionId: executionId,
+		OrgId:       orgId,
+		ProjectId:   projectId,
+		AppId:       appId,
+		TestplanId:  testPlanId,
+		Data:        data,
+	})
+	return nil
+}
+
+func sessionEvent(eventType EventType, sess session.Session) Event {
+	return Event{
+		Type:           eventType,
+		ExecutionId:    sess.ExecutionId,
+		OrgId:          sess.OrgId,
+		ProjectId:      sess.ProjectId,
+		AppId:          sess.AppId,
+		TestplanId:     sess.TestplanId,
+		TestsuiteId:    sess.TestsuiteId,
+		TestcaseId:     sess.TestcaseId,
+		MachineId:      sess.MachineId,
+		IsAdhoc:        sess.IsAdhoc,
+		IsPreRequisite: sess.IsPreRequisite,
+	}
+}
+
+func (b *Bridge) publish(event Event) {
+	if err := b.Publisher.Publish(event); err != nil {
+		logger.Warn("could not publish execution event",
+			zap.String("type", string(event.Type)),
+			zap.String("execution_id", event.ExecutionId),
+			zap.Error(err))
+	}
+}
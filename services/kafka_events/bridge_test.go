@@ -0,0 +1,57 @@
+package kafkaevents
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/models/executionstatus"
+	"agent/models/executionstep"
+	"agent/models/runresult"
+	"agent/models/session"
+	executionbridge "agent/services/execution_bridge"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: Unit tests for the event publishing bridge
+*/
+
+func TestBridgePublishesAcceptedCalls(t *testing.T) {
+	transport := executionbridge.NewMemoryTransport()
+	writer := &FakeWriter{}
+	publisher := NewPublisher(writer, Options{})
+	bridge := NewBridge(executionbridge.NewExecutionServiceBridgeWithTransport("http://execution-service", transport), publisher)
+	ctx := context.Background()
+
+	require.NoError(t, bridge.SaveSession(ctx, "o", "p", "a", apxconstants.Local, session.Session{
+		OrgId: "o", ProjectId: "p", AppId: "a", ExecutionId: "exec-1", TestcaseId: "tc-1", Browser: "chromium", Status: apxconstants.Running,
+	}))
+	require.NoError(t, bridge.UpdateStepCount(ctx, "o", "p", "a", apxconstants.Local, "exec-1", executionstep.ExecutionStep{ExecutionId: "exec-1", TestcaseId: "tc-1", StepCount: "3"}))
+	transport.Respond("SaveSessionStatus", http.StatusBadRequest, nil)
+	require.Error(t, bridge.SaveSessionStatus(ctx, executionstatus.ExecutionStatus{ExecutionId: "exec-1", Status: apxconstants.Failed}))
+	require.NoError(t, bridge.SaveSessionStatus(ctx, executionstatus.ExecutionStatus{ExecutionId: "exec-1", TestcaseId: "tc-1", Status: apxconstants.Passed}))
+	require.NoError(t, bridge.CreateLocalAgentResults(ctx, "o", "p", "a", "exec-1", "tp-1", &runresult.RunResult{Status: apxconstants.Passed, ExecutedTestCasesCount: 1}, apxconstants.RunResultType))
+	require.NoError(t, publisher.Close(ctx))
+
+	var events []map[string]any
+	for _, message := range writer.Messages() {
+		assert.Equal(t, "exec-1", string(message.Key))
+		var event map[string]any
+		require.NoError(t, json.Unmarshal(message.Value, &event))
+		events = append(events, event)
+	}
+	require.Len(t, events, 4, "the rejected status is not published")
+	assert.Equal(t, "session.created", events[0]["type"])
+	assert.Equal(t, "chromium", events[0]["data"].(map[string]any)["browser"])
+	assert.Equal(t, "session.step_count", events[1]["type"])
+	assert.Equal(t, float64(3), events[1]["data"].(map[string]any)["step_count"])
+	assert.Equal(t, "session.status_changed", events[2]["type"])
+	assert.Equal(t, apxconstants.Passed, events[2]["data"].(map[string]any)["status"])
+	assert.Equal(t, "run.result", events[3]["type"])
+	assert.Equal(t, "tp-1", events[3]["testplan_id"])
+}
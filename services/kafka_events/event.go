@@ -0,0 +1,142 @@
+package kafkaevents
+
+import (
+	_ "embed"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+)
+
+/*
+nkk: Execution lifecycle events, one JSON envelope per message
+  {"schema_version":1,"id":"<uuid>","type":"session.status_changed","occurred_at":"...","execution_id":"...",...,"data":{...}}
+The message key is the execution ID so every event of an execution lands on one partition, in order.
+The ID is fixed when the event is created and survives retries, consumers drop the IDs they have seen.
+Breaking changes to the envelope or a data type bump SchemaVersion, see schema/v1.json.
+*/
+
+// SchemaVersion is the version of the event envelope and its data types
+const SchemaVersion = 1
+
+//go:embed schema/v1.json
+var SchemaV1 []byte
+
+// EventType names what happened
+type EventType string
+
+const (
+	SessionCreated       EventType = "session.created"
+	SessionStatusChanged EventType = "session.status_changed"
+	StepCountUpdated     EventType = "session.step_count"
+	ArtifactUploaded     EventType = "artifact.uploaded"
+	RunResultCreated     EventType = "run.result"
+)
+
+// EventTypes lists the types of the current schema version
+var EventTypes = []EventType{SessionCreated, SessionStatusChanged, StepCountUpdated, ArtifactUploaded, RunResultCreated}
+
+// Message headers, consumers can route on them without decoding the value
+const (
+	HeaderEventId       = "event_id"
+	HeaderEventType     = "event_type"
+	HeaderSchemaVersion = "schema_version"
+)
+
+// Event is the envelope of every message
+type Event struct {
+	SchemaVersion  int       `json:"schema_version"`
+	Id             string    `json:"id"`
+	Type           EventType `json:"type"`
+	OccurredAt     time.Time `json:"occurred_at"`
+	ExecutionId    string    `json:"execution_id"`
+	OrgId          string    `json:"org_id,omitempty"`
+	ProjectId      string    `json:"project_id,omitempty"`
+	AppId          string    `json:"app_id,omitempty"`
+	TestplanId     string    `json:"testplan_id,omitempty"`
+	TestsuiteId    string    `json:"testsuite_id,omitempty"`
+	TestcaseId     string    `json:"testcase_id,omitempty"`
+	MachineId      string    `json:"machine_id,omitempty"`
+	IsAdhoc        bool      `json:"is_adhoc,omitempty"`
+	IsPreRequisite bool      `json:"is_prerequisite,omitempty"`
+	Data           any       `json:"data"`
+}
+
+// SessionData is the data of session.created
+type SessionData struct {
+	Testlab        string `json:"testlab,omitempty"`
+	Name           string `json:"name,omitempty"`
+	RunName        string `json:"run_name,omitempty"`
+	Status         string `json:"status,omitempty"`
+	Browser        string `json:"browser,omitempty"`
+	BrowserVersion string `json:"browser_version,omitempty"`
+	OS             string `json:"os,omitempty"`
+	Resolution     string `json:"resolution,omitempty"`
+}
+
+// StatusData is the data of session.status_changed
+type StatusData struct {
+	Status    string `json:"status"`
+	Message   string `json:"message,omitempty"`
+	StepCount int    `json:"step_count,omitempty"`
+}
+
+// StepCountData is the data of session.step_count
+type StepCountData struct {
+	StepCount int `json:"step_count"`
+}
+
+// Artifact kinds
+const (
+	ArtifactScreenshot  = "screenshot"
+	ArtifactScreenshots = "screenshots"
+	ArtifactVideo       = "video"
+	ArtifactNetworkLogs = "network_logs"
+)
+
+// ArtifactData is the data of artifact.uploaded
+type ArtifactData struct {
+	Kind string `json:"kind"`
+	Path string `json:"path,omitempty"`
+}
+
+// RunResultData is the data of run.result
+type RunResultData struct {
+	ResultType             string `json:"result_type"`
+	Status                 string `json:"status,omitempty"`
+	Title                  string `json:"title,omitempty"`
+	RunName                string `json:"run_name,omitempty"`
+	Duration               *int64 `json:"duration,omitempty"`
+	ExecutedTestCasesCount int    `json:"executed_test_cases_count,omitempty"`
+}
+
+// stamp fills the envelope fields a publisher owns
+func (e *Event) stamp(now time.Time) {
+	e.SchemaVersion = SchemaVersion
+	if e.Id == "" {
+		e.Id = uuid.NewString()
+	}
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = now.UTC()
+	}
+}
+
+// Message encodes the event, keyed by execution ID
+func (e *Event) Message() (kafka.Message, error) {
+	value, err := json.Marshal(e)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	return kafka.Message{
+		Key:   []byte(e.ExecutionId),
+		Value: value,
+		Time:  e.OccurredAt,
+		Headers: []kafka.Header{
+			{Key: HeaderEventId, Value: []byte(e.Id)},
+			{Key: HeaderEventType, Value: []byte(e.Type)},
+			{Key: HeaderSchemaVersion, Value: []byte(strconv.Itoa(e.SchemaVersion))},
+		},
+	}, nil
+}
@@ -0,0 +1,288 @@
+package kafkaevents
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+
+	"agent/logger"
+	"agent/services/monitoring"
+)
+
+/*
+nkk: Publisher queues the events in memory and one worker writes them in batches, so the bridge calls never wait on Kafka.
+at-least-once: a failed batch is retried with the same event IDs until it is written (or MaxAttempts), a full queue holds Publish up to EnqueueTimeout.
+at-most-once: one attempt per batch, a full queue drops the event right away.
+*/
+
+// Delivery modes
+const (
+	AtLeastOnce = "at-least-once"
+	AtMostOnce  = "at-most-once"
+)
+
+const maxBatchSize = 100
+
+// MessageWriter is the part of *kafka.Writer the publisher uses, FakeWriter stands in for it in tests
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Options tune a Publisher, zero values get the defaults
+type Options struct {
+	Delivery       string
+	QueueSize      int
+	MaxAttempts    int // nkk: 0 retries until Close gives up, at-least-once only
+	WriteTimeout   time.Duration
+	EnqueueTimeout time.Duration
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+}
+
+// NewKafkaWriter creates the writer for brokers, messages are partitioned by key
+func NewKafkaWriter(brokers []string, topic string, delivery string, batchTimeout time.Duration) *kafka.Writer {
+	writer := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: batchTimeout,
+		RequiredAcks: kafka.RequireAll,
+	}
+	if delivery == AtMostOnce {
+		writer.RequiredAcks = kafka.RequireOne
+		writer.MaxAttempts = 1
+	}
+	return writer
+}
+
+// Publisher sends events to a MessageWriter
+type Publisher struct {
+	writer MessageWriter
+	opts   Options
+	queue  chan kafka.Message
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+
+	published *monitoring.Metric
+	dropped   *monitoring.Metric
+	retries   *monitoring.Metric
+}
+
+// NewPublisher starts the worker writing to writer
+func NewPublisher(writer MessageWriter, opts Options) *Publisher {
+	if opts.Delivery == "" {
+		opts.Delivery = AtLeastOnce
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1000
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = 10 * time.Second
+	}
+	if opts.EnqueueTimeout <= 0 {
+		opts.EnqueueTimeout = opts.WriteTimeout
+	}
+	if opts.RetryBaseDelay <= 0 {
+		opts.RetryBaseDelay = 500 * time.Millisecond
+	}
+	if opts.RetryMaxDelay < opts.RetryBaseDelay {
+		opts.RetryMaxDelay = 30 * time.Second
+	}
+
+	registry := monitoring.GetRegistry()
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Publisher{
+		writer:    writer,
+		opts:      opts,
+		queue:     make(chan kafka.Message, opts.QueueSize),
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		published: registry.Counter("kafka_events_published_total", "Execution events written to Kafka", map[string]string{}),
+		dropped:   registry.Counter("kafka_events_dropped_total", "Execution events that were not written to Kafka", map[string]string{}),
+		retries:   registry.Counter("kafka_events_retries_total", "Execution event batches that are retried", map[string]string{}),
+	}
+	go p.run()
+	return p
+}
+
+// Publish stamps the event and queues it, the returned error means the event is lost
+func (p *Publisher) Publish(event Event) error {
+	event.stamp(time.Now())
+	msg, err := event.Message()
+	if err != nil {
+		p.dropped.Inc()
+		return fmt.Errorf("failed to encode %s event: %w", event.Type, err)
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		p.dropped.Inc()
+		return fmt.Errorf("event publisher is closed")
+	}
+
+	select {
+	case p.queue <- msg:
+		return nil
+	default:
+	}
+	if p.opts.Delivery == AtLeastOnce {
+		timer := time.NewTimer(p.opts.EnqueueTimeout)
+		defer timer.Stop()
+		select {
+		case p.queue <- msg:
+			return nil
+		case <-timer.C:
+		}
+	}
+	p.dropped.Inc()
+	return fmt.Errorf("event queue is full, dropped %s event %s", event.Type, event.Id)
+}
+
+// Close writes the queued events until ctx is done, then closes the writer
+func (p *Publisher) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		// nkk: Stop retrying, whatever is left is dropped
+		p.cancel()
+		<-p.done
+	}
+	p.cancel()
+	return p.writer.Close()
+}
+
+func (p *Publisher) run() {
+	defer close(p.done)
+	batch := make([]kafka.Message, 0, maxBatchSize)
+	for msg := range p.queue {
+		batch = append(batch[:0], msg)
+	fill:
+		for len(batch) < maxBatchSize {
+			select {
+			case next, ok := <-p.queue:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, next)
+			default:
+				break fill
+			}
+		}
+		p.write(batch)
+	}
+}
+
+// write sends one batch, retrying it as the delivery mode allows
+func (p *Publisher) write(batch []kafka.Message) {
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(p.ctx, p.opts.WriteTimeout)
+		err := p.writer.WriteMessages(ctx, batch...)
+		cancel()
+		if err == nil {
+			p.published.Add(float64(len(batch)))
+			return
+		}
+
+		giveUp := p.opts.Delivery != AtLeastOnce || p.ctx.Err() != nil ||
+			(p.opts.MaxAttempts > 0 && attempt >= p.opts.MaxAttempts)
+		if giveUp {
+			p.dropped.Add(float64(len(batch)))
+			logger.Error("could not publish execution events",
+				zap.Int("events", len(batch)),
+				zap.Int("attempts", attempt),
+				zap.Error(err))
+			return
+		}
+
+		p.retries.Inc()
+		delay := p.backoff(attempt)
+		logger.Warn("execution events will be published again",
+			zap.Int("events", len(batch)),
+			zap.Int("attempt", attempt),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-p.ctx.Done():
+			timer.Stop()
+		}
+	}
+}
+
+func (p *Publisher) backoff(attempt int) time.Duration {
+	delay := p.opts.RetryBaseDelay
+	for i := 1; i < attempt && delay < p.opts.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.opts.RetryMaxDelay {
+		delay = p.opts.RetryMaxDelay
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)*3/10+1))
+}
+
+// FakeWriter records the messages in memory, Fail makes the next writes fail
+type FakeWriter struct {
+	mu       sync.Mutex
+	messages []kafka.Message
+	failures []error
+	closed   bool
+}
+
+// Fail queues an error for the next WriteMessages
+func (w *FakeWriter) Fail(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.failures = append(w.failures, err)
+}
+
+func (w *FakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return fmt.Errorf("writer is closed")
+	}
+	if len(w.failures) > 0 {
+		err := w.failures[0]
+		w.failures = w.failures[1:]
+		return err
+	}
+	w.messages = append(w.messages, msgs...)
+	return nil
+}
+
+func (w *FakeWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	return nil
+}
+
+// Messages returns the messages written so far, in order
+func (w *FakeWriter) Messages() []kafka.Message {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]kafka.Message(nil), w.messages...)
+}
@@ -0,0 +1,75 @@
+package kafkaevents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+nkk: Unit tests for the event publisher
+Retries run with a millisecond backoff so the tests do not wait on the real schedule
+*/
+
+func TestPublisherRetriesWithSameEventIds(t *testing.T) {
+	writer := &FakeWriter{}
+	writer.Fail(errors.New("leader not available"))
+	writer.Fail(errors.New("leader not available"))
+	publisher := NewPublisher(writer, Options{RetryBaseDelay: time.Millisecond, RetryMaxDelay: 5 * time.Millisecond})
+
+	require.NoError(t, publisher.Publish(Event{Type: SessionCreated, ExecutionId: "exec-1", Data: SessionData{Status: "running"}}))
+	require.NoError(t, publisher.Publish(Event{Id: "fixed", Type: SessionStatusChanged, ExecutionId: "exec-1", Data: StatusData{Status: "passed"}}))
+	require.NoError(t, publisher.Close(context.Background()))
+
+	messages := writer.Messages()
+	require.Len(t, messages, 2, "the failed batch is written once it goes through")
+	var first, second Event
+	require.NoError(t, json.Unmarshal(messages[0].Value, &first))
+	require.NoError(t, json.Unmarshal(messages[1].Value, &second))
+	assert.Equal(t, SessionCreated, first.Type)
+	assert.Equal(t, SessionStatusChanged, second.Type)
+	assert.Equal(t, SchemaVersion, first.SchemaVersion)
+	assert.NotEmpty(t, first.Id)
+	assert.False(t, first.OccurredAt.IsZero())
+	assert.Equal(t, "fixed", second.Id)
+	assert.Equal(t, "exec-1", string(messages[0].Key))
+
+	headers := map[string]string{}
+	for _, header := range messages[1].Headers {
+		headers[header.Key] = string(header.Value)
+	}
+	assert.Equal(t, map[string]string{HeaderEventId: "fixed", HeaderEventType: "session.status_changed", HeaderSchemaVersion: "1"}, headers)
+
+	assert.Error(t, publisher.Publish(Event{Type: SessionCreated, ExecutionId: "exec-1"}), "a closed publisher refuses events")
+}
+
+func TestPublisherAtMostOnceDropsFailedBatch(t *testing.T) {
+	writer := &FakeWriter{}
+	writer.Fail(errors.New("leader not available"))
+	publisher := NewPublisher(writer, Options{Delivery: AtMostOnce, RetryBaseDelay: time.Millisecond})
+
+	require.NoError(t, publisher.Publish(Event{Type: StepCountUpdated, ExecutionId: "exec-1", Data: StepCountData{StepCount: 1}}))
+	require.NoError(t, publisher.Close(context.Background()))
+	assert.Empty(t, writer.Messages(), "the failed batch is not written again")
+}
+
+func TestSchemaListsEventTypes(t *testing.T) {
+	var schema struct {
+		Properties struct {
+			SchemaVersion struct {
+				Const int `json:"const"`
+			} `json:"schema_version"`
+			Type struct {
+				Enum []EventType `json:"enum"`
+			} `json:"type"`
+		} `json:"properties"`
+	}
+	require.NoError(t, json.Unmarshal(SchemaV1, &schema))
+	assert.Equal(t, SchemaVersion, schema.Properties.SchemaVersion.Const)
+	assert.ElementsMatch(t, EventTypes, schema.Properties.Type.Enum)
+}
@@ -0,0 +1,762 @@
+package mockserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi"
+	"go.uber.org/zap"
+
+	"agent/errors"
+	apxresp "agent/http/response"
+	"agent/logger"
+	"agent/models/executionstatus"
+	"agent/models/executionstep"
+	"agent/models/localdevice"
+	"agent/models/localexecution"
+	"agent/models/logs"
+	"agent/models/runresult"
+	"agent/models/screenshot"
+	"agent/models/session"
+)
+
+/*
+nkk: Server stands in for the execution service (execution_service_domain) and the autotest server (server_domain)
+so the agent and integration tests can run full flows offline. Point both domains at it.
+- Writes (sessions, statuses, step counts, run results, network logs, screenshots, videos, HARs, warnings, artifact URLs, console logs) are kept in memory,
+  chunked uploads are checked chunk by chunk and against their final SHA-256 but only their hash is kept.
+- Reads come from the fixtures directory, one JSON file per object:
+    <fixtures>/testscripts/<testcase_id>.json
+    <fixtures>/testplans/<testplan_id>.json
+    <fixtures>/execution-details/<testplan_id>.json
+    <fixtures>/environments/<environment_id>.json
+    <fixtures>/integrations/<type>_<name>.json
+    <fixtures>/local-executions/*.json     queued for GET /local-agent/local-executions at startup
+- GET /mock/state shows what was received, DELETE /mock/state resets it, POST /mock/local-executions queues one more.
+*/
+
+// Fixture kinds, the subdirectories of the fixtures directory
+const (
+	FixtureTestScripts      = "testscripts"
+	FixtureTestPlans        = "testplans"
+	FixtureExecutionDetails = "execution-details"
+	FixtureEnvironments     = "environments"
+	FixtureIntegrations     = "integrations"
+	FixtureLocalExecutions  = "local-executions"
+)
+
+const maxBodySize = 64 << 20
+
+// Server is the mock execution and autotest service
+type Server struct {
+	fixturesDir string
+	store       store
+}
+
+// NewServer creates the mock server, fixturesDir may be empty
+func NewServer(fixturesDir string) (*Server, error) {
+	s := &Server{fixturesDir: fixturesDir}
+	if err := s.Reset(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reset drops the received state and queues the fixture local executions again
+func (s *Server) Reset() error {
+	state := newState()
+	if s.fixturesDir != "" {
+		files, err := filepath.Glob(filepath.Join(s.fixturesDir, FixtureLocalExecutions, "*.json"))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read local execution fixture: %w", err)
+			}
+			var exec localexecution.LocalExecution
+			if err := json.Unmarshal(data, &exec); err != nil {
+				return fmt.Errorf("failed to decode local execution fixture %s: %w", filepath.Base(file), err)
+			}
+			if exec.ID == "" {
+				exec.ID = strings.TrimSuffix(filepath.Base(file), ".json")
+			}
+			state.LocalExecutions[exec.ID] = exec
+		}
+	}
+	s.store.mu.Lock()
+	s.store.state = state
+	s.store.hashes = make(map[string]hash.Hash)
+	s.store.mu.Unlock()
+	return nil
+}
+
+// State returns a copy of what the server received
+func (s *Server) State() State {
+	return s.store.snapshot()
+}
+
+// Handler serves every route of the execution service and the autotest server
+func (s *Server) Handler() http.Handler {
+	r := chi.NewRouter()
+	r.Use(s.record)
+
+	// nkk: Execution service, see executionbridge.ExecutionServiceBridge
+	r.Route("/organisations/{orgId}/projects/{projectId}/apps/{appId}", func(r chi.Router) {
+		r.Post("/{testlab}/sessions", s.handle(s.saveSession))
+		r.Put("/{testlab}/sessions", s.handle(s.saveSession))
+		r.Post("/{testlab}/sessions/{executionId}/update-status", s.handle(s.saveStatus))
+		r.Put("/{testlab}/sessions/{executionId}/update-stepcount", s.handle(s.updateStepCount))
+		r.Post("/{testlab}/sessions/{executionId}/take-screenshot", s.handle(s.takeScreenshot))
+		r.Post("/{testlab}/sessions/{executionId}/upload-screenshots", s.handle(s.uploadScreenshots))
+		r.Post("/{testlab}/sessions/{executionId}/upload-video", s.handle(s.uploadVideo))
+		r.Post("/{testlab}/sessions/{executionId}/upload-har", s.handle(s.uploadHAR))
+		r.Post("/{testlab}/sessions/{executionId}/warnings", s.handle(s.addWarning))
+		r.Post("/{testlab}/sessions/{executionId}/artifact-urls", s.handle(s.saveArtifactURLs))
+		r.Post("/{testlab}/sessions/{executionId}/console-logs", s.handle(s.saveConsoleLogs))
+		r.Post("/{testlab}/sessions/{executionId}/uploads", s.handle(s.createUpload))
+		r.Get("/{testlab}/sessions/{executionId}/uploads/{uploadId}", s.handle(s.getUpload))
+		r.Put("/{testlab}/sessions/{executionId}/uploads/{uploadId}/chunks", s.handle(s.uploadChunk))
+		r.Post("/{testlab}/sessions/{executionId}/uploads/{uploadId}/complete", s.handle(s.completeUpload))
+		r.Post("/local-agent/run-results", s.handle(s.createRunResult))
+		r.Post("/local-agent/network-logs", s.handle(s.createNetworkLogs))
+		r.Get("/local-agent/{testlab}/run-count/{testplanId}", s.handle(s.runCount))
+	})
+	r.Post("/batch/sessions", s.handle(s.saveSessionBatch))
+	r.Post("/batch/session-statuses", s.handle(s.saveStatusBatch))
+
+	// nkk: Autotest server, see autotestbridge.AutotestBridgeService
+	r.Route("/local-agent/organisations/{orgId}/projects/{projectId}/apps/{appId}", func(r chi.Router) {
+		r.Get("/testcases/{id}/testscript", s.handle(s.fixture(FixtureTestScripts)))
+		r.Get("/testplans/{id}/execution-details", s.handle(s.fixture(FixtureExecutionDetails)))
+		r.Get("/testplans/{id}", s.handle(s.fixture(FixtureTestPlans)))
+		r.Get("/environments/{id}", s.handle(s.fixture(FixtureEnvironments)))
+		r.Get("/integrations/{type}/{name}", s.handle(s.integration))
+	})
+	r.Get("/local-agent/local-executions", s.handle(s.nextLocalExecution))
+	r.Delete("/local-agent/local-executions", s.handle(s.deleteLocalExecution))
+	r.Post("/local-devices", s.handle(s.insertDevice))
+	r.Get("/checkDeviceRegistration", s.handle(s.checkDevice))
+
+	r.Get("/mock/state", s.handle(s.getState))
+	r.Delete("/mock/state", s.handle(s.resetState))
+	r.Post("/mock/local-executions", s.handle(s.queueLocalExecution))
+	return r
+}
+
+// handle writes the result of a handler the same way the agent's own server does
+func (s *Server) handle(handler func(w http.ResponseWriter, r *http.Request) (any, int, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response, status, err := handler(w, r)
+		if err != nil {
+			var e *errors.Error
+			if errors.As(err, &e) {
+				apxresp.RespondError(w, e)
+				return
+			}
+			apxresp.RespondMessage(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if raw, ok := response.(json.RawMessage); ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write(raw)
+			return
+		}
+		apxresp.RespondJSON(w, status, response)
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (s *Server) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		if strings.HasPrefix(r.URL.Path, "/mock/") {
+			return
+		}
+		logger.Debug("mock server request", zap.String("method", r.Method), zap.String("path", r.URL.Path), zap.Int("status", recorder.status))
+		s.store.update(func(state *State) {
+			state.Requests = append(state.Requests, RecordedRequest{
+				Time:   time.Now().UTC(),
+				Method: r.Method,
+				Path:   r.URL.Path,
+				Query:  r.URL.RawQuery,
+				Status: recorder.status,
+			})
+			if len(state.Requests) > maxRecordedRequests {
+				state.Requests = state.Requests[len(state.Requests)-maxRecordedRequests:]
+			}
+		})
+	})
+}
+
+func decode(r *http.Request, v any) error {
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxBodySize)).Decode(v); err != nil {
+		return errors.E(errors.Invalid, "invalid request body", err)
+	}
+	return nil
+}
+
+func (s *Server) saveSession(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var sess session.Session
+	if err := decode(r, &sess); err != nil {
+		return nil, 0, err
+	}
+	if sess.Testlab == "" {
+		sess.Testlab = chi.URLParam(r, "testlab")
+	}
+	s.store.update(func(state *State) {
+		state.Sessions[sessionKey(sess.ExecutionId, sess.TestcaseId)] = sess
+	})
+	return sess, http.StatusOK, nil
+}
+
+func (s *Server) saveSessionBatch(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var batch struct {
+		Sessions []session.Session `json:"sessions"`
+	}
+	if err := decode(r, &batch); err != nil {
+		return nil, 0, err
+	}
+	s.store.update(func(state *State) {
+		for _, sess := range batch.Sessions {
+			state.Sessions[sessionKey(sess.ExecutionId, sess.TestcaseId)] = sess
+		}
+	})
+	return map[string]int{"count": len(batch.Sessions)}, http.StatusOK, nil
+}
+
+func (s *Server) saveStatus(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var status executionstatus.ExecutionStatus
+	if err := decode(r, &status); err != nil {
+		return nil, 0, err
+	}
+	if status.ExecutionId == "" {
+		status.ExecutionId = chi.URLParam(r, "executionId")
+	}
+	s.store.update(func(state *State) {
+		state.Statuses = append(state.Statuses, status)
+		applyStatus(state, status)
+	})
+	return status, http.StatusOK, nil
+}
+
+func (s *Server) saveStatusBatch(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var batch struct {
+		Statuses []executionstatus.ExecutionStatus `json:"statuses"`
+	}
+	if err := decode(r, &batch); err != nil {
+		return nil, 0, err
+	}
+	s.store.update(func(state *State) {
+		for _, status := range batch.Statuses {
+			state.Statuses = append(state.Statuses, status)
+			applyStatus(state, status)
+		}
+	})
+	return map[string]int{"count": len(batch.Statuses)}, http.StatusOK, nil
+}
+
+// applyStatus mirrors a status onto its session, like the execution service does
+func applyStatus(state *State, status executionstatus.ExecutionStatus) {
+	key := sessionKey(status.ExecutionId, status.TestcaseId)
+	if sess, ok := state.Sessions[key]; ok {
+		sess.Status = status.Status
+		state.Sessions[key] = sess
+	}
+}
+
+func (s *Server) updateStepCount(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var step executionstep.ExecutionStep
+	if err := decode(r, &step); err != nil {
+		return nil, 0, err
+	}
+	if step.ExecutionId == "" {
+		step.ExecutionId = chi.URLParam(r, "executionId")
+	}
+	s.store.update(func(state *State) {
+		state.StepCounts = append(state.StepCounts, step)
+		key := sessionKey(step.ExecutionId, step.TestcaseId)
+		if sess, ok := state.Sessions[key]; ok {
+			sess.StepCount = step.StepCount
+			state.Sessions[key] = sess
+		}
+	})
+	return step, http.StatusOK, nil
+}
+
+func (s *Server) takeScreenshot(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var shot screenshot.TakeScreenshot
+	if err := decode(r, &shot); err != nil {
+		return nil, 0, err
+	}
+	record := ScreenshotRecord{ExecutionId: chi.URLParam(r, "executionId"), ScreenshotPath: shot.ScreenshotPath, Size: len(shot.Screenshot)}
+	s.store.update(func(state *State) {
+		state.Screenshots = append(state.Screenshots, record)
+	})
+	return record, http.StatusOK, nil
+}
+
+func (s *Server) uploadScreenshots(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var upload screenshot.UploadScreenshotRequest
+	if err := decode(r, &upload); err != nil {
+		return nil, 0, err
+	}
+	s.store.update(func(state *State) {
+		state.ScreenshotUploads = append(state.ScreenshotUploads, upload)
+	})
+	return upload, http.StatusOK, nil
+}
+
+func (s *Server) uploadVideo(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, 0, errors.E(errors.Invalid, "expected a multipart body", err)
+	}
+	record := VideoRecord{ExecutionId: chi.URLParam(r, "executionId")}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, errors.E(errors.Invalid, "invalid multipart body", err)
+		}
+		if part.FileName() != "" {
+			record.FileName = part.FileName()
+			record.Size, err = io.Copy(io.Discard, part)
+			if err != nil {
+				return nil, 0, errors.E(errors.Invalid, "failed to read video", err)
+			}
+			continue
+		}
+		if part.FormName() == "testcase_id" {
+			value, _ := io.ReadAll(io.LimitReader(part, 1024))
+			record.TestcaseId = string(value)
+		}
+	}
+	s.store.update(func(state *State) {
+		state.Videos = append(state.Videos, record)
+	})
+	return record, http.StatusOK, nil
+}
+
+// uploadHAR keeps the entry count of an uploaded HAR, not the file
+func (s *Server) uploadHAR(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, 0, errors.E(errors.Invalid, "expected a multipart body", err)
+	}
+	record := HARRecord{ExecutionId: chi.URLParam(r, "executionId")}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, errors.E(errors.Invalid, "invalid multipart body", err)
+		}
+		if part.FileName() != "" {
+			var har struct {
+				Log struct {
+					Version string            `json:"version"`
+					Entries []json.RawMessage `json:"entries"`
+				} `json:"log"`
+			}
+			counter := &countingReader{reader: part}
+			if err := json.NewDecoder(counter).Decode(&har); err != nil {
+				return nil, 0, errors.E(errors.Invalid, "invalid HAR", err)
+			}
+			record.FileName, record.Version, record.Entries = part.FileName(), har.Log.Version, len(har.Log.Entries)
+			io.Copy(io.Discard, counter)
+			record.Size = counter.n
+			continue
+		}
+		if part.FormName() == "testcase_id" {
+			value, _ := io.ReadAll(io.LimitReader(part, 1024))
+			record.TestcaseId = string(value)
+		}
+	}
+	s.store.update(func(state *State) {
+		state.HARs = append(state.HARs, record)
+	})
+	return record, http.StatusOK, nil
+}
+
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (s *Server) createRunResult(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var result runresult.RunResult
+	if err := decode(r, &result); err != nil {
+		return nil, 0, err
+	}
+	query := r.URL.Query()
+	record := RunResultRecord{
+		ExecutionId: query.Get("execution_id"),
+		TestplanId:  query.Get("testplan_id"),
+		ResultType:  query.Get("result_type"),
+		RunResult:   result,
+	}
+	s.store.update(func(state *State) {
+		state.RunResults = append(state.RunResults, record)
+	})
+	return record, http.StatusOK, nil
+}
+
+func (s *Server) createNetworkLogs(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var log logs.Log
+	if err := decode(r, &log); err != nil {
+		return nil, 0, err
+	}
+	s.store.update(func(state *State) {
+		state.NetworkLogs = append(state.NetworkLogs, log)
+	})
+	return map[string]int{"snapshots": len(log.Logs)}, http.StatusOK, nil
+}
+
+// addWarning keeps the warning and adds it to the session it belongs to
+func (s *Server) addWarning(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var warning session.Warning
+	if err := decode(r, &warning); err != nil {
+		return nil, 0, err
+	}
+	s.store.update(func(state *State) {
+		state.Warnings = append(state.Warnings, warning)
+		key := sessionKey(warning.ExecutionId, warning.TestcaseId)
+		if stored, ok := state.Sessions[key]; ok {
+			stored.Warnings = append(stored.Warnings, warning)
+			state.Sessions[key] = stored
+		}
+	})
+	return warning, http.StatusOK, nil
+}
+
+// saveArtifactURLs keeps the links and sets them on the session they belong to
+func (s *Server) saveArtifactURLs(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var urls session.ArtifactURLs
+	if err := decode(r, &urls); err != nil {
+		return nil, 0, err
+	}
+	s.store.update(func(state *State) {
+		state.ArtifactURLs = append(state.ArtifactURLs, urls)
+		key := sessionKey(urls.ExecutionId, urls.TestcaseId)
+		if stored, ok := state.Sessions[key]; ok {
+			if urls.VideoURL != "" {
+				stored.VideoURL = urls.VideoURL
+			}
+			stored.Screenshots = append(stored.Screenshots, urls.Screenshots...)
+			state.Sessions[key] = stored
+		}
+	})
+	return urls, http.StatusOK, nil
+}
+
+// saveConsoleLogs keeps the console summary and sets it as the logs of the session it belongs to
+func (s *Server) saveConsoleLogs(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var logs session.ConsoleLogs
+	if err := decode(r, &logs); err != nil {
+		return nil, 0, err
+	}
+	s.store.update(func(state *State) {
+		state.ConsoleLogs = append(state.ConsoleLogs, logs)
+		key := sessionKey(logs.ExecutionId, logs.TestcaseId)
+		if stored, ok := state.Sessions[key]; ok {
+			stored.Logs = logs.Summary
+			state.Sessions[key] = stored
+		}
+	})
+	return logs, http.StatusOK, nil
+}
+
+type uploadAnswer struct {
+	UploadId string `json:"upload_id"`
+	Offset   int64  `json:"offset"`
+}
+
+// createUpload starts a chunked upload, see executionbridge.ChunkedUploader
+func (s *Server) createUpload(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var request struct {
+		Kind     string            `json:"kind"`
+		FileName string            `json:"file_name"`
+		Size     int64             `json:"size"`
+		SHA256   string            `json:"sha256"`
+		Fields   map[string]string `json:"fields"`
+	}
+	if err := decode(r, &request); err != nil {
+		return nil, 0, err
+	}
+	if request.Size < 0 || request.SHA256 == "" {
+		return nil, 0, errors.E(errors.Invalid, "size and sha256 are required")
+	}
+	var record UploadRecord
+	s.store.update(func(state *State) {
+		record = UploadRecord{
+			UploadId:    fmt.Sprintf("upload-%d", len(state.Uploads)+1),
+			ExecutionId: chi.URLParam(r, "executionId"),
+			Kind:        request.Kind,
+			FileName:    request.FileName,
+			Size:        request.Size,
+			SHA256:      request.SHA256,
+			Fields:      request.Fields,
+		}
+		state.Uploads[record.UploadId] = record
+		s.store.hashes[record.UploadId] = sha256.New()
+	})
+	return uploadAnswer{UploadId: record.UploadId}, http.StatusCreated, nil
+}
+
+func (s *Server) getUpload(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var record UploadRecord
+	var ok bool
+	s.store.update(func(state *State) {
+		record, ok = state.Uploads[chi.URLParam(r, "uploadId")]
+	})
+	if !ok {
+		return nil, 0, errors.E(errors.NotFound, "upload not found")
+	}
+	return uploadAnswer{UploadId: record.UploadId, Offset: record.Offset}, http.StatusOK, nil
+}
+
+// uploadChunk appends a chunk, it has to start where the upload ends and match its checksum
+func (s *Server) uploadChunk(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	offset, err := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	if err != nil {
+		return nil, 0, errors.E(errors.Invalid, "invalid offset", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		return nil, 0, errors.E(errors.Invalid, "failed to read chunk", err)
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != r.URL.Query().Get("sha256") {
+		return nil, 0, errors.E(errors.Invalid, "chunk checksum mismatch")
+	}
+	uploadId := chi.URLParam(r, "uploadId")
+	var answer uploadAnswer
+	err = nil
+	s.store.update(func(state *State) {
+		record, ok := state.Uploads[uploadId]
+		switch {
+		case !ok:
+			err = errors.E(errors.NotFound, "upload not found")
+		case record.Completed || offset != record.Offset:
+			err = errors.E(errors.Conflict, fmt.Sprintf("upload is at offset %d", record.Offset))
+		case offset+int64(len(data)) > record.Size:
+			err = errors.E(errors.Invalid, "chunk ends after the upload size")
+		default:
+			s.store.hashes[uploadId].Write(data)
+			record.Offset += int64(len(data))
+			record.Chunks++
+			state.Uploads[uploadId] = record
+			answer = uploadAnswer{UploadId: uploadId, Offset: record.Offset}
+		}
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return answer, http.StatusOK, nil
+}
+
+// completeUpload checks the whole file against the checksum the upload was created with
+func (s *Server) completeUpload(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var request struct {
+		Size   int64  `json:"size"`
+		SHA256 string `json:"sha256"`
+	}
+	if err := decode(r, &request); err != nil {
+		return nil, 0, err
+	}
+	uploadId := chi.URLParam(r, "uploadId")
+	var record UploadRecord
+	var err error
+	s.store.update(func(state *State) {
+		var ok bool
+		record, ok = state.Uploads[uploadId]
+		switch {
+		case !ok:
+			err = errors.E(errors.NotFound, "upload not found")
+		case record.Offset != record.Size || request.Size != record.Size:
+			err = errors.E(errors.Conflict, fmt.Sprintf("upload is at offset %d of %d", record.Offset, record.Size))
+		case hex.EncodeToString(s.store.hashes[uploadId].Sum(nil)) != record.SHA256 || request.SHA256 != record.SHA256:
+			err = errors.E(errors.ExpectationFailed, "upload checksum mismatch")
+		default:
+			record.Completed = true
+			state.Uploads[uploadId] = record
+		}
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return record, http.StatusOK, nil
+}
+
+// runCount counts the distinct executions with a run result for the testplan
+func (s *Server) runCount(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	testplanId := chi.URLParam(r, "testplanId")
+	executions := make(map[string]bool)
+	s.store.update(func(state *State) {
+		for _, result := range state.RunResults {
+			if result.TestplanId == testplanId {
+				executions[result.ExecutionId] = true
+			}
+		}
+	})
+	return len(executions), http.StatusOK, nil
+}
+
+// fixture serves <fixtures>/<kind>/<id>.json as is
+func (s *Server) fixture(kind string) func(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	return func(w http.ResponseWriter, r *http.Request) (any, int, error) {
+		data, err := s.readFixture(kind, chi.URLParam(r, "id"))
+		if err != nil {
+			return nil, 0, err
+		}
+		return data, http.StatusOK, nil
+	}
+}
+
+func (s *Server) integration(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	data, err := s.readFixture(FixtureIntegrations, chi.URLParam(r, "type")+"_"+chi.URLParam(r, "name"))
+	if err != nil {
+		return nil, 0, err
+	}
+	return data, http.StatusOK, nil
+}
+
+func (s *Server) readFixture(kind, id string) (json.RawMessage, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return nil, errors.E(errors.Invalid, "invalid fixture id "+id)
+	}
+	if s.fixturesDir == "" {
+		return nil, errors.E(errors.NotFound, "no fixtures directory")
+	}
+	data, err := os.ReadFile(filepath.Join(s.fixturesDir, kind, id+".json"))
+	if os.IsNotExist(err) {
+		return nil, errors.E(errors.NotFound, fmt.Sprintf("no %s fixture for %s", kind, id))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("%s fixture %s is not valid JSON", kind, id)
+	}
+	return data, nil
+}
+
+// nextLocalExecution answers the agent's poll, deviceId matches the machine, the device or the execution of a queued one
+func (s *Server) nextLocalExecution(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	deviceId := r.URL.Query().Get("deviceId")
+	var found *localexecution.LocalExecution
+	s.store.update(func(state *State) {
+		// nkk: Oldest first is not tracked, the smallest _id keeps the answer stable
+		for _, exec := range state.LocalExecutions {
+			if deviceId == "" || deviceId == exec.MachineId || deviceId == exec.LocalDeviceId || deviceId == exec.ExecutionId || deviceId == exec.ID {
+				if found == nil || exec.ID < found.ID {
+					exec := exec
+					found = &exec
+				}
+			}
+		}
+	})
+	if found == nil {
+		return json.RawMessage("null"), http.StatusOK, nil
+	}
+	return found, http.StatusOK, nil
+}
+
+func (s *Server) deleteLocalExecution(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	id := r.URL.Query().Get("executionId")
+	deleted := false
+	s.store.update(func(state *State) {
+		if _, ok := state.LocalExecutions[id]; ok {
+			delete(state.LocalExecutions, id)
+			deleted = true
+		}
+	})
+	if !deleted {
+		return nil, 0, errors.E(errors.NotFound, "no local execution "+id)
+	}
+	return map[string]string{"deleted": id}, http.StatusOK, nil
+}
+
+func (s *Server) queueLocalExecution(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var exec localexecution.LocalExecution
+	if err := decode(r, &exec); err != nil {
+		return nil, 0, err
+	}
+	if exec.ID == "" {
+		exec.ID = exec.ExecutionId
+	}
+	if exec.ID == "" {
+		return nil, 0, errors.E(errors.Invalid, "_id or execution_id is required")
+	}
+	s.store.update(func(state *State) {
+		state.LocalExecutions[exec.ID] = exec
+	})
+	return exec, http.StatusCreated, nil
+}
+
+func (s *Server) insertDevice(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	var device localdevice.Config
+	if err := decode(r, &device); err != nil {
+		return nil, 0, err
+	}
+	if machineId := r.URL.Query().Get("machineId"); machineId != "" {
+		device.MachineId = machineId
+	}
+	s.store.update(func(state *State) {
+		state.Devices[device.MachineId] = device
+	})
+	return device, http.StatusOK, nil
+}
+
+// checkDevice treats every machine as registered, the agent only checks that the call succeeds
+func (s *Server) checkDevice(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	machineId := r.URL.Query().Get("machineId")
+	var registered bool
+	s.store.update(func(state *State) {
+		_, registered = state.Devices[machineId]
+	})
+	return map[string]any{"machine_id": machineId, "registered": registered}, http.StatusOK, nil
+}
+
+func (s *Server) getState(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	return s.State(), http.StatusOK, nil
+}
+
+func (s *Server) resetState(w http.ResponseWriter, r *http.Request) (any, int, error) {
+	if err := s.Reset(); err != nil {
+		return nil, 0, err
+	}
+	return map[string]string{"message": "state reset"}, http.StatusOK, nil
+}
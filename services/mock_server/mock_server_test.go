@@ -0,0 +1,105 @@
+package mockserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/models/executionstatus"
+	"agent/models/executionstep"
+	"agent/models/runresult"
+	"agent/models/screenshot"
+	"agent/models/session"
+	autotestbridge "agent/services/autotest_bridge"
+	executionbridge "agent/services/execution_bridge"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: Unit tests for the mock execution and autotest service, driven through the real bridges
+*/
+
+func writeFixture(t *testing.T, dir, kind, name, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, kind), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, kind, name), []byte(content), 0644))
+}
+
+func TestMockServerRecordsBridgeCalls(t *testing.T) {
+	fixtures := t.TempDir()
+	writeFixture(t, fixtures, FixtureTestScripts, "tc-1.json", `{"_id":"tc-1","title":"Login","script":"await page.goto('/')"}`)
+	writeFixture(t, fixtures, FixtureLocalExecutions, "le-1.json", `{"execution_id":"exec-1","machine_id":"machine-1","testcase_id":"tc-1"}`)
+	mock, err := NewServer(fixtures)
+	require.NoError(t, err)
+	server := httptest.NewServer(mock.Handler())
+	defer server.Close()
+
+	execution := executionbridge.NewExecutionServiceBridge(server.URL)
+	autotest := autotestbridge.NewAutoTestBridgeService(server.URL)
+	ctx := context.Background()
+
+	queued, err := autotest.GetLocalexecution("machine-1")
+	require.NoError(t, err)
+	require.NotNil(t, queued)
+	assert.Equal(t, "le-1", queued.ID, "the file name is the id of a fixture without one")
+	script, err := autotest.GetTestCase("o", "p", "a", queued.TestcaseId, "")
+	require.NoError(t, err)
+	assert.Equal(t, "Login", script.Title)
+
+	sess := session.Session{OrgId: "o", ProjectId: "p", AppId: "a", ExecutionId: "exec-1", TestcaseId: "tc-1", Status: apxconstants.Running}
+	require.NoError(t, execution.SaveSession(ctx, "o", "p", "a", apxconstants.Local, sess))
+	require.NoError(t, execution.UpdateStepCount(ctx, "o", "p", "a", apxconstants.Local, "exec-1", executionstep.ExecutionStep{ExecutionId: "exec-1", TestcaseId: "tc-1", StepCount: "4"}))
+	require.NoError(t, execution.SaveSessionStatus(ctx, executionstatus.ExecutionStatus{OrgId: "o", ProjectId: "p", AppId: "a", TestLab: apxconstants.Local, ExecutionId: "exec-1", TestcaseId: "tc-1", Status: apxconstants.Passed}))
+	require.NoError(t, execution.TakeScreenshot(ctx, "o", "p", "a", apxconstants.Local, "exec-1", screenshot.TakeScreenshot{Screenshot: "aGVsbG8=", ScreenshotPath: "shots/1.png", ExecutionId: "exec-1"}))
+	require.NoError(t, execution.CreateLocalAgentResults(ctx, "o", "p", "a", "exec-1", "tp-1", &runresult.RunResult{ExecutionId: "exec-1", TestPlanId: "tp-1", Status: apxconstants.Passed}, apxconstants.RunResultType))
+	count, err := execution.GetRunCountForTestPlan(ctx, "o", "p", "a", apxconstants.Local, "tp-1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+
+	videoPath := filepath.Join(t.TempDir(), apxconstants.VideoFileName)
+	require.NoError(t, os.WriteFile(videoPath, []byte("a video of more than one chunk"), 0644))
+	uploader := executionbridge.NewChunkedUploader(executionbridge.NewHTTPTransport(server.URL, http.DefaultClient), executionbridge.ChunkedUploadOptions{ChunkSize: 8})
+	require.NoError(t, uploader.Upload(ctx, executionbridge.ArtifactUpload{Kind: executionbridge.ArtifactKindVideo, FilePath: videoPath, FileName: apxconstants.VideoFileName, OrgId: "o", ProjectId: "p", AppId: "a", Testlab: apxconstants.Local, ExecutionId: "exec-1"}))
+
+	require.NoError(t, autotest.DeleteLocalexecution("machine-1", queued.ID))
+	next, err := autotest.GetLocalexecution("machine-1")
+	require.NoError(t, err)
+	assert.Nil(t, next, "a deleted execution is not handed out again")
+
+	res, err := http.Get(server.URL + "/mock/state")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	var state State
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&state))
+	stored := state.Sessions["exec-1/tc-1"]
+	assert.Equal(t, apxconstants.Passed, stored.Status, "statuses are applied to the session")
+	assert.Equal(t, "4", stored.StepCount)
+	require.Len(t, state.Statuses, 1)
+	require.Len(t, state.RunResults, 1)
+	assert.Equal(t, apxconstants.RunResultType, state.RunResults[0].ResultType)
+	require.Len(t, state.Screenshots, 1)
+	assert.Equal(t, "shots/1.png", state.Screenshots[0].ScreenshotPath)
+	assert.Empty(t, state.LocalExecutions)
+	require.Len(t, state.Uploads, 1)
+	for _, upload := range state.Uploads {
+		assert.True(t, upload.Completed, "the chunks add up to the checksum of the file")
+		assert.Equal(t, 4, upload.Chunks)
+	}
+	assert.NotEmpty(t, state.Requests)
+
+	res, err = http.Get(server.URL + "/local-agent/organisations/o/projects/p/apps/a/testcases/..%2Fsecret/testscript")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode, "fixture ids cannot leave their directory")
+
+	require.NoError(t, mock.Reset())
+	assert.Len(t, mock.State().LocalExecutions, 1, "a reset queues the fixtures again")
+	assert.Empty(t, mock.State().Sessions)
+}
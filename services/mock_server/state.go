@@ -0,0 +1,158 @@
+package mockserver
+
+import (
+	"hash"
+	"sync"
+	"time"
+
+	"agent/models/executionstatus"
+	"agent/models/executionstep"
+	"agent/models/localdevice"
+	"agent/models/localexecution"
+	"agent/models/logs"
+	"agent/models/runresult"
+	"agent/models/screenshot"
+	"agent/models/session"
+)
+
+// nkk: Everything the mock server was sent, GET /mock/state returns a snapshot of it
+
+const maxRecordedRequests = 1000
+
+// RecordedRequest is one request the mock server answered
+type RecordedRequest struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Query  string    `json:"query,omitempty"`
+	Status int       `json:"status"`
+}
+
+// RunResultRecord is a run result with the query it was posted with
+type RunResultRecord struct {
+	ExecutionId string              `json:"execution_id"`
+	TestplanId  string              `json:"testplan_id"`
+	ResultType  string              `json:"result_type"`
+	RunResult   runresult.RunResult `json:"run_result"`
+}
+
+// ScreenshotRecord is a screenshot taken by the fixture, the image itself is not kept
+type ScreenshotRecord struct {
+	ExecutionId    string `json:"execution_id"`
+	ScreenshotPath string `json:"screenshot_path"`
+	Size           int    `json:"size"`
+}
+
+// VideoRecord is an uploaded video, the file itself is not kept
+type VideoRecord struct {
+	ExecutionId string `json:"execution_id"`
+	TestcaseId  string `json:"testcase_id,omitempty"`
+	FileName    string `json:"file_name"`
+	Size        int64  `json:"size"`
+}
+
+// HARRecord is an uploaded HAR, the file itself is not kept
+type HARRecord struct {
+	ExecutionId string `json:"execution_id"`
+	TestcaseId  string `json:"testcase_id,omitempty"`
+	FileName    string `json:"file_name"`
+	Version     string `json:"version"`
+	Entries     int    `json:"entries"`
+	Size        int64  `json:"size"`
+}
+
+// UploadRecord is a chunked artifact upload, the bytes are only hashed
+type UploadRecord struct {
+	UploadId    string            `json:"upload_id"`
+	ExecutionId string            `json:"execution_id"`
+	Kind        string            `json:"kind"`
+	FileName    string            `json:"file_name"`
+	Size        int64             `json:"size"`
+	SHA256      string            `json:"sha256"`
+	Offset      int64             `json:"offset"`
+	Chunks      int               `json:"chunks"`
+	Completed   bool              `json:"completed"`
+	Fields      map[string]string `json:"fields,omitempty"`
+}
+
+// State is the in-memory state of the mock services
+type State struct {
+	Sessions          map[string]session.Session               `json:"sessions"` // nkk: latest per "<execution_id>/<testcase_id>"
+	Statuses          []executionstatus.ExecutionStatus        `json:"statuses"`
+	StepCounts        []executionstep.ExecutionStep            `json:"step_counts"`
+	RunResults        []RunResultRecord                        `json:"run_results"`
+	NetworkLogs       []logs.Log                               `json:"network_logs"`
+	Screenshots       []ScreenshotRecord                       `json:"screenshots"`
+	ScreenshotUploads []screenshot.UploadScreenshotRequest     `json:"screenshot_uploads"`
+	Videos            []VideoRecord                            `json:"videos"`
+	HARs              []HARRecord                              `json:"hars"`
+	Warnings          []session.Warning                        `json:"warnings"`
+	ArtifactURLs      []session.ArtifactURLs                   `json:"artifact_urls"`
+	ConsoleLogs       []session.ConsoleLogs                    `json:"console_logs"`
+	Uploads           map[string]UploadRecord                  `json:"uploads"`          // nkk: by upload ID
+	LocalExecutions   map[string]localexecution.LocalExecution `json:"local_executions"` // nkk: queued, by _id
+	Devices           map[string]localdevice.Config            `json:"devices"`          // nkk: by machine ID
+	Requests          []RecordedRequest                        `json:"requests"`
+}
+
+func newState() State {
+	return State{
+		Sessions:        make(map[string]session.Session),
+		LocalExecutions: make(map[string]localexecution.LocalExecution),
+		Devices:         make(map[string]localdevice.Config),
+		Uploads:         make(map[string]UploadRecord),
+	}
+}
+
+type store struct {
+	mu     sync.Mutex
+	state  State
+	hashes map[string]hash.Hash // nkk: running SHA-256 of each upload, by upload ID
+}
+
+func (s *store) update(fn func(state *State)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.state)
+}
+
+// snapshot copies the state so it can be encoded without the lock
+func (s *store) snapshot() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := State{
+		Sessions:          make(map[string]session.Session, len(s.state.Sessions)),
+		Statuses:          append([]executionstatus.ExecutionStatus(nil), s.state.Statuses...),
+		StepCounts:        append([]executionstep.ExecutionStep(nil), s.state.StepCounts...),
+		RunResults:        append([]RunResultRecord(nil), s.state.RunResults...),
+		NetworkLogs:       append([]logs.Log(nil), s.state.NetworkLogs...),
+		Screenshots:       append([]ScreenshotRecord(nil), s.state.Screenshots...),
+		ScreenshotUploads: append([]screenshot.UploadScreenshotRequest(nil), s.state.ScreenshotUploads...),
+		Videos:            append([]VideoRecord(nil), s.state.Videos...),
+		HARs:              append([]HARRecord(nil), s.state.HARs...),
+		Warnings:          append([]session.Warning(nil), s.state.Warnings...),
+		ArtifactURLs:      append([]session.ArtifactURLs(nil), s.state.ArtifactURLs...),
+		ConsoleLogs:       append([]session.ConsoleLogs(nil), s.state.ConsoleLogs...),
+		LocalExecutions:   make(map[string]localexecution.LocalExecution, len(s.state.LocalExecutions)),
+		Devices:           make(map[string]localdevice.Config, len(s.state.Devices)),
+		Uploads:           make(map[string]UploadRecord, len(s.state.Uploads)),
+		Requests:          append([]RecordedRequest(nil), s.state.Requests...),
+	}
+	for k, v := range s.state.Sessions {
+		out.Sessions[k] = v
+	}
+	for k, v := range s.state.LocalExecutions {
+		out.LocalExecutions[k] = v
+	}
+	for k, v := range s.state.Devices {
+		out.Devices[k] = v
+	}
+	for k, v := range s.state.Uploads {
+		out.Uploads[k] = v
+	}
+	return out
+}
+
+func sessionKey(executionId, testcaseId string) string {
+	return executionId + "/" + testcaseId
+}
@@ -548,13 +548,13 @@ func (h *HealthChecker) HealthHandler() http.HandlerFunc {
 			}
 		}
 
+		w.Header().Set("Content-Type", "application/json")
+
 		if !healthy {
 			response["status"] = "unhealthy"
 			w.WriteHeader(http.StatusServiceUnavailable)
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-
 		// Simple JSON encoding without external dependencies
 		fmt.Fprint(w, `{"status":"`)
 		fmt.Fprint(w, response["status"])
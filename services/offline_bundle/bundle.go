@@ -0,0 +1,476 @@
+package offline_bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"agent/logger"
+)
+
+/*
+nkk: Offline install bundle
+Lab machines without internet cannot run `npm i` / `npx playwright install`, so we ship
+everything they need in one archive:
+
+	workspace/node_modules, workspace/tests, workspace/playwright.config.js, ...
+	runtimes/<version>/...   (Playwright runtime incl. its browsers)
+	manifest.json            (last entry - format/bundle version, platform, per-file SHA-256)
+
+A `<archive>.sha256` sidecar carries the checksum of the whole archive.
+Import extracts into staging dirs, verifies every checksum and only then moves things into place.
+*/
+
+// FormatVersion is bumped whenever the archive layout changes
+const FormatVersion = 1
+
+const (
+	manifestName     = "manifest.json"
+	workspacePrefix  = "workspace"
+	runtimesPrefix   = "runtimes"
+	stagingDirPrefix = ".bundle-staging-"
+)
+
+// workspaceEntries are the workspace files and directories shipped in a bundle
+var workspaceEntries = []string{"node_modules", "tests", "playwright.config.js", "package.json", "package-lock.json"}
+
+// FileEntry describes a file in the bundle
+type FileEntry struct {
+	Path     string      `json:"path"`
+	Size     int64       `json:"size"`
+	Mode     fs.FileMode `json:"mode"`
+	SHA256   string      `json:"sha256,omitempty"`
+	Linkname string      `json:"linkname,omitempty"`
+}
+
+// Manifest describes the bundle contents
+type Manifest struct {
+	FormatVersion      int         `json:"format_version"`
+	BundleVersion      string      `json:"bundle_version"`
+	CreatedAt          time.Time   `json:"created_at"`
+	OS                 string      `json:"os"`
+	Arch               string      `json:"arch"`
+	PlaywrightVersions []string    `json:"playwright_versions"`
+	Files              []FileEntry `json:"files"`
+}
+
+// ExportOptions configures Export
+type ExportOptions struct {
+	WorkspaceDir       string
+	RuntimesDir        string
+	PlaywrightVersions []string
+	BundleVersion      string
+	OutputDir          string
+}
+
+// ImportOptions configures Import
+type ImportOptions struct {
+	WorkspaceDir string
+	RuntimesDir  string
+}
+
+// ArchiveName returns the file name of a bundle for this platform
+func ArchiveName(bundleVersion string) string {
+	return fmt.Sprintf("agent-bundle-%s-%s-%s.tar.gz", bundleVersion, runtime.GOOS, runtime.GOARCH)
+}
+
+// Export packs the workspace dependencies and runtimes into a checksummed archive
+func Export(ctx context.Context, opts ExportOptions) (string, *Manifest, error) {
+	if opts.BundleVersion == "" {
+		return "", nil, fmt.Errorf("bundle version cannot be empty")
+	}
+	if len(opts.PlaywrightVersions) == 0 {
+		return "", nil, fmt.Errorf("at least one playwright version is required")
+	}
+
+	if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+		return "", nil, err
+	}
+	archivePath := filepath.Join(opts.OutputDir, ArchiveName(opts.BundleVersion))
+
+	out, err := os.Create(archivePath)
+	if err != nil {
+		return "", nil, err
+	}
+	defer out.Close()
+
+	archiveHash := sha256.New()
+	gz := gzip.NewWriter(io.MultiWriter(out, archiveHash))
+	tw := tar.NewWriter(gz)
+
+	manifest := &Manifest{
+		FormatVersion:      FormatVersion,
+		BundleVersion:      opts.BundleVersion,
+		CreatedAt:          time.Now().UTC(),
+		OS:                 runtime.GOOS,
+		Arch:               runtime.GOARCH,
+		PlaywrightVersions: opts.PlaywrightVersions,
+		Files:              make([]FileEntry, 0),
+	}
+
+	for _, entry := range workspaceEntries {
+		src := filepath.Join(opts.WorkspaceDir, entry)
+		if _, err := os.Lstat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := addTree(ctx, tw, manifest, src, path.Join(workspacePrefix, entry)); err != nil {
+			return "", nil, err
+		}
+	}
+
+	for _, version := range opts.PlaywrightVersions {
+		src := filepath.Join(opts.RuntimesDir, version)
+		if _, err := os.Stat(src); err != nil {
+			return "", nil, fmt.Errorf("playwright runtime %s is not installed: %w", version, err)
+		}
+		if err := addTree(ctx, tw, manifest, src, path.Join(runtimesPrefix, version)); err != nil {
+			return "", nil, err
+		}
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", nil, err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: manifestName, Mode: 0644, Size: int64(len(manifestData)), ModTime: manifest.CreatedAt}); err != nil {
+		return "", nil, err
+	}
+	if _, err := tw.Write(manifestData); err != nil {
+		return "", nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return "", nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return "", nil, err
+	}
+
+	checksum := hex.EncodeToString(archiveHash.Sum(nil))
+	sidecar := fmt.Sprintf("%s  %s\n", checksum, filepath.Base(archivePath))
+	if err := os.WriteFile(archivePath+".sha256", []byte(sidecar), 0644); err != nil {
+		return "", nil, err
+	}
+
+	logger.Info("exported offline bundle",
+		zap.String("archive", archivePath),
+		zap.Int("files", len(manifest.Files)),
+		zap.String("sha256", checksum))
+
+	return archivePath, manifest, nil
+}
+
+// addTree writes a file or directory tree into the archive under prefix
+func addTree(ctx context.Context, tw *tar.Writer, manifest *Manifest, root, prefix string) error {
+	return filepath.Walk(root, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		name := path.Join(prefix, filepath.ToSlash(rel))
+
+		// nkk: Skip staging leftovers from interrupted imports
+		if strings.HasPrefix(info.Name(), stagingDirPrefix) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		linkname := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			if linkname, err = os.Readlink(filePath); err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, linkname)
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			return nil
+		case linkname != "":
+			manifest.Files = append(manifest.Files, FileEntry{Path: name, Mode: info.Mode(), Linkname: linkname})
+			return nil
+		case !info.Mode().IsRegular():
+			return nil
+		}
+
+		file, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		hash := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(tw, hash), file); err != nil {
+			return err
+		}
+
+		manifest.Files = append(manifest.Files, FileEntry{
+			Path:   name,
+			Size:   info.Size(),
+			Mode:   info.Mode(),
+			SHA256: hex.EncodeToString(hash.Sum(nil)),
+		})
+		return nil
+	})
+}
+
+// Import verifies an archive and installs its contents
+func Import(ctx context.Context, archivePath string, opts ImportOptions) (*Manifest, error) {
+	if err := verifyArchiveChecksum(archivePath); err != nil {
+		return nil, err
+	}
+
+	for _, dir := range []string{opts.WorkspaceDir, opts.RuntimesDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	workspaceStaging, err := os.MkdirTemp(opts.WorkspaceDir, stagingDirPrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(workspaceStaging)
+
+	runtimesStaging, err := os.MkdirTemp(opts.RuntimesDir, stagingDirPrefix)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(runtimesStaging)
+
+	manifest, extracted, err := extract(ctx, archivePath, map[string]string{
+		workspacePrefix: workspaceStaging,
+		runtimesPrefix:  runtimesStaging,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyManifest(manifest, extracted); err != nil {
+		return nil, err
+	}
+
+	// nkk: Everything verified - move into place
+	if err := promote(runtimesStaging, opts.RuntimesDir); err != nil {
+		return nil, err
+	}
+	if err := promote(workspaceStaging, opts.WorkspaceDir); err != nil {
+		return nil, err
+	}
+
+	logger.Info("imported offline bundle",
+		zap.String("bundle_version", manifest.BundleVersion),
+		zap.Strings("playwright_versions", manifest.PlaywrightVersions),
+		zap.Int("files", len(manifest.Files)))
+
+	return manifest, nil
+}
+
+// verifyArchiveChecksum compares the archive against its .sha256 sidecar
+func verifyArchiveChecksum(archivePath string) error {
+	sidecar, err := os.ReadFile(archivePath + ".sha256")
+	if err != nil {
+		return fmt.Errorf("missing checksum file for %s: %w", archivePath, err)
+	}
+	fields := strings.Fields(string(sidecar))
+	if len(fields) == 0 {
+		return fmt.Errorf("empty checksum file for %s", archivePath)
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != fields[0] {
+		return fmt.Errorf("archive checksum mismatch: expected %s, got %s", fields[0], actual)
+	}
+	return nil
+}
+
+// extract unpacks the archive into the staging dir of each top-level prefix
+func extract(ctx context.Context, archivePath string, targets map[string]string) (*Manifest, map[string]FileEntry, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	extracted := make(map[string]FileEntry)
+	var manifest *Manifest
+
+	for {
+		if ctx.Err() != nil {
+			return nil, nil, ctx.Err()
+		}
+
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if header.Name == manifestName {
+			manifest = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, nil, fmt.Errorf("invalid bundle manifest: %w", err)
+			}
+			continue
+		}
+
+		name := path.Clean(strings.TrimSuffix(header.Name, "/"))
+		prefix, rel, _ := strings.Cut(name, "/")
+		root, ok := targets[prefix]
+		if !ok || rel == "" || !filepath.IsLocal(filepath.FromSlash(rel)) {
+			return nil, nil, fmt.Errorf("unexpected path in bundle: %s", header.Name)
+		}
+		dest := filepath.Join(root, filepath.FromSlash(rel))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				return nil, nil, err
+			}
+		case tar.TypeSymlink:
+			// nkk: Links (node_modules/.bin) must stay inside the bundle
+			target := path.Join(path.Dir(rel), header.Linkname)
+			if path.IsAbs(header.Linkname) || !filepath.IsLocal(filepath.FromSlash(target)) {
+				return nil, nil, fmt.Errorf("symlink escapes bundle: %s -> %s", header.Name, header.Linkname)
+			}
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return nil, nil, err
+			}
+			if err := os.Symlink(header.Linkname, dest); err != nil {
+				return nil, nil, err
+			}
+			extracted[name] = FileEntry{Path: name, Linkname: header.Linkname}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return nil, nil, err
+			}
+			out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fs.FileMode(header.Mode).Perm())
+			if err != nil {
+				return nil, nil, err
+			}
+			hash := sha256.New()
+			size, err := io.Copy(io.MultiWriter(out, hash), tr)
+			out.Close()
+			if err != nil {
+				return nil, nil, err
+			}
+			extracted[name] = FileEntry{Path: name, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}
+		default:
+			return nil, nil, fmt.Errorf("unsupported entry type in bundle: %s", header.Name)
+		}
+	}
+
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("bundle has no manifest")
+	}
+	return manifest, extracted, nil
+}
+
+// verifyManifest checks format, platform and every file checksum
+func verifyManifest(manifest *Manifest, extracted map[string]FileEntry) error {
+	if manifest.FormatVersion != FormatVersion {
+		return fmt.Errorf("unsupported bundle format %d, this agent reads format %d", manifest.FormatVersion, FormatVersion)
+	}
+	if manifest.OS != runtime.GOOS || manifest.Arch != runtime.GOARCH {
+		return fmt.Errorf("bundle was built for %s/%s, this machine is %s/%s", manifest.OS, manifest.Arch, runtime.GOOS, runtime.GOARCH)
+	}
+	if len(manifest.Files) != len(extracted) {
+		return fmt.Errorf("bundle contains %d files, manifest lists %d", len(extracted), len(manifest.Files))
+	}
+
+	for _, expected := range manifest.Files {
+		actual, ok := extracted[expected.Path]
+		if !ok {
+			return fmt.Errorf("bundle is missing %s", expected.Path)
+		}
+		if expected.Linkname != actual.Linkname || expected.SHA256 != actual.SHA256 || expected.Size != actual.Size {
+			return fmt.Errorf("checksum mismatch for %s", expected.Path)
+		}
+	}
+	return nil
+}
+
+// promote moves every entry of a staging dir into dest, replacing what is there
+func promote(staging, dest string) error {
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		from := filepath.Join(staging, entry.Name())
+		to := filepath.Join(dest, entry.Name())
+
+		// nkk: tests also holds generated plan files, so merge it instead of replacing
+		if entry.IsDir() && entry.Name() == "tests" {
+			if err := promote(from, to); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.RemoveAll(to); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(from, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package offline_bundle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+nkk: Unit tests for offline bundle export and import
+*/
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func exportFixture(t *testing.T) string {
+	t.Helper()
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "workspace", "package.json"), `{"name":"executions"}`)
+	writeFile(t, filepath.Join(src, "workspace", "node_modules", "dep", "index.js"), "module.exports = 1")
+	writeFile(t, filepath.Join(src, "workspace", "tests", "fixture.js"), "// fixture")
+	writeFile(t, filepath.Join(src, "runtimes", "1.47.2", "runtime.json"), `{"version":"1.47.2"}`)
+	writeFile(t, filepath.Join(src, "runtimes", "1.47.2", "browsers", "chromium-1134", "chrome"), "binary")
+
+	archive, manifest, err := Export(context.Background(), ExportOptions{
+		WorkspaceDir:       filepath.Join(src, "workspace"),
+		RuntimesDir:        filepath.Join(src, "runtimes"),
+		PlaywrightVersions: []string{"1.47.2"},
+		BundleVersion:      "test",
+		OutputDir:          filepath.Join(src, "dist"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, FormatVersion, manifest.FormatVersion)
+	assert.Len(t, manifest.Files, 5)
+	return archive
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	archive := exportFixture(t)
+
+	dest := t.TempDir()
+	workspace := filepath.Join(dest, "executions")
+	writeFile(t, filepath.Join(workspace, "tests", "existing.spec.js"), "// keep me")
+
+	manifest, err := Import(context.Background(), archive, ImportOptions{
+		WorkspaceDir: workspace,
+		RuntimesDir:  filepath.Join(dest, "runtimes"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "test", manifest.BundleVersion)
+
+	data, err := os.ReadFile(filepath.Join(dest, "runtimes", "1.47.2", "browsers", "chromium-1134", "chrome"))
+	require.NoError(t, err)
+	assert.Equal(t, "binary", string(data))
+	assert.FileExists(t, filepath.Join(workspace, "node_modules", "dep", "index.js"))
+	assert.FileExists(t, filepath.Join(workspace, "tests", "fixture.js"))
+	assert.FileExists(t, filepath.Join(workspace, "tests", "existing.spec.js"))
+
+	// nkk: No staging leftovers after a successful import
+	entries, err := os.ReadDir(filepath.Join(dest, "runtimes"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestImportRejectsTamperedArchive(t *testing.T) {
+	archive := exportFixture(t)
+
+	f, err := os.OpenFile(archive, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("tampered"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	dest := t.TempDir()
+	_, err = Import(context.Background(), archive, ImportOptions{
+		WorkspaceDir: filepath.Join(dest, "executions"),
+		RuntimesDir:  filepath.Join(dest, "runtimes"),
+	})
+	assert.ErrorContains(t, err, "checksum mismatch")
+	assert.NoDirExists(t, filepath.Join(dest, "runtimes", "1.47.2"))
+}
+
+func TestImportRejectsPathTraversal(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, ArchiveName("evil"))
+
+	f, err := os.Create(archive)
+	require.NoError(t, err)
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	content := []byte("owned")
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "runtimes/../../escape.txt", Mode: 0644, Size: int64(len(content))}))
+	_, err = tw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	require.NoError(t, f.Close())
+
+	data, err := os.ReadFile(archive)
+	require.NoError(t, err)
+	sum := sha256.Sum256(data)
+	writeFile(t, archive+".sha256", fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), filepath.Base(archive)))
+
+	dest := filepath.Join(dir, "dest")
+	_, err = Import(context.Background(), archive, ImportOptions{
+		WorkspaceDir: filepath.Join(dest, "executions"),
+		RuntimesDir:  filepath.Join(dest, "runtimes"),
+	})
+	assert.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "escape.txt"))
+	assert.NoFileExists(t, filepath.Join(dest, "escape.txt"))
+}
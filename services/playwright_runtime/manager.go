@@ -0,0 +1,488 @@
+package playwright_runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"agent/logger"
+)
+
+/*
+nkk: Side-by-side Playwright runtimes
+Each version lives in its own directory with its own node_modules and browsers:
+
+	<root>/<version>/
+	    package.json
+	    node_modules/@playwright/test
+	    browsers/            (PLAYWRIGHT_BROWSERS_PATH)
+	    runtime.json         (install + last used metadata)
+
+Design:
+- Installed on demand, concurrent requests for the same version share one install
+- Installs happen in a temp dir and are renamed in place, so a half install is never used
+- Versions not used for MaxIdle are garbage-collected, the default version is always kept
+*/
+
+const metadataFileName = "runtime.json"
+
+// versionPattern restricts versions to semver so they are safe as paths and npm specs
+var versionPattern = regexp.MustCompile(`^\d+\.\d+\.\d+(-[0-9A-Za-z.]+)?$`)
+
+// BrowserRevision is a browser build bundled with a Playwright version
+type BrowserRevision struct {
+	Name             string `json:"name"`
+	Revision         string `json:"revision"`
+	BrowserVersion   string `json:"browserVersion,omitempty"`
+	InstallByDefault bool   `json:"installByDefault"`
+	Installed        bool   `json:"installed"`
+}
+
+// dirName is the folder Playwright installs this browser into
+func (b BrowserRevision) dirName() string {
+	return strings.ReplaceAll(b.Name, "-", "_") + "-" + b.Revision
+}
+
+// MissingBrowsersError lists required browsers that are not installed
+type MissingBrowsersError struct {
+	Version  string
+	Browsers []string
+}
+
+func (e *MissingBrowsersError) Error() string {
+	return fmt.Sprintf("playwright %s is missing browsers: %s (run `npx playwright install` or import an offline bundle)",
+		e.Version, strings.Join(e.Browsers, ", "))
+}
+
+// Runtime is an installed Playwright version
+type Runtime struct {
+	Version     string            `json:"version"`
+	Dir         string            `json:"dir"`
+	Browsers    []BrowserRevision `json:"browsers"`
+	InstalledAt time.Time         `json:"installed_at"`
+	LastUsedAt  time.Time         `json:"last_used_at"`
+	InUse       int               `json:"in_use"`
+	Default     bool              `json:"default"`
+}
+
+// CLIPath returns the Playwright test CLI of this runtime
+func (r *Runtime) CLIPath() string {
+	return filepath.Join(r.Dir, "node_modules", "@playwright", "test", "cli.js")
+}
+
+// NodePath returns the module directory test files resolve @playwright/test from
+func (r *Runtime) NodePath() string {
+	return filepath.Join(r.Dir, "node_modules")
+}
+
+// BrowsersPath returns the directory holding this runtime's browsers
+func (r *Runtime) BrowsersPath() string {
+	return filepath.Join(r.Dir, "browsers")
+}
+
+// Installer installs Playwright version into dir
+type Installer func(ctx context.Context, dir, version string) error
+
+type installCall struct {
+	done chan struct{}
+	err  error
+}
+
+// Manager manages installed Playwright runtimes
+type Manager struct {
+	root           string
+	defaultVersion string
+	installer      Installer
+	offline        bool
+
+	mu         sync.Mutex
+	installing map[string]*installCall
+	inUse      map[string]int
+}
+
+// NewManager creates a new runtime manager rooted at root
+func NewManager(root, defaultVersion string) *Manager {
+	return &Manager{
+		root:           root,
+		defaultVersion: defaultVersion,
+		installer:      npmInstall,
+		installing:     make(map[string]*installCall),
+		inUse:          make(map[string]int),
+	}
+}
+
+// SetInstaller overrides how versions are installed
+func (m *Manager) SetInstaller(installer Installer) {
+	m.installer = installer
+}
+
+// SetOffline disables on-demand installs, runtimes must come from an offline bundle
+func (m *Manager) SetOffline(offline bool) {
+	m.offline = offline
+}
+
+// DefaultVersion returns the version used when an execution does not ask for one
+func (m *Manager) DefaultVersion() string {
+	return m.defaultVersion
+}
+
+// ValidateVersion checks that version is a plain semver string
+func ValidateVersion(version string) error {
+	if !versionPattern.MatchString(version) {
+		return fmt.Errorf("invalid playwright version %q", version)
+	}
+	return nil
+}
+
+// Ensure returns the runtime for version, installing it if needed
+func (m *Manager) Ensure(ctx context.Context, version string) (*Runtime, error) {
+	if version == "" {
+		version = m.defaultVersion
+	}
+	if err := ValidateVersion(version); err != nil {
+		return nil, err
+	}
+
+	for {
+		if rt, err := m.load(version); err == nil {
+			return rt, nil
+		}
+
+		m.mu.Lock()
+		call, ok := m.installing[version]
+		if !ok {
+			call = &installCall{done: make(chan struct{})}
+			m.installing[version] = call
+			// nkk: The install is shared, so one caller going away must not cancel it for the others
+			go m.runInstall(context.WithoutCancel(ctx), version, call)
+		}
+		m.mu.Unlock()
+
+		select {
+		case <-call.done:
+			if call.err != nil {
+				return nil, call.err
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// runInstall installs version for every caller waiting on call
+func (m *Manager) runInstall(ctx context.Context, version string, call *installCall) {
+	call.err = m.install(ctx, version)
+
+	m.mu.Lock()
+	delete(m.installing, version)
+	m.mu.Unlock()
+	close(call.done)
+}
+
+// Acquire ensures version and marks it in use until release is called
+func (m *Manager) Acquire(ctx context.Context, version string) (*Runtime, func(), error) {
+	var rt *Runtime
+	for {
+		var err error
+		rt, err = m.Ensure(ctx, version)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		m.mu.Lock()
+		// nkk: GC holds the lock while removing, so the runtime is safe once counted
+		_, statErr := os.Stat(filepath.Join(rt.Dir, metadataFileName))
+		if statErr == nil {
+			m.inUse[rt.Version]++
+		}
+		m.mu.Unlock()
+
+		if statErr == nil {
+			break
+		}
+	}
+
+	m.touch(rt)
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			m.mu.Lock()
+			m.inUse[rt.Version]--
+			if m.inUse[rt.Version] <= 0 {
+				delete(m.inUse, rt.Version)
+			}
+			m.mu.Unlock()
+			m.touch(rt)
+		})
+	}
+	return rt, release, nil
+}
+
+// List returns all installed runtimes sorted by version
+func (m *Manager) List() ([]Runtime, error) {
+	entries, err := os.ReadDir(m.root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Runtime{}, nil
+		}
+		return nil, err
+	}
+
+	runtimes := make([]Runtime, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() || !versionPattern.MatchString(entry.Name()) {
+			continue
+		}
+		rt, err := m.load(entry.Name())
+		if err != nil {
+			continue
+		}
+		runtimes = append(runtimes, *rt)
+	}
+
+	sort.Slice(runtimes, func(i, j int) bool { return compareVersions(runtimes[i].Version, runtimes[j].Version) < 0 })
+	return runtimes, nil
+}
+
+// GC removes runtimes that are not in use and have been idle longer than maxIdle
+func (m *Manager) GC(maxIdle time.Duration) ([]string, error) {
+	runtimes, err := m.List()
+	if err != nil {
+		return nil, err
+	}
+
+	removed := make([]string, 0)
+	for _, rt := range runtimes {
+		if rt.Default || rt.InUse > 0 || time.Since(rt.LastUsedAt) < maxIdle {
+			continue
+		}
+
+		m.mu.Lock()
+		// nkk: Re-check under lock, an execution may have acquired it meanwhile
+		if m.inUse[rt.Version] > 0 {
+			m.mu.Unlock()
+			continue
+		}
+		// nkk: Rename first so a concurrent load never sees a half deleted runtime
+		trashDir := filepath.Join(m.root, ".gc-"+rt.Version+"-"+fmt.Sprint(time.Now().UnixNano()))
+		err := os.Rename(rt.Dir, trashDir)
+		m.mu.Unlock()
+
+		if err == nil {
+			err = os.RemoveAll(trashDir)
+		}
+		if err != nil {
+			logger.Error("failed to remove playwright runtime", zap.String("version", rt.Version), zap.Error(err))
+			continue
+		}
+		logger.Info("removed unused playwright runtime", zap.String("version", rt.Version))
+		removed = append(removed, rt.Version)
+	}
+	return removed, nil
+}
+
+// StartGC periodically garbage-collects idle runtimes until ctx is cancelled
+func (m *Manager) StartGC(ctx context.Context, interval, maxIdle time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := m.GC(maxIdle); err != nil {
+					logger.Error("playwright runtime gc failed", zap.Error(err))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// load reads an installed runtime from disk
+func (m *Manager) load(version string) (*Runtime, error) {
+	dir := filepath.Join(m.root, version)
+	data, err := os.ReadFile(filepath.Join(dir, metadataFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var rt Runtime
+	if err := json.Unmarshal(data, &rt); err != nil {
+		return nil, err
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	rt.Dir = absDir
+	rt.Version = version
+	rt.Default = version == m.defaultVersion
+	rt.Browsers = readBrowserRevisions(absDir)
+
+	m.mu.Lock()
+	rt.InUse = m.inUse[version]
+	m.mu.Unlock()
+
+	return &rt, nil
+}
+
+// install installs version into a temp dir and moves it into place
+func (m *Manager) install(ctx context.Context, version string) error {
+	if m.offline {
+		return fmt.Errorf("playwright %s is not installed and the agent is offline, import an offline bundle that contains it", version)
+	}
+
+	if err := os.MkdirAll(m.root, 0755); err != nil {
+		return err
+	}
+
+	tmpDir, err := os.MkdirTemp(m.root, "."+version+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	logger.Info("installing playwright runtime", zap.String("version", version))
+	start := time.Now()
+
+	if err := m.installer(ctx, tmpDir, version); err != nil {
+		return fmt.Errorf("failed to install playwright %s: %w", version, err)
+	}
+
+	now := time.Now()
+	metadata, err := json.MarshalIndent(Runtime{Version: version, InstalledAt: now, LastUsedAt: now}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, metadataFileName), metadata, 0644); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpDir, filepath.Join(m.root, version)); err != nil {
+		return err
+	}
+
+	logger.Info("installed playwright runtime",
+		zap.String("version", version),
+		zap.Duration("duration", time.Since(start)))
+	return nil
+}
+
+// touch records the last time a runtime was used
+func (m *Manager) touch(rt *Runtime) {
+	metadata, err := json.MarshalIndent(Runtime{
+		Version:     rt.Version,
+		InstalledAt: rt.InstalledAt,
+		LastUsedAt:  time.Now(),
+	}, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(filepath.Join(rt.Dir, metadataFileName), metadata, 0644); err != nil {
+		logger.Warn("failed to update playwright runtime metadata", zap.String("version", rt.Version), zap.Error(err))
+	}
+}
+
+// VerifyBrowsers checks that the required browsers of a runtime are present on disk
+func (m *Manager) VerifyBrowsers(version string, required []string) error {
+	if version == "" {
+		version = m.defaultVersion
+	}
+	rt, err := m.load(version)
+	if err != nil {
+		return fmt.Errorf("playwright %s is not installed: %w", version, err)
+	}
+
+	installed := make(map[string]bool)
+	for _, browser := range rt.Browsers {
+		installed[browser.Name] = browser.Installed
+	}
+
+	missing := make([]string, 0)
+	for _, name := range required {
+		if !installed[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return &MissingBrowsersError{Version: version, Browsers: missing}
+	}
+	return nil
+}
+
+// compareVersions orders semver strings numerically
+func compareVersions(a, b string) int {
+	var aMajor, aMinor, aPatch, bMajor, bMinor, bPatch int
+	fmt.Sscanf(a, "%d.%d.%d", &aMajor, &aMinor, &aPatch)
+	fmt.Sscanf(b, "%d.%d.%d", &bMajor, &bMinor, &bPatch)
+
+	for _, diff := range []int{aMajor - bMajor, aMinor - bMinor, aPatch - bPatch} {
+		if diff != 0 {
+			return diff
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// readBrowserRevisions reads the browser builds pinned by playwright-core
+func readBrowserRevisions(dir string) []BrowserRevision {
+	data, err := os.ReadFile(filepath.Join(dir, "node_modules", "playwright-core", "browsers.json"))
+	if err != nil {
+		return []BrowserRevision{}
+	}
+
+	var manifest struct {
+		Browsers []BrowserRevision `json:"browsers"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return []BrowserRevision{}
+	}
+
+	for i := range manifest.Browsers {
+		_, err := os.Stat(filepath.Join(dir, "browsers", manifest.Browsers[i].dirName()))
+		manifest.Browsers[i].Installed = err == nil
+	}
+	return manifest.Browsers
+}
+
+// npmInstall installs @playwright/test and its browsers into dir
+func npmInstall(ctx context.Context, dir, version string) error {
+	packageJSON := `{"name":"playwright-runtime","private":true}`
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(packageJSON), 0644); err != nil {
+		return err
+	}
+
+	env := append(os.Environ(), "PLAYWRIGHT_BROWSERS_PATH="+filepath.Join(dir, "browsers"))
+
+	steps := [][]string{
+		{"npm", "install", "--no-audit", "--no-fund", "@playwright/test@" + version},
+		{"npx", "playwright", "install"},
+	}
+	for _, step := range steps {
+		cmd := exec.CommandContext(ctx, step[0], step[1:]...)
+		cmd.Dir = dir
+		cmd.Env = env
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			logger.Error("playwright runtime install step failed",
+				zap.Strings("command", step),
+				zap.String("output", string(output)))
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package playwright_runtime
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+nkk: Unit tests for the Playwright runtime manager
+A fake installer writes a browsers.json instead of running npm
+*/
+
+func fakeInstaller(installs *int32) Installer {
+	return func(ctx context.Context, dir, version string) error {
+		atomic.AddInt32(installs, 1)
+		coreDir := filepath.Join(dir, "node_modules", "playwright-core")
+		if err := os.MkdirAll(coreDir, 0755); err != nil {
+			return err
+		}
+		browsers := `{"browsers":[{"name":"chromium","revision":"1134","browserVersion":"129.0.6668.29"}]}`
+		return os.WriteFile(filepath.Join(coreDir, "browsers.json"), []byte(browsers), 0644)
+	}
+}
+
+func TestEnsureInstallsOnce(t *testing.T) {
+	var installs int32
+	m := NewManager(t.TempDir(), "1.47.2")
+	m.SetInstaller(fakeInstaller(&installs))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.Ensure(context.Background(), "1.48.0")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&installs))
+}
+
+func TestEnsureInstallOutlivesFirstCaller(t *testing.T) {
+	var installs int32
+	release := make(chan struct{})
+	install := fakeInstaller(&installs)
+	m := NewManager(t.TempDir(), "1.47.2")
+	m.SetInstaller(func(ctx context.Context, dir, version string) error {
+		<-release
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return install(ctx, dir, version)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := m.Ensure(ctx, "1.48.0")
+		firstErr <- err
+	}()
+	waiterErr := make(chan error, 1)
+	go func() {
+		_, err := m.Ensure(context.Background(), "1.48.0")
+		waiterErr <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-firstErr, context.Canceled, "the first caller stops waiting on its own ctx")
+	close(release)
+	assert.NoError(t, <-waiterErr, "the shared install is not cancelled with the first caller")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&installs))
+}
+
+func TestEnsureRejectsInvalidVersion(t *testing.T) {
+	m := NewManager(t.TempDir(), "1.47.2")
+	_, err := m.Ensure(context.Background(), "../1.47.2")
+	assert.Error(t, err)
+}
+
+func TestListReportsBrowserRevisions(t *testing.T) {
+	var installs int32
+	m := NewManager(t.TempDir(), "1.47.2")
+	m.SetInstaller(fakeInstaller(&installs))
+
+	_, err := m.Ensure(context.Background(), "")
+	require.NoError(t, err)
+	_, err = m.Ensure(context.Background(), "1.10.0")
+	require.NoError(t, err)
+
+	runtimes, err := m.List()
+	require.NoError(t, err)
+	require.Len(t, runtimes, 2)
+	assert.Equal(t, "1.10.0", runtimes[0].Version)
+	assert.Equal(t, "1.47.2", runtimes[1].Version)
+	assert.True(t, runtimes[1].Default)
+	assert.Equal(t, "chromium", runtimes[1].Browsers[0].Name)
+	assert.Equal(t, "1134", runtimes[1].Browsers[0].Revision)
+}
+
+func TestGCKeepsDefaultAndInUse(t *testing.T) {
+	var installs int32
+	m := NewManager(t.TempDir(), "1.47.2")
+	m.SetInstaller(fakeInstaller(&installs))
+
+	for _, version := range []string{"1.47.2", "1.46.0", "1.45.0"} {
+		_, err := m.Ensure(context.Background(), version)
+		require.NoError(t, err)
+	}
+
+	_, release, err := m.Acquire(context.Background(), "1.46.0")
+	require.NoError(t, err)
+
+	removed, err := m.GC(0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.45.0"}, removed)
+
+	release()
+	removed, err = m.GC(time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+
+	removed, err = m.GC(0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.46.0"}, removed)
+}
+
+func TestVerifyBrowsersReportsMissing(t *testing.T) {
+	var installs int32
+	root := t.TempDir()
+	m := NewManager(root, "1.47.2")
+	m.SetInstaller(fakeInstaller(&installs))
+
+	_, err := m.Ensure(context.Background(), "1.47.2")
+	require.NoError(t, err)
+
+	err = m.VerifyBrowsers("", []string{"chromium"})
+	var missing *MissingBrowsersError
+	require.ErrorAs(t, err, &missing)
+	assert.Equal(t, []string{"chromium"}, missing.Browsers)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "1.47.2", "browsers", "chromium-1134"), 0755))
+	assert.NoError(t, m.VerifyBrowsers("1.47.2", []string{"chromium"}))
+}
+
+func TestOfflineManagerDoesNotInstall(t *testing.T) {
+	var installs int32
+	m := NewManager(t.TempDir(), "1.47.2")
+	m.SetInstaller(fakeInstaller(&installs))
+	m.SetOffline(true)
+
+	_, err := m.Ensure(context.Background(), "1.47.2")
+	assert.Error(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&installs))
+}
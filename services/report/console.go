@@ -0,0 +1,67 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// nkk: Browser console of an execution, built from the fixture's console lines and kept next to the reports
+
+const ConsoleFile = "console.json"
+
+// ConsoleEntry is one console message or page error, as printed by the fixture
+type ConsoleEntry struct {
+	ExecutionId    string    `json:"execution_id"`
+	TestcaseId     string    `json:"testcase_id,omitempty"`
+	TestsuiteId    string    `json:"testsuite_id,omitempty"`
+	TestplanId     string    `json:"testplan_id,omitempty"`
+	MachineId      string    `json:"machine_id,omitempty"`
+	IsPreRequisite bool      `json:"is_prerequisite,omitempty"`
+	Time           time.Time `json:"time"`
+	Type           string    `json:"type"`            // nkk: console message type, pageerror for uncaught errors and rejections
+	Level          string    `json:"level,omitempty"` // nkk: filled in by the agent
+	Text           string    `json:"text"`
+	URL            string    `json:"url,omitempty"`
+	Line           int       `json:"line,omitempty"`
+	Column         int       `json:"column,omitempty"`
+	Uncaught       bool      `json:"uncaught,omitempty"`
+}
+
+// ConsoleLog holds the console entries of one testcase run
+type ConsoleLog struct {
+	TestcaseId     string         `json:"testcase_id"`
+	TestsuiteId    string         `json:"testsuite_id,omitempty"`
+	MachineId      string         `json:"machine_id,omitempty"`
+	IsPreRequisite bool           `json:"is_prerequisite,omitempty"`
+	Level          string         `json:"level"`
+	UncaughtErrors int            `json:"uncaught_errors"`
+	Entries        []ConsoleEntry `json:"entries"`
+}
+
+// ConsoleReport is the content of console.json
+type ConsoleReport struct {
+	ExecutionId string       `json:"execution_id"`
+	Testcases   []ConsoleLog `json:"testcases"`
+}
+
+// WriteConsole stores the console logs of an execution
+func (s *Store) WriteConsole(executionId string, logs []ConsoleLog) error {
+	dir, err := s.Dir(executionId)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if logs == nil {
+		logs = []ConsoleLog{}
+	}
+	return writeFile(filepath.Join(dir, ConsoleFile), func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(ConsoleReport{ExecutionId: executionId, Testcases: logs})
+	})
+}
@@ -0,0 +1,89 @@
+package report
+
+import (
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// nkk: Single static page, inline CSS and no scripts, so it can be archived by CI or opened from disk.
+// Attachment links are relative to the report directory.
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"duration": func(d time.Duration) string {
+		if d < time.Second {
+			return d.Round(time.Millisecond).String()
+		}
+		return d.Round(100 * time.Millisecond).String()
+	},
+	"timestamp": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format("2006-01-02 15:04:05 UTC")
+	},
+	"isImage": func(a *Attachment) bool { return strings.HasPrefix(a.ContentType, "image/") },
+	"isVideo": func(a *Attachment) bool { return strings.HasPrefix(a.ContentType, "video/") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{if .Title}}{{.Title}}{{else}}Execution {{.ExecutionId}}{{end}}</title>
+<style>
+body{font-family:-apple-system,BlinkMacSystemFont,"Segoe UI",Roboto,sans-serif;margin:0;padding:24px;color:#1f2328;background:#f6f8fa}
+h1{font-size:20px;margin:0 0 4px}
+.meta{color:#656d76;font-size:13px;margin-bottom:16px}
+.stats{display:flex;gap:12px;margin-bottom:24px;flex-wrap:wrap}
+.stat{background:#fff;border:1px solid #d0d7de;border-radius:6px;padding:8px 16px;font-size:13px}
+.stat b{display:block;font-size:20px}
+.suite{background:#fff;border:1px solid #d0d7de;border-radius:6px;margin-bottom:16px}
+.suite h2{font-size:14px;margin:0;padding:10px 16px;border-bottom:1px solid #d0d7de;background:#f6f8fa}
+.suite h2 span{color:#656d76;font-weight:normal}
+.case{padding:8px 16px;border-bottom:1px solid #eaeef2}
+.case:last-child{border-bottom:0}
+.case summary{cursor:pointer;list-style:none;display:flex;gap:8px;align-items:center}
+.badge{font-size:11px;font-weight:600;border-radius:10px;padding:2px 8px;text-transform:uppercase}
+.passed{background:#dafbe1;color:#1a7f37}.failed,.interrupted{background:#ffebe9;color:#cf222e}
+.flaky{background:#fff8c5;color:#9a6700}.skipped{background:#eaeef2;color:#656d76}
+.name{flex:1}.time{color:#656d76;font-size:12px}
+pre{background:#f6f8fa;border-radius:6px;padding:8px;overflow-x:auto;font-size:12px;white-space:pre-wrap}
+.message{color:#cf222e}
+.attachments{display:flex;gap:12px;flex-wrap:wrap;margin-top:8px}
+.attachments img,.attachments video{max-width:480px;border:1px solid #d0d7de;border-radius:6px}
+</style>
+</head>
+<body>
+<h1>{{if .Title}}{{.Title}}{{else}}Execution {{.ExecutionId}}{{end}}</h1>
+<div class="meta">Execution {{.ExecutionId}}{{with timestamp .StartedAt}} · {{.}}{{end}} · {{duration .Duration}}</div>
+<div class="stats">
+<div class="stat"><b>{{.Tests}}</b>tests</div>
+<div class="stat"><b>{{.Failures}}</b>failed</div>
+<div class="stat"><b>{{.Errors}}</b>errors</div>
+<div class="stat"><b>{{.Flaky}}</b>flaky</div>
+<div class="stat"><b>{{.Skipped}}</b>skipped</div>
+</div>
+{{with .RunErrors}}<div class="suite"><h2>Run errors</h2>{{range .}}<div class="case"><pre class="message">{{.}}</pre></div>{{end}}</div>{{end}}
+{{range .Suites}}<div class="suite">
+<h2>{{.Name}}{{if .Project}} <span>· {{.Project}}</span>{{end}} <span>· {{.Tests}} tests · {{duration .Duration}}</span></h2>
+{{range .Cases}}<details class="case"{{if or (eq .Status "failed") (eq .Status "interrupted")}} open{{end}}>
+<summary><span class="badge {{.Status}}">{{.Status}}</span><span class="name">{{.Name}}</span><span class="time">{{duration .Duration}}{{if .Retries}} · {{.Retries}} retries{{end}}</span></summary>
+{{with .Message}}<pre class="message">{{.}}</pre>{{end}}
+{{if and .Stack (ne .Stack .Message)}}<pre>{{.Stack}}</pre>{{end}}
+{{with .Output}}<pre>{{.}}</pre>{{end}}
+{{if .Attachments}}<div class="attachments">{{range .Attachments}}{{if .Path}}
+{{if isImage .}}<a href="{{.Path}}"><img src="{{.Path}}" alt="{{.Name}}"></a>
+{{else if isVideo .}}<video src="{{.Path}}" controls preload="metadata"></video>
+{{else}}<a href="{{.Path}}">{{.Name}}</a>{{end}}{{end}}{{end}}</div>{{end}}
+</details>
+{{end}}</div>
+{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML renders the report as a static HTML page
+func WriteHTML(w io.Writer, r *Report) error {
+	return htmlTemplate.Execute(w, r)
+}
@@ -0,0 +1,148 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// nkk: JUnit XML in the flavour Jenkins and GitLab both ingest, attachments use the
+// [[ATTACHMENT|path]] convention of the Jenkins attachments plugin
+
+type junitTestsuites struct {
+	XMLName   xml.Name         `xml:"testsuites"`
+	Name      string           `xml:"name,attr"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Errors    int              `xml:"errors,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      string           `xml:"time,attr"`
+	Timestamp string           `xml:"timestamp,attr,omitempty"`
+	Suites    []junitTestsuite `xml:"testsuite"`
+}
+
+type junitTestsuite struct {
+	Name      string          `xml:"name,attr"`
+	Hostname  string          `xml:"hostname,attr,omitempty"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestcase `xml:"testcase"`
+}
+
+type junitTestcase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit renders the report as JUnit XML
+func WriteJUnit(w io.Writer, r *Report) error {
+	doc := junitTestsuites{
+		Name:     firstNonEmpty(r.Title, r.ExecutionId),
+		Tests:    r.Tests,
+		Failures: r.Failures,
+		Errors:   r.Errors + len(r.RunErrors),
+		Skipped:  r.Skipped,
+		Time:     seconds(r.Duration),
+		Suites:   make([]junitTestsuite, 0, len(r.Suites)),
+	}
+	if !r.StartedAt.IsZero() {
+		doc.Timestamp = r.StartedAt.UTC().Format(time.RFC3339)
+	}
+
+	for _, suite := range r.Suites {
+		js := junitTestsuite{
+			Name:      suite.Name,
+			Hostname:  suite.Project,
+			Tests:     suite.Tests,
+			Failures:  suite.Failures,
+			Errors:    suite.Errors,
+			Skipped:   suite.Skipped,
+			Time:      seconds(suite.Duration),
+			Timestamp: doc.Timestamp,
+		}
+		for _, c := range suite.Cases {
+			js.Cases = append(js.Cases, junitCase(c))
+		}
+		doc.Suites = append(doc.Suites, js)
+	}
+
+	// nkk: Errors outside tests get a suite of their own so CI marks the build failed
+	if len(r.RunErrors) > 0 {
+		js := junitTestsuite{Name: "run", Tests: len(r.RunErrors), Errors: len(r.RunErrors), Time: "0"}
+		for i, message := range r.RunErrors {
+			js.Cases = append(js.Cases, junitTestcase{
+				Name:      fmt.Sprintf("run error %d", i+1),
+				Classname: "run",
+				Time:      "0",
+				Error:     &junitFailure{Message: firstLine(message), Type: "Error", Body: message},
+			})
+		}
+		doc.Tests += len(r.RunErrors)
+		doc.Suites = append(doc.Suites, js)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitCase(c *Case) junitTestcase {
+	jc := junitTestcase{
+		Name:      c.Name,
+		Classname: c.Classname,
+		Time:      seconds(c.Duration),
+	}
+	if c.Project != "" {
+		jc.Name = fmt.Sprintf("[%s] %s", c.Project, c.Name)
+	}
+
+	switch c.Status {
+	case CaseFailed:
+		jc.Failure = &junitFailure{Message: c.Message, Type: "AssertionError", Body: c.Stack}
+	case CaseInterrupted:
+		jc.Error = &junitFailure{Message: firstNonEmpty(c.Message, "Test was interrupted"), Type: "Interrupted", Body: c.Stack}
+	case CaseSkipped:
+		jc.Skipped = &struct{}{}
+	}
+
+	var out strings.Builder
+	out.WriteString(c.Output)
+	if c.Status == CaseFlaky {
+		fmt.Fprintf(&out, "\nflaky: passed after %d retries, first failure: %s", c.Retries, c.Message)
+	}
+	for _, attachment := range c.Attachments {
+		if attachment.Path != "" {
+			fmt.Fprintf(&out, "\n[[ATTACHMENT|%s]]", attachment.Path)
+		}
+	}
+	jc.SystemOut = strings.TrimSpace(out.String())
+	return jc
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,93 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// nkk: Subset of the Playwright JSON reporter output (--reporter=json) the reports are built from
+
+// PlaywrightReport is the root of the JSON reporter output
+type PlaywrightReport struct {
+	Suites []PlaywrightSuite `json:"suites"`
+	Errors []PlaywrightError `json:"errors"`
+	Stats  PlaywrightStats   `json:"stats"`
+}
+
+type PlaywrightStats struct {
+	StartTime  time.Time `json:"startTime"`
+	Duration   float64   `json:"duration"`
+	Expected   int       `json:"expected"`
+	Unexpected int       `json:"unexpected"`
+	Flaky      int       `json:"flaky"`
+	Skipped    int       `json:"skipped"`
+}
+
+// PlaywrightSuite is a file or a describe block
+type PlaywrightSuite struct {
+	Title  string            `json:"title"`
+	File   string            `json:"file"`
+	Line   int               `json:"line"`
+	Specs  []PlaywrightSpec  `json:"specs"`
+	Suites []PlaywrightSuite `json:"suites"`
+}
+
+type PlaywrightSpec struct {
+	Title string           `json:"title"`
+	File  string           `json:"file"`
+	Line  int              `json:"line"`
+	Tests []PlaywrightTest `json:"tests"`
+}
+
+// PlaywrightTest is a spec run in one project (browser)
+type PlaywrightTest struct {
+	ProjectName    string                 `json:"projectName"`
+	ExpectedStatus string                 `json:"expectedStatus"`
+	Status         string                 `json:"status"` // expected, unexpected, flaky, skipped
+	Results        []PlaywrightTestResult `json:"results"`
+}
+
+// PlaywrightTestResult is one attempt, retries add more
+type PlaywrightTestResult struct {
+	Status      string                 `json:"status"` // passed, failed, timedOut, skipped, interrupted
+	Duration    float64                `json:"duration"`
+	StartTime   time.Time              `json:"startTime"`
+	Retry       int                    `json:"retry"`
+	Error       *PlaywrightError       `json:"error,omitempty"`
+	Errors      []PlaywrightError      `json:"errors"`
+	Stdout      []PlaywrightOutput     `json:"stdout"`
+	Stderr      []PlaywrightOutput     `json:"stderr"`
+	Attachments []PlaywrightAttachment `json:"attachments"`
+}
+
+type PlaywrightError struct {
+	Message string `json:"message"`
+	Stack   string `json:"stack"`
+}
+
+// PlaywrightOutput is a chunk of test output, either text or base64 buffer
+type PlaywrightOutput struct {
+	Text string `json:"text"`
+}
+
+type PlaywrightAttachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"contentType"`
+	Path        string `json:"path,omitempty"`
+}
+
+// ReadPlaywrightReport parses the JSON reporter output at path
+func ReadPlaywrightReport(path string) (*PlaywrightReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var report PlaywrightReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("parsing playwright report %s: %w", path, err)
+	}
+	return &report, nil
+}
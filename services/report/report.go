@@ -0,0 +1,249 @@
+package report
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+/*
+nkk: Execution reports for CI
+The Playwright JSON reporter output of an execution is normalised into a Report,
+which is then rendered as JUnit XML (Jenkins, GitLab) and a static HTML page.
+Suites follow Playwright's own junit reporter: one per (project, file).
+*/
+
+const (
+	CasePassed      = "passed"
+	CaseFailed      = "failed"
+	CaseFlaky       = "flaky"
+	CaseSkipped     = "skipped"
+	CaseInterrupted = "interrupted"
+)
+
+// maxOutputBytes caps the captured stdout per testcase
+const maxOutputBytes = 64 * 1024
+
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// Report is one execution's results
+type Report struct {
+	ExecutionId string        `json:"execution_id"`
+	Title       string        `json:"title"`
+	StartedAt   time.Time     `json:"started_at"`
+	Duration    time.Duration `json:"duration"`
+	Tests       int           `json:"tests"`
+	Failures    int           `json:"failures"`
+	Errors      int           `json:"errors"`
+	Skipped     int           `json:"skipped"`
+	Flaky       int           `json:"flaky"`
+	Suites      []*Suite      `json:"suites"`
+	RunErrors   []string      `json:"run_errors,omitempty"` // nkk: Errors outside any test, e.g. a script that does not compile
+}
+
+// Passed reports whether nothing failed
+func (r *Report) Passed() bool {
+	return r.Failures == 0 && r.Errors == 0 && len(r.RunErrors) == 0
+}
+
+type Suite struct {
+	Name     string        `json:"name"`
+	File     string        `json:"file"`
+	Project  string        `json:"project"`
+	Tests    int           `json:"tests"`
+	Failures int           `json:"failures"`
+	Errors   int           `json:"errors"`
+	Skipped  int           `json:"skipped"`
+	Duration time.Duration `json:"duration"`
+	Cases    []*Case       `json:"cases"`
+}
+
+type Case struct {
+	Name        string        `json:"name"`
+	Classname   string        `json:"classname"`
+	Project     string        `json:"project"`
+	Status      string        `json:"status"`
+	Duration    time.Duration `json:"duration"`
+	Retries     int           `json:"retries"`
+	Message     string        `json:"message,omitempty"`
+	Stack       string        `json:"stack,omitempty"`
+	Output      string        `json:"output,omitempty"`
+	Attachments []*Attachment `json:"attachments,omitempty"`
+}
+
+// Attachment is a screenshot, video or trace of a testcase
+type Attachment struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+	Path        string `json:"path,omitempty"` // nkk: Relative to the report directory once copied
+	Source      string `json:"-"`              // nkk: Path written by Playwright
+}
+
+// Build normalises a Playwright JSON report
+func Build(executionId, title string, pr *PlaywrightReport) *Report {
+	r := &Report{
+		ExecutionId: executionId,
+		Title:       title,
+		StartedAt:   pr.Stats.StartTime,
+		Duration:    millis(pr.Stats.Duration),
+		Suites:      make([]*Suite, 0),
+	}
+
+	for _, err := range pr.Errors {
+		r.RunErrors = append(r.RunErrors, cleanText(firstNonEmpty(err.Message, err.Stack)))
+	}
+
+	suites := make(map[string]*Suite)
+	for _, fileSuite := range pr.Suites {
+		file := firstNonEmpty(fileSuite.File, fileSuite.Title)
+		collectSpecs(fileSuite, nil, func(titlePath []string, spec PlaywrightSpec) {
+			for _, test := range spec.Tests {
+				key := test.ProjectName + "\x00" + file
+				suite, ok := suites[key]
+				if !ok {
+					suite = &Suite{Name: file, File: file, Project: test.ProjectName}
+					suites[key] = suite
+					r.Suites = append(r.Suites, suite)
+				}
+				suite.add(buildCase(file, append(titlePath, spec.Title), test))
+			}
+		})
+	}
+
+	for _, suite := range r.Suites {
+		r.Tests += suite.Tests
+		r.Failures += suite.Failures
+		r.Errors += suite.Errors
+		r.Skipped += suite.Skipped
+		for _, c := range suite.Cases {
+			if c.Status == CaseFlaky {
+				r.Flaky++
+			}
+		}
+	}
+	return r
+}
+
+// collectSpecs walks nested describe blocks, titlePath excludes the file suite
+func collectSpecs(suite PlaywrightSuite, titlePath []string, fn func([]string, PlaywrightSpec)) {
+	for _, spec := range suite.Specs {
+		fn(append([]string{}, titlePath...), spec)
+	}
+	for _, child := range suite.Suites {
+		collectSpecs(child, append(append([]string{}, titlePath...), child.Title), fn)
+	}
+}
+
+func (s *Suite) add(c *Case) {
+	s.Cases = append(s.Cases, c)
+	s.Tests++
+	s.Duration += c.Duration
+	switch c.Status {
+	case CaseFailed:
+		s.Failures++
+	case CaseInterrupted:
+		s.Errors++
+	case CaseSkipped:
+		s.Skipped++
+	}
+}
+
+func buildCase(file string, titlePath []string, test PlaywrightTest) *Case {
+	c := &Case{
+		Name:      strings.Join(titlePath, " › "),
+		Classname: file,
+		Project:   test.ProjectName,
+		Status:    CasePassed,
+	}
+	if len(test.Results) == 0 {
+		c.Status = CaseSkipped
+		return c
+	}
+
+	for _, result := range test.Results {
+		c.Duration += millis(result.Duration)
+	}
+	c.Retries = len(test.Results) - 1
+
+	// nkk: The last attempt decides, earlier attempts only make it flaky
+	last := test.Results[len(test.Results)-1]
+	switch {
+	case last.Status == "skipped" || test.Status == "skipped":
+		c.Status = CaseSkipped
+	case last.Status == "interrupted":
+		c.Status = CaseInterrupted
+	case test.Status == "flaky":
+		c.Status = CaseFlaky
+	case test.Status == "unexpected":
+		c.Status = CaseFailed
+	}
+
+	failed := last
+	if c.Status == CaseFlaky {
+		// nkk: Keep the failure that made it flaky
+		for _, result := range test.Results {
+			if result.Error != nil || len(result.Errors) > 0 {
+				failed = result
+				break
+			}
+		}
+	}
+	if c.Status != CasePassed && c.Status != CaseSkipped {
+		errs := failed.Errors
+		if failed.Error != nil {
+			errs = append([]PlaywrightError{*failed.Error}, errs...)
+		}
+		if len(errs) > 0 {
+			c.Message = cleanText(firstLine(firstNonEmpty(errs[0].Message, errs[0].Stack)))
+			c.Stack = cleanText(firstNonEmpty(errs[0].Stack, errs[0].Message))
+		}
+		if c.Message == "" && last.Status == "timedOut" {
+			c.Message = "Test timeout exceeded"
+		}
+	}
+
+	var output strings.Builder
+	for _, chunk := range last.Stdout {
+		if output.Len()+len(chunk.Text) > maxOutputBytes {
+			output.WriteString("\n... output truncated")
+			break
+		}
+		output.WriteString(chunk.Text)
+	}
+	c.Output = cleanText(output.String())
+
+	for _, result := range test.Results {
+		for _, attachment := range result.Attachments {
+			if attachment.Path == "" {
+				continue
+			}
+			c.Attachments = append(c.Attachments, &Attachment{
+				Name:        attachment.Name,
+				ContentType: attachment.ContentType,
+				Source:      attachment.Path,
+			})
+		}
+	}
+	return c
+}
+
+func millis(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+func cleanText(s string) string {
+	return strings.TrimSpace(ansiPattern.ReplaceAllString(s, ""))
+}
+
+func firstLine(s string) string {
+	return strings.SplitN(strings.TrimSpace(s), "\n", 2)[0]
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
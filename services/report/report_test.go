@@ -0,0 +1,173 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+nkk: Unit tests for the execution reports
+The fixture is trimmed Playwright JSON reporter output of a plan run
+*/
+
+const playwrightResults = `{
+  "suites": [{
+    "title": "testPlan_p1/test.list.js",
+    "file": "testPlan_p1/test.list.js",
+    "specs": [],
+    "suites": [{
+      "title": "Login",
+      "specs": [{
+        "title": "logs in",
+        "tests": [{
+          "projectName": "chromium",
+          "status": "expected",
+          "results": [{"status": "passed", "duration": 1500, "stdout": [{"text": "navigated\n"}], "attachments": []}]
+        }, {
+          "projectName": "firefox",
+          "status": "unexpected",
+          "results": [{
+            "status": "failed",
+            "duration": 2500,
+            "error": {"message": "\u001b[31mError: expect(received).toBe(expected)\u001b[39m\nExpected: 1", "stack": "Error: expect(received).toBe(expected)\n    at login.spec.js:10:5"},
+            "attachments": [
+              {"name": "screenshot", "contentType": "image/png", "path": "/workspace/test-results/login-firefox/failure.png"},
+              {"name": "video", "contentType": "video/webm", "path": "/workspace/test-results/login-firefox/video.webm"}
+            ]
+          }]
+        }]
+      }, {
+        "title": "remembers user",
+        "tests": [{
+          "projectName": "chromium",
+          "status": "flaky",
+          "results": [
+            {"status": "failed", "duration": 400, "errors": [{"message": "Timeout 5000ms exceeded"}]},
+            {"status": "passed", "duration": 600, "retry": 1}
+          ]
+        }, {
+          "projectName": "firefox",
+          "status": "skipped",
+          "results": [{"status": "skipped", "duration": 0}]
+        }]
+      }]
+    }]
+  }],
+  "errors": [],
+  "stats": {"startTime": "2026-10-18T10:00:00.000Z", "duration": 5200.5, "expected": 1, "unexpected": 1, "flaky": 1, "skipped": 1}
+}`
+
+func writeResults(t *testing.T, store *Store, executionId string) string {
+	require.NoError(t, store.Prepare(executionId))
+	dir, err := store.Dir(executionId)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ResultsFile), []byte(playwrightResults), 0644))
+	return dir
+}
+
+func TestBuildGroupsByProjectAndFile(t *testing.T) {
+	store := NewStore(t.TempDir())
+	dir := writeResults(t, store, "exec-1")
+	pr, err := ReadPlaywrightReport(filepath.Join(dir, ResultsFile))
+	require.NoError(t, err)
+
+	r := Build("exec-1", "Nightly", pr)
+	require.Len(t, r.Suites, 2)
+	assert.Equal(t, "chromium", r.Suites[0].Project)
+	assert.Equal(t, "firefox", r.Suites[1].Project)
+	assert.Equal(t, 4, r.Tests)
+	assert.Equal(t, 1, r.Failures)
+	assert.Equal(t, 1, r.Skipped)
+	assert.Equal(t, 1, r.Flaky)
+	assert.False(t, r.Passed())
+
+	failed := r.Suites[1].Cases[0]
+	assert.Equal(t, "Login › logs in", failed.Name)
+	assert.Equal(t, CaseFailed, failed.Status)
+	assert.Equal(t, "Error: expect(received).toBe(expected)", failed.Message, "ANSI codes are stripped")
+	assert.Contains(t, failed.Stack, "login.spec.js:10:5")
+	assert.Len(t, failed.Attachments, 2)
+
+	flaky := r.Suites[0].Cases[1]
+	assert.Equal(t, CaseFlaky, flaky.Status)
+	assert.Equal(t, 1, flaky.Retries)
+	assert.Equal(t, "Timeout 5000ms exceeded", flaky.Message)
+	assert.Equal(t, int64(1000), flaky.Duration.Milliseconds())
+}
+
+func TestGenerateWritesJUnitAndHTML(t *testing.T) {
+	workspace := t.TempDir()
+	store := NewStore(workspace)
+	dir := writeResults(t, store, "exec-1")
+
+	// nkk: Attachments were written inside a runner container that mounted the workspace at /workspace
+	artifacts := filepath.Join(workspace, "test-results", "login-firefox")
+	require.NoError(t, os.MkdirAll(artifacts, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(artifacts, "failure.png"), []byte("png"), 0644))
+
+	r, err := store.Generate("exec-1", GenerateOptions{Title: "Nightly", ContainerDir: "/workspace"})
+	require.NoError(t, err)
+
+	attachments := r.Suites[1].Cases[0].Attachments
+	assert.Equal(t, "attachments/001-failure.png", attachments[0].Path)
+	assert.Empty(t, attachments[1].Path, "a missing video is left out instead of failing the report")
+	copied, err := os.ReadFile(filepath.Join(dir, "attachments", "001-failure.png"))
+	require.NoError(t, err)
+	assert.Equal(t, "png", string(copied))
+
+	junit, err := os.ReadFile(filepath.Join(dir, JUnitFile))
+	require.NoError(t, err)
+	var doc junitTestsuites
+	require.NoError(t, xml.Unmarshal(junit, &doc))
+	assert.Equal(t, "Nightly", doc.Name)
+	assert.Equal(t, 4, doc.Tests)
+	assert.Equal(t, 1, doc.Failures)
+	assert.Equal(t, "5.200", doc.Time)
+	require.Len(t, doc.Suites, 2)
+	assert.Equal(t, "firefox", doc.Suites[1].Hostname)
+	failedCase := doc.Suites[1].Cases[0]
+	assert.Equal(t, "[firefox] Login › logs in", failedCase.Name)
+	require.NotNil(t, failedCase.Failure)
+	assert.Contains(t, failedCase.Failure.Body, "login.spec.js:10:5")
+	assert.Contains(t, failedCase.SystemOut, "[[ATTACHMENT|attachments/001-failure.png]]")
+	assert.NotNil(t, doc.Suites[1].Cases[1].Skipped)
+
+	html, err := os.ReadFile(filepath.Join(dir, HTMLFile))
+	require.NoError(t, err)
+	assert.Contains(t, string(html), `<img src="attachments/001-failure.png"`)
+	assert.Contains(t, string(html), "Login › remembers user")
+	assert.NotContains(t, string(html), "<script", "the page must work without scripts")
+}
+
+func TestRunErrorsFailTheJUnitReport(t *testing.T) {
+	r := Build("exec-2", "", &PlaywrightReport{Errors: []PlaywrightError{{Message: "SyntaxError: Unexpected token\n    at test.list.js:3"}}})
+
+	var out bytes.Buffer
+	require.NoError(t, WriteJUnit(&out, r))
+	assert.True(t, strings.HasPrefix(out.String(), "<?xml"))
+	assert.Contains(t, out.String(), `errors="1"`)
+	assert.Contains(t, out.String(), `message="SyntaxError: Unexpected token"`)
+	assert.False(t, r.Passed())
+}
+
+func TestStoreRejectsPathsOutsideTheReport(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	_, err := store.Dir("../exec")
+	assert.Error(t, err)
+	_, err = store.ResultsPath("a/b")
+	assert.Error(t, err)
+
+	dir, err := store.Dir("exec-1")
+	require.NoError(t, err)
+	path, err := store.File("exec-1", "attachments/../../../../etc/passwd")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(path, dir), "cleaned paths stay inside the report directory")
+}
@@ -0,0 +1,76 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// nkk: Step timeline of an execution, built from the fixture's step lines and kept next to the reports
+
+const (
+	StepsFile = "steps.json"
+
+	StepPassed = "passed"
+	StepFailed = "failed"
+)
+
+// Step is one fixture step, as printed by the fixture
+type Step struct {
+	ExecutionId      string    `json:"execution_id"`
+	TestcaseId       string    `json:"testcase_id,omitempty"`
+	TestsuiteId      string    `json:"testsuite_id,omitempty"`
+	TestplanId       string    `json:"testplan_id,omitempty"`
+	MachineId        string    `json:"machine_id,omitempty"`
+	IsAdhoc          bool      `json:"is_adhoc,omitempty"`
+	IsPreRequisite   bool      `json:"is_prerequisite,omitempty"`
+	ParentTestCaseId string    `json:"parent_testcase_id,omitempty"`
+	Index            int       `json:"index"`
+	Name             string    `json:"name"`
+	Action           string    `json:"action,omitempty"`
+	Selector         string    `json:"selector,omitempty"`
+	Status           string    `json:"status"`
+	StartedAt        time.Time `json:"started_at"`
+	DurationMs       int64     `json:"duration_ms"`
+	Screenshot       string    `json:"screenshot,omitempty"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// StepTimeline holds the steps of one testcase run
+type StepTimeline struct {
+	TestcaseId       string `json:"testcase_id"`
+	TestsuiteId      string `json:"testsuite_id,omitempty"`
+	MachineId        string `json:"machine_id,omitempty"`
+	IsPreRequisite   bool   `json:"is_prerequisite,omitempty"`
+	ParentTestCaseId string `json:"parent_testcase_id,omitempty"`
+	Passed           int    `json:"passed"`
+	Failed           int    `json:"failed"`
+	Steps            []Step `json:"steps"`
+}
+
+// StepsReport is the content of steps.json
+type StepsReport struct {
+	ExecutionId string         `json:"execution_id"`
+	Testcases   []StepTimeline `json:"testcases"`
+}
+
+// WriteSteps stores the step timelines of an execution
+func (s *Store) WriteSteps(executionId string, timelines []StepTimeline) error {
+	dir, err := s.Dir(executionId)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if timelines == nil {
+		timelines = []StepTimeline{}
+	}
+	return writeFile(filepath.Join(dir, StepsFile), func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(StepsReport{ExecutionId: executionId, Testcases: timelines})
+	})
+}
@@ -0,0 +1,197 @@
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"agent/logger"
+)
+
+/*
+nkk: Report files live next to the run in the execution workspace
+  <workspace>/reports/<execution_id>/results.json   Playwright JSON reporter output
+  <workspace>/reports/<execution_id>/junit.xml
+  <workspace>/reports/<execution_id>/index.html
+  <workspace>/reports/<execution_id>/attachments/   screenshots, videos and traces of the run
+*/
+
+const (
+	ResultsFile    = "results.json"
+	JUnitFile      = "junit.xml"
+	HTMLFile       = "index.html"
+	attachmentsDir = "attachments"
+	reportsDir     = "reports"
+)
+
+type Store struct {
+	workspace string
+}
+
+// NewStore keeps reports under <workspace>/reports
+func NewStore(workspace string) *Store {
+	return &Store{workspace: workspace}
+}
+
+// validExecutionId keeps execution ids inside the reports directory
+func validExecutionId(executionId string) error {
+	if executionId == "" || executionId == "." || executionId == ".." || strings.ContainsAny(executionId, `/\`) {
+		return fmt.Errorf("invalid execution id %q", executionId)
+	}
+	return nil
+}
+
+// Dir returns the report directory of an execution
+func (s *Store) Dir(executionId string) (string, error) {
+	if err := validExecutionId(executionId); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.workspace, reportsDir, executionId), nil
+}
+
+// ResultsPath is where Playwright writes its JSON report, relative to the workspace
+// so it resolves the same on the host and inside a runner container
+func (s *Store) ResultsPath(executionId string) (string, error) {
+	if err := validExecutionId(executionId); err != nil {
+		return "", err
+	}
+	return path.Join(reportsDir, executionId, ResultsFile), nil
+}
+
+// Prepare creates the report directory and removes results of an earlier run with the same id
+func (s *Store) Prepare(executionId string) error {
+	dir, err := s.Dir(executionId)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return os.MkdirAll(dir, 0755)
+}
+
+// File resolves a file inside an execution's report directory
+func (s *Store) File(executionId, name string) (string, error) {
+	dir, err := s.Dir(executionId)
+	if err != nil {
+		return "", err
+	}
+	clean := path.Clean("/" + filepath.ToSlash(name))
+	if clean == "/" {
+		return "", fmt.Errorf("invalid report file %q", name)
+	}
+	return filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(clean, "/"))), nil
+}
+
+// GenerateOptions describe where the Playwright process saw the workspace
+type GenerateOptions struct {
+	Title        string
+	ContainerDir string // nkk: Workspace mount point when Playwright ran in a container
+}
+
+// Generate builds the JUnit and HTML reports from the Playwright results of an execution
+func (s *Store) Generate(executionId string, opts GenerateOptions) (*Report, error) {
+	dir, err := s.Dir(executionId)
+	if err != nil {
+		return nil, err
+	}
+	pr, err := ReadPlaywrightReport(filepath.Join(dir, ResultsFile))
+	if err != nil {
+		return nil, err
+	}
+
+	r := Build(executionId, opts.Title, pr)
+	s.collectAttachments(dir, r, opts.ContainerDir)
+
+	if err := writeFile(filepath.Join(dir, JUnitFile), func(w io.Writer) error { return WriteJUnit(w, r) }); err != nil {
+		return nil, err
+	}
+	if err := writeFile(filepath.Join(dir, HTMLFile), func(w io.Writer) error { return WriteHTML(w, r) }); err != nil {
+		return nil, err
+	}
+
+	logger.Info("execution report written",
+		zap.String("execution_id", executionId),
+		zap.String("dir", dir),
+		zap.Int("tests", r.Tests),
+		zap.Int("failures", r.Failures))
+	return r, nil
+}
+
+// collectAttachments copies attachments into the report so it can be served and archived on its own
+func (s *Store) collectAttachments(dir string, r *Report, containerDir string) {
+	workspace, _ := filepath.Abs(s.workspace)
+	n := 0
+	for _, suite := range r.Suites {
+		for _, c := range suite.Cases {
+			for _, attachment := range c.Attachments {
+				source := attachment.Source
+				if containerDir != "" && strings.HasPrefix(source, containerDir+"/") {
+					source = filepath.Join(workspace, filepath.FromSlash(strings.TrimPrefix(source, containerDir+"/")))
+				} else if !filepath.IsAbs(source) {
+					source = filepath.Join(workspace, source)
+				}
+
+				n++
+				name := fmt.Sprintf("%03d-%s", n, filepath.Base(source))
+				if err := linkOrCopy(source, filepath.Join(dir, attachmentsDir, name)); err != nil {
+					logger.Warn("could not add attachment to report",
+						zap.String("execution_id", r.ExecutionId),
+						zap.String("attachment", attachment.Source),
+						zap.Error(err))
+					continue
+				}
+				attachment.Path = path.Join(attachmentsDir, name)
+			}
+		}
+	}
+}
+
+// linkOrCopy hard links src to dst, copying when they are on different volumes
+func linkOrCopy(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func writeFile(name string, render func(io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := render(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
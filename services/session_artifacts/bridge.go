@@ -0,0 +1,61 @@
+package sessionartifacts
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"agent/logger"
+	"agent/models/screenshot"
+	"agent/models/session"
+	"agent/models/uploadvideo"
+	executionbridge "agent/services/execution_bridge"
+)
+
+// Bridge forwards every call to the wrapped bridge and records the artifacts of each session
+type Bridge struct {
+	executionbridge.ExecutionBridge
+	Index *Index
+}
+
+// NewBridge wraps bridge so the artifacts its calls point at are indexed
+func NewBridge(bridge executionbridge.ExecutionBridge, index *Index) *Bridge {
+	return &Bridge{
+		ExecutionBridge: bridge,
+		Index:           index,
+	}
+}
+
+// CreateLocalAgentNetworkLogs indexes the Playwright output directory, it holds the trace, video, failure screenshots and HAR
+func (b *Bridge) CreateLocalAgentNetworkLogs(ctx context.Context, sess session.Session) error {
+	b.record(sess.ExecutionId, sess.TestcaseId, sess.OutputDir)
+	return b.ExecutionBridge.CreateLocalAgentNetworkLogs(ctx, sess)
+}
+
+func (b *Bridge) UploadVideo(ctx context.Context, data uploadvideo.UploadVideo) error {
+	b.record(data.ExecutionId, data.TestcaseId, data.OutputDir)
+	return b.ExecutionBridge.UploadVideo(ctx, data)
+}
+
+func (b *Bridge) UploadScreenshots(ctx context.Context, orgId string, projectId string, appId string, testlab string, executionId string, request screenshot.UploadScreenshotRequest) error {
+	b.record(executionId, request.TestcaseId, request.ScreenshotsDir)
+	return b.ExecutionBridge.UploadScreenshots(ctx, orgId, projectId, appId, testlab, executionId, request)
+}
+
+func (b *Bridge) SaveConsoleLogs(ctx context.Context, orgId string, projectId string, appId string, testlab string, logs session.ConsoleLogs) error {
+	b.record(logs.ExecutionId, logs.TestcaseId, logs.Artifact)
+	return b.ExecutionBridge.SaveConsoleLogs(ctx, orgId, projectId, appId, testlab, logs)
+}
+
+func (b *Bridge) record(executionId, testcaseId, source string) {
+	if source == "" || executionId == "" {
+		return
+	}
+	if err := b.Index.Record(executionId, testcaseId, source); err != nil {
+		logger.Warn("could not index session artifacts",
+			zap.String("execution_id", executionId),
+			zap.String("testcase_id", testcaseId),
+			zap.String("source", source),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,246 @@
+package sessionartifacts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/services/report"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: Index of the artifacts each session left on disk, so they can be listed and downloaded from the agent
+Sources are recorded per testcase as the bridge calls pass through, see Bridge, and kept in
+  <workspace>/reports/<execution_id>/artifacts.json
+A source is a file or a directory, only the files directly inside a directory are artifacts so
+the trace Playwright's trace.zip was extracted to stays out. Missing files are left out of listings.
+*/
+
+const IndexFile = "artifacts.json"
+
+// Artifact kinds
+const (
+	KindTrace      = "trace"
+	KindVideo      = "video"
+	KindScreenshot = "screenshot"
+	KindHAR        = "har"
+	KindConsole    = "console"
+	KindFile       = "file"
+)
+
+// ErrNotFound is returned for an artifact the session does not have
+var ErrNotFound = errors.New("artifact not found")
+
+// Artifact is one file of a session
+type Artifact struct {
+	Name        string    `json:"name"` // nkk: unique within the session
+	Kind        string    `json:"kind"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	ModifiedAt  time.Time `json:"modified_at"`
+	URL         string    `json:"url,omitempty"`        // nkk: signed download link, filled in by the handler
+	ViewerURL   string    `json:"viewer_url,omitempty"` // nkk: trace viewer link for traces
+	Path        string    `json:"-"`
+}
+
+type indexFile struct {
+	ExecutionId string              `json:"execution_id"`
+	Testcases   map[string][]string `json:"testcases"` // nkk: testcase id -> sources
+}
+
+// Index keeps the artifact sources of every session next to the execution's reports
+type Index struct {
+	reports *report.Store
+	mu      sync.Mutex
+}
+
+// NewIndex creates an index backed by the report directories of reports
+func NewIndex(reports *report.Store) *Index {
+	return &Index{reports: reports}
+}
+
+// Record adds sources to the session of testcaseId, recording a source twice is fine
+func (i *Index) Record(executionId, testcaseId string, sources ...string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	index, err := i.read(executionId)
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, source := range sources {
+		if source == "" {
+			continue
+		}
+		source, err := filepath.Abs(source)
+		if err != nil {
+			return err
+		}
+		if !slices.Contains(index.Testcases[testcaseId], source) {
+			index.Testcases[testcaseId] = append(index.Testcases[testcaseId], source)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return i.write(executionId, index)
+}
+
+// Testcases returns the ids of the sessions of an execution that recorded artifacts
+func (i *Index) Testcases(executionId string) ([]string, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	index, err := i.read(executionId)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(index.Testcases))
+	for id := range index.Testcases {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// List returns the artifacts of a session that are still on disk
+func (i *Index) List(executionId, testcaseId string) ([]Artifact, error) {
+	i.mu.Lock()
+	index, err := i.read(executionId)
+	i.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := []Artifact{}
+	names := make(map[string]bool)
+	add := func(path string, info os.FileInfo) {
+		name := info.Name()
+		for n := 2; names[name]; n++ {
+			name = fmt.Sprintf("%d-%s", n, info.Name())
+		}
+		names[name] = true
+		kind, contentType := classify(info.Name())
+		artifacts = append(artifacts, Artifact{
+			Name:        name,
+			Kind:        kind,
+			ContentType: contentType,
+			Size:        info.Size(),
+			ModifiedAt:  info.ModTime().UTC(),
+			Path:        path,
+		})
+	}
+	for _, source := range index.Testcases[testcaseId] {
+		info, err := os.Stat(source)
+		if err != nil {
+			continue
+		}
+		if !info.IsDir() {
+			add(source, info)
+			continue
+		}
+		entries, err := os.ReadDir(source)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+				add(filepath.Join(source, entry.Name()), info)
+			}
+		}
+	}
+	return artifacts, nil
+}
+
+// Find returns one artifact of a session by name
+func (i *Index) Find(executionId, testcaseId, name string) (Artifact, error) {
+	artifacts, err := i.List(executionId, testcaseId)
+	if err != nil {
+		return Artifact{}, err
+	}
+	for _, artifact := range artifacts {
+		if artifact.Name == name {
+			return artifact, nil
+		}
+	}
+	return Artifact{}, ErrNotFound
+}
+
+func (i *Index) path(executionId string) (string, error) {
+	return i.reports.File(executionId, IndexFile)
+}
+
+// read loads the index of an execution, called with mu held
+func (i *Index) read(executionId string) (*indexFile, error) {
+	path, err := i.path(executionId)
+	if err != nil {
+		return nil, err
+	}
+	index := &indexFile{ExecutionId: executionId}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, index); err != nil {
+			return nil, fmt.Errorf("failed to read artifact index: %w", err)
+		}
+	}
+	if index.Testcases == nil {
+		index.Testcases = make(map[string][]string)
+	}
+	return index, nil
+}
+
+// write replaces the index of an execution, called with mu held
+func (i *Index) write(executionId string, index *indexFile) error {
+	path, err := i.path(executionId)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// classify tells the kind and content type of an artifact from its file name
+func classify(name string) (string, string) {
+	lower := strings.ToLower(name)
+	ext := filepath.Ext(lower)
+	contentType := mime.TypeByExtension(ext)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	switch {
+	case lower == filepath.Base(apxconstants.LogsZipFolderName):
+		return KindTrace, "application/zip"
+	case ext == ".webm":
+		return KindVideo, "video/webm"
+	case ext == ".png" || ext == ".jpg" || ext == ".jpeg":
+		return KindScreenshot, contentType
+	case ext == ".har":
+		return KindHAR, "application/json"
+	case lower == report.ConsoleFile:
+		return KindConsole, "application/json"
+	}
+	return KindFile, contentType
+}
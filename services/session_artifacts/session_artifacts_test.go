@@ -0,0 +1,175 @@
+package sessionartifacts
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"agent/models/session"
+	"agent/models/uploadvideo"
+	executionbridge "agent/services/execution_bridge"
+	"agent/services/report"
+	apxconstants "agent/utils/constants"
+)
+
+/*
+nkk: Unit tests for the session artifact index, link signing and the indexing bridge
+*/
+
+func writeArtifact(t *testing.T, path string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte("data"), 0644))
+}
+
+func names(artifacts []Artifact) []string {
+	result := make([]string, 0, len(artifacts))
+	for _, artifact := range artifacts {
+		result = append(result, artifact.Name)
+	}
+	return result
+}
+
+func TestIndexListsRecordedSources(t *testing.T) {
+	workspace := t.TempDir()
+	output := filepath.Join(workspace, "test-results", "tc-1")
+	writeArtifact(t, filepath.Join(output, "trace.zip"))
+	writeArtifact(t, filepath.Join(output, "video.webm"))
+	writeArtifact(t, filepath.Join(output, "test-failed-1.png"))
+	writeArtifact(t, filepath.Join(output, "network.har"))
+	writeArtifact(t, filepath.Join(output, "trace", "resources", "page.html"))
+	console := filepath.Join(workspace, "reports", "exec-1", report.ConsoleFile)
+	writeArtifact(t, console)
+
+	index := NewIndex(report.NewStore(workspace))
+	require.NoError(t, index.Record("exec-1", "tc-1", output, console))
+	require.NoError(t, index.Record("exec-1", "tc-1", output), "recording a source twice is fine")
+
+	artifacts, err := index.List("exec-1", "tc-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"trace.zip", "video.webm", "test-failed-1.png", "network.har", "console.json"}, names(artifacts),
+		"only the files directly inside a directory are listed")
+
+	kinds := map[string]string{}
+	for _, artifact := range artifacts {
+		kinds[artifact.Name] = artifact.Kind
+		assert.Equal(t, int64(4), artifact.Size)
+	}
+	assert.Equal(t, KindTrace, kinds["trace.zip"])
+	assert.Equal(t, KindVideo, kinds["video.webm"])
+	assert.Equal(t, KindScreenshot, kinds["test-failed-1.png"])
+	assert.Equal(t, KindHAR, kinds["network.har"])
+	assert.Equal(t, KindConsole, kinds["console.json"])
+
+	testcases, err := index.Testcases("exec-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"tc-1"}, testcases)
+}
+
+func TestIndexSkipsMissingAndRenamesDuplicates(t *testing.T) {
+	workspace := t.TempDir()
+	first := filepath.Join(workspace, "retry-0", "video.webm")
+	second := filepath.Join(workspace, "retry-1", "video.webm")
+	writeArtifact(t, first)
+	writeArtifact(t, second)
+
+	index := NewIndex(report.NewStore(workspace))
+	require.NoError(t, index.Record("exec-1", "tc-1", first, second, filepath.Join(workspace, "gone.webm")))
+
+	artifacts, err := index.List("exec-1", "tc-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"video.webm", "2-video.webm"}, names(artifacts))
+
+	found, err := index.Find("exec-1", "tc-1", "2-video.webm")
+	require.NoError(t, err)
+	assert.Equal(t, second, found.Path)
+
+	_, err = index.Find("exec-1", "tc-1", "gone.webm")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = index.Find("exec-1", "tc-2", "video.webm")
+	assert.ErrorIs(t, err, ErrNotFound, "artifacts belong to their own session")
+}
+
+func TestIndexSurvivesRestart(t *testing.T) {
+	workspace := t.TempDir()
+	trace := filepath.Join(workspace, "out", "trace.zip")
+	writeArtifact(t, trace)
+	require.NoError(t, NewIndex(report.NewStore(workspace)).Record("exec-1", "tc-1", trace))
+
+	artifacts, err := NewIndex(report.NewStore(workspace)).List("exec-1", "tc-1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"trace.zip"}, names(artifacts))
+}
+
+func TestIndexRejectsUnsafeExecutionIds(t *testing.T) {
+	index := NewIndex(report.NewStore(t.TempDir()))
+	_, err := index.List("../exec-1", "tc-1")
+	assert.Error(t, err)
+}
+
+func TestSignerVerifiesOwnLinks(t *testing.T) {
+	signer, err := NewSigner("secret", time.Hour)
+	require.NoError(t, err)
+	now := time.Unix(1_700_000_000, 0)
+	signer.now = func() time.Time { return now }
+
+	query := signer.Sign("/v1/artifacts/tc-1/trace.zip")
+	assert.Equal(t, "1700003600", query.Get("expires"))
+	assert.NoError(t, signer.Verify("/v1/artifacts/tc-1/trace.zip", query.Get("expires"), query.Get("signature")))
+
+	assert.ErrorIs(t, signer.Verify("/v1/artifacts/tc-1/video.webm", query.Get("expires"), query.Get("signature")), ErrInvalidSignature)
+	assert.ErrorIs(t, signer.Verify("/v1/artifacts/tc-1/trace.zip", "1800000000", query.Get("signature")), ErrInvalidSignature)
+
+	other, err := NewSigner("other", time.Hour)
+	require.NoError(t, err)
+	assert.ErrorIs(t, other.Verify("/v1/artifacts/tc-1/trace.zip", query.Get("expires"), query.Get("signature")), ErrInvalidSignature)
+
+	now = now.Add(time.Hour + time.Second)
+	assert.ErrorIs(t, signer.Verify("/v1/artifacts/tc-1/trace.zip", query.Get("expires"), query.Get("signature")), ErrLinkExpired)
+}
+
+func TestNewSigner(t *testing.T) {
+	_, err := NewSigner("secret", 0)
+	assert.Error(t, err)
+
+	first, err := NewSigner("", time.Hour)
+	require.NoError(t, err)
+	second, err := NewSigner("", time.Hour)
+	require.NoError(t, err)
+	query := first.Sign("/a")
+	assert.NotEmpty(t, query.Get("signature"))
+	assert.ErrorIs(t, second.Verify("/a", query.Get("expires"), query.Get("signature")), ErrInvalidSignature,
+		"every agent without a configured key signs with its own random key")
+	_, err = url.ParseQuery(query.Encode())
+	assert.NoError(t, err)
+}
+
+func TestBridgeIndexesArtifacts(t *testing.T) {
+	workspace := t.TempDir()
+	output := filepath.Join(workspace, "test-results", "tc-1")
+	writeArtifact(t, filepath.Join(output, "trace.zip"))
+	writeArtifact(t, filepath.Join(output, "video.webm"))
+	console := filepath.Join(workspace, "reports", "exec-1", report.ConsoleFile)
+	writeArtifact(t, console)
+
+	sink, err := executionbridge.NewLocalSink(filepath.Join(workspace, "sink"))
+	require.NoError(t, err)
+	defer sink.Close()
+	index := NewIndex(report.NewStore(workspace))
+	bridge := NewBridge(sink, index)
+
+	ctx := context.Background()
+	require.NoError(t, bridge.CreateLocalAgentNetworkLogs(ctx, session.Session{ExecutionId: "exec-1", TestcaseId: "tc-1", OutputDir: output}))
+	require.NoError(t, bridge.UploadVideo(ctx, uploadvideo.UploadVideo{ExecutionId: "exec-1", TestcaseId: "tc-1", OutputDir: output}))
+	require.NoError(t, bridge.SaveConsoleLogs(ctx, "o", "p", "a", apxconstants.Local, session.ConsoleLogs{ExecutionId: "exec-1", TestcaseId: "tc-1", Artifact: console}))
+
+	artifacts, err := index.List("exec-1", "tc-1")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"trace.zip", "video.webm", "console.json"}, names(artifacts))
+}
@@ -0,0 +1,69 @@
+package sessionartifacts
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrInvalidSignature is returned for a link that was not signed by this agent or was changed
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrLinkExpired is returned for a signed link past its expiry
+	ErrLinkExpired = errors.New("link expired")
+)
+
+// Signer signs download paths so they can be shared without credentials for a limited time
+type Signer struct {
+	key    []byte
+	expiry time.Duration
+	now    func() time.Time
+}
+
+// NewSigner signs with key, a random key is used when it is empty
+func NewSigner(key string, expiry time.Duration) (*Signer, error) {
+	if expiry <= 0 {
+		return nil, fmt.Errorf("link expiry must be positive")
+	}
+	secret := []byte(key)
+	if key == "" {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to create signing key: %w", err)
+		}
+	}
+	return &Signer{key: secret, expiry: expiry, now: time.Now}, nil
+}
+
+// Sign returns the expires and signature query parameters of a link to path
+func (s *Signer) Sign(path string) url.Values {
+	expires := strconv.FormatInt(s.now().Add(s.expiry).Unix(), 10)
+	return url.Values{"expires": {expires}, "signature": {s.signature(path, expires)}}
+}
+
+// Verify checks the expires and signature query parameters of a signed path
+func (s *Signer) Verify(path, expires, signature string) error {
+	if !hmac.Equal([]byte(signature), []byte(s.signature(path, expires))) {
+		return ErrInvalidSignature
+	}
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if s.now().Unix() > unix {
+		return ErrLinkExpired
+	}
+	return nil
+}
+
+func (s *Signer) signature(path, expires string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(path + "|" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -54,32 +54,37 @@ func NewTunnelService() *TunnelService {
 	}
 
 	// nkk: Start cleanup routine for stale tunnels
-	go s.cleanupStaleTunnels()
+	go s.runCleanup()
 
 	return s
 }
 
-// cleanupStaleTunnels removes inactive tunnels
-func (s *TunnelService) cleanupStaleTunnels() {
+// runCleanup periodically removes stale tunnels
+func (s *TunnelService) runCleanup() {
 	ticker := time.NewTicker(5 * time.Minute)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		var toDelete []string
+		s.cleanupStaleTunnels()
+	}
+}
 
-		s.tunnels.Range(func(key, value interface{}) bool {
-			tunnel := value.(*Tunnel)
-			// nkk: Remove tunnels inactive for > 30 minutes
-			if !tunnel.Active && time.Since(tunnel.LastUsed) > 30*time.Minute {
-				toDelete = append(toDelete, tunnel.ID)
-			}
-			return true
-		})
+// cleanupStaleTunnels removes inactive tunnels
+func (s *TunnelService) cleanupStaleTunnels() {
+	var toDelete []string
 
-		for _, id := range toDelete {
-			s.tunnels.Delete(id)
-			logger.Debug("Cleaned up stale tunnel", zap.String("id", id))
+	s.tunnels.Range(func(key, value interface{}) bool {
+		tunnel := value.(*Tunnel)
+		// nkk: Remove tunnels inactive for > 30 minutes
+		if !tunnel.Active && time.Since(tunnel.LastUsed) > 30*time.Minute {
+			toDelete = append(toDelete, tunnel.ID)
 		}
+		return true
+	})
+
+	for _, id := range toDelete {
+		s.tunnels.Delete(id)
+		logger.Debug("Cleaned up stale tunnel", zap.String("id", id))
 	}
 }
 
@@ -203,10 +203,6 @@ func TestTunnelService_ProxyHTTPRequest(t *testing.T) {
 	client := &http.Client{Timeout: 10 * time.Second}
 
 	// Test valid request
-	headers := map[string]string{
-		"Content-Type": "application/json",
-		"User-Agent":   "test-agent",
-	}
 	headersJSON := `{"Content-Type":"application/json","User-Agent":"test-agent"}`
 
 	payload := []byte("GET|/test|" + headersJSON + "|")
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package updater
+
+import (
+	"os"
+	"syscall"
+)
+
+// RestartProcess replaces the running process with the new binary, keeping pid and arguments
+func RestartProcess(binaryPath string) error {
+	return syscall.Exec(binaryPath, os.Args, os.Environ())
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package updater
+
+import (
+	"os"
+	"os/exec"
+)
+
+// RestartProcess starts the new binary and exits, Windows has no exec(2)
+func RestartProcess(binaryPath string) error {
+	cmd := exec.Command(binaryPath, os.Args[1:]...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	os.Exit(0)
+	return nil
+}
@@ -0,0 +1,81 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+/*
+nkk: Release sources
+A release source is any location serving manifest.json and the binaries it lists,
+either an HTTP(S) base URL or a local/shared directory for air-gapped labs.
+*/
+
+// Source opens files published by a release source
+type Source interface {
+	Open(ctx context.Context, name string) (io.ReadCloser, error)
+}
+
+// NewSource picks the source implementation from the location
+func NewSource(location string, client *http.Client) (Source, error) {
+	if location == "" {
+		return nil, fmt.Errorf("update source cannot be empty")
+	}
+
+	parsed, err := url.Parse(location)
+	if err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") {
+		if client == nil {
+			client = http.DefaultClient
+		}
+		return &httpSource{baseURL: parsed, client: client}, nil
+	}
+	if err == nil && parsed.Scheme == "file" {
+		return &dirSource{dir: parsed.Path}, nil
+	}
+	return &dirSource{dir: location}, nil
+}
+
+// httpSource fetches release files relative to a base URL
+type httpSource struct {
+	baseURL *url.URL
+	client  *http.Client
+}
+
+func (s *httpSource) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	target := *s.baseURL
+	target.Path = path.Join(s.baseURL.Path, name)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("fetching %s: unexpected status %d", target.String(), res.StatusCode)
+	}
+	return res.Body, nil
+}
+
+// dirSource reads release files from a directory
+type dirSource struct {
+	dir string
+}
+
+func (s *dirSource) Open(ctx context.Context, name string) (io.ReadCloser, error) {
+	// nkk: Names come from the manifest, never let them leave the release dir
+	if !filepath.IsLocal(name) || strings.Contains(name, "\\") {
+		return nil, fmt.Errorf("invalid release file name %q", name)
+	}
+	return os.Open(filepath.Join(s.dir, name))
+}
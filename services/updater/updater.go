@@ -0,0 +1,584 @@
+package updater
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"agent/logger"
+)
+
+/*
+nkk: Verified self-update
+Flow: Check -> Download (SHA-256 + ed25519 signature) -> Install (rename aside,
+health check, rollback) -> pause executions and wait until none run -> Restart.
+The signature covers the raw SHA-256 digest of the binary, so release tooling
+only needs the digest it already publishes to sign a release.
+
+A marker next to the binary carries the update across the restart. The new
+process marks it on startup and removes it once healthy (ResumeUpdate), a marker
+still marked on the next start, or not removed in time, restores the previous binary.
+
+An installed release waits for its restart without being downloaded again, so the
+backup keeps the binary it replaced. A release that fails its health check, at install
+or after the restart, is listed next to the binary and no longer offered by Check.
+*/
+
+const (
+	manifestName = "manifest.json"
+	backupSuffix = ".previous"
+	failedSuffix = ".failed"
+	markerSuffix = ".update"
+	skipSuffix   = ".skipped"
+	stagedPrefix = ".agent-update-"
+)
+
+// ErrUpToDate is returned by Update when no newer release is published
+var ErrUpToDate = errors.New("agent is up to date")
+
+// ErrRolledBack is returned by ResumeUpdate when the new release never became healthy and the previous binary is back
+var ErrRolledBack = errors.New("agent update rolled back")
+
+// Manifest describes the latest release published by a source
+type Manifest struct {
+	Version     string    `json:"version"`
+	PublishedAt time.Time `json:"published_at"`
+	Assets      []Asset   `json:"assets"`
+}
+
+// Asset is the binary for one platform
+type Asset struct {
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	Name      string `json:"name"`
+	SHA256    string `json:"sha256"`
+	Signature string `json:"signature"` // nkk: base64 ed25519 signature of the SHA-256 digest
+}
+
+// ExecutionTracker reports how many executions are in flight
+type ExecutionTracker interface {
+	RunningExecutions() int
+	// PauseExecutions refuses new executions until resume is called
+	PauseExecutions() (resume func())
+}
+
+// updateMarker is left next to the binary while a restart into Version is unconfirmed
+type updateMarker struct {
+	Version string `json:"version"`
+	Started bool   `json:"started"` // nkk: set by the first start of Version, still set on the next start means it never became healthy
+}
+
+// Options configures an Updater
+type Options struct {
+	Source             Source
+	PublicKey          ed25519.PublicKey
+	CurrentVersion     string
+	BinaryPath         string
+	Executions         ExecutionTracker
+	HealthCheck        func(ctx context.Context, binaryPath, version string) error // nkk: defaults to running `<binary> version`
+	Restart            func(binaryPath string) error                               // nkk: defaults to re-executing the binary
+	HealthCheckTimeout time.Duration
+	StartupTimeout     time.Duration // nkk: how long the restarted agent has to call confirm from ResumeUpdate
+	IdlePollInterval   time.Duration
+}
+
+// Updater checks for, verifies and installs new agent releases
+type Updater struct {
+	opts    Options
+	mu      sync.Mutex // nkk: one update at a time
+	pending string     // nkk: version installed by Update and waiting for its restart, guarded by mu
+}
+
+// NewUpdater validates the options and fills in defaults
+func NewUpdater(opts Options) (*Updater, error) {
+	if opts.Source == nil {
+		return nil, fmt.Errorf("update source is required")
+	}
+	if len(opts.PublicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid update public key size %d", len(opts.PublicKey))
+	}
+	if opts.BinaryPath == "" {
+		executable, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		opts.BinaryPath = executable
+	}
+	if resolved, err := filepath.EvalSymlinks(opts.BinaryPath); err == nil {
+		opts.BinaryPath = resolved
+	}
+	if opts.HealthCheck == nil {
+		opts.HealthCheck = runVersionCheck
+	}
+	if opts.Restart == nil {
+		opts.Restart = RestartProcess
+	}
+	if opts.HealthCheckTimeout <= 0 {
+		opts.HealthCheckTimeout = 30 * time.Second
+	}
+	if opts.StartupTimeout <= 0 {
+		opts.StartupTimeout = 5 * time.Minute
+	}
+	if opts.IdlePollInterval <= 0 {
+		opts.IdlePollInterval = 10 * time.Second
+	}
+
+	return &Updater{opts: opts}, nil
+}
+
+// ParsePublicKey decodes a base64 ed25519 public key from config
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("decoding update public key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid update public key size %d", len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Check returns the release manifest and this platform's asset when it is newer than the running agent
+func (u *Updater) Check(ctx context.Context) (*Manifest, *Asset, error) {
+	reader, err := u.opts.Source.Open(ctx, manifestName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("fetching release manifest: %w", err)
+	}
+	defer reader.Close()
+
+	var manifest Manifest
+	if err := json.NewDecoder(io.LimitReader(reader, 1<<20)).Decode(&manifest); err != nil {
+		return nil, nil, fmt.Errorf("decoding release manifest: %w", err)
+	}
+
+	if compareVersions(manifest.Version, u.opts.CurrentVersion) <= 0 {
+		return &manifest, nil, nil
+	}
+	if u.skipped(manifest.Version) {
+		logger.Debug("skipping agent release that failed its health check", zap.String("version", manifest.Version))
+		return &manifest, nil, nil
+	}
+
+	for i := range manifest.Assets {
+		asset := &manifest.Assets[i]
+		if asset.OS == runtime.GOOS && asset.Arch == runtime.GOARCH {
+			return &manifest, asset, nil
+		}
+	}
+	return &manifest, nil, fmt.Errorf("release %s has no binary for %s/%s", manifest.Version, runtime.GOOS, runtime.GOARCH)
+}
+
+// Download fetches the asset next to the current binary and verifies it, returning the staged path
+func (u *Updater) Download(ctx context.Context, asset *Asset) (string, error) {
+	expected, err := hex.DecodeString(asset.SHA256)
+	if err != nil || len(expected) != sha256.Size {
+		return "", fmt.Errorf("invalid sha256 for %s", asset.Name)
+	}
+	signature, err := base64.StdEncoding.DecodeString(asset.Signature)
+	if err != nil {
+		return "", fmt.Errorf("invalid signature encoding for %s: %w", asset.Name, err)
+	}
+
+	reader, err := u.opts.Source.Open(ctx, asset.Name)
+	if err != nil {
+		return "", fmt.Errorf("fetching %s: %w", asset.Name, err)
+	}
+	defer reader.Close()
+
+	// nkk: Stage in the binary's directory so the final rename stays on one filesystem
+	staged, err := os.CreateTemp(filepath.Dir(u.opts.BinaryPath), stagedPrefix)
+	if err != nil {
+		return "", err
+	}
+	stagedPath := staged.Name()
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(staged, hash), reader)
+	if closeErr := staged.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(stagedPath)
+		return "", fmt.Errorf("downloading %s: %w", asset.Name, err)
+	}
+
+	digest := hash.Sum(nil)
+	if hex.EncodeToString(digest) != hex.EncodeToString(expected) {
+		os.Remove(stagedPath)
+		return "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", asset.Name, asset.SHA256, hex.EncodeToString(digest))
+	}
+	if !ed25519.Verify(u.opts.PublicKey, digest, signature) {
+		os.Remove(stagedPath)
+		return "", fmt.Errorf("signature verification failed for %s", asset.Name)
+	}
+
+	if err := os.Chmod(stagedPath, 0755); err != nil {
+		os.Remove(stagedPath)
+		return "", err
+	}
+	return stagedPath, nil
+}
+
+// Install swaps the staged binary in and rolls back when it fails its health check.
+// It refuses while an installed update waits for its restart, the backup is the only copy of the running binary.
+func (u *Updater) Install(ctx context.Context, stagedPath, version string) error {
+	binaryPath := u.opts.BinaryPath
+	if u.pending != "" {
+		os.Remove(stagedPath)
+		return fmt.Errorf("update %s is installed and waiting for a restart", u.pending)
+	}
+
+	// nkk: Windows cannot replace a running exe but can rename it, so the current binary becomes the backup
+	if err := replaceFile(stagedPath, binaryPath, binaryPath+backupSuffix); err != nil {
+		os.Remove(stagedPath)
+		return fmt.Errorf("installing new binary: %w", err)
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, u.opts.HealthCheckTimeout)
+	defer cancel()
+
+	if err := u.opts.HealthCheck(checkCtx, binaryPath, version); err != nil {
+		logger.Error("new agent binary failed its health check, rolling back",
+			zap.String("version", version),
+			zap.Error(err))
+		u.skipRelease(version)
+		if rollbackErr := u.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("health check failed: %v, rollback failed: %w", err, rollbackErr)
+		}
+		return fmt.Errorf("health check failed for %s: %w", version, err)
+	}
+	return nil
+}
+
+// Rollback restores the binary that was replaced by the last install
+func (u *Updater) Rollback() error {
+	backupPath := u.opts.BinaryPath + backupSuffix
+	if _, err := os.Stat(backupPath); err != nil {
+		return fmt.Errorf("no previous binary to roll back to: %w", err)
+	}
+	// nkk: The rejected binary may be the one running, it is renamed aside like in Install
+	return replaceFile(backupPath, u.opts.BinaryPath, u.opts.BinaryPath+failedSuffix)
+}
+
+// ResumeUpdate runs at startup and finishes an update this process was restarted into.
+// confirm must be called once the agent is healthy. When it is not called within StartupTimeout,
+// or a previous start of the release never called it, the previous binary is restored:
+// at startup ResumeUpdate returns ErrRolledBack and the caller restarts, later Restart is called.
+func (u *Updater) ResumeUpdate(ctx context.Context) (confirm func(), err error) {
+	markerPath := u.opts.BinaryPath + markerSuffix
+	marker, err := readMarker(markerPath)
+	if err != nil || marker == nil {
+		return func() {}, err
+	}
+	if marker.Version != u.opts.CurrentVersion {
+		// nkk: The restart never happened or the binary was replaced by hand, nothing to confirm
+		return func() {}, os.Remove(markerPath)
+	}
+
+	if marker.Started {
+		logger.Error("agent update never became healthy, rolling back", zap.String("version", marker.Version))
+		u.skipRelease(marker.Version)
+		if err := u.Rollback(); err != nil {
+			return nil, fmt.Errorf("rolling back %s: %w", marker.Version, err)
+		}
+		os.Remove(markerPath)
+		return nil, ErrRolledBack
+	}
+
+	marker.Started = true
+	if err := writeMarker(markerPath, marker); err != nil {
+		return nil, err
+	}
+
+	confirmed := make(chan struct{})
+	var once sync.Once
+	go func() {
+		timer := time.NewTimer(u.opts.StartupTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-confirmed:
+			return
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		logger.Error("agent update did not become healthy in time, rolling back",
+			zap.String("version", marker.Version),
+			zap.Duration("timeout", u.opts.StartupTimeout))
+		u.skipRelease(marker.Version)
+		if err := u.Rollback(); err != nil {
+			logger.Error("agent update rollback failed", zap.Error(err))
+			return
+		}
+		os.Remove(markerPath)
+		if err := u.opts.Restart(u.opts.BinaryPath); err != nil {
+			logger.Error("restarting into previous agent failed", zap.Error(err))
+		}
+	}()
+
+	return func() {
+		once.Do(func() {
+			close(confirmed)
+			if err := os.Remove(markerPath); err != nil && !os.IsNotExist(err) {
+				logger.Warn("could not remove agent update marker", zap.Error(err))
+			}
+			logger.Info("agent update confirmed healthy", zap.String("version", marker.Version))
+		})
+	}, nil
+}
+
+// SetExecutions sets the executions Update waits for before restarting
+func (u *Updater) SetExecutions(executions ExecutionTracker) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.opts.Executions = executions
+}
+
+// BinaryPath returns the binary updates are installed to
+func (u *Updater) BinaryPath() string {
+	return u.opts.BinaryPath
+}
+
+// WaitForIdle blocks until no executions are running
+func (u *Updater) WaitForIdle(ctx context.Context) error {
+	if u.opts.Executions == nil {
+		return nil
+	}
+
+	ticker := time.NewTicker(u.opts.IdlePollInterval)
+	defer ticker.Stop()
+
+	for {
+		running := u.opts.Executions.RunningExecutions()
+		if running == 0 {
+			return nil
+		}
+		logger.Info("waiting for executions to finish before restart", zap.Int("running", running))
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update runs one full check, download, install and restart cycle.
+// A release installed by an earlier call whose restart was postponed only gets its restart.
+func (u *Updater) Update(ctx context.Context) (string, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.pending == "" {
+		manifest, asset, err := u.Check(ctx)
+		if err != nil {
+			return "", err
+		}
+		if asset == nil {
+			return u.opts.CurrentVersion, ErrUpToDate
+		}
+
+		logger.Info("downloading agent update",
+			zap.String("current_version", u.opts.CurrentVersion),
+			zap.String("version", manifest.Version))
+
+		stagedPath, err := u.Download(ctx, asset)
+		if err != nil {
+			return "", err
+		}
+		if err := u.Install(ctx, stagedPath, manifest.Version); err != nil {
+			return "", err
+		}
+		u.pending = manifest.Version
+		logger.Info("agent update installed", zap.String("version", manifest.Version))
+	}
+	version := u.pending
+
+	// nkk: Pause first, an execution starting between the idle check and the restart would be killed
+	resume := func() {}
+	if u.opts.Executions != nil {
+		resume = u.opts.Executions.PauseExecutions()
+	}
+	if err := u.WaitForIdle(ctx); err != nil {
+		resume()
+		return version, fmt.Errorf("update installed but restart postponed: %w", err)
+	}
+
+	markerPath := u.opts.BinaryPath + markerSuffix
+	if err := writeMarker(markerPath, &updateMarker{Version: version}); err != nil {
+		resume()
+		return version, fmt.Errorf("update installed but restart postponed: %w", err)
+	}
+	if err := u.opts.Restart(u.opts.BinaryPath); err != nil {
+		os.Remove(markerPath)
+		resume()
+		return version, fmt.Errorf("restarting agent: %w", err)
+	}
+	return version, nil
+}
+
+// Run checks for updates every interval until ctx is done
+func (u *Updater) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		version, err := u.Update(ctx)
+		switch {
+		case errors.Is(err, ErrUpToDate):
+			logger.Debug("agent is up to date", zap.String("version", version))
+		case err != nil:
+			logger.Error("agent update failed", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runVersionCheck expects `<binary> version` to succeed and report the new version
+func runVersionCheck(ctx context.Context, binaryPath, version string) error {
+	output, err := exec.CommandContext(ctx, binaryPath, "version").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	if !strings.Contains(string(output), strings.TrimPrefix(version, "v")) {
+		return fmt.Errorf("binary reports %q, expected version %s", strings.TrimSpace(string(output)), version)
+	}
+	return nil
+}
+
+// replaceFile moves src to dst, first renaming the current dst to aside.
+// nkk: Renaming never writes over dst, which Windows refuses while dst is the running executable
+func replaceFile(src, dst, aside string) error {
+	if err := os.Remove(aside); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(dst, aside); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err != nil {
+		if restoreErr := os.Rename(aside, dst); restoreErr != nil {
+			return fmt.Errorf("%v, restoring %s failed: %w", err, dst, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// skipped reports whether version failed its health check before
+func (u *Updater) skipped(version string) bool {
+	versions, err := readSkipped(u.opts.BinaryPath + skipSuffix)
+	if err != nil {
+		logger.Warn("could not read skipped agent releases", zap.Error(err))
+	}
+	for _, skipped := range versions {
+		if skipped == version {
+			return true
+		}
+	}
+	return false
+}
+
+// skipRelease keeps Check from offering version again
+func (u *Updater) skipRelease(version string) {
+	path := u.opts.BinaryPath + skipSuffix
+	versions, _ := readSkipped(path)
+	for _, skipped := range versions {
+		if skipped == version {
+			return
+		}
+	}
+	data, err := json.Marshal(append(versions, version))
+	if err == nil {
+		err = os.WriteFile(path+".tmp", data, 0644)
+	}
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		logger.Warn("could not record skipped agent release", zap.String("version", version), zap.Error(err))
+	}
+}
+
+// readSkipped returns the releases listed at path, none when there is no list
+func readSkipped(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	if err := json.Unmarshal(data, &versions); err != nil {
+		return nil, fmt.Errorf("decoding skipped releases %s: %w", path, err)
+	}
+	return versions, nil
+}
+
+// readMarker returns the update marker at path, nil when there is none
+func readMarker(path string) (*updateMarker, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var marker updateMarker
+	if err := json.Unmarshal(data, &marker); err != nil {
+		return nil, fmt.Errorf("decoding update marker %s: %w", path, err)
+	}
+	return &marker, nil
+}
+
+// writeMarker replaces the update marker at path
+func writeMarker(path string, marker *updateMarker) error {
+	data, err := json.Marshal(marker)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// compareVersions orders release versions numerically, "dev" builds are always older
+func compareVersions(a, b string) int {
+	parse := func(v string) [3]int {
+		var parts [3]int
+		fmt.Sscanf(strings.TrimPrefix(v, "v"), "%d.%d.%d", &parts[0], &parts[1], &parts[2])
+		return parts
+	}
+
+	aParts, bParts := parse(a), parse(b)
+	for i := range aParts {
+		if diff := aParts[i] - bParts[i]; diff != 0 {
+			return diff
+		}
+	}
+	return 0
+}
@@ -0,0 +1,415 @@
+package updater
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+nkk: Unit tests for the self-updater
+Releases are published into a temp dir, served directly or through httptest.
+*/
+
+type fakeExecutions struct {
+	running atomic.Int64
+	paused  atomic.Bool
+}
+
+func (f *fakeExecutions) RunningExecutions() int { return int(f.running.Load()) }
+
+func (f *fakeExecutions) PauseExecutions() func() {
+	f.paused.Store(true)
+	return func() { f.paused.Store(false) }
+}
+
+type release struct {
+	dir        string
+	publicKey  ed25519.PublicKey
+	privateKey ed25519.PrivateKey
+}
+
+func newRelease(t *testing.T) *release {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return &release{dir: t.TempDir(), publicKey: public, privateKey: private}
+}
+
+// publish writes a binary and a manifest; tamper corrupts the binary after signing
+func (r *release) publish(t *testing.T, version string, binary []byte, tamper bool) {
+	t.Helper()
+	name := "agent-" + version
+	digest := sha256.Sum256(binary)
+
+	manifest := Manifest{
+		Version:     version,
+		PublishedAt: time.Now().UTC(),
+		Assets: []Asset{{
+			OS:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+			Name:      name,
+			SHA256:    hex.EncodeToString(digest[:]),
+			Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(r.privateKey, digest[:])),
+		}},
+	}
+	data, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(r.dir, manifestName), data, 0644))
+
+	if tamper {
+		binary = append(binary, []byte("tampered")...)
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(r.dir, name), binary, 0755))
+}
+
+func newTestUpdater(t *testing.T, source Source, publicKey ed25519.PublicKey, executions ExecutionTracker, healthy bool) (*Updater, string, *int32) {
+	t.Helper()
+	binaryPath := filepath.Join(t.TempDir(), "agent")
+	require.NoError(t, os.WriteFile(binaryPath, []byte("old binary"), 0755))
+
+	var restarts int32
+	u, err := NewUpdater(Options{
+		Source:         source,
+		PublicKey:      publicKey,
+		CurrentVersion: "1.0.0",
+		BinaryPath:     binaryPath,
+		Executions:     executions,
+		StartupTimeout: time.Minute,
+		HealthCheck: func(ctx context.Context, path, version string) error {
+			if !healthy {
+				return errors.New("exit status 1")
+			}
+			return nil
+		},
+		Restart: func(path string) error {
+			atomic.AddInt32(&restarts, 1)
+			return nil
+		},
+		IdlePollInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	return u, binaryPath, &restarts
+}
+
+func TestUpdateFromHTTPSource(t *testing.T) {
+	rel := newRelease(t)
+	rel.publish(t, "1.1.0", []byte("new binary"), false)
+
+	server := httptest.NewServer(http.FileServer(http.Dir(rel.dir)))
+	defer server.Close()
+
+	source, err := NewSource(server.URL, server.Client())
+	require.NoError(t, err)
+	u, binaryPath, restarts := newTestUpdater(t, source, rel.publicKey, nil, true)
+
+	version, err := u.Update(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.0", version)
+	assert.Equal(t, int32(1), atomic.LoadInt32(restarts))
+
+	data, err := os.ReadFile(binaryPath)
+	require.NoError(t, err)
+	assert.Equal(t, "new binary", string(data))
+
+	backup, err := os.ReadFile(binaryPath + backupSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, "old binary", string(backup))
+}
+
+func TestUpdateFromDirectoryUpToDate(t *testing.T) {
+	rel := newRelease(t)
+	rel.publish(t, "1.0.0", []byte("same binary"), false)
+
+	source, err := NewSource(rel.dir, nil)
+	require.NoError(t, err)
+	u, _, restarts := newTestUpdater(t, source, rel.publicKey, nil, true)
+
+	_, err = u.Update(context.Background())
+	assert.ErrorIs(t, err, ErrUpToDate)
+	assert.Equal(t, int32(0), atomic.LoadInt32(restarts))
+}
+
+func TestUpdateRejectsChecksumMismatch(t *testing.T) {
+	rel := newRelease(t)
+	rel.publish(t, "1.1.0", []byte("new binary"), true)
+
+	source, err := NewSource(rel.dir, nil)
+	require.NoError(t, err)
+	u, binaryPath, restarts := newTestUpdater(t, source, rel.publicKey, nil, true)
+
+	_, err = u.Update(context.Background())
+	assert.ErrorContains(t, err, "checksum mismatch")
+	assert.Equal(t, int32(0), atomic.LoadInt32(restarts))
+
+	data, err := os.ReadFile(binaryPath)
+	require.NoError(t, err)
+	assert.Equal(t, "old binary", string(data))
+}
+
+func TestUpdateRejectsForeignSignature(t *testing.T) {
+	rel := newRelease(t)
+	rel.publish(t, "1.1.0", []byte("new binary"), false)
+
+	otherKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	source, err := NewSource(rel.dir, nil)
+	require.NoError(t, err)
+	u, binaryPath, _ := newTestUpdater(t, source, otherKey, nil, true)
+
+	_, err = u.Update(context.Background())
+	assert.ErrorContains(t, err, "signature verification failed")
+
+	data, err := os.ReadFile(binaryPath)
+	require.NoError(t, err)
+	assert.Equal(t, "old binary", string(data))
+}
+
+func TestUpdateRollsBackOnFailedHealthCheck(t *testing.T) {
+	rel := newRelease(t)
+	rel.publish(t, "1.1.0", []byte("broken binary"), false)
+
+	source, err := NewSource(rel.dir, nil)
+	require.NoError(t, err)
+	u, binaryPath, restarts := newTestUpdater(t, source, rel.publicKey, nil, false)
+
+	_, err = u.Update(context.Background())
+	assert.ErrorContains(t, err, "health check failed")
+	assert.Equal(t, int32(0), atomic.LoadInt32(restarts))
+
+	data, err := os.ReadFile(binaryPath)
+	require.NoError(t, err)
+	assert.Equal(t, "old binary", string(data))
+}
+
+func TestUpdateWaitsForRunningExecutions(t *testing.T) {
+	rel := newRelease(t)
+	rel.publish(t, "1.1.0", []byte("new binary"), false)
+
+	executions := &fakeExecutions{}
+	executions.running.Store(1)
+
+	source, err := NewSource(rel.dir, nil)
+	require.NoError(t, err)
+	u, _, restarts := newTestUpdater(t, source, rel.publicKey, executions, true)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := u.Update(context.Background())
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(restarts))
+	assert.True(t, executions.paused.Load(), "no execution may start while waiting for the running ones")
+
+	executions.running.Store(0)
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("update did not restart after executions finished")
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(restarts))
+	assert.True(t, executions.paused.Load(), "executions stay paused into the restart")
+}
+
+func TestUpdateResumesExecutionsWhenRestartIsPostponed(t *testing.T) {
+	rel := newRelease(t)
+	rel.publish(t, "1.1.0", []byte("new binary"), false)
+
+	executions := &fakeExecutions{}
+	executions.running.Store(1)
+
+	source, err := NewSource(rel.dir, nil)
+	require.NoError(t, err)
+	u, binaryPath, restarts := newTestUpdater(t, source, rel.publicKey, executions, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = u.Update(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, int32(0), atomic.LoadInt32(restarts))
+	assert.False(t, executions.paused.Load())
+	assert.NoFileExists(t, binaryPath+markerSuffix)
+}
+
+func TestUpdateRestartsPostponedInstallWithoutReinstalling(t *testing.T) {
+	rel := newRelease(t)
+	rel.publish(t, "1.1.0", []byte("new binary"), false)
+
+	executions := &fakeExecutions{}
+	executions.running.Store(1)
+
+	source, err := NewSource(rel.dir, nil)
+	require.NoError(t, err)
+	u, binaryPath, restarts := newTestUpdater(t, source, rel.publicKey, executions, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = u.Update(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// A newer release is published while 1.1.0 waits for its restart
+	rel.publish(t, "1.2.0", []byte("newer binary"), false)
+	executions.running.Store(0)
+	version, err := u.Update(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "1.1.0", version)
+	assert.Equal(t, int32(1), atomic.LoadInt32(restarts))
+
+	data, err := os.ReadFile(binaryPath)
+	require.NoError(t, err)
+	assert.Equal(t, "new binary", string(data))
+	backup, err := os.ReadFile(binaryPath + backupSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, "old binary", string(backup), "the backup still holds the binary that was replaced")
+
+	err = u.Install(context.Background(), filepath.Join(t.TempDir(), "staged"), "1.2.0")
+	assert.ErrorContains(t, err, "waiting for a restart")
+}
+
+func TestUpdateSkipsReleaseThatFailedHealthCheck(t *testing.T) {
+	rel := newRelease(t)
+	rel.publish(t, "1.1.0", []byte("broken binary"), false)
+
+	source, err := NewSource(rel.dir, nil)
+	require.NoError(t, err)
+	u, binaryPath, restarts := newTestUpdater(t, source, rel.publicKey, nil, false)
+
+	_, err = u.Update(context.Background())
+	require.ErrorContains(t, err, "health check failed")
+	for i := 0; i < 2; i++ {
+		_, err = u.Update(context.Background())
+		assert.ErrorIs(t, err, ErrUpToDate, "the failed release is not downloaded again")
+	}
+	assert.Equal(t, int32(0), atomic.LoadInt32(restarts))
+
+	entries, err := os.ReadDir(filepath.Dir(binaryPath))
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), stagedPrefix, "no staged download is left behind")
+	}
+
+	// A fixed release is offered again
+	rel.publish(t, "1.1.1", []byte("fixed binary"), false)
+	_, asset, err := u.Check(context.Background())
+	require.NoError(t, err)
+	assert.NotNil(t, asset)
+}
+
+// restartedUpdater is the updater of the process restarted into binaryPath
+func restartedUpdater(t *testing.T, binaryPath string, startupTimeout time.Duration, restarts *int32) *Updater {
+	t.Helper()
+	source, err := NewSource(t.TempDir(), nil)
+	require.NoError(t, err)
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	u, err := NewUpdater(Options{
+		Source:         source,
+		PublicKey:      public,
+		CurrentVersion: "1.1.0",
+		BinaryPath:     binaryPath,
+		StartupTimeout: startupTimeout,
+		Restart: func(path string) error {
+			atomic.AddInt32(restarts, 1)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	return u
+}
+
+func updateAndRestart(t *testing.T) string {
+	t.Helper()
+	rel := newRelease(t)
+	rel.publish(t, "1.1.0", []byte("new binary"), false)
+
+	source, err := NewSource(rel.dir, nil)
+	require.NoError(t, err)
+	u, binaryPath, _ := newTestUpdater(t, source, rel.publicKey, nil, true)
+	_, err = u.Update(context.Background())
+	require.NoError(t, err)
+	require.FileExists(t, binaryPath+markerSuffix)
+	return binaryPath
+}
+
+func TestResumeUpdateConfirmsHealthyStart(t *testing.T) {
+	binaryPath := updateAndRestart(t)
+
+	var restarts int32
+	u := restartedUpdater(t, binaryPath, time.Minute, &restarts)
+	confirm, err := u.ResumeUpdate(context.Background())
+	require.NoError(t, err)
+	confirm()
+	assert.NoFileExists(t, binaryPath+markerSuffix)
+
+	confirm, err = u.ResumeUpdate(context.Background())
+	require.NoError(t, err)
+	assert.NotPanics(t, confirm, "nothing to confirm without a marker")
+
+	data, err := os.ReadFile(binaryPath)
+	require.NoError(t, err)
+	assert.Equal(t, "new binary", string(data))
+}
+
+func TestResumeUpdateRollsBackUnconfirmedStart(t *testing.T) {
+	binaryPath := updateAndRestart(t)
+
+	var restarts int32
+	u := restartedUpdater(t, binaryPath, time.Minute, &restarts)
+	_, err := u.ResumeUpdate(context.Background())
+	require.NoError(t, err)
+
+	// The first start died before confirming
+	u = restartedUpdater(t, binaryPath, time.Minute, &restarts)
+	_, err = u.ResumeUpdate(context.Background())
+	assert.ErrorIs(t, err, ErrRolledBack)
+	assert.NoFileExists(t, binaryPath+markerSuffix)
+	assert.True(t, u.skipped("1.1.0"), "the previous binary does not install the release again")
+
+	data, err := os.ReadFile(binaryPath)
+	require.NoError(t, err)
+	assert.Equal(t, "old binary", string(data))
+}
+
+func TestResumeUpdateRollsBackWhenNotConfirmedInTime(t *testing.T) {
+	binaryPath := updateAndRestart(t)
+
+	var restarts int32
+	u := restartedUpdater(t, binaryPath, 20*time.Millisecond, &restarts)
+	_, err := u.ResumeUpdate(context.Background())
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&restarts) == 1 }, 2*time.Second, 10*time.Millisecond)
+	data, err := os.ReadFile(binaryPath)
+	require.NoError(t, err)
+	assert.Equal(t, "old binary", string(data))
+	assert.NoFileExists(t, binaryPath+markerSuffix)
+}
+
+func TestDirSourceRejectsEscapingNames(t *testing.T) {
+	source, err := NewSource(t.TempDir(), nil)
+	require.NoError(t, err)
+
+	_, err = source.Open(context.Background(), "../manifest.json")
+	assert.Error(t, err)
+}
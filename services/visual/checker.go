@@ -0,0 +1,319 @@
+package visual
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"agent/logger"
+	"agent/services/monitoring"
+	"agent/utils/helpers"
+)
+
+/*
+nkk: Visual regression checks against approved baselines
+Baselines live under <dir>/<org>/<project>/<app>/<testcase>/<browser>/<resolution>/ as
+  <name>.png         the approved baseline
+  <name>.actual.png  the latest screenshot, waiting for approval
+  <name>.diff.png    the diff of the latest screenshot against the baseline
+Approve promotes the latest screenshot to baseline, Reject drops it and keeps the baseline.
+A checkpoint without a baseline records the screenshot as its baseline when AutoApproveNew is set,
+otherwise it waits for approval and the check passes as new.
+*/
+
+// Check statuses
+const (
+	StatusPassed = "passed"
+	StatusFailed = "failed"
+	StatusNew    = "new" // nkk: no baseline yet
+)
+
+// Image kinds of a checkpoint
+const (
+	ImageBaseline = "baseline"
+	ImageActual   = "actual"
+	ImageDiff     = "diff"
+)
+
+var (
+	// ErrInvalid is wrapped by errors about the request, such as a bad checkpoint or a screenshot that is no PNG
+	ErrInvalid = errors.New("invalid visual check")
+	// ErrNotFound is returned for a checkpoint without the requested image
+	ErrNotFound = errors.New("visual checkpoint not found")
+	// ErrNoCandidate is returned when approving or rejecting a checkpoint without a pending screenshot
+	ErrNoCandidate = errors.New("no screenshot waiting for approval")
+)
+
+var segmentPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// Checkpoint identifies a baseline, one per testcase, browser, resolution and name
+type Checkpoint struct {
+	OrgId      string `json:"org_id"`
+	ProjectId  string `json:"project_id"`
+	AppId      string `json:"app_id"`
+	TestcaseId string `json:"testcase_id"`
+	Browser    string `json:"browser"`
+	Resolution string `json:"resolution"` // nkk: <width>x<height> of the viewport
+	Name       string `json:"name"`
+}
+
+// Validate makes sure every part is a plain path segment
+func (c Checkpoint) Validate() error {
+	for field, value := range map[string]string{
+		"org_id":      c.OrgId,
+		"project_id":  c.ProjectId,
+		"app_id":      c.AppId,
+		"testcase_id": c.TestcaseId,
+		"browser":     c.Browser,
+		"resolution":  c.Resolution,
+		"name":        c.Name,
+	} {
+		if !segmentPattern.MatchString(value) || strings.Trim(value, ".") == "" {
+			return fmt.Errorf("%s %q must only contain letters, digits, '.', '_' and '-'", field, value)
+		}
+	}
+	return nil
+}
+
+func (c Checkpoint) dir(root string) string {
+	return filepath.Join(root, c.OrgId, c.ProjectId, c.AppId, c.TestcaseId, c.Browser, c.Resolution)
+}
+
+// Resolution formats a viewport size as a checkpoint resolution
+func Resolution(width, height int) string {
+	return fmt.Sprintf("%dx%d", width, height)
+}
+
+// Options configure a Checker
+type Options struct {
+	Dir            string
+	Diff           DiffOptions // nkk: defaults, a check can override them
+	AutoApproveNew bool
+}
+
+// CheckRequest is a screenshot to compare against its baseline
+type CheckRequest struct {
+	Checkpoint
+	ExecutionId   string   `json:"execution_id"`
+	Screenshot    string   `json:"screenshot"` // nkk: base64 PNG, a data URL prefix is fine
+	Mode          string   `json:"mode,omitempty"`
+	Threshold     *float64 `json:"threshold,omitempty"`
+	MaxDiffRatio  *float64 `json:"max_diff_ratio,omitempty"`
+	IgnoreRegions []Region `json:"ignore_regions,omitempty"`
+}
+
+// CheckResult is the outcome of a check
+type CheckResult struct {
+	Checkpoint
+	DiffResult
+	ExecutionId string    `json:"execution_id"`
+	Status      string    `json:"status"`
+	CheckedAt   time.Time `json:"checked_at"`
+}
+
+// Checker compares screenshots against baselines kept on disk
+type Checker struct {
+	opts Options
+	mu   sync.Mutex // nkk: checks and approvals of a checkpoint touch the same files
+
+	checks *monitoring.Metric
+	fails  *monitoring.Metric
+}
+
+// NewChecker creates a checker keeping baselines under opts.Dir
+func NewChecker(opts Options) (*Checker, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("visual baselines need a directory")
+	}
+	if err := opts.Diff.Validate(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create baseline directory: %w", err)
+	}
+	registry := monitoring.GetRegistry()
+	return &Checker{
+		opts:   opts,
+		checks: registry.Counter("visual_checks_total", "Screenshots compared against their baseline", map[string]string{}),
+		fails:  registry.Counter("visual_check_failures_total", "Screenshots that differed from their baseline above the threshold", map[string]string{}),
+	}, nil
+}
+
+// ImagePath returns the path of an image of a checkpoint
+func (c *Checker) ImagePath(checkpoint Checkpoint, kind string) (string, error) {
+	if err := checkpoint.Validate(); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	base := filepath.Join(checkpoint.dir(c.opts.Dir), checkpoint.Name)
+	switch kind {
+	case ImageBaseline:
+		return base + ".png", nil
+	case ImageActual:
+		return base + ".actual.png", nil
+	case ImageDiff:
+		return base + ".diff.png", nil
+	}
+	return "", fmt.Errorf("%w: image must be %s, %s or %s, got %q", ErrInvalid, ImageBaseline, ImageActual, ImageDiff, kind)
+}
+
+// Image returns the path of an existing image of a checkpoint
+func (c *Checker) Image(checkpoint Checkpoint, kind string) (string, error) {
+	path, err := c.ImagePath(checkpoint, kind)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrNotFound
+	}
+	return path, nil
+}
+
+// Check saves the screenshot of request and compares it against its baseline
+func (c *Checker) Check(request CheckRequest) (CheckResult, error) {
+	if err := request.Checkpoint.Validate(); err != nil {
+		return CheckResult{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	opts := c.opts.Diff
+	if request.Mode != "" {
+		opts.Mode = request.Mode
+	}
+	if request.Threshold != nil {
+		opts.Threshold = *request.Threshold
+	}
+	if request.MaxDiffRatio != nil {
+		opts.MaxDiffRatio = *request.MaxDiffRatio
+	}
+	opts.IgnoreRegions = append(append([]Region(nil), opts.IgnoreRegions...), request.IgnoreRegions...)
+	if err := opts.Validate(); err != nil {
+		return CheckResult{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	baselinePath, _ := c.ImagePath(request.Checkpoint, ImageBaseline)
+	actualPath, _ := c.ImagePath(request.Checkpoint, ImageActual)
+	diffPath, _ := c.ImagePath(request.Checkpoint, ImageDiff)
+	if err := helpers.DecodeBase64ToImageAndSave(request.Screenshot, filepath.Dir(actualPath), filepath.Base(actualPath)); err != nil {
+		return CheckResult{}, fmt.Errorf("%w: screenshot could not be saved: %v", ErrInvalid, err)
+	}
+	actual, err := readPNG(actualPath)
+	if err != nil {
+		os.Remove(actualPath)
+		return CheckResult{}, fmt.Errorf("%w: screenshot is not a PNG: %v", ErrInvalid, err)
+	}
+
+	result := CheckResult{Checkpoint: request.Checkpoint, ExecutionId: request.ExecutionId, CheckedAt: time.Now().UTC()}
+	baseline, err := readPNG(baselinePath)
+	if errors.Is(err, os.ErrNotExist) {
+		os.Remove(diffPath)
+		result.Status = StatusNew
+		result.Passed = true
+		bounds := actual.Bounds()
+		result.TotalPixels = bounds.Dx() * bounds.Dy()
+		if c.opts.AutoApproveNew {
+			if err := os.Rename(actualPath, baselinePath); err != nil {
+				return CheckResult{}, fmt.Errorf("failed to record baseline: %w", err)
+			}
+		}
+		logger.Info("visual baseline missing", zap.String("checkpoint", baselinePath), zap.Bool("recorded", c.opts.AutoApproveNew))
+		return result, nil
+	}
+	if err != nil {
+		return CheckResult{}, fmt.Errorf("failed to read baseline: %w", err)
+	}
+
+	result.DiffResult, err = c.writeDiff(baseline, actual, opts, diffPath)
+	if err != nil {
+		return CheckResult{}, err
+	}
+	c.checks.Inc()
+	result.Status = StatusPassed
+	if !result.Passed {
+		result.Status = StatusFailed
+		c.fails.Inc()
+		logger.Warn("screenshot differs from baseline",
+			zap.String("execution_id", request.ExecutionId),
+			zap.String("checkpoint", baselinePath),
+			zap.Float64("diff_ratio", result.DiffRatio),
+			zap.Float64("max_diff_ratio", opts.MaxDiffRatio))
+		return result, nil
+	}
+	// nkk: A matching screenshot leaves nothing to approve
+	os.Remove(actualPath)
+	return result, nil
+}
+
+func (c *Checker) writeDiff(baseline, actual image.Image, opts DiffOptions, diffPath string) (DiffResult, error) {
+	result, diff := Diff(baseline, actual, opts)
+	file, err := os.Create(diffPath)
+	if err != nil {
+		return DiffResult{}, fmt.Errorf("failed to create diff image: %w", err)
+	}
+	defer file.Close()
+	if err := png.Encode(file, diff); err != nil {
+		return DiffResult{}, fmt.Errorf("failed to write diff image: %w", err)
+	}
+	return result, nil
+}
+
+// Approve makes the latest screenshot of checkpoint its baseline
+func (c *Checker) Approve(checkpoint Checkpoint) error {
+	actualPath, err := c.ImagePath(checkpoint, ImageActual)
+	if err != nil {
+		return err
+	}
+	baselinePath, _ := c.ImagePath(checkpoint, ImageBaseline)
+	diffPath, _ := c.ImagePath(checkpoint, ImageDiff)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.Rename(actualPath, baselinePath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNoCandidate
+		}
+		return fmt.Errorf("failed to approve screenshot: %w", err)
+	}
+	os.Remove(diffPath)
+	logger.Info("visual baseline approved", zap.String("checkpoint", baselinePath))
+	return nil
+}
+
+// Reject drops the latest screenshot of checkpoint, the baseline stays
+func (c *Checker) Reject(checkpoint Checkpoint) error {
+	actualPath, err := c.ImagePath(checkpoint, ImageActual)
+	if err != nil {
+		return err
+	}
+	diffPath, _ := c.ImagePath(checkpoint, ImageDiff)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := os.Remove(actualPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNoCandidate
+		}
+		return fmt.Errorf("failed to reject screenshot: %w", err)
+	}
+	os.Remove(diffPath)
+	logger.Info("visual screenshot rejected", zap.String("checkpoint", actualPath))
+	return nil
+}
+
+func readPNG(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return png.Decode(file)
+}
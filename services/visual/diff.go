@@ -0,0 +1,156 @@
+package visual
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Comparison modes
+const (
+	ModePixel      = "pixel"      // nkk: largest RGB channel difference
+	ModePerceptual = "perceptual" // nkk: YIQ color distance, as pixelmatch, lighter on antialiasing and color noise
+)
+
+// maxYIQDelta is the YIQ distance between black and white
+const maxYIQDelta = 35215.0
+
+// Region is a rectangle in screenshot pixels
+type Region struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func (r Region) rect() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)
+}
+
+// DiffOptions configure Diff
+type DiffOptions struct {
+	Mode          string
+	Threshold     float64  // nkk: 0-1, how different a pixel may be and still match
+	MaxDiffRatio  float64  // nkk: 0-1, share of differing pixels above which the comparison fails
+	IgnoreRegions []Region // nkk: dynamic content such as clocks or ads
+}
+
+// Validate checks the options before a comparison
+func (o DiffOptions) Validate() error {
+	if o.Mode != ModePixel && o.Mode != ModePerceptual {
+		return fmt.Errorf("mode must be %s or %s, got %q", ModePixel, ModePerceptual, o.Mode)
+	}
+	if o.Threshold < 0 || o.Threshold > 1 {
+		return fmt.Errorf("threshold must be between 0 and 1")
+	}
+	if o.MaxDiffRatio < 0 || o.MaxDiffRatio > 1 {
+		return fmt.Errorf("max_diff_ratio must be between 0 and 1")
+	}
+	for _, region := range o.IgnoreRegions {
+		if region.Width <= 0 || region.Height <= 0 {
+			return fmt.Errorf("ignore region %+v must have a positive size", region)
+		}
+	}
+	return nil
+}
+
+// DiffResult is the outcome of a comparison
+type DiffResult struct {
+	DiffPixels   int     `json:"diff_pixels"`
+	TotalPixels  int     `json:"total_pixels"`
+	DiffRatio    float64 `json:"diff_ratio"`
+	SizeMismatch bool    `json:"size_mismatch,omitempty"`
+	Passed       bool    `json:"passed"`
+}
+
+var (
+	diffColor    = color.RGBA{R: 255, A: 255}
+	ignoredColor = color.RGBA{R: 255, G: 220, B: 100, A: 255}
+)
+
+// Diff compares actual against baseline. The diff image shows the baseline faded to gray,
+// differing pixels in red and ignored regions in yellow. Pixels only one of the images has
+// always differ, so a resized page fails unless the size change is inside an ignored region.
+func Diff(baseline, actual image.Image, opts DiffOptions) (DiffResult, *image.RGBA) {
+	bb, ab := baseline.Bounds(), actual.Bounds()
+	width, height := max(bb.Dx(), ab.Dx()), max(bb.Dy(), ab.Dy())
+	diff := image.NewRGBA(image.Rect(0, 0, width, height))
+	result := DiffResult{SizeMismatch: bb.Dx() != ab.Dx() || bb.Dy() != ab.Dy()}
+
+	ignored := make([]image.Rectangle, len(opts.IgnoreRegions))
+	for i, region := range opts.IgnoreRegions {
+		ignored[i] = region.rect()
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if inRegions(ignored, x, y) {
+				diff.SetRGBA(x, y, ignoredColor)
+				continue
+			}
+			result.TotalPixels++
+			inBaseline := x < bb.Dx() && y < bb.Dy()
+			inActual := x < ab.Dx() && y < ab.Dy()
+			if !inBaseline || !inActual {
+				result.DiffPixels++
+				diff.SetRGBA(x, y, diffColor)
+				continue
+			}
+			expected := baseline.At(bb.Min.X+x, bb.Min.Y+y)
+			if pixelsDiffer(expected, actual.At(ab.Min.X+x, ab.Min.Y+y), opts) {
+				result.DiffPixels++
+				diff.SetRGBA(x, y, diffColor)
+				continue
+			}
+			diff.SetRGBA(x, y, faded(expected))
+		}
+	}
+	if result.TotalPixels > 0 {
+		result.DiffRatio = float64(result.DiffPixels) / float64(result.TotalPixels)
+	}
+	result.Passed = result.DiffRatio <= opts.MaxDiffRatio
+	return result, diff
+}
+
+func inRegions(regions []image.Rectangle, x, y int) bool {
+	for _, region := range regions {
+		if (image.Point{X: x, Y: y}).In(region) {
+			return true
+		}
+	}
+	return false
+}
+
+func pixelsDiffer(a, b color.Color, opts DiffOptions) bool {
+	r1, g1, b1 := blend(a)
+	r2, g2, b2 := blend(b)
+	if opts.Mode == ModePerceptual {
+		y := rgb2y(r1, g1, b1) - rgb2y(r2, g2, b2)
+		i := rgb2i(r1, g1, b1) - rgb2i(r2, g2, b2)
+		q := rgb2q(r1, g1, b1) - rgb2q(r2, g2, b2)
+		delta := 0.5053*y*y + 0.299*i*i + 0.1957*q*q
+		return delta > maxYIQDelta*opts.Threshold*opts.Threshold
+	}
+	channel := math.Max(math.Abs(r1-r2), math.Max(math.Abs(g1-g2), math.Abs(b1-b2)))
+	return channel/255 > opts.Threshold
+}
+
+// blend puts a pixel on white, transparent areas compare as the page background
+func blend(c color.Color) (float64, float64, float64) {
+	r, g, b, a := c.RGBA()
+	alpha := float64(a) / 0xffff
+	white := 255 * (1 - alpha)
+	// nkk: RGBA is alpha-premultiplied already
+	return float64(r)/257 + white, float64(g)/257 + white, float64(b)/257 + white
+}
+
+func rgb2y(r, g, b float64) float64 { return r*0.29889531 + g*0.58662247 + b*0.11448223 }
+func rgb2i(r, g, b float64) float64 { return r*0.59597799 - g*0.27417610 - b*0.32180189 }
+func rgb2q(r, g, b float64) float64 { return r*0.21147017 - g*0.52261711 + b*0.31114694 }
+
+func faded(c color.Color) color.RGBA {
+	r, g, b := blend(c)
+	gray := uint8(255 - (255-rgb2y(r, g, b))*0.1)
+	return color.RGBA{R: gray, G: gray, B: gray, A: 255}
+}
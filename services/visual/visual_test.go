@@ -0,0 +1,125 @@
+package visual
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+nkk: Unit tests for screenshot diffs and visual baselines
+*/
+
+func solid(width, height int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func encode(t *testing.T, img image.Image) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestDiff(t *testing.T) {
+	white := color.RGBA{255, 255, 255, 255}
+	baseline := solid(10, 10, white)
+	actual := solid(10, 10, white)
+	actual.Set(1, 1, color.RGBA{0, 0, 0, 255})
+	actual.Set(8, 8, color.RGBA{250, 250, 250, 255}) // nkk: below any sensible threshold
+
+	result, diff := Diff(baseline, actual, DiffOptions{Mode: ModePixel, Threshold: 0.1, MaxDiffRatio: 0})
+	assert.Equal(t, DiffResult{DiffPixels: 1, TotalPixels: 100, DiffRatio: 0.01}, result)
+	assert.Equal(t, diffColor, diff.RGBAAt(1, 1))
+	assert.NotEqual(t, diffColor, diff.RGBAAt(8, 8))
+
+	result, _ = Diff(baseline, actual, DiffOptions{Mode: ModePixel, Threshold: 0.1, MaxDiffRatio: 0.01})
+	assert.True(t, result.Passed, "the ratio may reach the maximum")
+	result, _ = Diff(baseline, actual, DiffOptions{Mode: ModePerceptual, Threshold: 0, MaxDiffRatio: 0})
+	assert.Equal(t, 2, result.DiffPixels, "a zero threshold catches every change")
+
+	result, diff = Diff(baseline, actual, DiffOptions{Mode: ModePixel, MaxDiffRatio: 0, IgnoreRegions: []Region{{X: 0, Y: 0, Width: 2, Height: 2}, {X: 8, Y: 8, Width: 1, Height: 1}}})
+	assert.True(t, result.Passed, "ignored regions are not compared")
+	assert.Equal(t, 95, result.TotalPixels)
+	assert.Equal(t, ignoredColor, diff.RGBAAt(1, 1))
+
+	result, diff = Diff(baseline, solid(10, 12, white), DiffOptions{Mode: ModePixel, MaxDiffRatio: 0.1})
+	assert.True(t, result.SizeMismatch)
+	assert.Equal(t, 20, result.DiffPixels, "pixels only one image has differ")
+	assert.False(t, result.Passed)
+	assert.Equal(t, image.Rect(0, 0, 10, 12), diff.Bounds())
+
+	transparent := solid(10, 10, color.RGBA{})
+	result, _ = Diff(baseline, transparent, DiffOptions{Mode: ModePixel})
+	assert.True(t, result.Passed, "transparent pixels compare as white")
+}
+
+func TestCheckerApprovesAndRejects(t *testing.T) {
+	dir := t.TempDir()
+	checker, err := NewChecker(Options{Dir: dir, Diff: DiffOptions{Mode: ModePixel, Threshold: 0.1}, AutoApproveNew: true})
+	require.NoError(t, err)
+	checkpoint := Checkpoint{OrgId: "o", ProjectId: "p", AppId: "a", TestcaseId: "tc-1", Browser: "chromium", Resolution: Resolution(10, 10), Name: "home"}
+	white := solid(10, 10, color.RGBA{255, 255, 255, 255})
+	changed := solid(10, 10, color.RGBA{255, 255, 255, 255})
+	changed.Set(5, 5, color.RGBA{255, 0, 0, 255})
+
+	result, err := checker.Check(CheckRequest{Checkpoint: checkpoint, ExecutionId: "exec-1", Screenshot: encode(t, white)})
+	require.NoError(t, err)
+	assert.Equal(t, StatusNew, result.Status)
+	assert.True(t, result.Passed)
+	_, err = checker.Image(checkpoint, ImageBaseline)
+	require.NoError(t, err, "the first screenshot becomes the baseline")
+
+	result, err = checker.Check(CheckRequest{Checkpoint: checkpoint, ExecutionId: "exec-2", Screenshot: encode(t, changed)})
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, result.Status)
+	assert.Equal(t, 1, result.DiffPixels)
+	diffPath, err := checker.Image(checkpoint, ImageDiff)
+	require.NoError(t, err)
+	file, err := os.Open(diffPath)
+	require.NoError(t, err)
+	diff, err := png.Decode(file)
+	file.Close()
+	require.NoError(t, err)
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, color.RGBAModel.Convert(diff.At(5, 5)))
+
+	require.NoError(t, checker.Reject(checkpoint))
+	assert.ErrorIs(t, checker.Reject(checkpoint), ErrNoCandidate)
+	_, err = checker.Image(checkpoint, ImageDiff)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	threshold := 1.0
+	result, err = checker.Check(CheckRequest{Checkpoint: checkpoint, Screenshot: encode(t, changed), Threshold: &threshold})
+	require.NoError(t, err)
+	assert.Equal(t, StatusPassed, result.Status, "a check can loosen the threshold")
+	assert.ErrorIs(t, checker.Approve(checkpoint), ErrNoCandidate, "a passing screenshot leaves nothing to approve")
+
+	_, err = checker.Check(CheckRequest{Checkpoint: checkpoint, Screenshot: encode(t, changed)})
+	require.NoError(t, err)
+	require.NoError(t, checker.Approve(checkpoint))
+	result, err = checker.Check(CheckRequest{Checkpoint: checkpoint, Screenshot: encode(t, changed)})
+	require.NoError(t, err)
+	assert.Equal(t, StatusPassed, result.Status, "the approved screenshot is the new baseline")
+
+	invalid := checkpoint
+	invalid.TestcaseId = "../tc-2"
+	_, err = checker.Check(CheckRequest{Checkpoint: invalid, Screenshot: encode(t, white)})
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = checker.Check(CheckRequest{Checkpoint: checkpoint, Screenshot: base64.StdEncoding.EncodeToString([]byte("not a png"))})
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = checker.Check(CheckRequest{Checkpoint: checkpoint, Screenshot: encode(t, white), Mode: "fuzzy"})
+	assert.ErrorIs(t, err, ErrInvalid)
+}
@@ -6,6 +6,7 @@ const (
 	LogsFileName      = "0-trace.network"
 	TestResultsDir    = "./executions/test-results/"
 	VideoFileName     = "video.webm"
+	HARFileName       = "network.har"
 )
 
 // run results and edc result-type
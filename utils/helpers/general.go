@@ -113,6 +113,47 @@ func ExtractZipFile(zipFilePath string, destDir string) error {
 	return nil
 }
 
+// ZipDirectory writes the files under dir into a zip at zipFilePath, the zip only appears once complete
+func ZipDirectory(dir string, zipFilePath string) error {
+	tmpPath := zipFilePath + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+	archive := zip.NewWriter(out)
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		w, err := archive.Create(filepath.ToSlash(name))
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(w, file)
+		return err
+	})
+	if err == nil {
+		err = archive.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, zipFilePath)
+}
+
 func extractFile(file *zip.File, filePath string) error {
 	srcFile, err := file.Open()
 	if err != nil {
@@ -262,7 +262,13 @@ func (r *Retrier) isRetryableError(err error) bool {
 func (r *Retrier) GetMetrics() RetryMetrics {
 	r.metrics.mutex.RLock()
 	defer r.metrics.mutex.RUnlock()
-	return *r.metrics
+	return RetryMetrics{
+		TotalAttempts:   r.metrics.TotalAttempts,
+		TotalSuccesses:  r.metrics.TotalSuccesses,
+		TotalFailures:   r.metrics.TotalFailures,
+		TotalRetries:    r.metrics.TotalRetries,
+		AverageAttempts: r.metrics.AverageAttempts,
+	}
 }
 
 // ResetMetrics resets retry metrics